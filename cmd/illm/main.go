@@ -15,6 +15,7 @@ import (
 	"github.com/Vaayne/aienvoy/pkg/llm"
 	"github.com/Vaayne/aienvoy/pkg/llm/client"
 	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/Vaayne/aienvoy/pkg/llm/githubcopilot"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -22,6 +23,7 @@ import (
 const (
 	appName               = "illm"
 	defaultConfigFileName = "config.yaml"
+	copilotTokenFileName  = "github_copilot.json"
 )
 
 var rootCmd = &cobra.Command{
@@ -42,6 +44,7 @@ var rootCmd = &cobra.Command{
 		}
 
 		loadConfig(viper.GetString("config"))
+		githubcopilot.DefaultTokenStore = githubcopilot.NewFileTokenStore(filepath.Join(configDir(), copilotTokenFileName))
 
 		prompt := args[0]
 		model := viper.GetString("model")
@@ -67,6 +70,41 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "login to llm providers",
+}
+
+var loginCopilotCmd = &cobra.Command{
+	Use:   "copilot",
+	Short: "login to github copilot by the device flow",
+	Run: func(cmd *cobra.Command, args []string) {
+		initLog(slog.LevelInfo)
+		account, _ := cmd.Flags().GetString("account")
+		ctx := context.Background()
+
+		flow := githubcopilot.NewDeviceFlow()
+		code, err := flow.Start(ctx)
+		if err != nil {
+			slog.Error("start github login error", "err", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Open %s and enter the code %s\n", code.VerificationUri, code.UserCode)
+
+		token, err := flow.Wait(ctx, code)
+		if err != nil {
+			slog.Error("github login error", "err", err)
+			os.Exit(1)
+		}
+		store := githubcopilot.NewFileTokenStore(filepath.Join(configDir(), copilotTokenFileName))
+		if err := store.SaveToken(ctx, account, token); err != nil {
+			slog.Error("save github token error", "err", err)
+			os.Exit(1)
+		}
+		fmt.Println("Login success")
+	},
+}
+
 func setFlags() {
 	bindFlag := func(flag string) {
 		if err := viper.BindPFlag(flag, rootCmd.Flags().Lookup(flag)); err != nil {
@@ -90,6 +128,10 @@ func setFlags() {
 	rootCmd.Flags().StringP("model", "m", "", "model")
 	bindFlag("model")
 	rootCmd.Flags().BoolP("help", "h", false, "help")
+
+	loginCopilotCmd.Flags().StringP("account", "a", llmconfig.DefaultGithubCopilotAccount, "account name, matches github_copilot.account of the config")
+	loginCmd.AddCommand(loginCopilotCmd)
+	rootCmd.AddCommand(loginCmd)
 }
 
 func init() {
@@ -118,20 +160,24 @@ type Config struct {
 
 var globalConfig = &Config{}
 
+// configDir is the xdg config dir of illm, it is created if it doesn't exist
+func configDir() string {
+	usr, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+	dir := filepath.Join(usr.HomeDir, ".config", appName)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		panic(err)
+	}
+	return dir
+}
+
 // read config from file in xdg config dir `~/.config/llama/config.yaml`
 // then map the config to aigateway.Config
 func loadConfig(configFileName string) {
 	if configFileName == "" {
-		usr, err := user.Current()
-		if err != nil {
-			panic(err)
-		}
-		configDir := filepath.Join(usr.HomeDir, ".config", appName)
-		// Create the directory if it doesn't exist
-		if err := os.MkdirAll(configDir, os.ModePerm); err != nil {
-			panic(err)
-		}
-		configFileName = filepath.Join(configDir, defaultConfigFileName)
+		configFileName = filepath.Join(configDir(), defaultConfigFileName)
 	}
 
 	configFileName, err := filepath.Abs(configFileName)
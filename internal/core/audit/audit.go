@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/internal/pkg/ctxutils"
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/Vaayne/aienvoy/pkg/redact"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+// Recorder records every upstream llm call with sanitized request and response.
+type Recorder struct {
+	dao      *daos.Dao
+	redactor *redact.Redactor
+}
+
+func New(dao *daos.Dao, cfg config.Audit) (*Recorder, error) {
+	rules := append([]redact.Rule{}, redact.SecretRules...)
+	if cfg.RedactPII {
+		rules = append(rules, redact.PIIRules...)
+	}
+	rules = append(rules, cfg.RedactRules...)
+	redactor, err := redact.New(rules...)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		dao:      dao,
+		redactor: redactor,
+	}, nil
+}
+
+// Middleware wraps cli so that every call of it is recorded.
+func (r *Recorder) Middleware(cfg llmconfig.Config, cli llm.Client) llm.Client {
+	provider := cfg.LLMType.String()
+	return llm.Wrap(cli, llm.WithHooks(llm.Hooks{
+		OnResponse: func(ctx context.Context, req llm.ChatCompletionRequest, resp llm.ChatCompletionResponse, err error, latency time.Duration) {
+			r.record(ctx, provider, req, resp, err, latency)
+		},
+	}))
+}
+
+// Cleanup deletes records older than retention.
+func (r *Recorder) Cleanup(ctx context.Context, retention time.Duration) (int64, error) {
+	return DeleteRecordsBefore(ctx, r.dao, time.Now().Add(-retention))
+}
+
+func (r *Recorder) record(ctx context.Context, provider string, req llm.ChatCompletionRequest, resp llm.ChatCompletionResponse, err error, latency time.Duration) {
+	record := Record{
+		RequestId:        ctxutils.GetRequestId(ctx),
+		UserId:           ctxutils.GetUserId(ctx),
+		Provider:         provider,
+		Model:            req.Model,
+		Stream:           req.Stream,
+		Request:          r.sanitize(req),
+		LatencyMs:        latency.Milliseconds(),
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+	}
+	if err != nil {
+		record.Error = r.redactor.Redact(err.Error())
+	} else {
+		record.Response = r.sanitize(resp)
+	}
+
+	if _, err := SaveRecord(ctx, r.dao, record); err != nil {
+		slog.ErrorContext(ctx, "save audit record error", "err", err, "provider", provider, "model", req.Model)
+	}
+}
+
+func (r *Recorder) sanitize(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("marshal audit payload error", "err", err)
+		return ""
+	}
+	redacted, err := r.redactor.RedactJSON(data)
+	if err != nil {
+		slog.Error("redact audit payload error", "err", err)
+		return ""
+	}
+	return string(redacted)
+}
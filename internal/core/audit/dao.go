@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/dtoutils"
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const tableNameAudits = "llm_audits"
+
+// Record is an audited upstream llm call, request and response are already redacted.
+type Record struct {
+	dtoutils.BaseModel
+	RequestId        string `json:"request_id" db:"request_id"`
+	UserId           string `json:"user_id" db:"user_id"`
+	Provider         string `json:"provider" db:"provider"`
+	Model            string `json:"model" db:"model"`
+	Stream           bool   `json:"stream" db:"stream"`
+	Request          string `json:"request" db:"request"`
+	Response         string `json:"response" db:"response"`
+	Error            string `json:"error" db:"error"`
+	LatencyMs        int64  `json:"latency_ms" db:"latency_ms"`
+	PromptTokens     int    `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens" db:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens" db:"total_tokens"`
+}
+
+func (r Record) TableName() string {
+	return tableNameAudits
+}
+
+// ListFilter filters audit records, empty fields are ignored.
+type ListFilter struct {
+	RequestId string
+	Provider  string
+	Model     string
+	UserId    string
+	Limit     int64
+	Offset    int64
+}
+
+func SaveRecord(ctx context.Context, tx *daos.Dao, record Record) (Record, error) {
+	if record.Id == "" {
+		record.Id = uuid.NewString()
+	}
+	record.Created = types.NowDateTime()
+	record.Updated = record.Created
+	if err := tx.DB().Model(&record).Insert(); err != nil {
+		return Record{}, err
+	}
+	return record, nil
+}
+
+func GetRecord(ctx context.Context, tx *daos.Dao, id string) (Record, error) {
+	var record Record
+	if err := tx.DB().Select().Model(id, &record); err != nil {
+		return Record{}, err
+	}
+	return record, nil
+}
+
+func ListRecords(ctx context.Context, tx *daos.Dao, filter ListFilter) ([]Record, error) {
+	exp := dbx.HashExp{}
+	if filter.RequestId != "" {
+		exp["request_id"] = filter.RequestId
+	}
+	if filter.Provider != "" {
+		exp["provider"] = filter.Provider
+	}
+	if filter.Model != "" {
+		exp["model"] = filter.Model
+	}
+	if filter.UserId != "" {
+		exp["user_id"] = filter.UserId
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	var records []Record
+	query := tx.DB().Select().From(tableNameAudits).OrderBy("created DESC").Limit(filter.Limit).Offset(filter.Offset)
+	if len(exp) > 0 {
+		query = query.Where(exp)
+	}
+	if err := query.All(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// DeleteRecordsBefore deletes records created before t and returns the count of deleted records.
+func DeleteRecordsBefore(ctx context.Context, tx *daos.Dao, t time.Time) (int64, error) {
+	before, err := types.ParseDateTime(t)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.DB().Delete(tableNameAudits, dbx.NewExp("created < {:before}", dbx.Params{"before": before.String()})).Execute()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Vaayne/aienvoy/internal/core/llms"
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+// ReplayResult is the result of replaying a recorded request against a model.
+type ReplayResult struct {
+	Record    Record                     `json:"record"`
+	Model     string                     `json:"model"`
+	Response  llm.ChatCompletionResponse `json:"response"`
+	Error     string                     `json:"error,omitempty"`
+	LatencyMs int64                      `json:"latency_ms"`
+}
+
+// Replay sends the recorded request of audit record id to model, the recorded model is used if model is empty.
+// The replayed request is the sanitized one, so redacted values are replayed as placeholders.
+func Replay(ctx context.Context, tx *daos.Dao, id, model string) (ReplayResult, error) {
+	record, err := GetRecord(ctx, tx, id)
+	if err != nil {
+		return ReplayResult{}, fmt.Errorf("get audit record %s error: %w", id, err)
+	}
+
+	var req llm.ChatCompletionRequest
+	if err := json.Unmarshal([]byte(record.Request), &req); err != nil {
+		return ReplayResult{}, fmt.Errorf("unmarshal audit request error: %w", err)
+	}
+	if model != "" {
+		req.Model = model
+	}
+	req.Stream = false
+
+	svc, err := llms.NewWithDao(req.Model, llms.NewDao(tx))
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	result := ReplayResult{
+		Record: record,
+		Model:  req.Model,
+	}
+	start := time.Now()
+	resp, err := svc.CreateChatCompletion(ctx, req)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Response = resp
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/dtoutils"
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	TableChatUsers = "chat_users"
+
+	ChatUserPending  = "pending"
+	ChatUserApproved = "approved"
+	ChatUserBanned   = "banned"
+)
+
+// ChatUser is an account of a chat port like telegram or discord, UserId and ApiKeyId are set by /link.
+// Status is the access approved by admins, it is only used by ports with approvals like telegram.
+type ChatUser struct {
+	dtoutils.BaseModel
+	Platform   string `json:"platform" db:"platform"`
+	ExternalId string `json:"external_id" db:"external_id"`
+	Username   string `json:"username" db:"username"`
+	Status     string `json:"status" db:"status"`
+	UserId     string `json:"user_id" db:"user_id"`
+	ApiKeyId   string `json:"api_key_id" db:"api_key_id"`
+}
+
+func (u ChatUser) TableName() string {
+	return TableChatUsers
+}
+
+// Linked reports if the account is bound to a PocketBase user
+func (u ChatUser) Linked() bool {
+	return u.ApiKeyId != ""
+}
+
+// GetChatUser returns sql.ErrNoRows for unknown accounts
+func GetChatUser(ctx context.Context, tx *daos.Dao, platform, externalId string) (ChatUser, error) {
+	var user ChatUser
+	err := tx.DB().Select().From(TableChatUsers).Where(dbx.HashExp{
+		"platform":    platform,
+		"external_id": externalId,
+	}).One(&user)
+	return user, err
+}
+
+func SaveChatUser(ctx context.Context, tx *daos.Dao, user ChatUser) (ChatUser, error) {
+	user.Updated = types.NowDateTime()
+	if user.Id != "" {
+		return user, tx.DB().Model(&user).Update()
+	}
+	user.Id = uuid.NewString()
+	user.Created = user.Updated
+	return user, tx.DB().Model(&user).Insert()
+}
+
+// SetChatUserStatus approves or bans an account, unknown accounts are created.
+func SetChatUserStatus(ctx context.Context, tx *daos.Dao, platform, externalId, status string) (ChatUser, error) {
+	user, err := GetChatUser(ctx, tx, platform, externalId)
+	if errors.Is(err, sql.ErrNoRows) {
+		user = ChatUser{Platform: platform, ExternalId: externalId}
+	} else if err != nil {
+		return ChatUser{}, err
+	}
+	user.Status = status
+	return SaveChatUser(ctx, tx, user)
+}
+
+// LinkChatUser binds an account to the user of an api key, linked accounts are approved.
+func LinkChatUser(ctx context.Context, tx *daos.Dao, user ChatUser, apiKey string) (ChatUser, error) {
+	record, err := FindAuthRecordByApiKey(ctx, tx, apiKey)
+	if err != nil {
+		return ChatUser{}, err
+	}
+	user.UserId = record.GetString("user_id")
+	user.ApiKeyId = record.Id
+	if user.Status != ChatUserBanned {
+		user.Status = ChatUserApproved
+	}
+	return SaveChatUser(ctx, tx, user)
+}
+
+func FindAuthRecordById(ctx context.Context, tx *daos.Dao, id string) (*models.Record, error) {
+	return tx.FindRecordById(TableApiKeys, id)
+}
+
+// AllowsModel reports if the api key of record may use model, keys without llm_models may use all models.
+func AllowsModel(record *models.Record, model string) bool {
+	if record == nil {
+		return true
+	}
+	var models []string
+	raw, _ := record.Get("llm_models").(types.JsonRaw)
+	if len(raw) == 0 || json.Unmarshal(raw, &models) != nil || len(models) == 0 {
+		return true
+	}
+	return slices.Contains(models, model)
+}
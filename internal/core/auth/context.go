@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// WithChatUser puts the PocketBase user, the api key and the auth record of a linked account into ctx,
+// like the auth middleware of the http server does for requests. ok is false when the account is not
+// linked or its api key is deleted.
+func WithChatUser(ctx context.Context, tx *daos.Dao, user ChatUser) (context.Context, *models.Record, bool) {
+	if !user.Linked() {
+		return ctx, nil, false
+	}
+	record, err := FindAuthRecordById(ctx, tx, user.ApiKeyId)
+	if err != nil {
+		slog.WarnContext(ctx, "find api key of chat user error", "err", err, "platform", user.Platform, "external_id", user.ExternalId)
+		return ctx, nil, false
+	}
+	ctx = context.WithValue(ctx, config.ContextKeyUserId, record.GetString("user_id"))
+	ctx = context.WithValue(ctx, config.ContextKeyApiKey, record.GetString(ColumnApiKey))
+	ctx = context.WithValue(ctx, config.ContextKeyAuthRecord, record)
+	return ctx, record, true
+}
+
+// ContextAllowsModel reports if the api key of the auth record in ctx may use model
+func ContextAllowsModel(ctx context.Context, model string) bool {
+	record, _ := ctx.Value(config.ContextKeyAuthRecord).(*models.Record)
+	return AllowsModel(record, model)
+}
@@ -11,8 +11,9 @@ import (
 )
 
 const (
-	TableApiKeys = "api_keys"
-	ColumnApiKey = "api_key"
+	TableApiKeys        = "api_keys"
+	ColumnApiKey        = "api_key"
+	ColumnSemanticCache = "semantic_cache"
 )
 
 type ApiKey struct {
@@ -20,6 +21,8 @@ type ApiKey struct {
 	ApiKey    string   `json:"api_key,omitempty" mapstructure:"api_key,omitempty"`
 	UserId    string   `json:"user_id,omitempty" mapstructure:"user_id,omitempty"`
 	LlmModels []string `json:"llm_models,omitempty" mapstructure:"llm_models,omitempty"`
+	// SemanticCache enables semantic cache for requests with this api key
+	SemanticCache bool `json:"semantic_cache,omitempty" mapstructure:"semantic_cache,omitempty"`
 }
 
 func FindAuthRecordByApiKey(ctx context.Context, tx *daos.Dao, apiKey string) (*models.Record, error) {
@@ -0,0 +1,123 @@
+package chatstate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Vaayne/aienvoy/internal/core/llms"
+	"github.com/Vaayne/aienvoy/internal/pkg/dtoutils"
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	tableNameChatStates = "chat_states"
+	// conversationNameLength limits the names of conversations, they are the first prompts
+	conversationNameLength = 48
+)
+
+// Key is a chat of a chat port, ThreadId is the topic or thread inside the chat, it is empty for plain chats.
+type Key struct {
+	Platform string
+	ChatId   string
+	ThreadId string
+}
+
+// String is kept in the extra_info of the conversations of the chat
+func (k Key) String() string {
+	return fmt.Sprintf("%s:%s:%s", k.Platform, k.ChatId, k.ThreadId)
+}
+
+// State is the active model and conversation of a chat, and its settings
+type State struct {
+	dtoutils.BaseModel
+	Platform       string `json:"platform" db:"platform"`
+	ChatId         string `json:"chat_id" db:"chat_id"`
+	ThreadId       string `json:"thread_id" db:"thread_id"`
+	Model          string `json:"model" db:"model"`
+	ConversationId string `json:"conversation_id" db:"conversation_id"`
+	// VoiceReply sends the answers as voice messages too
+	VoiceReply bool `json:"voice_reply" db:"voice_reply"`
+	// ShowTranscript shows the transcripts of voice messages before the answers
+	ShowTranscript bool `json:"show_transcript" db:"show_transcript"`
+}
+
+func (s State) TableName() string {
+	return tableNameChatStates
+}
+
+func (s State) Key() Key {
+	return Key{Platform: s.Platform, ChatId: s.ChatId, ThreadId: s.ThreadId}
+}
+
+// Get returns the state of a chat, chats without state get an empty one.
+func Get(ctx context.Context, tx *daos.Dao, key Key) (State, error) {
+	var state State
+	err := tx.DB().Select().From(tableNameChatStates).Where(dbx.HashExp{
+		"platform":  key.Platform,
+		"chat_id":   key.ChatId,
+		"thread_id": key.ThreadId,
+	}).One(&state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return State{Platform: key.Platform, ChatId: key.ChatId, ThreadId: key.ThreadId}, nil
+	}
+	return state, err
+}
+
+func Save(ctx context.Context, tx *daos.Dao, state State) (State, error) {
+	state.Updated = types.NowDateTime()
+	if state.Id != "" {
+		return state, tx.DB().Model(&state).Update()
+	}
+	state.Id = uuid.NewString()
+	state.Created = state.Updated
+	return state, tx.DB().Model(&state).Insert()
+}
+
+// NewConversation creates a conversation of the chat and makes it the active one, the prompt names it.
+func NewConversation(ctx context.Context, tx *daos.Dao, state State, model, prompt string) (State, error) {
+	cov, err := llms.NewDao(tx).SaveConversation(ctx, llm.Conversation{
+		Name:      conversationName(prompt),
+		Model:     model,
+		ExtraInfo: state.Key().String(),
+	})
+	if err != nil {
+		return State{}, fmt.Errorf("create conversation error: %w", err)
+	}
+	state.Model = model
+	state.ConversationId = cov.Id
+	return Save(ctx, tx, state)
+}
+
+// ListConversations returns the latest conversations of the chat
+func ListConversations(ctx context.Context, tx *daos.Dao, key Key, limit int64) ([]llm.Conversation, error) {
+	return llms.NewDao(tx).ListConversationsByExtraInfo(ctx, key.String(), limit)
+}
+
+// Switch makes a conversation of the chat the active one, its model is used again.
+func Switch(ctx context.Context, tx *daos.Dao, state State, conversationId string) (State, error) {
+	cov, err := llms.NewDao(tx).GetConversation(ctx, conversationId)
+	if err != nil || cov.ExtraInfo != state.Key().String() {
+		return State{}, fmt.Errorf("conversation %s not found in this chat", conversationId)
+	}
+	state.ConversationId = cov.Id
+	if cov.Model != "" {
+		state.Model = cov.Model
+	}
+	return Save(ctx, tx, state)
+}
+
+func conversationName(prompt string) string {
+	name := strings.Join(strings.Fields(prompt), " ")
+	if utf8.RuneCountInString(name) <= conversationNameLength {
+		return name
+	}
+	return string([]rune(name)[:conversationNameLength]) + "..."
+}
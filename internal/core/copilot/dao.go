@@ -0,0 +1,70 @@
+package copilot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/dtoutils"
+	"github.com/Vaayne/aienvoy/pkg/llm/githubcopilot"
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const tableNameTokens = "github_copilot_tokens"
+
+// Token is the github token of a device flow login
+type Token struct {
+	dtoutils.BaseModel
+	Account string `json:"account" db:"account"`
+	Token   string `json:"token" db:"token"`
+}
+
+func (t Token) TableName() string {
+	return tableNameTokens
+}
+
+// Dao stores the github tokens of copilot in PocketBase, it implements githubcopilot.TokenStore.
+type Dao struct {
+	tx *daos.Dao
+}
+
+func NewDao(tx *daos.Dao) *Dao {
+	return &Dao{tx: tx}
+}
+
+func (d *Dao) GetToken(ctx context.Context, account string) (string, error) {
+	token, err := d.find(account)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", githubcopilot.ErrNotLoggedIn
+	}
+	if err != nil {
+		return "", err
+	}
+	return token.Token, nil
+}
+
+func (d *Dao) SaveToken(ctx context.Context, account, token string) error {
+	record, err := d.find(account)
+	if errors.Is(err, sql.ErrNoRows) {
+		record = Token{Account: account, Token: token}
+		record.Id = uuid.NewString()
+		record.Created = types.NowDateTime()
+		record.Updated = record.Created
+		return d.tx.DB().Model(&record).Insert()
+	}
+	if err != nil {
+		return err
+	}
+	record.Token = token
+	record.Updated = types.NowDateTime()
+	return d.tx.DB().Model(&record).Update()
+}
+
+func (d *Dao) find(account string) (Token, error) {
+	var token Token
+	err := d.tx.DB().Select().From(tableNameTokens).Where(dbx.HashExp{"account": account}).One(&token)
+	return token, err
+}
@@ -0,0 +1,103 @@
+package copilot
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm/client"
+	"github.com/Vaayne/aienvoy/pkg/llm/githubcopilot"
+)
+
+const (
+	LoginStatusPending = "pending"
+	LoginStatusSuccess = "success"
+	LoginStatusFailed  = "failed"
+)
+
+// LoginState is the state of the last device flow login of an account
+type LoginState struct {
+	Account         string    `json:"account"`
+	Status          string    `json:"status"`
+	UserCode        string    `json:"user_code,omitempty"`
+	VerificationUri string    `json:"verification_uri,omitempty"`
+	ExpiresAt       time.Time `json:"expires_at,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// Login runs device flow logins in background and saves the github tokens of authorized logins
+type Login struct {
+	flow  *githubcopilot.DeviceFlow
+	store githubcopilot.TokenStore
+
+	mu     sync.Mutex
+	states map[string]LoginState
+}
+
+func NewLogin(store githubcopilot.TokenStore) *Login {
+	return &Login{
+		flow:   githubcopilot.NewDeviceFlow(),
+		store:  store,
+		states: make(map[string]LoginState),
+	}
+}
+
+// Start requests a device code for account, the user authorizes it on the verification uri.
+func (l *Login) Start(ctx context.Context, account string) (LoginState, error) {
+	code, err := l.flow.Start(ctx)
+	if err != nil {
+		return LoginState{}, err
+	}
+	state := LoginState{
+		Account:         account,
+		Status:          LoginStatusPending,
+		UserCode:        code.UserCode,
+		VerificationUri: code.VerificationUri,
+		ExpiresAt:       time.Now().Add(time.Duration(code.ExpiresIn) * time.Second),
+	}
+	l.setState(state)
+
+	go func() {
+		err := l.wait(context.Background(), account, code)
+		state.UserCode = ""
+		state.VerificationUri = ""
+		if err != nil {
+			slog.Error("github copilot login error", "err", err, "account", account)
+			state.Status = LoginStatusFailed
+			state.Error = err.Error()
+		} else {
+			slog.Info("github copilot login success", "account", account)
+			state.Status = LoginStatusSuccess
+		}
+		l.setState(state)
+	}()
+	return state, nil
+}
+
+// Status returns the state of the last login of account
+func (l *Login) Status(account string) (LoginState, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state, ok := l.states[account]
+	return state, ok
+}
+
+func (l *Login) wait(ctx context.Context, account string, code githubcopilot.DeviceCode) error {
+	token, err := l.flow.Wait(ctx, code)
+	if err != nil {
+		return err
+	}
+	if err := l.store.SaveToken(ctx, account, token); err != nil {
+		return err
+	}
+	// list the models of copilot, they were the defaults without login
+	client.Refresh()
+	return nil
+}
+
+func (l *Login) setState(state LoginState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.states[state.Account] = state
+}
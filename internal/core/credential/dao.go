@@ -0,0 +1,126 @@
+package credential
+
+import (
+	"context"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/dtoutils"
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	tableNameCredentials = "credentials"
+	tableNameAudits      = "credential_audits"
+)
+
+// Credential is an encrypted provider secret, Value is the ciphertext and never leaves the vault.
+type Credential struct {
+	dtoutils.BaseModel
+	Name        string `json:"name" db:"name"`
+	Value       string `json:"-" db:"value"`
+	Description string `json:"description" db:"description"`
+	// Version is increased by every rotation
+	Version   int    `json:"version" db:"version"`
+	Revoked   bool   `json:"revoked" db:"revoked"`
+	UpdatedBy string `json:"updated_by" db:"updated_by"`
+}
+
+func (c Credential) TableName() string {
+	return tableNameCredentials
+}
+
+const (
+	ActionCreate = "create"
+	ActionRotate = "rotate"
+	ActionRevoke = "revoke"
+	ActionRead   = "read"
+	// ActionResolve is the resolution of a secret:// reference of the settings, it is only recorded
+	// when a version is resolved the first time or fails to resolve, not on every reload
+	ActionResolve = "resolve"
+)
+
+// Audit is an access to a credential, failed reads are recorded with their error.
+type Audit struct {
+	dtoutils.BaseModel
+	Name    string `json:"name" db:"name"`
+	Action  string `json:"action" db:"action"`
+	Actor   string `json:"actor" db:"actor"`
+	Version int    `json:"version" db:"version"`
+	Error   string `json:"error" db:"error"`
+}
+
+func (a Audit) TableName() string {
+	return tableNameAudits
+}
+
+func getCredential(ctx context.Context, tx *daos.Dao, name string) (Credential, error) {
+	var credential Credential
+	err := tx.DB().Select().From(tableNameCredentials).Where(dbx.HashExp{"name": name}).One(&credential)
+	return credential, err
+}
+
+func listCredentials(ctx context.Context, tx *daos.Dao) ([]Credential, error) {
+	var credentials []Credential
+	if err := tx.DB().Select().From(tableNameCredentials).OrderBy("name").All(&credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func insertCredential(ctx context.Context, tx *daos.Dao, credential Credential) (Credential, error) {
+	credential.Id = uuid.NewString()
+	credential.Created = types.NowDateTime()
+	credential.Updated = credential.Created
+	if err := tx.DB().Model(&credential).Insert(); err != nil {
+		return Credential{}, err
+	}
+	return credential, nil
+}
+
+func updateCredential(ctx context.Context, tx *daos.Dao, credential Credential) (Credential, error) {
+	credential.Updated = types.NowDateTime()
+	if err := tx.DB().Model(&credential).Update(); err != nil {
+		return Credential{}, err
+	}
+	return credential, nil
+}
+
+func saveAudit(ctx context.Context, tx *daos.Dao, audit Audit) error {
+	audit.Id = uuid.NewString()
+	audit.Created = types.NowDateTime()
+	audit.Updated = audit.Created
+	return tx.DB().Model(&audit).Insert()
+}
+
+// ListFilter filters audits, empty fields are ignored.
+type ListFilter struct {
+	Name   string
+	Action string
+	Limit  int64
+	Offset int64
+}
+
+func listAudits(ctx context.Context, tx *daos.Dao, filter ListFilter) ([]Audit, error) {
+	exp := dbx.HashExp{}
+	if filter.Name != "" {
+		exp["name"] = filter.Name
+	}
+	if filter.Action != "" {
+		exp["action"] = filter.Action
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	var audits []Audit
+	query := tx.DB().Select().From(tableNameAudits).OrderBy("created DESC").Limit(filter.Limit).Offset(filter.Offset)
+	if len(exp) > 0 {
+		query = query.Where(exp)
+	}
+	if err := query.All(&audits); err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
@@ -0,0 +1,203 @@
+package credential
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sync"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/pkg/vault"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+var (
+	ErrNotFound = errors.New("credential not found")
+	ErrExists   = errors.New("credential already exists")
+	ErrRevoked  = errors.New("credential is revoked")
+
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-]{0,127}$`)
+)
+
+type actorKey struct{}
+
+// WithActor sets the admin or component which accesses credentials, it is recorded by audits.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return "system"
+}
+
+// Vault encrypts credentials by the master key and records every access of them.
+type Vault struct {
+	tx     *daos.Dao
+	cipher *vault.Cipher
+
+	mu sync.Mutex
+	// resolved is the last resolution of every secret:// reference, unchanged ones are not audited again
+	resolved map[string]resolution
+}
+
+type resolution struct {
+	version int
+	err     string
+}
+
+// New returns vault.ErrNoMasterKey when the master key is not configured
+func New(tx *daos.Dao, cfg config.Vault) (*Vault, error) {
+	key, err := vault.LoadMasterKey(cfg.MasterKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := vault.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{tx: tx, cipher: cipher, resolved: make(map[string]resolution)}, nil
+}
+
+func (v *Vault) Add(ctx context.Context, name, value, description string) (Credential, error) {
+	if !namePattern.MatchString(name) {
+		return Credential{}, fmt.Errorf("invalid credential name %q", name)
+	}
+	if _, err := getCredential(ctx, v.tx, name); err == nil {
+		return Credential{}, ErrExists
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return Credential{}, err
+	}
+
+	ciphertext, err := v.cipher.Encrypt(name, value)
+	if err != nil {
+		return Credential{}, err
+	}
+	credential, err := insertCredential(ctx, v.tx, Credential{
+		Name:        name,
+		Value:       ciphertext,
+		Description: description,
+		Version:     1,
+		UpdatedBy:   actor(ctx),
+	})
+	if err != nil {
+		return Credential{}, fmt.Errorf("save credential error: %w", err)
+	}
+	v.audit(ctx, credential, ActionCreate, nil)
+	return credential, nil
+}
+
+// Rotate replaces the value of a credential, revoked credentials are restored by rotations.
+func (v *Vault) Rotate(ctx context.Context, name, value string) (Credential, error) {
+	credential, err := v.get(ctx, name)
+	if err != nil {
+		return Credential{}, err
+	}
+	ciphertext, err := v.cipher.Encrypt(name, value)
+	if err != nil {
+		return Credential{}, err
+	}
+	credential.Value = ciphertext
+	credential.Version++
+	credential.Revoked = false
+	credential.UpdatedBy = actor(ctx)
+	if credential, err = updateCredential(ctx, v.tx, credential); err != nil {
+		return Credential{}, fmt.Errorf("save credential error: %w", err)
+	}
+	v.audit(ctx, credential, ActionRotate, nil)
+	return credential, nil
+}
+
+// Revoke erases the value of a credential, references to it fail to resolve until it is rotated.
+func (v *Vault) Revoke(ctx context.Context, name string) (Credential, error) {
+	credential, err := v.get(ctx, name)
+	if err != nil {
+		return Credential{}, err
+	}
+	credential.Value = ""
+	credential.Revoked = true
+	credential.UpdatedBy = actor(ctx)
+	if credential, err = updateCredential(ctx, v.tx, credential); err != nil {
+		return Credential{}, fmt.Errorf("save credential error: %w", err)
+	}
+	v.audit(ctx, credential, ActionRevoke, nil)
+	return credential, nil
+}
+
+// List returns the credentials without their values
+func (v *Vault) List(ctx context.Context) ([]Credential, error) {
+	return listCredentials(ctx, v.tx)
+}
+
+// Get decrypts the value of a credential, every read is audited.
+func (v *Vault) Get(ctx context.Context, name string) (string, error) {
+	credential, value, err := v.decrypt(ctx, name)
+	v.audit(ctx, credential, ActionRead, err)
+	return value, err
+}
+
+func (v *Vault) ListAudits(ctx context.Context, filter ListFilter) ([]Audit, error) {
+	return listAudits(ctx, v.tx, filter)
+}
+
+// ResolveConfig replaces the secret:// references of cfg by the values of their credentials.
+// Config reloads resolve every reference again, so only changed resolutions are audited.
+func (v *Vault) ResolveConfig(ctx context.Context, cfg *config.Config) error {
+	return vault.Resolve(cfg, func(name string) (string, error) {
+		credential, value, err := v.decrypt(ctx, name)
+		r := resolution{version: credential.Version}
+		if err != nil {
+			r.err = err.Error()
+		}
+		v.mu.Lock()
+		changed := v.resolved[name] != r
+		v.resolved[name] = r
+		v.mu.Unlock()
+		if changed {
+			v.audit(ctx, credential, ActionResolve, err)
+		}
+		return value, err
+	})
+}
+
+func (v *Vault) decrypt(ctx context.Context, name string) (Credential, string, error) {
+	credential, err := v.get(ctx, name)
+	if err == nil && credential.Revoked {
+		err = ErrRevoked
+	}
+	var value string
+	if err == nil {
+		value, err = v.cipher.Decrypt(name, credential.Value)
+	}
+	credential.Name = name
+	return credential, value, err
+}
+
+func (v *Vault) get(ctx context.Context, name string) (Credential, error) {
+	credential, err := getCredential(ctx, v.tx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Credential{}, ErrNotFound
+	}
+	return credential, err
+}
+
+func (v *Vault) audit(ctx context.Context, credential Credential, action string, err error) {
+	audit := Audit{
+		Name:    credential.Name,
+		Action:  action,
+		Actor:   actor(ctx),
+		Version: credential.Version,
+	}
+	if err != nil {
+		audit.Error = err.Error()
+	}
+	if err := saveAudit(ctx, v.tx, audit); err != nil {
+		slog.ErrorContext(ctx, "save credential audit error", "err", err, "name", credential.Name, "action", action)
+	}
+	slog.InfoContext(ctx, "credential accessed", "name", credential.Name, "action", action, "actor", audit.Actor, "version", credential.Version)
+}
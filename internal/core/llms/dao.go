@@ -60,6 +60,20 @@ func (d *Dao) ListConversations(ctx context.Context) ([]llm.Conversation, error)
 	return conversations, nil
 }
 
+// ListConversationsByExtraInfo returns the latest conversations of a chat, ports keep the chat in extra_info.
+func (d *Dao) ListConversationsByExtraInfo(ctx context.Context, extraInfo string, limit int64) ([]llm.Conversation, error) {
+	var dtos []ConversationDTO
+	if err := d.tx.DB().Select().Where(dbx.HashExp{"extra_info": extraInfo}).OrderBy("created DESC").Limit(limit).All(&dtos); err != nil {
+		return nil, err
+	}
+
+	conversations := make([]llm.Conversation, 0, len(dtos))
+	for _, dto := range dtos {
+		conversations = append(conversations, dto.ToLLMConversation())
+	}
+	return conversations, nil
+}
+
 func (d *Dao) DeleteConversation(ctx context.Context, id string) error {
 	return d.tx.DB().Model(&ConversationDTO{BaseModel: dtoutils.BaseModel{Id: id}}).Delete()
 }
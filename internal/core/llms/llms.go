@@ -6,6 +6,13 @@ import (
 	"github.com/Vaayne/aienvoy/pkg/llm/client"
 )
 
+func init() {
+	// pick up changed llm configs and newly discovered models without restarts
+	config.OnChange(func() {
+		client.Reload(config.GetConfig().LLMs)
+	})
+}
+
 func NewWithDao(model string, dao llm.Dao) (llm.Interface, error) {
 	return client.NewWithDao(model, config.GetConfig().LLMs, dao)
 }
@@ -13,3 +20,15 @@ func NewWithDao(model string, dao llm.Dao) (llm.Interface, error) {
 func New(model string) (llm.Interface, error) {
 	return NewWithDao(model, llm.NewMemoryDao())
 }
+
+// DescribeModels returns the details of all served models, like context length and pricing.
+func DescribeModels(dao llm.Dao) []llm.Model {
+	client.Init(config.GetConfig().LLMs, dao)
+	return client.DescribeModels()
+}
+
+// Models returns the names of all served models, sorted by name.
+func Models(dao llm.Dao) []string {
+	client.Init(config.GetConfig().LLMs, dao)
+	return client.Models()
+}
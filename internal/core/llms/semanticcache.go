@@ -0,0 +1,146 @@
+package llms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/internal/pkg/dtoutils"
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/llm/client"
+	"github.com/Vaayne/aienvoy/pkg/llm/semanticcache"
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+const tableNameMessageEmbeddings = "conversation_message_embeddings"
+
+type MessageEmbeddingDTO struct {
+	dtoutils.BaseModel
+	Model          string `json:"model" db:"model"`
+	EmbeddingModel string `json:"embedding_model" db:"embedding_model"`
+	MessageId      string `json:"message_id,omitempty" db:"message_id"`
+	Scope          string `json:"scope" db:"scope"`
+	ContextHash    string `json:"context_hash" db:"context_hash"`
+	Prompt         string `json:"prompt" db:"prompt"`
+	PromptHash     string `json:"prompt_hash" db:"prompt_hash"`
+	Vector         []byte `json:"vector,omitempty" db:"vector"`
+	Response       []byte `json:"response,omitempty" db:"response"`
+}
+
+func (m MessageEmbeddingDTO) TableName() string {
+	return tableNameMessageEmbeddings
+}
+
+func (m *MessageEmbeddingDTO) FromEntry(entry semanticcache.Entry) {
+	m.Id = entry.Id
+	m.Created = mustParseDateTime(entry.CreatedAt)
+	m.Updated = m.Created
+	m.Model = entry.Model
+	m.EmbeddingModel = entry.EmbeddingModel
+	m.MessageId = entry.MessageId
+	m.Scope = entry.Scope
+	m.ContextHash = entry.ContextHash
+	m.Prompt = entry.Prompt
+	m.PromptHash = entry.PromptHash
+	m.Vector = mustMarshal(entry.Vector)
+	m.Response = mustMarshal(entry.Response)
+}
+
+func (m MessageEmbeddingDTO) ToEntry() semanticcache.Entry {
+	var vector []float32
+	var resp llm.ChatCompletionResponse
+	mustUnMarshal(m.Vector, &vector)
+	mustUnMarshal(m.Response, &resp)
+	return semanticcache.Entry{
+		Id:             m.Id,
+		CreatedAt:      m.Created.Time(),
+		Model:          m.Model,
+		EmbeddingModel: m.EmbeddingModel,
+		MessageId:      m.MessageId,
+		Scope:          m.Scope,
+		ContextHash:    m.ContextHash,
+		Prompt:         m.Prompt,
+		PromptHash:     m.PromptHash,
+		Vector:         vector,
+		Response:       resp,
+	}
+}
+
+// SemanticCacheStore saves semantic cache entries in sqlite.
+type SemanticCacheStore struct {
+	tx *daos.Dao
+}
+
+func NewSemanticCacheStore(tx *daos.Dao) *SemanticCacheStore {
+	return &SemanticCacheStore{tx: tx}
+}
+
+func (s *SemanticCacheStore) SaveEntry(ctx context.Context, entry semanticcache.Entry) (semanticcache.Entry, error) {
+	if entry.Id == "" {
+		entry.Id = uuid.NewString()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	var dto MessageEmbeddingDTO
+	dto.FromEntry(entry)
+	if err := s.tx.DB().Model(&dto).Insert(); err != nil {
+		return semanticcache.Entry{}, err
+	}
+	return entry, nil
+}
+
+func (s *SemanticCacheStore) ListEntries(ctx context.Context, model string) ([]semanticcache.Entry, error) {
+	var dtos []MessageEmbeddingDTO
+	if err := s.tx.DB().Select().Where(dbx.HashExp{"model": model}).All(&dtos); err != nil {
+		return nil, err
+	}
+	entries := make([]semanticcache.Entry, 0, len(dtos))
+	for _, dto := range dtos {
+		entries = append(entries, dto.ToEntry())
+	}
+	return entries, nil
+}
+
+var (
+	semanticCache   *semanticcache.Cache
+	semanticCacheMu sync.Mutex
+)
+
+func init() {
+	// the embedder and the thresholds may change with the config
+	config.OnChange(resetSemanticCache)
+}
+
+func resetSemanticCache() {
+	semanticCacheMu.Lock()
+	defer semanticCacheMu.Unlock()
+	semanticCache = nil
+}
+
+// GetSemanticCache returns the process wide semantic cache,
+// the vector index is shared so it is only loaded once from sqlite until the config changes.
+// Failed inits are retried by the next call.
+func GetSemanticCache(tx *daos.Dao) (*semanticcache.Cache, error) {
+	semanticCacheMu.Lock()
+	defer semanticCacheMu.Unlock()
+	if semanticCache != nil {
+		return semanticCache, nil
+	}
+	cfg := config.GetConfig().SemanticCache
+	embedder, err := client.NewEmbedderWithDao(cfg.EmbeddingModel, config.GetConfig().LLMs, NewDao(tx))
+	if err != nil {
+		return nil, fmt.Errorf("init semantic cache embedder error: %w", err)
+	}
+	semanticCache = semanticcache.New(embedder, NewSemanticCacheStore(tx), semanticcache.Config{
+		EmbeddingModel: cfg.EmbeddingModel,
+		Threshold:      cfg.Threshold,
+		TTL:            cfg.TTL,
+		MaxEntries:     cfg.MaxEntries,
+	})
+	return semanticCache, nil
+}
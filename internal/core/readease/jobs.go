@@ -5,20 +5,39 @@ import (
 	"fmt"
 	"log/slog"
 	"runtime"
+	"sync"
+	"time"
 
 	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/internal/pkg/telemetry"
 	"github.com/Vaayne/aienvoy/pkg/hackernews"
 
 	"github.com/pocketbase/pocketbase"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/semaphore"
 )
 
-func PeriodJob(app *pocketbase.PocketBase, model string) ([]string, error) {
-	ctx := context.Background()
+func PeriodJob(app *pocketbase.PocketBase, model string) (contents []string, err error) {
+	ctx, span := telemetry.Tracer().Start(context.Background(), "readease.period_job", trace.WithAttributes(
+		attribute.String("llm.model", model),
+	))
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.SetAttributes(attribute.Int("readease.summaries", len(contents)))
+		span.End()
+		telemetry.ObserveJob("readease", err, time.Since(start))
+	}()
+
 	slog.InfoContext(ctx, "Start readease period job...")
 	topStoiresCnt := config.GetConfig().ReadEase.TopStoriesCnt
 
-	contents := make([]string, 0, topStoiresCnt)
+	contents = make([]string, 0, topStoiresCnt)
 
 	hn := hackernews.New()
 	stories, err := hn.GetBestStories(topStoiresCnt)
@@ -27,12 +46,13 @@ func PeriodJob(app *pocketbase.PocketBase, model string) ([]string, error) {
 	}
 	slog.InfoContext(ctx, "success get hackernews top stories", "count", len(stories))
 
+	var mu sync.Mutex
 	reader := NewReader(app)
 	maxWorkers := runtime.GOMAXPROCS(0)
 	sem := semaphore.NewWeighted(int64(maxWorkers))
 	for _, id := range stories {
 		if err := sem.Acquire(ctx, 1); err != nil {
-			slog.ErrorContext(ctx, "Failed to acquire semaphore: %v", err)
+			slog.ErrorContext(ctx, "Failed to acquire semaphore", "err", err)
 			break
 		}
 
@@ -55,7 +75,9 @@ func PeriodJob(app *pocketbase.PocketBase, model string) ([]string, error) {
 				return
 			}
 			if article.Summary != "" {
+				mu.Lock()
 				contents = append(contents, article.Summary)
+				mu.Unlock()
 				article.IsReadeaseSent = true
 				if err := UpsertArticle(ctx, app.Dao(), article); err != nil {
 					slog.ErrorContext(ctx, "error update article send status", "err", err)
@@ -66,7 +88,7 @@ func PeriodJob(app *pocketbase.PocketBase, model string) ([]string, error) {
 	}
 	// Acquire all of the tokens to wait for any remaining workers to finish.
 	if err := sem.Acquire(ctx, int64(maxWorkers)); err != nil {
-		slog.InfoContext(ctx, "Failed to acquire semaphore: %v", err)
+		slog.InfoContext(ctx, "Failed to acquire semaphore", "err", err)
 	}
 	slog.InfoContext(ctx, "success read top hackernews", "count", len(contents))
 	return contents, nil
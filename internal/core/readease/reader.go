@@ -11,6 +11,7 @@ import (
 
 	"github.com/Vaayne/aienvoy/internal/core/llms"
 	"github.com/Vaayne/aienvoy/internal/pkg/parser"
+	"github.com/Vaayne/aienvoy/pkg/docextract"
 	"github.com/Vaayne/aienvoy/pkg/llm"
 	"github.com/pocketbase/pocketbase"
 )
@@ -44,6 +45,16 @@ A: ...
 Please ensure that you maintain the XML tags in your responses and avoid adding explanations or extra words.
 `
 
+const (
+	// maxContentLength limits the content of a summary request of models without known context length,
+	// longer contents are summarized by parts first
+	maxContentLength = 48000
+	// maxParts limits the summary requests of a long content, the parts after it are dropped
+	maxParts = 8
+)
+
+var errEmptyChoices = errors.New("llm response has no choices")
+
 type Reader struct {
 	app *pocketbase.PocketBase
 }
@@ -120,9 +131,13 @@ func (s *Reader) Read(ctx context.Context, url, model string) (*Article, error)
 		return nil, fmt.Errorf("failed to create llm service: %w", err)
 	}
 
+	content, err := condense(ctx, llmSvc, model, article.Content, s.contentLength(model))
+	if err != nil {
+		return nil, err
+	}
 	req := llm.ChatCompletionRequest{
 		Model:       model,
-		Messages:    buildMessages(article),
+		Messages:    buildMessages(content),
 		MaxTokens:   8192,
 		Temperature: 0.7,
 	}
@@ -130,6 +145,9 @@ func (s *Reader) Read(ctx context.Context, url, model string) (*Article, error)
 	if err != nil {
 		return nil, fmt.Errorf("summaryArticle create chat message err: %v", err)
 	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("summaryArticle create chat message err: %w", errEmptyChoices)
+	}
 
 	summary, err := buildSummaryResponse(url, article.Title, resp.Choices[0].Message.Content)
 	if err != nil {
@@ -154,8 +172,9 @@ func (s *Reader) ReadStream(ctx context.Context, url, model string, respChan cha
 	}
 
 	if article != nil && article.Summary != "" {
-		slog.InfoContext(ctx, "article already summaries", "url", url, "title", article.Title, "summary", article.Summary[:100])
-		respChan <- llm.ChatCompletionStreamResponse{}
+		slog.InfoContext(ctx, "article already summaries", "url", url, "title", article.Title, "summary", article.Summary[:min(100, len(article.Summary))])
+		respChan <- llm.ChatCompletionStreamResponse{Choices: []llm.ChatCompletionStreamChoice{{Delta: llm.ChatCompletionStreamChoiceDelta{Content: article.Summary}}}}
+		errChan <- io.EOF
 		return
 	}
 
@@ -166,9 +185,14 @@ func (s *Reader) ReadStream(ctx context.Context, url, model string, respChan cha
 		return
 	}
 
+	content, err := condense(ctx, llmSvc, model, article.Content, s.contentLength(model))
+	if err != nil {
+		errChan <- err
+		return
+	}
 	req := llm.ChatCompletionRequest{
 		Model:       model,
-		Messages:    buildMessages(article),
+		Messages:    buildMessages(content),
 		MaxTokens:   8192,
 		Temperature: 0.7,
 		Stream:      true,
@@ -184,6 +208,9 @@ func (s *Reader) ReadStream(ctx context.Context, url, model string, respChan cha
 	for {
 		select {
 		case resp := <-dataChan:
+			if len(resp.Choices) == 0 {
+				continue
+			}
 			sb.WriteString(resp.Choices[0].Delta.Content)
 			respChan <- resp
 		case err := <-innerErrChan:
@@ -209,7 +236,62 @@ func (s *Reader) ReadStream(ctx context.Context, url, model string, respChan cha
 	}
 }
 
-func buildMessages(article *Article) []llm.ChatCompletionMessage {
+// contentLength is the length of the content of a summary request to model, it takes about half of the
+// context of the model with 4 characters per token.
+func (s *Reader) contentLength(model string) int {
+	for _, m := range llms.DescribeModels(llms.NewDao(s.app.Dao())) {
+		if m.ID == model && m.ContextLength > 0 {
+			return m.ContextLength * 2
+		}
+	}
+	return maxContentLength
+}
+
+// condense summarizes the parts of contents longer than length, so that the summary request fits the context
+// of the model. At most maxParts parts are summarized, summaries which are still too long are condensed again.
+func condense(ctx context.Context, llmSvc llm.Interface, model, content string, length int) (string, error) {
+	if len(content) <= length {
+		return content, nil
+	}
+	parts := docextract.Chunk(content, length)
+	truncated := len(parts) > maxParts
+	if truncated {
+		slog.WarnContext(ctx, "article is too long, only its first parts are summarized", "length", len(content), "parts", len(parts))
+		parts = parts[:maxParts]
+	}
+	summaries := make([]string, 0, len(parts))
+	for i, part := range parts {
+		resp, err := llmSvc.CreateChatCompletion(ctx, llm.ChatCompletionRequest{
+			Model: model,
+			Messages: []llm.ChatCompletionMessage{
+				{
+					Role:    llm.ChatMessageRoleUser,
+					Content: fmt.Sprintf("Here is part %d of %d of an article enclosed within the XML tag <part>, summarize its key points in detail.\n<part>%s</part>", i+1, len(parts), part),
+				},
+			},
+			Temperature: 0.3,
+		})
+		if err != nil {
+			return "", fmt.Errorf("summary article part %d err: %w", i+1, err)
+		}
+		if len(resp.Choices) == 0 {
+			return "", fmt.Errorf("summary article part %d err: %w", i+1, errEmptyChoices)
+		}
+		summaries = append(summaries, resp.Choices[0].Message.Content)
+	}
+	if truncated {
+		summaries = append(summaries, fmt.Sprintf("(The article is truncated, only its first %d parts are summarized.)", maxParts))
+	}
+	slog.InfoContext(ctx, "condense long article", "length", len(content), "parts", len(parts))
+	condensed := strings.Join(summaries, "\n\n")
+	// summaries which don't get shorter would be condensed forever
+	if len(condensed) >= len(content) {
+		return condensed, nil
+	}
+	return condense(ctx, llmSvc, model, condensed, length)
+}
+
+func buildMessages(content string) []llm.ChatCompletionMessage {
 	return []llm.ChatCompletionMessage{
 		{
 			Role:    llm.ChatMessageRoleSystem,
@@ -217,7 +299,7 @@ func buildMessages(article *Article) []llm.ChatCompletionMessage {
 		},
 		{
 			Role:    llm.ChatMessageRoleUser,
-			Content: fmt.Sprintf("Here is my article enclosed within the XML tag <article>.\n<article>%s</article>", article.Content),
+			Content: fmt.Sprintf("Here is my article enclosed within the XML tag <article>.\n<article>%s</article>", content),
 		},
 		{
 			Role:    llm.ChatMessageRoleUser,
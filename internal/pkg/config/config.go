@@ -1,15 +1,109 @@
 package config
 
 import (
+	"log/slog"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/Vaayne/aienvoy/pkg/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	// globalConfig is replaced as a whole on reloads, a loaded config is never changed
+	globalConfig atomic.Pointer[Config]
+	// mu guards the registrations and keeps reloads in order
+	mu        sync.Mutex
+	onChanges []func()
+	// resolvers rewrite the loaded config before it is used, like secret references
+	resolvers []func(cfg *Config)
+	// reloadTimer debounces ReloadLater
+	reloadTimer *time.Timer
 )
 
-var globalConfig = &Config{}
+// reloadDelay is how long ReloadLater waits for more changes before reloading
+const reloadDelay = time.Second
 
 func init() {
-	config.Load(globalConfig)
+	config.Watch(func(e fsnotify.Event) {
+		Reload()
+	})
+	cfg, err := load()
+	if err != nil {
+		panic(err)
+	}
+	globalConfig.Store(cfg)
+}
+
+// OnChange registers f to be called after the config is reloaded because the settings file changed.
+func OnChange(f func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	onChanges = append(onChanges, f)
+}
+
+// OnLoad registers f to rewrite the config, the config is reloaded with it and so is every reloaded one.
+func OnLoad(f func(cfg *Config)) {
+	mu.Lock()
+	resolvers = append(resolvers, f)
+	mu.Unlock()
+	Reload()
+}
+
+// Reload decodes the settings again, like after the secrets they refer to are rotated.
+// The new config replaces the current one only after it is resolved, OnChange callbacks
+// are only called when the config changed.
+func Reload() {
+	mu.Lock()
+	cfg, err := load()
+	if err != nil {
+		mu.Unlock()
+		slog.Error("reload config error", "err", err)
+		return
+	}
+	if reflect.DeepEqual(cfg, globalConfig.Load()) {
+		mu.Unlock()
+		return
+	}
+	globalConfig.Store(cfg)
+	changes := append([]func(){}, onChanges...)
+	mu.Unlock()
+
+	for _, onChange := range changes {
+		onChange()
+	}
+}
+
+// ReloadLater reloads the config in the background after reloadDelay, so that a burst of
+// changes like credential updates is reloaded once and requests do not wait for it.
+func ReloadLater() {
+	mu.Lock()
+	defer mu.Unlock()
+	if reloadTimer != nil {
+		reloadTimer.Stop()
+	}
+	reloadTimer = time.AfterFunc(reloadDelay, Reload)
 }
 
 func GetConfig() *Config {
-	return globalConfig
+	return globalConfig.Load()
+}
+
+// Set replaces the config until the next reload, like in tests.
+func Set(cfg *Config) {
+	globalConfig.Store(cfg)
+}
+
+// load decodes the settings into a new config and resolves it, mu must be held
+func load() (*Config, error) {
+	cfg := &Config{}
+	if err := config.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+	for _, f := range resolvers {
+		f(cfg)
+	}
+	return cfg, nil
 }
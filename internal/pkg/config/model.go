@@ -1,15 +1,21 @@
 package config
 
-import llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+import (
+	"time"
+
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/Vaayne/aienvoy/pkg/redact"
+	"github.com/Vaayne/aienvoy/pkg/speech"
+)
 
 type Config struct {
-	Service  ServiceConfig
-	Admins   []Admin
-	LLMs     []llmconfig.Config
-	Axiom    Axiom
-	Telegram struct {
-		Token string `yaml:"token"`
-	}
+	Service   ServiceConfig
+	Admins    []Admin
+	LLMs      []llmconfig.Config
+	Axiom     Axiom
+	Telegram  Telegram
+	Discord   Discord
+	Slack     Slack
 	ClaudeWeb struct {
 		Token string `yaml:"token"`
 	}
@@ -20,6 +26,14 @@ type Config struct {
 	CookieCloud CookieCloud
 	MidJourney  MidJourney
 	AWS         AWSConfig
+	// SemanticCache answers near-duplicate prompts from previous responses
+	SemanticCache SemanticCache
+	// Audit records every upstream llm call for compliance reviews
+	Audit Audit
+	// Vault stores provider credentials encrypted in PocketBase, settings refer to them by secret://name
+	Vault Vault
+	// Speech transcribes the voice messages of the bot and synthesizes its voice replies
+	Speech Speech
 }
 
 type ServiceConfig struct {
@@ -34,6 +48,8 @@ type ServiceConfig struct {
 type Axiom struct {
 	Token   string
 	Dataset string
+	// Endpoint is the OTLP host traces are exported to, default is api.axiom.co
+	Endpoint string
 }
 
 type Admin struct {
@@ -41,6 +57,49 @@ type Admin struct {
 	Password string
 }
 
+type Telegram struct {
+	Token string `yaml:"token"`
+	// AllowUsers are the telegram user ids which are served without approval
+	AllowUsers []int64 `yaml:"allowUsers"`
+	// AllowChats are the group chat ids whose members are all served
+	AllowChats []int64 `yaml:"allowChats"`
+	// Admins are the telegram user ids which approve and ban users by /approve and /ban
+	Admins []int64 `yaml:"admins"`
+	// Aliases are extra model commands of the bot, like gpt4: gpt-4-1106-preview
+	Aliases map[string]string `yaml:"aliases"`
+	// Mode is polling or webhook, by default the bot polls, the webhook is set to Service.URL
+	Mode string `yaml:"mode"`
+	// SecretToken verifies the webhook requests, it is required by the webhook mode
+	SecretToken string `yaml:"secretToken"`
+	// Workers is the number of updates processed at the same time, the updates of a chat are processed in order
+	Workers int `yaml:"workers"`
+}
+
+type Discord struct {
+	Token string `yaml:"token"`
+	// GuildIds are the guilds the slash commands are registered to, empty registers them globally
+	GuildIds []string `yaml:"guildIds"`
+	// Model is the model of guilds and direct messages without one chosen by /model
+	Model string `yaml:"model"`
+	// AllowUnlinked serves users who did not link their api key by /link with the llm keys of the server
+	AllowUnlinked bool `yaml:"allowUnlinked"`
+}
+
+type Slack struct {
+	// BotToken is the xoxb token of the bot
+	BotToken string `yaml:"botToken"`
+	// SigningSecret verifies the requests of the events api and the slash commands
+	SigningSecret string `yaml:"signingSecret"`
+	// AppToken is the xapp token of socket mode
+	AppToken string `yaml:"appToken"`
+	// Mode is events or socket, by default socket mode is used in dev and the events api otherwise
+	Mode string `yaml:"mode"`
+	// Model is the model of new conversations
+	Model string `yaml:"model"`
+	// AllowUnmapped serves slack users who are not mapped to PocketBase users
+	AllowUnmapped bool `yaml:"allowUnmapped"`
+}
+
 type ReadEase struct {
 	TelegramChannel int64 `yaml:"telegramChannel"`
 	TopStoriesCnt   int   `yaml:"topStoriesCnt"`
@@ -66,3 +125,40 @@ type AWSConfig struct {
 	AccessKeyId     string
 	SecretAccessKey string
 }
+
+type SemanticCache struct {
+	// Enabled is the global switch, api keys still need semantic_cache enabled
+	Enabled bool
+	// EmbeddingModel must be one of the embedding_models of the llm configs
+	EmbeddingModel string `yaml:"embeddingModel"`
+	// Threshold is the minimal cosine similarity for a cache hit
+	Threshold float32
+	// TTL is how long a cached answer stays valid, zero means forever
+	TTL time.Duration
+	// MaxEntries bounds the cached answers kept in memory, the oldest are evicted beyond it
+	MaxEntries int `yaml:"maxEntries"`
+	// Telegram enables the semantic cache for the telegram bot
+	Telegram bool
+}
+
+type Audit struct {
+	Enabled bool
+	// RetentionDays is how many days audit records are kept, zero means forever
+	RetentionDays int `yaml:"retentionDays"`
+	// RedactPII redacts emails, phone numbers and credit cards, secrets are always redacted
+	RedactPII bool `yaml:"redactPII"`
+	// RedactRules are extra regexp rules applied to requests, responses and errors
+	RedactRules []redact.Rule `yaml:"redactRules"`
+}
+
+type Speech struct {
+	// Stt is the speech to text backend, voice messages are ignored without it
+	Stt speech.Config `yaml:"stt"`
+	// Tts is the text to speech backend of voice replies
+	Tts speech.Config `yaml:"tts"`
+}
+
+type Vault struct {
+	// MasterKeyFile is the file of the base64 master key, the env APP_VAULT_MASTER_KEY takes precedence
+	MasterKeyFile string `yaml:"masterKeyFile"`
+}
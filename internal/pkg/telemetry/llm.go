@@ -0,0 +1,127 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware wraps cli so that every upstream call is traced and measured.
+func Middleware(cfg llmconfig.Config, cli llm.Client) llm.Client {
+	c := &instrument{provider: cfg.LLMType.String()}
+	return &client{
+		Client: llm.Wrap(cli, llm.Interceptor{
+			Unary:  c.unary,
+			Stream: c.stream,
+		}),
+		instrument: c,
+	}
+}
+
+type instrument struct {
+	provider string
+}
+
+// client traces embeddings which are not covered by interceptors
+type client struct {
+	llm.Client
+	*instrument
+}
+
+func (c *instrument) startSpan(ctx context.Context, name string, req llm.ChatCompletionRequest) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("llm.provider", c.provider),
+		attribute.String("llm.model", req.Model),
+		attribute.Bool("llm.stream", req.Stream),
+	))
+}
+
+func (c *instrument) unary(ctx context.Context, req llm.ChatCompletionRequest, next llm.UnaryInvoker) (llm.ChatCompletionResponse, error) {
+	ctx, span := c.startSpan(ctx, "llm.chat_completion", req)
+	defer span.End()
+
+	start := time.Now()
+	resp, err := next(ctx, req)
+	c.observe(span, req, resp.Usage, err, time.Since(start), 0)
+	return resp, err
+}
+
+func (c *instrument) stream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error, next llm.StreamInvoker) {
+	ctx, span := c.startSpan(ctx, "llm.chat_completion_stream", req)
+	defer span.End()
+
+	// tokens are only measured for providers which report the usage of streams
+	start := time.Now()
+	var ttft time.Duration
+	var acc llm.StreamAccumulator
+	llm.RelayStream(ctx, req, dataChan, errChan, next, func(data llm.ChatCompletionStreamResponse) {
+		acc.Add(data)
+		if ttft == 0 && acc.Content() != "" {
+			ttft = time.Since(start)
+			span.AddEvent("first_token")
+		}
+	}, func(err error) {
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		c.observe(span, req, acc.Response().Usage, err, time.Since(start), ttft)
+	})
+}
+
+func (c *client) CreateEmbeddings(ctx context.Context, req llm.EmbeddingRequest) (llm.EmbeddingResponse, error) {
+	ctx, span := Tracer().Start(ctx, "llm.embeddings", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("llm.provider", c.provider),
+		attribute.String("llm.model", req.Model),
+		attribute.Int("llm.inputs", len(req.Input)),
+	))
+	defer span.End()
+
+	resp, err := c.Client.(llm.Embedder).CreateEmbeddings(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return resp, err
+}
+
+func (c *client) DescribeModels() []llm.Model {
+	return llm.DescribeModels(c.Client)
+}
+
+func (c *instrument) observe(span trace.Span, req llm.ChatCompletionRequest, usage llm.Usage, err error, latency, ttft time.Duration) {
+	stream := strconv.FormatBool(req.Stream)
+	llmRequests.WithLabelValues(c.provider, req.Model, stream, status(err)).Inc()
+	llmDuration.WithLabelValues(c.provider, req.Model, stream).Observe(latency.Seconds())
+	if err != nil {
+		llmErrors.WithLabelValues(c.provider, req.Model).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	if ttft > 0 {
+		llmTimeToFirstToken.WithLabelValues(c.provider, req.Model).Observe(ttft.Seconds())
+		span.SetAttributes(attribute.Int64("llm.time_to_first_token_ms", ttft.Milliseconds()))
+	}
+	if usage == (llm.Usage{}) {
+		return
+	}
+	llmTokens.WithLabelValues(c.provider, req.Model, "prompt").Add(float64(usage.PromptTokens))
+	llmTokens.WithLabelValues(c.provider, req.Model, "completion").Add(float64(usage.CompletionTokens))
+	if generation := latency - ttft; usage.CompletionTokens > 0 && generation > 0 {
+		llmTokensPerSecond.WithLabelValues(c.provider, req.Model).Observe(float64(usage.CompletionTokens) / generation.Seconds())
+	}
+	span.SetAttributes(
+		attribute.Int("llm.usage.prompt_tokens", usage.PromptTokens),
+		attribute.Int("llm.usage.completion_tokens", usage.CompletionTokens),
+		attribute.Int("llm.usage.total_tokens", usage.TotalTokens),
+	)
+}
@@ -0,0 +1,174 @@
+package telemetry
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "aienvoy"
+
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+)
+
+var (
+	llmRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "requests_total",
+		Help:      "Total number of upstream llm requests.",
+	}, []string{"provider", "model", "stream", "status"})
+
+	llmErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "errors_total",
+		Help:      "Total number of failed upstream llm requests.",
+	}, []string{"provider", "model"})
+
+	llmDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of upstream llm requests.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"provider", "model", "stream"})
+
+	llmTimeToFirstToken = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "time_to_first_token_seconds",
+		Help:      "Time to the first streamed token of upstream llm requests.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+	}, []string{"provider", "model"})
+
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "tokens_total",
+		Help:      "Total number of tokens, type is prompt or completion.",
+	}, []string{"provider", "model", "type"})
+
+	llmTokensPerSecond = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "tokens_per_second",
+		Help:      "Completion tokens generated per second.",
+		Buckets:   []float64{1, 5, 10, 20, 40, 60, 80, 100, 150, 200},
+	}, []string{"provider", "model"})
+
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of http requests.",
+	}, []string{"method", "route", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of http requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	telegramUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "telegram",
+		Name:      "updates_total",
+		Help:      "Total number of handled telegram updates.",
+	}, []string{"status"})
+
+	telegramDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "telegram",
+		Name:      "update_duration_seconds",
+		Help:      "Latency of handling telegram updates.",
+		Buckets:   []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120},
+	}, []string{"status"})
+
+	discordEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "discord",
+		Name:      "events_total",
+		Help:      "Total number of handled discord events, kind is interaction or message.",
+	}, []string{"kind", "status"})
+
+	discordDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "discord",
+		Name:      "event_duration_seconds",
+		Help:      "Latency of handling discord events.",
+		Buckets:   []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120},
+	}, []string{"kind", "status"})
+
+	slackEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "slack",
+		Name:      "events_total",
+		Help:      "Total number of handled slack events, kind is event or command.",
+	}, []string{"kind", "status"})
+
+	slackDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "slack",
+		Name:      "event_duration_seconds",
+		Help:      "Latency of handling slack events.",
+		Buckets:   []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120},
+	}, []string{"kind", "status"})
+
+	jobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "runs_total",
+		Help:      "Total number of scheduled job runs.",
+	}, []string{"job", "status"})
+
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "duration_seconds",
+		Help:      "Duration of scheduled job runs.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800},
+	}, []string{"job"})
+)
+
+func status(err error) string {
+	if err != nil {
+		return StatusError
+	}
+	return StatusSuccess
+}
+
+// ObserveHTTP records a finished http request, route is the matched route path.
+func ObserveHTTP(method, route, code string, latency time.Duration) {
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(latency.Seconds())
+}
+
+// ObserveTelegram records a handled telegram update.
+func ObserveTelegram(err error, latency time.Duration) {
+	telegramUpdates.WithLabelValues(status(err)).Inc()
+	telegramDuration.WithLabelValues(status(err)).Observe(latency.Seconds())
+}
+
+// ObserveDiscord records a handled discord interaction or message.
+func ObserveDiscord(kind string, err error, latency time.Duration) {
+	discordEvents.WithLabelValues(kind, status(err)).Inc()
+	discordDuration.WithLabelValues(kind, status(err)).Observe(latency.Seconds())
+}
+
+// ObserveSlack records a handled slack event or slash command.
+func ObserveSlack(kind string, err error, latency time.Duration) {
+	slackEvents.WithLabelValues(kind, status(err)).Inc()
+	slackDuration.WithLabelValues(kind, status(err)).Observe(latency.Seconds())
+}
+
+// ObserveJob records a scheduled job run.
+func ObserveJob(job string, err error, latency time.Duration) {
+	jobRuns.WithLabelValues(job, status(err)).Inc()
+	jobDuration.WithLabelValues(job).Observe(latency.Seconds())
+}
@@ -0,0 +1,74 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName           = "github.com/Vaayne/aienvoy"
+	defaultAxiomEndpoint = "api.axiom.co"
+)
+
+// Tracer returns the tracer of the global trace provider, spans are dropped until Init is called.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// MetricsHandler serves the prometheus metrics.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Init exports traces to axiom over OTLP when the axiom token is set,
+// the returned shutdown flushes the pending spans.
+func Init(ctx context.Context, service config.ServiceConfig, axiom config.Axiom) (func(context.Context) error, error) {
+	if axiom.Token == "" {
+		slog.Info("axiom token is empty, skip exporting traces")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint := axiom.Endpoint
+	if endpoint == "" {
+		endpoint = defaultAxiomEndpoint
+	}
+	exporter, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpoint(endpoint),
+		otlptracehttp.WithHeaders(map[string]string{
+			"Authorization":   "Bearer " + axiom.Token,
+			"X-Axiom-Dataset": axiom.Dataset,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(service.Name),
+		semconv.DeploymentEnvironment(service.Env),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	slog.Info("start exporting traces", "endpoint", endpoint, "dataset", axiom.Dataset)
+	return tp.Shutdown, nil
+}
@@ -0,0 +1,50 @@
+package discordbot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/bwmarrin/discordgo"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+const platformDiscord = "discord"
+
+var errNotLinked = errors.New("discord user is not linked")
+
+// authenticate puts the PocketBase user and api key of a linked user into ctx, the models and usage of
+// the key apply to the user. Users who are not linked get errNotLinked unless allowUnlinked is set.
+func authenticate(ctx context.Context, user *discordgo.User) (context.Context, error) {
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	chatUser, err := auth.GetChatUser(ctx, tx, platformDiscord, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return ctx, fmt.Errorf("get discord user error: %w", err)
+	}
+	if linked, _, ok := auth.WithChatUser(ctx, tx, chatUser); ok {
+		return linked, nil
+	}
+	if config.GetConfig().Discord.AllowUnlinked {
+		return ctx, nil
+	}
+	return ctx, errNotLinked
+}
+
+// onLink binds the user to the PocketBase user of an api key, the reply is only shown to the user.
+func (b *Bot) onLink(ctx context.Context, i *discordgo.Interaction, user *discordgo.User, apiKey string) error {
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	chatUser, err := auth.GetChatUser(ctx, tx, platformDiscord, user.ID)
+	if err != nil {
+		chatUser = auth.ChatUser{Platform: platformDiscord, ExternalId: user.ID}
+	}
+	chatUser.Username = user.Username
+	if _, err := auth.LinkChatUser(ctx, tx, chatUser, apiKey); err != nil {
+		slog.Info("link discord user error", "err", err, "discord_id", user.ID)
+		return b.respond(i, "Invalid api key", true)
+	}
+	return b.respond(i, "Your discord account is linked, your messages are served with your api key now", true)
+}
@@ -0,0 +1,55 @@
+package discordbot
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/bwmarrin/discordgo"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// newTestContext returns a context with the dao of an empty chat_users table
+func newTestContext(t *testing.T) context.Context {
+	db, err := dbx.Open("sqlite", filepath.Join(t.TempDir(), "data.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.NewQuery("CREATE TABLE " + auth.TableChatUsers + " (id TEXT PRIMARY KEY, created TEXT, updated TEXT, " +
+		"platform TEXT, external_id TEXT, username TEXT, status TEXT, user_id TEXT, api_key_id TEXT)").Execute()
+	require.NoError(t, err)
+	return context.WithValue(context.Background(), config.ContextKeyDao, daos.New(db))
+}
+
+func setDiscordConfig(t *testing.T, discord config.Discord) {
+	previous := config.GetConfig()
+	cfg := *previous
+	cfg.Discord = discord
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(previous) })
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name    string
+		discord config.Discord
+		err     error
+	}{
+		// deployments without the setting must not serve unlinked users
+		{name: "unlinked users are refused by default", discord: config.Discord{}, err: errNotLinked},
+		{name: "unlinked users are allowed", discord: config.Discord{AllowUnlinked: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setDiscordConfig(t, tt.discord)
+			ctx := newTestContext(t)
+			_, err := authenticate(ctx, &discordgo.User{ID: "1", Username: "alice"})
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package discordbot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/internal/pkg/telemetry"
+)
+
+const (
+	kindInteraction = "interaction"
+	kindMessage     = "message"
+)
+
+type Bot struct {
+	*discordgo.Session
+	app *pocketbase.PocketBase
+}
+
+func New(token string, app *pocketbase.PocketBase) (*Bot, error) {
+	s, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, fmt.Errorf("new discord session error: %w", err)
+	}
+	// message content is a privileged intent, it has to be enabled in the developer portal too
+	s.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages |
+		discordgo.IntentsDirectMessages | discordgo.IntentMessageContent
+	return &Bot{Session: s, app: app}, nil
+}
+
+// Serve connects the bot in the background, the slash commands are registered once it is ready.
+func Serve(app *pocketbase.PocketBase) {
+	b, err := New(config.GetConfig().Discord.Token, app)
+	if err != nil {
+		slog.Error("Init discord bot error", "err", err)
+		return
+	}
+	b.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
+		b.registerCommands(r.Application.ID)
+	})
+	b.AddHandler(b.onInteraction)
+	b.AddHandler(b.onMessage)
+
+	go func() {
+		if err := b.Open(); err != nil {
+			slog.Error("open discord bot connection error", "err", err)
+			return
+		}
+		slog.Info("Start discord bot...")
+	}()
+	app.OnTerminate().Add(func(e *core.TerminateEvent) error {
+		return b.Close()
+	})
+}
+
+func (b *Bot) registerCommands(appID string) {
+	guildIds := config.GetConfig().Discord.GuildIds
+	if len(guildIds) == 0 {
+		// global commands take up to an hour to show up
+		guildIds = []string{""}
+	}
+	for _, guildID := range guildIds {
+		if _, err := b.ApplicationCommandBulkOverwrite(appID, guildID, commands); err != nil {
+			slog.Error("register discord commands error", "err", err, "guild", guildID)
+		} else {
+			slog.Info("success register discord commands", "guild", guildID)
+		}
+	}
+}
+
+// handle runs fn for an event of user and records its span and metrics.
+func (b *Bot) handle(kind string, user *discordgo.User, fn func(ctx context.Context) error) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, config.ContextKeyApp, b.app)
+	ctx = context.WithValue(ctx, config.ContextKeyDao, b.app.Dao())
+	ctx = context.WithValue(ctx, config.ContextKeyUserId, user.ID)
+	ctx = context.WithValue(ctx, config.ContextKeyRequestId, uuid.NewString())
+	ctx, span := telemetry.Tracer().Start(ctx, "discord."+kind, trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	start := time.Now()
+	err := fn(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.ErrorContext(ctx, "handle discord event error", "err", err, "kind", kind)
+	}
+	telemetry.ObserveDiscord(kind, err, time.Since(start))
+}
+
+func (b *Bot) onInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i.Interaction)
+	if user == nil || user.Bot {
+		return
+	}
+	b.handle(kindInteraction, user, func(ctx context.Context) error {
+		switch i.Type {
+		case discordgo.InteractionApplicationCommandAutocomplete:
+			return b.onAutocomplete(ctx, i.Interaction)
+		case discordgo.InteractionApplicationCommand:
+			return b.onCommand(ctx, i.Interaction, user)
+		}
+		return nil
+	})
+}
+
+// onMessage continues the conversations of the threads of the bot and of direct messages
+func (b *Bot) onMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot || m.Content == "" {
+		return
+	}
+	b.handle(kindMessage, m.Author, func(ctx context.Context) error {
+		return b.onThreadMessage(ctx, m.Message)
+	})
+}
+
+// interactionUser returns the user of an interaction, it is the member user in guilds
+func interactionUser(i *discordgo.Interaction) *discordgo.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
+}
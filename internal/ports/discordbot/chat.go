@@ -0,0 +1,207 @@
+package discordbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/core/chatstate"
+	"github.com/Vaayne/aienvoy/internal/core/llms"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/pkg/chatstream"
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/bwmarrin/discordgo"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+const (
+	// threadNameLength is the limit of the names of discord threads
+	threadNameLength = 100
+	// threadArchiveMinutes archives the threads of conversations after a day without messages
+	threadArchiveMinutes = 24 * 60
+)
+
+// guildKey keys the model of a server, direct messages are keyed by their channel
+func guildKey(guildID, channelID string) chatstate.Key {
+	if guildID == "" {
+		return chatstate.Key{Platform: platformDiscord, ChatId: channelID}
+	}
+	return chatstate.Key{Platform: platformDiscord, ChatId: guildID}
+}
+
+// threadKey keys the conversation of a thread, direct messages have one active conversation
+func threadKey(guildID, channelID string) chatstate.Key {
+	key := guildKey(guildID, channelID)
+	if guildID != "" {
+		key.ThreadId = channelID
+	}
+	return key
+}
+
+// model returns the model of a server or direct messages, the model of the config is the default.
+func (b *Bot) model(ctx context.Context, key chatstate.Key) string {
+	state, err := chatstate.Get(ctx, ctx.Value(config.ContextKeyDao).(*daos.Dao), key)
+	if err == nil && state.Model != "" {
+		return state.Model
+	}
+	return config.GetConfig().Discord.Model
+}
+
+// onChat starts a new conversation, in server channels the prompt starts a thread and the answer streams into it.
+func (b *Bot) onChat(ctx context.Context, i *discordgo.Interaction, user *discordgo.User, prompt, model string) error {
+	if model == "" {
+		model = b.model(ctx, guildKey(i.GuildID, i.ChannelID))
+	}
+	if model == "" {
+		return b.respond(i, fmt.Sprintf("Please choose a model by /%s <name> first", CommandModel), true)
+	}
+	if err := b.deferResponse(i); err != nil {
+		return err
+	}
+
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	channelID := i.ChannelID
+	var out chatstream.Sink = &interactionSink{s: b.Session, i: i}
+	if i.GuildID != "" && !b.isThread(channelID) {
+		content := fmt.Sprintf("**%s**: %s", user.Username, prompt)
+		if pages := chatstream.Split(content, maxMessageLength); len(pages) > 1 {
+			content = pages[0] + "..."
+		}
+		msg, err := b.InteractionResponseEdit(i, &discordgo.WebhookEdit{Content: &content})
+		if err != nil {
+			return fmt.Errorf("edit discord interaction error: %w", err)
+		}
+		thread, err := b.MessageThreadStartComplex(channelID, msg.ID, &discordgo.ThreadStart{
+			Name:                threadName(prompt),
+			AutoArchiveDuration: threadArchiveMinutes,
+		})
+		if err != nil {
+			return fmt.Errorf("start discord thread error: %w", err)
+		}
+		channelID = thread.ID
+		out = &channelSink{s: b.Session, channelID: thread.ID}
+	}
+
+	state, err := chatstate.Get(ctx, tx, threadKey(i.GuildID, channelID))
+	if err != nil {
+		return fmt.Errorf("get chat state error: %w", err)
+	}
+	state.ConversationId = ""
+	return b.llmChat(ctx, state, model, prompt, out)
+}
+
+// onThreadMessage continues the conversation of a thread of the bot, or of direct messages
+func (b *Bot) onThreadMessage(ctx context.Context, m *discordgo.Message) error {
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	state, err := chatstate.Get(ctx, tx, threadKey(m.GuildID, m.ChannelID))
+	if err != nil {
+		return fmt.Errorf("get chat state error: %w", err)
+	}
+	// other channels and threads of the server are not for the bot
+	if m.GuildID != "" && state.ConversationId == "" {
+		return nil
+	}
+
+	ctx, err = authenticate(ctx, m.Author)
+	if errors.Is(err, errNotLinked) {
+		_, err = b.ChannelMessageSendReply(m.ChannelID, fmt.Sprintf("Please link your api key by /%s first", CommandLink), m.Reference())
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	model := state.Model
+	if model == "" {
+		model = b.model(ctx, guildKey(m.GuildID, m.ChannelID))
+	}
+	if model == "" {
+		_, err = b.ChannelMessageSendReply(m.ChannelID, fmt.Sprintf("Please choose a model by /%s <name> first", CommandModel), m.Reference())
+		return err
+	}
+	return b.llmChat(ctx, state, model, m.Content, &channelSink{s: b.Session, channelID: m.ChannelID})
+}
+
+// llmChat streams the answer of prompt to out, a new conversation is created when the state has none.
+func (b *Bot) llmChat(ctx context.Context, state chatstate.State, model, prompt string, out chatstream.Sink) error {
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	if !auth.ContextAllowsModel(ctx, model) {
+		_, err := out.Send(fmt.Sprintf("Model %s is not allowed for your api key", model))
+		return err
+	}
+	svc, err := llms.NewWithDao(model, llms.NewDao(tx))
+	if err != nil {
+		return fmt.Errorf("init llm service error: %w", err)
+	}
+	if state.ConversationId == "" {
+		if state, err = chatstate.NewConversation(ctx, tx, state, model, prompt); err != nil {
+			return err
+		}
+	} else if state.Model != model {
+		state.Model = model
+		if state, err = chatstate.Save(ctx, tx, state); err != nil {
+			return fmt.Errorf("save chat state error: %w", err)
+		}
+	}
+
+	stream := newStream(out, model)
+	if err := stream.Start(fmt.Sprintf("Waiting for %s ...", model)); err != nil {
+		return err
+	}
+	req := llm.ChatCompletionRequest{
+		Model: model,
+		Messages: []llm.ChatCompletionMessage{
+			{
+				Role:    llm.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+		Stream: true,
+	}
+
+	// answers which take longer are given up, like the answers of /read
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	respChan := make(chan llm.ChatCompletionStreamResponse)
+	defer close(respChan)
+	errChan := make(chan error)
+	defer close(errChan)
+	go svc.CreateMessageStream(ctx, state.ConversationId, req, respChan, errChan)
+
+	for {
+		select {
+		case resp := <-respChan:
+			stream.Process(resp.DeltaContent())
+		case err := <-errChan:
+			if errors.Is(err, io.EOF) || err == nil {
+				return stream.Finish(io.EOF)
+			}
+			return stream.Finish(err)
+		case <-ctx.Done():
+			return stream.Finish(ctx.Err())
+		}
+	}
+}
+
+// isThread reports if a channel is a thread, channels which are not in the state are fetched
+func (b *Bot) isThread(channelID string) bool {
+	channel, err := b.State.Channel(channelID)
+	if err != nil {
+		if channel, err = b.Channel(channelID); err != nil {
+			return false
+		}
+	}
+	return channel.IsThread()
+}
+
+func threadName(prompt string) string {
+	name := strings.Join(strings.Fields(prompt), " ")
+	if utf8.RuneCountInString(name) <= threadNameLength {
+		return name
+	}
+	return string([]rune(name)[:threadNameLength-3]) + "..."
+}
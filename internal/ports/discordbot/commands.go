@@ -0,0 +1,318 @@
+package discordbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/core/chatstate"
+	"github.com/Vaayne/aienvoy/internal/core/llms"
+	"github.com/Vaayne/aienvoy/internal/core/midjourney"
+	"github.com/Vaayne/aienvoy/internal/core/readease"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/bwmarrin/discordgo"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+const (
+	CommandChat    = "chat"
+	CommandModel   = "model"
+	CommandRead    = "read"
+	CommandImagine = "imagine"
+	CommandLink    = "link"
+
+	// maxChoices is the limit of the autocomplete choices of discord
+	maxChoices = 25
+	// readModel summarizes articles, like the readease of the telegram bot
+	readModel = "gemini-pro"
+)
+
+var commands = []*discordgo.ApplicationCommand{
+	{
+		Name:        CommandChat,
+		Description: "Chat with a model in a new conversation, in servers the conversation is a thread",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "prompt",
+				Description: "What to ask",
+				Required:    true,
+			},
+			{
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "model",
+				Description:  "The model of the conversation, defaults to the model of the server",
+				Autocomplete: true,
+			},
+		},
+	},
+	{
+		Name:        CommandModel,
+		Description: "Show or change the model of this server",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "name",
+				Description:  "The new model",
+				Autocomplete: true,
+			},
+		},
+	},
+	{
+		Name:        CommandRead,
+		Description: "ReadEase to summary article or video",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "url",
+				Description: "The url of the article or video",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        CommandImagine,
+		Description: "Generate image using midjourney",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "prompt",
+				Description: "The prompt of the image",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        CommandLink,
+		Description: "Link your api key to use the bot as your user",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "api_key",
+				Description: "Your api key, only you see the reply",
+				Required:    true,
+			},
+		},
+	},
+}
+
+func (b *Bot) onCommand(ctx context.Context, i *discordgo.Interaction, user *discordgo.User) error {
+	data := i.ApplicationCommandData()
+	opts := options(data)
+	if data.Name == CommandLink {
+		return b.onLink(ctx, i, user, opts["api_key"])
+	}
+
+	ctx, err := authenticate(ctx, user)
+	if errors.Is(err, errNotLinked) {
+		return b.respond(i, fmt.Sprintf("Please link your api key by /%s first", CommandLink), true)
+	} else if err != nil {
+		return err
+	}
+
+	switch data.Name {
+	case CommandChat:
+		return b.onChat(ctx, i, user, opts["prompt"], opts["model"])
+	case CommandModel:
+		return b.onModel(ctx, i, opts["name"])
+	case CommandRead:
+		return b.onRead(ctx, i, opts["url"])
+	case CommandImagine:
+		return b.onImagine(ctx, i, opts["prompt"])
+	}
+	return nil
+}
+
+// onAutocomplete suggests the served models which contain the typed text
+func (b *Bot) onAutocomplete(ctx context.Context, i *discordgo.Interaction) error {
+	typed := ""
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Focused {
+			typed = strings.ToLower(opt.StringValue())
+		}
+	}
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, maxChoices)
+	for _, model := range llms.Models(llms.NewDao(ctx.Value(config.ContextKeyDao).(*daos.Dao))) {
+		if len(choices) == maxChoices {
+			break
+		}
+		if strings.Contains(strings.ToLower(model), typed) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: model, Value: model})
+		}
+	}
+	return b.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+}
+
+// onModel changes the model of the server, only members who can manage the server may change it.
+// In direct messages it is the model of the user.
+func (b *Bot) onModel(ctx context.Context, i *discordgo.Interaction, name string) error {
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	key := guildKey(i.GuildID, i.ChannelID)
+	if name == "" {
+		model := b.model(ctx, key)
+		if model == "" {
+			return b.respond(i, fmt.Sprintf("No model is chosen yet, please choose one by /%s <name>", CommandModel), true)
+		}
+		return b.respond(i, fmt.Sprintf("The model is %s, change it by /%s <name>", model, CommandModel), true)
+	}
+	if i.Member != nil && i.Member.Permissions&discordgo.PermissionManageServer == 0 {
+		return b.respond(i, "Only members who can manage the server can change its model", true)
+	}
+	if !slices.Contains(llms.Models(llms.NewDao(tx)), name) {
+		return b.respond(i, fmt.Sprintf("Unknown model %s", name), true)
+	}
+	if !auth.ContextAllowsModel(ctx, name) {
+		return b.respond(i, fmt.Sprintf("Model %s is not allowed for your api key", name), true)
+	}
+
+	state, err := chatstate.Get(ctx, tx, key)
+	if err != nil {
+		return fmt.Errorf("get chat state error: %w", err)
+	}
+	state.Model = name
+	if _, err := chatstate.Save(ctx, tx, state); err != nil {
+		return fmt.Errorf("save chat state error: %w", err)
+	}
+	return b.respond(i, fmt.Sprintf("The model is %s now", name), false)
+}
+
+// onRead streams the readease summary of an article or video
+func (b *Bot) onRead(ctx context.Context, i *discordgo.Interaction, urlStr string) error {
+	urlStr = strings.TrimSpace(urlStr)
+	if _, err := url.ParseRequestURI(urlStr); err != nil || !strings.HasPrefix(urlStr, "http") {
+		return b.respond(i, fmt.Sprintf("invalid url %s, please check and try again", urlStr), true)
+	}
+	if err := b.deferResponse(i); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	stream := newStream(&interactionSink{s: b.Session, i: i}, urlStr)
+	if err := stream.Start("please wait a moment, I am reading the article..."); err != nil {
+		return err
+	}
+
+	respChan := make(chan llm.ChatCompletionStreamResponse)
+	errChan := make(chan error)
+	defer close(respChan)
+	defer close(errChan)
+	go readease.NewReader(b.app).ReadStream(ctx, urlStr, readModel, respChan, errChan)
+
+	for {
+		select {
+		case resp := <-respChan:
+			stream.Process(resp.DeltaContent())
+		case err := <-errChan:
+			return stream.Finish(err)
+		case <-ctx.Done():
+			return stream.Finish(ctx.Err())
+		}
+	}
+}
+
+// onImagine generates an image by the midjourney bot, the progress of the job is shown until the image is done.
+func (b *Bot) onImagine(ctx context.Context, i *discordgo.Interaction, prompt string) error {
+	if config.GetConfig().MidJourney.DiscordBotToken == "" {
+		return b.respond(i, "Midjourney is not configured", true)
+	}
+	if err := b.deferResponse(i); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	mj := midjourney.GetDefaultClient()
+	job, err := mj.ProcessMessage(&midjourney.JobMessage{
+		Action:  midjourney.MJGenerate,
+		Prompt:  prompt,
+		Channel: mj.Client.ChannelID,
+	})
+	if err != nil {
+		return b.editResponse(i, fmt.Sprintf("create midjourney job error: %s", err))
+	}
+	if err := b.editResponse(i, "MidJourney job started, please wait ..."); err != nil {
+		return err
+	}
+
+	jobId := job.Id
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return b.editResponse(i, "time out error")
+		case <-ticker.C:
+			job, err = midjourney.GetJobRecord(mj.Dao, jobId)
+			if err != nil {
+				return b.editResponse(i, fmt.Sprintf("could not get midjourney job record by id %s", jobId))
+			}
+			switch *job.Status {
+			case midjourney.StatusProcessing:
+				if job.MessageContent != nil {
+					if err := b.editResponse(i, *job.MessageContent); err != nil {
+						slog.Warn("edit discord message error", "err", err)
+					}
+				}
+			case midjourney.StatusCompleted:
+				content := prompt
+				_, err := b.InteractionResponseEdit(i, &discordgo.WebhookEdit{
+					Content: &content,
+					Embeds:  &[]*discordgo.MessageEmbed{{Image: &discordgo.MessageEmbedImage{URL: *job.ImageUrl}}},
+				})
+				return err
+			case midjourney.StatusFailed:
+				return b.editResponse(i, "MidJourney job error")
+			}
+		}
+	}
+}
+
+// respond answers an interaction at once, ephemeral answers are only shown to the user
+func (b *Bot) respond(i *discordgo.Interaction, content string, ephemeral bool) error {
+	data := &discordgo.InteractionResponseData{Content: content}
+	if ephemeral {
+		data.Flags = discordgo.MessageFlagsEphemeral
+	}
+	return b.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: data,
+	})
+}
+
+// deferResponse acknowledges an interaction, discord drops interactions which are not answered in 3 seconds
+func (b *Bot) deferResponse(i *discordgo.Interaction) error {
+	err := b.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		return fmt.Errorf("defer discord interaction error: %w", err)
+	}
+	return nil
+}
+
+func (b *Bot) editResponse(i *discordgo.Interaction, content string) error {
+	_, err := b.InteractionResponseEdit(i, &discordgo.WebhookEdit{Content: &content})
+	return err
+}
+
+// options returns the string options of a command by name
+func options(data discordgo.ApplicationCommandInteractionData) map[string]string {
+	opts := make(map[string]string, len(data.Options))
+	for _, opt := range data.Options {
+		if opt.Type == discordgo.ApplicationCommandOptionString {
+			opts[opt.Name] = strings.TrimSpace(opt.StringValue())
+		}
+	}
+	return opts
+}
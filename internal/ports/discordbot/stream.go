@@ -0,0 +1,72 @@
+package discordbot
+
+import (
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/chatstream"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// maxMessageLength is the limit of discord messages
+	maxMessageLength = 2000
+	// editInterval keeps the edits of a streamed answer below the rate limit of discord
+	editInterval = 1500 * time.Millisecond
+	// originalMessage is the id of the interaction response in the sinks of interactions
+	originalMessage = "@original"
+)
+
+// newStream streams an answer to out, the footer is shown in italics below the finished answer
+func newStream(out chatstream.Sink, footer string) *chatstream.Stream {
+	stream := chatstream.New(out, maxMessageLength, editInterval)
+	stream.Footer = "-- *" + footer + "*"
+	return stream
+}
+
+// interactionSink streams to the response of an interaction, the first message is the deferred response.
+type interactionSink struct {
+	s       *discordgo.Session
+	i       *discordgo.Interaction
+	started bool
+}
+
+func (t *interactionSink) Send(content string) (string, error) {
+	if !t.started {
+		t.started = true
+		return originalMessage, t.Edit(originalMessage, content)
+	}
+	msg, err := t.s.FollowupMessageCreate(t.i, true, &discordgo.WebhookParams{Content: content})
+	if err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+func (t *interactionSink) Edit(id, content string) error {
+	var err error
+	if id == originalMessage {
+		_, err = t.s.InteractionResponseEdit(t.i, &discordgo.WebhookEdit{Content: &content})
+	} else {
+		_, err = t.s.FollowupMessageEdit(t.i, id, &discordgo.WebhookEdit{Content: &content})
+	}
+	return err
+}
+
+// channelSink streams to plain messages of a channel or thread
+type channelSink struct {
+	s         *discordgo.Session
+	channelID string
+}
+
+func (t *channelSink) Send(content string) (string, error) {
+	msg, err := t.s.ChannelMessageSend(t.channelID, content)
+	if err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+func (t *channelSink) Edit(id, content string) error {
+	_, err := t.s.ChannelMessageEdit(t.channelID, id, content)
+	return err
+}
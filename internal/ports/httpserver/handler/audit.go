@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Vaayne/aienvoy/internal/core/audit"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+type AuditHandler struct{}
+
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{}
+}
+
+func (h *AuditHandler) ListAudits(c echo.Context) error {
+	ctx := c.Request().Context()
+	limit, _ := strconv.ParseInt(c.QueryParam("limit"), 10, 64)
+	offset, _ := strconv.ParseInt(c.QueryParam("offset"), 10, 64)
+	records, err := audit.ListRecords(ctx, c.Get(config.ContextKeyDao).(*daos.Dao), audit.ListFilter{
+		RequestId: c.QueryParam("request_id"),
+		Provider:  c.QueryParam("provider"),
+		Model:     c.QueryParam("model"),
+		UserId:    c.QueryParam("user_id"),
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, records)
+}
+
+func (h *AuditHandler) GetAudit(c echo.Context) error {
+	ctx := c.Request().Context()
+	record, err := audit.GetRecord(ctx, c.Get(config.ContextKeyDao).(*daos.Dao), c.PathParam("id"))
+	if err != nil {
+		return c.String(http.StatusNotFound, err.Error())
+	}
+	return c.JSON(http.StatusOK, record)
+}
+
+type ReplayAuditRequest struct {
+	// Model to replay the recorded request, empty means the recorded model
+	Model string `json:"model"`
+}
+
+func (h *AuditHandler) ReplayAudit(c echo.Context) error {
+	ctx := c.Request().Context()
+	req := new(ReplayAuditRequest)
+	if err := c.Bind(req); err != nil {
+		slog.ErrorContext(ctx, "bind replay audit request body error", "err", err.Error())
+		return c.String(http.StatusBadRequest, "bad request")
+	}
+
+	result, err := audit.Replay(ctx, c.Get(config.ContextKeyDao).(*daos.Dao), c.PathParam("id"), req.Model)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, result)
+}
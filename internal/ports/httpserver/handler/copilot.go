@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/Vaayne/aienvoy/internal/core/copilot"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/labstack/echo/v5"
+)
+
+type CopilotHandler struct {
+	login *copilot.Login
+}
+
+func NewCopilotHandler(login *copilot.Login) *CopilotHandler {
+	return &CopilotHandler{login: login}
+}
+
+type CopilotLoginRequest struct {
+	// Account is the name of the login, it matches github_copilot.account of llm configs
+	Account string `json:"account"`
+}
+
+// Login starts a device flow login, admins open the verification uri and enter the user code.
+func (h *CopilotHandler) Login(c echo.Context) error {
+	ctx := c.Request().Context()
+	req := new(CopilotLoginRequest)
+	if err := c.Bind(req); err != nil {
+		slog.ErrorContext(ctx, "bind copilot login request body error", "err", err.Error())
+		return c.String(http.StatusBadRequest, "bad request")
+	}
+	if req.Account == "" {
+		req.Account = llmconfig.DefaultGithubCopilotAccount
+	}
+
+	state, err := h.login.Start(ctx, req.Account)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, state)
+}
+
+func (h *CopilotHandler) GetLogin(c echo.Context) error {
+	account := c.QueryParam("account")
+	if account == "" {
+		account = llmconfig.DefaultGithubCopilotAccount
+	}
+	state, ok := h.login.Status(account)
+	if !ok {
+		return c.String(http.StatusNotFound, "login not found")
+	}
+	return c.JSON(http.StatusOK, state)
+}
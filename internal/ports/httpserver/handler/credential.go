@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Vaayne/aienvoy/internal/core/credential"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+type CredentialHandler struct {
+	// vault is nil when the master key is not configured
+	vault *credential.Vault
+}
+
+func NewCredentialHandler(vault *credential.Vault) *CredentialHandler {
+	return &CredentialHandler{vault: vault}
+}
+
+type AddCredentialRequest struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+type RotateCredentialRequest struct {
+	Value string `json:"value"`
+}
+
+func (h *CredentialHandler) ListCredentials(c echo.Context) error {
+	if h.vault == nil {
+		return vaultDisabled(c)
+	}
+	credentials, err := h.vault.List(c.Request().Context())
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, credentials)
+}
+
+func (h *CredentialHandler) AddCredential(c echo.Context) error {
+	if h.vault == nil {
+		return vaultDisabled(c)
+	}
+	ctx := withActor(c)
+	req := new(AddCredentialRequest)
+	if err := c.Bind(req); err != nil {
+		slog.ErrorContext(ctx, "bind add credential request body error", "err", err.Error())
+		return c.String(http.StatusBadRequest, "bad request")
+	}
+	if req.Value == "" {
+		return c.String(http.StatusBadRequest, "value is required")
+	}
+
+	cred, err := h.vault.Add(ctx, req.Name, req.Value, req.Description)
+	if errors.Is(err, credential.ErrExists) {
+		return c.String(http.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	// settings which referred to the missing credential resolve now
+	config.ReloadLater()
+	return c.JSON(http.StatusCreated, cred)
+}
+
+func (h *CredentialHandler) RotateCredential(c echo.Context) error {
+	if h.vault == nil {
+		return vaultDisabled(c)
+	}
+	ctx := withActor(c)
+	req := new(RotateCredentialRequest)
+	if err := c.Bind(req); err != nil {
+		slog.ErrorContext(ctx, "bind rotate credential request body error", "err", err.Error())
+		return c.String(http.StatusBadRequest, "bad request")
+	}
+	if req.Value == "" {
+		return c.String(http.StatusBadRequest, "value is required")
+	}
+
+	cred, err := h.vault.Rotate(ctx, c.PathParam("name"), req.Value)
+	if err != nil {
+		return credentialError(c, err)
+	}
+	// pick up the rotated value in clients and bots
+	config.ReloadLater()
+	return c.JSON(http.StatusOK, cred)
+}
+
+func (h *CredentialHandler) RevokeCredential(c echo.Context) error {
+	if h.vault == nil {
+		return vaultDisabled(c)
+	}
+	ctx := withActor(c)
+	cred, err := h.vault.Revoke(ctx, c.PathParam("name"))
+	if err != nil {
+		return credentialError(c, err)
+	}
+	config.ReloadLater()
+	return c.JSON(http.StatusOK, cred)
+}
+
+func (h *CredentialHandler) ListAudits(c echo.Context) error {
+	if h.vault == nil {
+		return vaultDisabled(c)
+	}
+	limit, _ := strconv.ParseInt(c.QueryParam("limit"), 10, 64)
+	offset, _ := strconv.ParseInt(c.QueryParam("offset"), 10, 64)
+	audits, err := h.vault.ListAudits(c.Request().Context(), credential.ListFilter{
+		Name:   c.QueryParam("name"),
+		Action: c.QueryParam("action"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, audits)
+}
+
+// withActor records the admin of the request in the credential audits
+func withActor(c echo.Context) context.Context {
+	ctx := c.Request().Context()
+	if admin, ok := c.Get(apis.ContextAdminKey).(*models.Admin); ok {
+		return credential.WithActor(ctx, admin.Email)
+	}
+	return ctx
+}
+
+func credentialError(c echo.Context, err error) error {
+	if errors.Is(err, credential.ErrNotFound) {
+		return c.String(http.StatusNotFound, err.Error())
+	}
+	return c.String(http.StatusInternalServerError, err.Error())
+}
+
+func vaultDisabled(c echo.Context) error {
+	return c.String(http.StatusServiceUnavailable, "vault is disabled, the master key is not configured")
+}
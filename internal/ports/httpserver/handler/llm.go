@@ -22,6 +22,20 @@ func NewLLMHandler() *LLMHandler {
 	return &LLMHandler{}
 }
 
+type ListModelsResponse struct {
+	Object string      `json:"object"`
+	Data   []llm.Model `json:"data"`
+}
+
+// ListModels lists the served models in the shape of the OpenAI models api, with context length and pricing.
+func (l *LLMHandler) ListModels(c echo.Context) error {
+	models := llms.DescribeModels(llms.NewDao(c.Get(config.ContextKeyDao).(*daos.Dao)))
+	return c.JSON(http.StatusOK, ListModelsResponse{
+		Object: "list",
+		Data:   models,
+	})
+}
+
 type CreateConversationRequest struct {
 	Name  string `json:"name,omitempty"`
 	Model string `json:"model"`
@@ -215,18 +229,30 @@ func (l *LLMHandler) CreateChatCompletion(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "unknown model")
 	}
 
+	cache, cacheResult := lookupSemanticCache(c, *req)
+	if cacheResult.Hit {
+		if req.Stream {
+			return writeCachedStream(c, cacheResult.Response)
+		}
+		return c.JSON(http.StatusOK, cacheResult.Response)
+	}
+
 	if req.Stream {
-		return l.chatStream(c, svc, *req)
+		return l.chatStream(c, svc, *req, func(resp llm.ChatCompletionResponse) {
+			saveSemanticCache(c, cache, cacheResult, resp)
+		})
 	}
 
 	resp, err := svc.CreateChatCompletion(ctx, *req)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
+	saveSemanticCache(c, cache, cacheResult, resp)
 	return c.JSON(http.StatusOK, resp)
 }
 
-func (l *LLMHandler) chatStream(c echo.Context, svc llm.Interface, req llm.ChatCompletionRequest) error {
+// chatStream writes the stream as sse, onDone is called with the whole response when stream finished
+func (l *LLMHandler) chatStream(c echo.Context, svc llm.Interface, req llm.ChatCompletionRequest, onDone func(resp llm.ChatCompletionResponse)) error {
 	dataChan := make(chan llm.ChatCompletionStreamResponse)
 	defer close(dataChan)
 	errChan := make(chan error)
@@ -241,9 +267,11 @@ func (l *LLMHandler) chatStream(c echo.Context, svc llm.Interface, req llm.ChatC
 
 	c.Response().WriteHeader(http.StatusOK)
 
+	var acc llm.StreamAccumulator
 	for {
 		select {
 		case data := <-dataChan:
+			acc.Add(data)
 			msg, err := json.Marshal(data)
 			if err != nil {
 				slog.ErrorContext(c.Request().Context(), "chat stream marshal response error", "err", err.Error())
@@ -257,6 +285,9 @@ func (l *LLMHandler) chatStream(c echo.Context, svc llm.Interface, req llm.ChatC
 			c.Response().Flush()
 		case err := <-errChan:
 			if errors.Is(err, io.EOF) {
+				if onDone != nil && acc.Chunks() > 0 {
+					onDone(acc.Response())
+				}
 				_, err = c.Response().Write([]byte("data: [DONE]\n\n"))
 				return err
 			}
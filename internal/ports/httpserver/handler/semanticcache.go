@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/core/llms"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/llm/semanticcache"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	HeaderCache           = "X-Cache"
+	HeaderCacheSimilarity = "X-Cache-Similarity"
+
+	cacheHit  = "HIT"
+	cacheMiss = "MISS"
+)
+
+// lookupSemanticCache returns nil cache when semantic cache is not enabled for the request api key
+func lookupSemanticCache(c echo.Context, req llm.ChatCompletionRequest) (*semanticcache.Cache, semanticcache.Result) {
+	ctx := c.Request().Context()
+	record, ok := semanticCacheRecord(c)
+	if !ok {
+		return nil, semanticcache.Result{}
+	}
+	cache, err := llms.GetSemanticCache(c.Get(config.ContextKeyDao).(*daos.Dao))
+	if err != nil {
+		slog.ErrorContext(ctx, "get semantic cache error", "err", err)
+		return nil, semanticcache.Result{}
+	}
+	// the answers of an api key are never served to other keys
+	result, err := cache.Lookup(ctx, record.Id, req)
+	if err != nil {
+		slog.ErrorContext(ctx, "semantic cache lookup error", "err", err, "model", req.Model)
+		return nil, semanticcache.Result{}
+	}
+
+	if result.Hit {
+		c.Response().Header().Set(HeaderCache, cacheHit)
+		c.Response().Header().Set(HeaderCacheSimilarity, fmt.Sprintf("%.4f", result.Similarity))
+	} else {
+		c.Response().Header().Set(HeaderCache, cacheMiss)
+	}
+	return cache, result
+}
+
+func saveSemanticCache(c echo.Context, cache *semanticcache.Cache, result semanticcache.Result, resp llm.ChatCompletionResponse) {
+	if cache == nil {
+		return
+	}
+	ctx := c.Request().Context()
+	if err := cache.Save(ctx, result, resp, ""); err != nil {
+		slog.ErrorContext(ctx, "save semantic cache error", "err", err)
+	}
+}
+
+// semanticCacheRecord returns the api key of the request when it has semantic cache enabled
+func semanticCacheRecord(c echo.Context) (*models.Record, bool) {
+	if !config.GetConfig().SemanticCache.Enabled {
+		return nil, false
+	}
+	record, ok := c.Get(config.ContextKeyAuthRecord).(*models.Record)
+	if !ok || record.Collection().Name != auth.TableApiKeys {
+		return nil, false
+	}
+	return record, record.GetBool(auth.ColumnSemanticCache)
+}
+
+// writeCachedStream writes the cached answer as a single chunk sse stream
+func writeCachedStream(c echo.Context, resp llm.ChatCompletionResponse) error {
+	choices := make([]llm.ChatCompletionStreamChoice, 0, len(resp.Choices))
+	for _, choice := range resp.Choices {
+		choices = append(choices, llm.ChatCompletionStreamChoice{
+			Index: choice.Index,
+			Delta: llm.ChatCompletionStreamChoiceDelta{
+				Role:    choice.Message.Role,
+				Content: choice.Message.Content,
+			},
+			FinishReason: choice.FinishReason,
+		})
+	}
+	msg, err := json.Marshal(llm.ChatCompletionStreamResponse{
+		ID:      resp.ID,
+		Object:  "chat.completion.chunk",
+		Created: resp.Created,
+		Model:   resp.Model,
+		Choices: choices,
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().WriteHeader(http.StatusOK)
+	_, err = c.Response().Write([]byte(fmt.Sprintf("data: %s\n\ndata: [DONE]\n\n", msg)))
+	return err
+}
@@ -2,8 +2,13 @@ package httpserver
 
 import (
 	"embed"
+	"log/slog"
 	"net/http"
 
+	"github.com/Vaayne/aienvoy/internal/core/copilot"
+	"github.com/Vaayne/aienvoy/internal/core/credential"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/internal/pkg/telemetry"
 	"github.com/Vaayne/aienvoy/internal/ports/httpserver/handler"
 	"github.com/Vaayne/aienvoy/internal/ports/httpserver/middlerware"
 
@@ -20,9 +25,13 @@ func RegisterRoutes(e *echo.Echo, app *pocketbase.PocketBase, staticFiles embed.
 	})
 	e.Add(http.MethodGet, "/web/*", echo.WrapHandler(http.FileServer(http.FS(staticFiles))))
 
+	// prometheus metrics, they expose the models and api keys in use so only admins can scrape them
+	e.GET("/metrics", echo.WrapHandler(telemetry.MetricsHandler()), apis.RequireAdminAuth())
+
 	// v1 apis
 	mds := []echo.MiddlewareFunc{
 		middlerware.ContextMiddleware(),
+		middlerware.TelemetryMiddleware(),
 		middlerware.RequestIDMiddleware(),
 		middlerware.AuthByApiKeyMiddleware(app.Dao()),
 		apis.RequireAdminOrRecordAuth(),
@@ -35,7 +44,7 @@ func RegisterRoutes(e *echo.Echo, app *pocketbase.PocketBase, staticFiles embed.
 	llmHandler := handler.NewLLMHandler()
 	v1.POST("/chat/completions", llmHandler.CreateChatCompletion)
 	// v1.POST("/embeddings", llmHandler.CreateEmbeddings)
-	// v1.GET("/models", llmHandler.GetModels)
+	v1.GET("/models", llmHandler.ListModels)
 	v1.GET("/status", func(c echo.Context) error {
 		return c.String(http.StatusOK, "OK")
 	})
@@ -51,4 +60,33 @@ func RegisterRoutes(e *echo.Echo, app *pocketbase.PocketBase, staticFiles embed.
 	v1.GET("/conversations/:conversationId/messages", llmHandler.ListMessages)
 	v1.GET("/conversations/:conversationId/messages/:messageId", llmHandler.GetMessage)
 	v1.DELETE("/conversations/:conversationId/messages/:messageId", llmHandler.DeleteMessage)
+
+	// admin apis
+	admin := e.Group("/v1/admin",
+		middlerware.ContextMiddleware(),
+		middlerware.TelemetryMiddleware(),
+		middlerware.RequestIDMiddleware(),
+		apis.RequireAdminAuth(),
+		middlerware.DaoMiddleware(app.Dao()),
+		middlerware.LoggerMiddleware(),
+	)
+	auditHandler := handler.NewAuditHandler()
+	admin.GET("/audits", auditHandler.ListAudits)
+	admin.GET("/audits/:id", auditHandler.GetAudit)
+	admin.POST("/audits/:id/replay", auditHandler.ReplayAudit)
+
+	copilotHandler := handler.NewCopilotHandler(copilot.NewLogin(copilot.NewDao(app.Dao())))
+	admin.POST("/copilot/login", copilotHandler.Login)
+	admin.GET("/copilot/login", copilotHandler.GetLogin)
+
+	vault, err := credential.New(app.Dao(), config.GetConfig().Vault)
+	if err != nil {
+		slog.Warn("credential vault is disabled", "err", err)
+	}
+	credentialHandler := handler.NewCredentialHandler(vault)
+	admin.GET("/credentials", credentialHandler.ListCredentials)
+	admin.POST("/credentials", credentialHandler.AddCredential)
+	admin.GET("/credentials/audits", credentialHandler.ListAudits)
+	admin.PUT("/credentials/:name", credentialHandler.RotateCredential)
+	admin.DELETE("/credentials/:name", credentialHandler.RevokeCredential)
 }
@@ -0,0 +1,68 @@
+package slackbot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+const (
+	platformSlack = "slack"
+	// usersCollection is the auth collection of PocketBase users
+	usersCollection = "users"
+)
+
+var errUnknownUser = errors.New("slack user is not mapped to a user")
+
+// authenticate puts the PocketBase user of a slack user into ctx, so that usage is recorded for it.
+// New slack users are mapped by the email of their profile, which needs the users:read.email scope,
+// other mappings are set in the chat_users collection. Unmapped users get errUnknownUser unless allowUnmapped is set.
+func (b *Bot) authenticate(ctx context.Context, slackUserID string) (context.Context, error) {
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	user, err := auth.GetChatUser(ctx, tx, platformSlack, slackUserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if user, err = b.mapUser(ctx, tx, slackUserID); err != nil {
+			return ctx, err
+		}
+	} else if err != nil {
+		return ctx, fmt.Errorf("get slack user error: %w", err)
+	}
+
+	if linked, _, ok := auth.WithChatUser(ctx, tx, user); ok {
+		return linked, nil
+	}
+	if user.UserId != "" {
+		return context.WithValue(ctx, config.ContextKeyUserId, user.UserId), nil
+	}
+	if config.GetConfig().Slack.AllowUnmapped {
+		return ctx, nil
+	}
+	return ctx, errUnknownUser
+}
+
+// mapUser saves a new slack user, it is mapped to the PocketBase user of the same email
+func (b *Bot) mapUser(ctx context.Context, tx *daos.Dao, slackUserID string) (auth.ChatUser, error) {
+	user := auth.ChatUser{Platform: platformSlack, ExternalId: slackUserID}
+	info, err := b.api.GetUserInfoContext(ctx, slackUserID)
+	if err != nil {
+		slog.Warn("get slack user info error", "err", err, "slack_id", slackUserID)
+	} else {
+		user.Username = info.Name
+		if info.Profile.Email != "" {
+			if record, err := tx.FindAuthRecordByEmail(usersCollection, info.Profile.Email); err == nil {
+				user.UserId = record.Id
+			}
+		}
+	}
+	user, err = auth.SaveChatUser(ctx, tx, user)
+	if err != nil {
+		return auth.ChatUser{}, fmt.Errorf("save slack user error: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,98 @@
+package slackbot
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/slack-go/slack"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/internal/pkg/telemetry"
+)
+
+const (
+	ModeEvents = "events"
+	ModeSocket = "socket"
+
+	kindEvent   = "event"
+	kindCommand = "command"
+)
+
+type Bot struct {
+	api *slack.Client
+	app *pocketbase.PocketBase
+	// userID is the slack user of the bot, its mentions are removed from prompts
+	userID string
+}
+
+func New(cfg config.Slack, app *pocketbase.PocketBase) *Bot {
+	return &Bot{
+		api: slack.New(cfg.BotToken, slack.OptionAppLevelToken(cfg.AppToken)),
+		app: app,
+	}
+}
+
+// mode is the configured mode, by default socket mode is used in dev and the events api otherwise
+func mode(cfg *config.Config) string {
+	if cfg.Slack.Mode != "" {
+		return cfg.Slack.Mode
+	}
+	if cfg.Service.Env == "dev" {
+		return ModeSocket
+	}
+	return ModeEvents
+}
+
+// Serve starts the bot, with the events api the events and slash commands are received by the routes of router.
+func Serve(app *pocketbase.PocketBase, router *echo.Echo) {
+	cfg := config.GetConfig()
+	if mode(cfg) == ModeEvents && cfg.Slack.SigningSecret == "" {
+		// without the secret every request would pass the verification
+		slog.Error("slack signing secret is required by the events api, the slack bot is not started")
+		return
+	}
+	b := New(cfg.Slack, app)
+	resp, err := b.api.AuthTest()
+	if err != nil {
+		slog.Error("Init slack bot error", "err", err)
+		return
+	}
+	b.userID = resp.UserID
+
+	if mode(cfg) == ModeSocket {
+		go b.serveSocket(context.Background())
+		slog.Info("Start slack bot by socket mode...")
+		return
+	}
+	router.POST(EventsPath, b.eventsHandler(cfg.Slack.SigningSecret))
+	router.POST(CommandsPath, b.commandsHandler(cfg.Slack.SigningSecret))
+	slog.Info("Start slack bot by events api...", "url", cfg.Service.URL+EventsPath)
+}
+
+// handle runs fn in the background, slack expects its requests to be acknowledged before they are answered.
+func (b *Bot) handle(kind, userID string, fn func(ctx context.Context) error) {
+	go func() {
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, config.ContextKeyApp, b.app)
+		ctx = context.WithValue(ctx, config.ContextKeyDao, b.app.Dao())
+		ctx = context.WithValue(ctx, config.ContextKeyUserId, userID)
+		ctx = context.WithValue(ctx, config.ContextKeyRequestId, uuid.NewString())
+		ctx, span := telemetry.Tracer().Start(ctx, "slack."+kind, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		start := time.Now()
+		err := fn(ctx)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			slog.ErrorContext(ctx, "handle slack event error", "err", err, "kind", kind)
+		}
+		telemetry.ObserveSlack(kind, err, time.Since(start))
+	}()
+}
@@ -0,0 +1,166 @@
+package slackbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/core/chatstate"
+	"github.com/Vaayne/aienvoy/internal/core/llms"
+	"github.com/Vaayne/aienvoy/internal/core/readease"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/pkg/chatstream"
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// maxMessageLength keeps messages below the length slack shows without truncating
+	maxMessageLength = 3900
+	// editInterval keeps chat.update below its rate limit
+	editInterval = 1200 * time.Millisecond
+	// readModel summarizes articles, like the readease of the telegram bot
+	readModel = "gemini-pro"
+)
+
+// threadSink streams to the replies of a thread
+type threadSink struct {
+	api       *slack.Client
+	ctx       context.Context
+	channelID string
+	threadTs  string
+}
+
+func (t *threadSink) Send(content string) (string, error) {
+	_, ts, err := t.api.PostMessageContext(t.ctx, t.channelID, slack.MsgOptionText(mrkdwn(content), false), slack.MsgOptionTS(t.threadTs))
+	return ts, err
+}
+
+func (t *threadSink) Edit(ts, content string) error {
+	_, _, _, err := t.api.UpdateMessageContext(t.ctx, t.channelID, ts, slack.MsgOptionText(mrkdwn(content), false))
+	return err
+}
+
+// newStream streams an answer to out, the footer is shown in italics below the finished answer
+func newStream(out chatstream.Sink, footer string) *chatstream.Stream {
+	stream := chatstream.New(out, maxMessageLength, editInterval)
+	stream.Footer = "-- _" + footer + "_"
+	return stream
+}
+
+// llmChat streams the answer of prompt to the thread, a new conversation is created when the thread has none.
+func (b *Bot) llmChat(ctx context.Context, state chatstate.State, model, prompt string, out *threadSink) error {
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	if model == "" {
+		_, err := out.Send("No model is configured for the slack bot")
+		return err
+	}
+	if !auth.ContextAllowsModel(ctx, model) {
+		_, err := out.Send(fmt.Sprintf("Model %s is not allowed for your api key", model))
+		return err
+	}
+	svc, err := llms.NewWithDao(model, llms.NewDao(tx))
+	if err != nil {
+		return fmt.Errorf("init llm service error: %w", err)
+	}
+	if state.ConversationId == "" {
+		if state, err = chatstate.NewConversation(ctx, tx, state, model, prompt); err != nil {
+			return err
+		}
+	}
+
+	stream := newStream(out, model)
+	if err := stream.Start(fmt.Sprintf("Waiting for %s ...", model)); err != nil {
+		return err
+	}
+	req := llm.ChatCompletionRequest{
+		Model: model,
+		Messages: []llm.ChatCompletionMessage{
+			{
+				Role:    llm.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+		Stream: true,
+	}
+
+	// answers which take longer are given up, like the answers of /read
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	respChan := make(chan llm.ChatCompletionStreamResponse)
+	defer close(respChan)
+	errChan := make(chan error)
+	defer close(errChan)
+	go svc.CreateMessageStream(ctx, state.ConversationId, req, respChan, errChan)
+
+	for {
+		select {
+		case resp := <-respChan:
+			stream.Process(resp.DeltaContent())
+		case err := <-errChan:
+			if errors.Is(err, io.EOF) || err == nil {
+				return stream.Finish(io.EOF)
+			}
+			return stream.Finish(err)
+		case <-ctx.Done():
+			return stream.Finish(ctx.Err())
+		}
+	}
+}
+
+// readEase streams the readease summary of an article or video to the thread
+func (b *Bot) readEase(ctx context.Context, urlStr string, out *threadSink) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	stream := newStream(out, urlStr)
+	if err := stream.Start("please wait a moment, I am reading the article..."); err != nil {
+		return err
+	}
+
+	respChan := make(chan llm.ChatCompletionStreamResponse)
+	errChan := make(chan error)
+	defer close(respChan)
+	defer close(errChan)
+	go readease.NewReader(b.app).ReadStream(ctx, urlStr, readModel, respChan, errChan)
+
+	for {
+		select {
+		case resp := <-respChan:
+			stream.Process(resp.DeltaContent())
+		case err := <-errChan:
+			return stream.Finish(err)
+		case <-ctx.Done():
+			return stream.Finish(ctx.Err())
+		}
+	}
+}
+
+var (
+	boldPattern    = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	linkPattern    = regexp.MustCompile(`\[([^\]\n]+)\]\((https?://[^)\s]+)\)`)
+	headingPattern = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+)
+
+// mrkdwn converts the markdown of answers to the mrkdwn of slack, code blocks are only escaped.
+func mrkdwn(md string) string {
+	md = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(md)
+	parts := strings.Split(md, "```")
+	for idx, part := range parts {
+		// odd parts are code blocks
+		if idx%2 == 1 {
+			continue
+		}
+		part = headingPattern.ReplaceAllString(part, "*$1*")
+		part = boldPattern.ReplaceAllString(part, "*$1*")
+		part = linkPattern.ReplaceAllString(part, "<$2|$1>")
+		parts[idx] = part
+	}
+	return strings.Join(parts, "```")
+}
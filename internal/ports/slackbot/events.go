@@ -0,0 +1,152 @@
+package slackbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/internal/core/chatstate"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+const (
+	CommandAsk  = "/ask"
+	CommandRead = "/read"
+
+	// channelTypeIM is the channel type of direct messages with the bot
+	channelTypeIM = "im"
+)
+
+// threadKey keys the conversation of a thread, direct messages outside of threads have one active conversation
+func threadKey(channelID, threadTs string) chatstate.Key {
+	return chatstate.Key{Platform: platformSlack, ChatId: channelID, ThreadId: threadTs}
+}
+
+// onEvent answers mentions of the bot, messages in the threads of its conversations and direct messages
+func (b *Bot) onEvent(event slackevents.EventsAPIInnerEvent) {
+	switch ev := event.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		if ev.BotID != "" {
+			return
+		}
+		// a mention outside of a thread starts a thread of the mention
+		threadTs := ev.ThreadTimeStamp
+		if threadTs == "" {
+			threadTs = ev.TimeStamp
+		}
+		b.handle(kindEvent, ev.User, func(ctx context.Context) error {
+			return b.onThreadMessage(ctx, ev.User, ev.Channel, threadTs, ev.Text, true)
+		})
+	case *slackevents.MessageEvent:
+		// edits, joins and the messages of bots have subtypes
+		if ev.BotID != "" || ev.SubType != "" || ev.User == "" || ev.User == b.userID {
+			return
+		}
+		// mentions in channels are app_mention events
+		if ev.ChannelType != channelTypeIM && (ev.ThreadTimeStamp == "" || strings.Contains(ev.Text, b.mention())) {
+			return
+		}
+		b.handle(kindEvent, ev.User, func(ctx context.Context) error {
+			return b.onThreadMessage(ctx, ev.User, ev.Channel, ev.ThreadTimeStamp, ev.Text, ev.ChannelType == channelTypeIM)
+		})
+	}
+}
+
+// onThreadMessage continues the conversation of a thread, a new one is started when force is set.
+// Other threads of channels are not for the bot.
+func (b *Bot) onThreadMessage(ctx context.Context, userID, channelID, threadTs, text string, force bool) error {
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	state, err := chatstate.Get(ctx, tx, threadKey(channelID, threadTs))
+	if err != nil {
+		return fmt.Errorf("get chat state error: %w", err)
+	}
+	if state.ConversationId == "" && !force {
+		return nil
+	}
+
+	ctx, err = b.authenticate(ctx, userID)
+	if errors.Is(err, errUnknownUser) {
+		return b.postEphemeral(ctx, channelID, userID, "You are not allowed to use this bot, ask an admin to map your slack account")
+	} else if err != nil {
+		return err
+	}
+
+	prompt := strings.TrimSpace(strings.ReplaceAll(text, b.mention(), ""))
+	if prompt == "" {
+		return nil
+	}
+	return b.llmChat(ctx, state, b.model(state), prompt, &threadSink{api: b.api, ctx: ctx, channelID: channelID, threadTs: threadTs})
+}
+
+// onCommand posts the slash command to the channel, the answer is streamed into the thread of the post.
+func (b *Bot) onCommand(cmd slack.SlashCommand) {
+	text := strings.TrimSpace(cmd.Text)
+	b.handle(kindCommand, cmd.UserID, func(ctx context.Context) error {
+		ctx, err := b.authenticate(ctx, cmd.UserID)
+		if errors.Is(err, errUnknownUser) {
+			return b.postEphemeral(ctx, cmd.ChannelID, cmd.UserID, "You are not allowed to use this bot, ask an admin to map your slack account")
+		} else if err != nil {
+			return err
+		}
+
+		switch cmd.Command {
+		case CommandAsk:
+			if text == "" {
+				return b.postEphemeral(ctx, cmd.ChannelID, cmd.UserID, fmt.Sprintf("Usage: %s <prompt>", CommandAsk))
+			}
+			threadTs, err := b.post(ctx, cmd.ChannelID, fmt.Sprintf("<@%s> asked: %s", cmd.UserID, text))
+			if err != nil {
+				return err
+			}
+			state, err := chatstate.Get(ctx, ctx.Value(config.ContextKeyDao).(*daos.Dao), threadKey(cmd.ChannelID, threadTs))
+			if err != nil {
+				return fmt.Errorf("get chat state error: %w", err)
+			}
+			return b.llmChat(ctx, state, b.model(state), text, &threadSink{api: b.api, ctx: ctx, channelID: cmd.ChannelID, threadTs: threadTs})
+		case CommandRead:
+			if _, err := url.ParseRequestURI(text); err != nil || !strings.HasPrefix(text, "http") {
+				return b.postEphemeral(ctx, cmd.ChannelID, cmd.UserID, fmt.Sprintf("invalid url %s, please check and try again", text))
+			}
+			threadTs, err := b.post(ctx, cmd.ChannelID, fmt.Sprintf("<@%s> reads: %s", cmd.UserID, text))
+			if err != nil {
+				return err
+			}
+			return b.readEase(ctx, text, &threadSink{api: b.api, ctx: ctx, channelID: cmd.ChannelID, threadTs: threadTs})
+		}
+		return nil
+	})
+}
+
+// model is the model of the conversation of a thread, new conversations use the model of the config
+func (b *Bot) model(state chatstate.State) string {
+	if state.Model != "" {
+		return state.Model
+	}
+	return config.GetConfig().Slack.Model
+}
+
+func (b *Bot) mention() string {
+	return "<@" + b.userID + ">"
+}
+
+// post sends a message to a channel and returns its timestamp, which is the thread of its replies
+func (b *Bot) post(ctx context.Context, channelID, text string) (string, error) {
+	_, ts, err := b.api.PostMessageContext(ctx, channelID, slack.MsgOptionText(text, false))
+	if err != nil {
+		return "", fmt.Errorf("post slack message error: %w", err)
+	}
+	return ts, nil
+}
+
+// postEphemeral sends a message only the user sees
+func (b *Bot) postEphemeral(ctx context.Context, channelID, userID, text string) error {
+	if _, err := b.api.PostEphemeralContext(ctx, channelID, userID, slack.MsgOptionText(text, false)); err != nil {
+		return fmt.Errorf("post slack ephemeral message error: %w", err)
+	}
+	return nil
+}
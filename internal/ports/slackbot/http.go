@@ -0,0 +1,97 @@
+package slackbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+const (
+	// EventsPath is the request url of the events api on the http server
+	EventsPath = "/slack/events"
+	// CommandsPath is the request url of the slash commands on the http server
+	CommandsPath = "/slack/commands"
+	// retryHeader is set on the retries of events which were not acknowledged in time
+	retryHeader = "X-Slack-Retry-Num"
+	// maxBodySize limits the requests of slack
+	maxBodySize = 1 << 20
+)
+
+// verify reads the body of a request of slack and checks its signature by the signing secret
+func verify(c echo.Context, secret string) ([]byte, bool) {
+	if secret == "" {
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxBodySize))
+	if err != nil {
+		return nil, false
+	}
+	verifier, err := slack.NewSecretsVerifier(c.Request().Header, secret)
+	if err != nil {
+		slog.Warn("slack request without valid signature headers", "err", err, "ip", c.RealIP())
+		return nil, false
+	}
+	if _, err := verifier.Write(body); err != nil {
+		return nil, false
+	}
+	if err := verifier.Ensure(); err != nil {
+		slog.Warn("slack request with invalid signature", "ip", c.RealIP())
+		return nil, false
+	}
+	return body, true
+}
+
+// eventsHandler answers the url verification of slack and handles the events in the background,
+// slack expects events to be acknowledged in 3 seconds.
+func (b *Bot) eventsHandler(secret string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		body, ok := verify(c, secret)
+		if !ok {
+			return c.NoContent(http.StatusUnauthorized)
+		}
+		event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+		if err != nil {
+			slog.Error("parse slack event error", "err", err)
+			return c.NoContent(http.StatusBadRequest)
+		}
+
+		switch event.Type {
+		case slackevents.URLVerification:
+			var challenge slackevents.ChallengeResponse
+			if err := json.Unmarshal(body, &challenge); err != nil {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			return c.String(http.StatusOK, challenge.Challenge)
+		case slackevents.CallbackEvent:
+			// the event is handled already, its retry is caused by the slow acknowledgement
+			if c.Request().Header.Get(retryHeader) == "" {
+				b.onEvent(event.InnerEvent)
+			}
+		}
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+// commandsHandler handles the slash commands in the background, the empty response keeps the command hidden.
+func (b *Bot) commandsHandler(secret string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		body, ok := verify(c, secret)
+		if !ok {
+			return c.NoContent(http.StatusUnauthorized)
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+		cmd, err := slack.SlashCommandParse(c.Request())
+		if err != nil {
+			slog.Error("parse slack command error", "err", err)
+			return c.NoContent(http.StatusBadRequest)
+		}
+		b.onCommand(cmd)
+		return c.NoContent(http.StatusOK)
+	}
+}
@@ -0,0 +1,81 @@
+package slackbot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "signing-secret"
+
+func sign(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	body := `{"type":"url_verification","challenge":"challenge"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		ok        bool
+	}{
+		{name: "valid signature", secret: testSecret, timestamp: now, signature: sign(testSecret, now, body), ok: true},
+		{name: "no signing secret configured", timestamp: now, signature: sign("", now, body)},
+		{name: "missing headers"},
+		{name: "missing signature", secret: testSecret, timestamp: now},
+		{name: "missing timestamp", secret: testSecret, signature: sign(testSecret, now, body)},
+		{name: "signed by another secret", secret: testSecret, timestamp: now, signature: sign("other", now, body)},
+		{name: "signed body was changed", secret: testSecret, timestamp: now, signature: sign(testSecret, now, body+" ")},
+		// replayed requests have old timestamps
+		{name: "stale timestamp", secret: testSecret, timestamp: stale, signature: sign(testSecret, stale, body)},
+		{name: "timestamp differs from the signed one", secret: testSecret, timestamp: now, signature: sign(testSecret, stale, body)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, EventsPath, strings.NewReader(body))
+			if tt.timestamp != "" {
+				req.Header.Set("X-Slack-Request-Timestamp", tt.timestamp)
+			}
+			if tt.signature != "" {
+				req.Header.Set("X-Slack-Signature", tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			got, ok := verify(c, tt.secret)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, body, string(got))
+			}
+
+			// the events handler answers the url verification of signed requests only
+			req = httptest.NewRequest(http.MethodPost, EventsPath, strings.NewReader(body))
+			req.Header = c.Request().Header
+			rec = httptest.NewRecorder()
+			err := (&Bot{}).eventsHandler(tt.secret)(echo.New().NewContext(req, rec))
+			assert.NoError(t, err)
+			if tt.ok {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, "challenge", rec.Body.String())
+			} else {
+				assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package slackbot
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// serveSocket receives the events and slash commands by socket mode, it needs no public url
+func (b *Bot) serveSocket(ctx context.Context) {
+	client := socketmode.New(b.api)
+	go func() {
+		for evt := range client.Events {
+			switch evt.Type {
+			case socketmode.EventTypeConnected:
+				slog.Info("slack socket mode connected")
+			case socketmode.EventTypeEventsAPI:
+				event, ok := evt.Data.(slackevents.EventsAPIEvent)
+				client.Ack(*evt.Request)
+				if ok && event.Type == slackevents.CallbackEvent {
+					b.onEvent(event.InnerEvent)
+				}
+			case socketmode.EventTypeSlashCommand:
+				cmd, ok := evt.Data.(slack.SlashCommand)
+				client.Ack(*evt.Request)
+				if ok {
+					b.onCommand(cmd)
+				}
+			}
+		}
+	}()
+	if err := client.RunContext(ctx); err != nil {
+		slog.Error("run slack socket mode error", "err", err)
+	}
+}
@@ -0,0 +1,125 @@
+package tgbot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/internal/ports/tgbot/handler"
+	"github.com/pocketbase/pocketbase/daos"
+	tb "gopkg.in/telebot.v3"
+)
+
+// authMiddleware serves allowed users only, in groups it only answers commands, mentions and replies to the bot.
+func authMiddleware(next tb.HandlerFunc) tb.HandlerFunc {
+	return func(c tb.Context) error {
+		sender := c.Sender()
+		if sender == nil || sender.IsBot {
+			return nil
+		}
+		// inline queries have no chat
+		if c.Chat() != nil && c.Chat().Type != tb.ChatPrivate && !addressed(c) {
+			return nil
+		}
+
+		ctx := c.Get(config.ContextKeyContext).(context.Context)
+		tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+		telegramId := strconv.FormatInt(sender.ID, 10)
+		user, err := auth.GetChatUser(ctx, tx, handler.PlatformTelegram, telegramId)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("get telegram user err: %v", err)
+		}
+		if user.Status == auth.ChatUserBanned {
+			return nil
+		}
+
+		// accounts whose api key is deleted fall back to the allow-lists
+		if linked, record, ok := auth.WithChatUser(ctx, tx, user); ok {
+			c.Set(config.ContextKeyContext, linked)
+			c.Set(config.ContextKeyAuthRecord, record)
+			return next(c)
+		}
+
+		if user.Status == auth.ChatUserApproved || allowed(c) || isLinkCommand(c) {
+			return next(c)
+		}
+
+		if c.Query() != nil || c.InlineResult() != nil {
+			return nil
+		}
+		if user.Id == "" {
+			user = auth.ChatUser{Platform: handler.PlatformTelegram, ExternalId: telegramId, Username: sender.Username, Status: auth.ChatUserPending}
+			if _, err := auth.SaveChatUser(ctx, tx, user); err != nil {
+				return fmt.Errorf("save telegram user err: %v", err)
+			}
+			notifyAdmins(c, sender)
+		}
+		return c.Reply(fmt.Sprintf("You are not allowed to use this bot yet, your telegram id is %d.\n"+
+			"Ask an admin to approve it, or link your api key by /%s <api_key> in a private chat.", sender.ID, handler.CommandLink))
+	}
+}
+
+// allowed reports if the sender or the group is in the allow-lists of the config
+func allowed(c tb.Context) bool {
+	cfg := config.GetConfig().Telegram
+	return slices.Contains(cfg.AllowUsers, c.Sender().ID) ||
+		slices.Contains(cfg.Admins, c.Sender().ID) ||
+		c.Chat() != nil && slices.Contains(cfg.AllowChats, c.Chat().ID)
+}
+
+func isLinkCommand(c tb.Context) bool {
+	cmd, _, _ := strings.Cut(c.Text(), " ")
+	cmd, _, _ = strings.Cut(cmd, "@")
+	return cmd == "/"+handler.CommandLink
+}
+
+// addressed reports if a group message or a button click is for the bot, the mention of the bot is removed from the text.
+func addressed(c tb.Context) bool {
+	if c.Callback() != nil {
+		return true
+	}
+	msg := c.Message()
+	if msg == nil {
+		return false
+	}
+	me := c.Bot().Me
+	// documents and other media have captions instead of text
+	text := &msg.Text
+	if *text == "" {
+		text = &msg.Caption
+	}
+	trimmed := strings.TrimSpace(*text)
+	if strings.HasPrefix(trimmed, "/") {
+		cmd, _, _ := strings.Cut(trimmed, " ")
+		_, username, ok := strings.Cut(cmd, "@")
+		return !ok || strings.EqualFold(username, me.Username)
+	}
+	mention := "@" + me.Username
+	if idx := strings.Index(strings.ToLower(trimmed), strings.ToLower(mention)); me.Username != "" && idx >= 0 {
+		*text = strings.TrimSpace(trimmed[:idx] + trimmed[idx+len(mention):])
+		return true
+	}
+	return msg.ReplyTo != nil && msg.ReplyTo.Sender != nil && msg.ReplyTo.Sender.ID == me.ID
+}
+
+// notifyAdmins asks the admins of the config to approve a new user
+func notifyAdmins(c tb.Context, sender *tb.User) {
+	name := strings.TrimSpace(sender.FirstName + " " + sender.LastName)
+	if sender.Username != "" {
+		name += " @" + sender.Username
+	}
+	text := fmt.Sprintf("%s (%d) requests access to the bot, /%s %d or /%s %d",
+		name, sender.ID, handler.CommandApprove, sender.ID, handler.CommandBan, sender.ID)
+	for _, admin := range config.GetConfig().Telegram.Admins {
+		if _, err := c.Bot().Send(tb.ChatID(admin), text); err != nil {
+			slog.Error("notify telegram admin error", "err", err, "admin", admin)
+		}
+	}
+}
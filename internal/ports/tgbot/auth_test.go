@@ -0,0 +1,180 @@
+package tgbot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/internal/ports/tgbot/handler"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tb "gopkg.in/telebot.v3"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	testBotId   = 42
+	testAdminId = 7
+)
+
+// newTestDao returns the dao of an empty chat_users table
+func newTestDao(t *testing.T) *daos.Dao {
+	db, err := dbx.Open("sqlite", filepath.Join(t.TempDir(), "data.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.NewQuery("CREATE TABLE " + auth.TableChatUsers + " (id TEXT PRIMARY KEY, created TEXT, updated TEXT, " +
+		"platform TEXT, external_id TEXT, username TEXT, status TEXT, user_id TEXT, api_key_id TEXT)").Execute()
+	require.NoError(t, err)
+	return daos.New(db)
+}
+
+// newTestBot returns an offline bot whose api calls are recorded by method
+func newTestBot(t *testing.T) (*tb.Bot, func() []string) {
+	var mu sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	bot, err := tb.NewBot(tb.Settings{URL: server.URL, Token: "token", Offline: true})
+	require.NoError(t, err)
+	bot.Me = &tb.User{ID: testBotId, Username: "envoy_bot", IsBot: true}
+	return bot, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(methods)
+	}
+}
+
+func setTelegramConfig(t *testing.T, telegram config.Telegram) {
+	previous := config.GetConfig()
+	cfg := *previous
+	cfg.Telegram = telegram
+	config.Set(&cfg)
+	t.Cleanup(func() { config.Set(previous) })
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	private := &tb.Chat{ID: 1, Type: tb.ChatPrivate}
+	group := &tb.Chat{ID: -100, Type: tb.ChatGroup}
+	alice := &tb.User{ID: 1, Username: "alice"}
+
+	tests := []struct {
+		name     string
+		telegram config.Telegram
+		status   string
+		message  *tb.Message
+		served   bool
+		// methods are the telegram api calls of the middleware
+		methods []string
+		// saved is the status of the sender after the middleware, empty when not saved
+		saved string
+	}{
+		{
+			name:    "bots are ignored",
+			message: &tb.Message{Sender: &tb.User{ID: 2, IsBot: true}, Chat: private, Text: "hi"},
+		},
+		{
+			name:     "group messages not for the bot are ignored",
+			telegram: config.Telegram{AllowUsers: []int64{alice.ID}},
+			message:  &tb.Message{Sender: alice, Chat: group, Text: "hi"},
+		},
+		{
+			name:     "banned users are ignored",
+			telegram: config.Telegram{AllowUsers: []int64{alice.ID}},
+			status:   auth.ChatUserBanned,
+			message:  &tb.Message{Sender: alice, Chat: private, Text: "hi"},
+			saved:    auth.ChatUserBanned,
+		},
+		{
+			name:    "approved users are served",
+			status:  auth.ChatUserApproved,
+			message: &tb.Message{Sender: alice, Chat: private, Text: "hi"},
+			served:  true,
+			saved:   auth.ChatUserApproved,
+		},
+		{
+			name:     "allowed users are served",
+			telegram: config.Telegram{AllowUsers: []int64{alice.ID}},
+			message:  &tb.Message{Sender: alice, Chat: private, Text: "hi"},
+			served:   true,
+		},
+		{
+			name:     "admins are served",
+			telegram: config.Telegram{Admins: []int64{alice.ID}},
+			message:  &tb.Message{Sender: alice, Chat: private, Text: "hi"},
+			served:   true,
+		},
+		{
+			name:     "mentions in allowed groups are served",
+			telegram: config.Telegram{AllowChats: []int64{group.ID}},
+			message:  &tb.Message{Sender: alice, Chat: group, Text: "@envoy_bot hi"},
+			served:   true,
+		},
+		{
+			name:     "commands of another bot are ignored",
+			telegram: config.Telegram{AllowChats: []int64{group.ID}},
+			message:  &tb.Message{Sender: alice, Chat: group, Text: "/chat@other_bot hi"},
+		},
+		{
+			name:    "unknown users may link their api key",
+			message: &tb.Message{Sender: alice, Chat: private, Text: "/" + handler.CommandLink + " key"},
+			served:  true,
+		},
+		{
+			name:     "unknown users are saved as pending and the admins are asked",
+			telegram: config.Telegram{Admins: []int64{testAdminId}},
+			message:  &tb.Message{Sender: alice, Chat: private, Text: "hi"},
+			methods:  []string{"sendMessage", "sendMessage"},
+			saved:    auth.ChatUserPending,
+		},
+		{
+			name:    "pending users are refused again without asking the admins",
+			status:  auth.ChatUserPending,
+			message: &tb.Message{Sender: alice, Chat: private, Text: "hi"},
+			methods: []string{"sendMessage"},
+			saved:   auth.ChatUserPending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTelegramConfig(t, tt.telegram)
+			tx := newTestDao(t)
+			ctx := context.WithValue(context.Background(), config.ContextKeyDao, tx)
+			if tt.status != "" {
+				_, err := auth.SaveChatUser(ctx, tx, auth.ChatUser{
+					Platform: handler.PlatformTelegram, ExternalId: "1", Username: alice.Username, Status: tt.status,
+				})
+				require.NoError(t, err)
+			}
+
+			bot, methods := newTestBot(t)
+			c := bot.NewContext(tb.Update{Message: tt.message})
+			c.Set(config.ContextKeyContext, ctx)
+			served := false
+			err := authMiddleware(func(tb.Context) error {
+				served = true
+				return nil
+			})(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.served, served)
+			assert.Equal(t, tt.methods, methods())
+
+			user, _ := auth.GetChatUser(ctx, tx, handler.PlatformTelegram, "1")
+			assert.Equal(t, tt.saved, user.Status)
+		})
+	}
+}
@@ -5,12 +5,17 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/internal/pkg/telemetry"
 	"github.com/Vaayne/aienvoy/internal/ports/tgbot/handler"
 
+	"github.com/labstack/echo/v5"
 	"github.com/pocketbase/pocketbase"
 	tb "gopkg.in/telebot.v3"
 )
@@ -21,6 +26,9 @@ type TeleBot struct {
 	app *pocketbase.PocketBase
 }
 
+// maxCommands is the limit of the commands of a telegram bot
+const maxCommands = 100
+
 var (
 	bot  *TeleBot
 	once sync.Once
@@ -28,8 +36,10 @@ var (
 
 func New(token string, app *pocketbase.PocketBase) *TeleBot {
 	b, err := tb.NewBot(tb.Settings{
-		Token: token, // Poller:  WebHook,
-		// Verbose: false,
+		Token:  token,
+		Poller: &tb.LongPoller{Timeout: 10 * time.Second},
+		// handlers run in the workers of the update pool
+		Synchronous: true,
 	})
 	if err != nil {
 		slog.Error("Init telegram bot error", "err", err)
@@ -64,45 +74,79 @@ func contextMiddleware(next tb.HandlerFunc) tb.HandlerFunc {
 	}
 }
 
+func telemetryMiddleware(next tb.HandlerFunc) tb.HandlerFunc {
+	return func(c tb.Context) error {
+		ctx, _ := c.Get(config.ContextKeyContext).(context.Context)
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, span := telemetry.Tracer().Start(ctx, "telegram.update", trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		c.Set(config.ContextKeyContext, ctx)
+
+		start := time.Now()
+		err := next(c)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		telemetry.ObserveTelegram(err, time.Since(start))
+		return err
+	}
+}
+
 func registerCommands(b *TeleBot) {
 	cmds := []tb.Command{
 		{
-			Text:        handler.CommandRead,
-			Description: "ReadEase to summary article or video using Claude 2",
+			Text:        handler.CommandNew,
+			Description: "Start a new conversation",
+		},
+		{
+			Text:        handler.CommandHistory,
+			Description: "List the latest conversations of this chat",
+		},
+		{
+			Text:        handler.CommandSwitch,
+			Description: "Switch to a conversation by id",
 		},
 		{
-			Text:        handler.CommandBard,
-			Description: "Chat using Google Bard",
+			Text:        handler.CommandModel,
+			Description: "Show or change the model of this chat",
 		},
 		{
-			Text:        handler.CommandChatGPT35,
-			Description: "Chat using ChatGPT 3.5",
+			Text:        handler.CommandLink,
+			Description: "Link your api key to use the bot as your user",
 		},
 		{
-			Text:        handler.CommandChatGPT4,
-			Description: "Chat using ChatGPT 4",
+			Text:        handler.CommandExplain,
+			Description: "Explain the message you reply to",
 		},
 		{
-			Text:        handler.CommandClaudeWeb,
-			Description: "Chat using Claude Web",
+			Text:        handler.CommandTranslate,
+			Description: "Translate the message you reply to",
 		},
 		{
-			Text:        handler.CommandClaudeV2,
-			Description: "Chat using Claude API V2",
+			Text:        handler.CommandVoice,
+			Description: "Turn voice replies of this chat on or off",
 		},
 		{
-			Text:        handler.CommandClaudeV1,
-			Description: "Chat using Claude API V1",
+			Text:        handler.CommandTranscript,
+			Description: "Turn transcripts of voice messages on or off",
 		},
 		{
-			Text:        handler.CommandClaudeInstant,
-			Description: "Chat using Claude API Instant",
+			Text:        handler.CommandRead,
+			Description: "ReadEase to summary article or video using Claude 2",
 		},
 		{
 			Text:        handler.CommandImagine,
 			Description: "Generate image using midjourney",
 		},
 	}
+	// model commands follow the registry, telegram takes at most 100 commands
+	cmds = append(cmds, handler.ModelCommands(b.app.Dao())...)
+	if len(cmds) > maxCommands {
+		cmds = cmds[:maxCommands]
+	}
 	if err := b.SetCommands(cmds); err != nil {
 		slog.Error("set telegram bot commands error", "err", err)
 	} else {
@@ -112,13 +156,47 @@ func registerCommands(b *TeleBot) {
 
 func registerHandlers(b *TeleBot) {
 	b.Handle(tb.OnText, handler.OnText)
+	b.Handle(tb.OnVoice, handler.OnVoice)
+	b.Handle(tb.OnAudio, handler.OnVoice)
+	b.Handle(tb.OnDocument, handler.OnDocument)
+	b.Handle(tb.OnQuery, handler.OnQuery)
+	b.Handle(tb.OnInlineResult, handler.OnInlineResult)
+	b.Handle(&tb.Btn{Unique: handler.CallbackModel}, handler.OnModelCallback)
 }
 
-func Serve(app *pocketbase.PocketBase) {
+// Serve starts the bot in the background, in webhook mode the updates are received by the webhook route of router.
+// An invalid mode is returned as error, so that the app does not start without receiving updates.
+func Serve(app *pocketbase.PocketBase, router *echo.Echo) error {
+	cfg := config.GetConfig()
+	botMode, err := mode(cfg)
+	if err != nil {
+		return err
+	}
 	b := DefaultBot(app)
-	b.Use(contextMiddleware)
+	if b == nil {
+		return nil
+	}
+	b.Use(contextMiddleware, telemetryMiddleware, authMiddleware)
 	registerHandlers(b)
 	registerCommands(b)
-	slog.Info("Start telegram bot...")
-	b.Start()
+	// models of reloaded llm configs get their commands without restarts
+	config.OnChange(func() {
+		registerCommands(b)
+	})
+
+	if botMode == ModeWebhook {
+		pool := newUpdatePool(webhookPoller(cfg), cfg.Telegram.Workers)
+		router.POST(WebhookPath, webhookHandler(pool, cfg.Telegram.SecretToken))
+		b.Poller = pool
+		slog.Info("Start telegram bot by webhook...", "url", cfg.Service.URL+WebhookPath)
+	} else {
+		// telegram refuses updates to polling bots with a webhook
+		if err := b.RemoveWebhook(); err != nil {
+			slog.Error("remove telegram webhook error", "err", err)
+		}
+		b.Poller = newUpdatePool(b.Poller, cfg.Telegram.Workers)
+		slog.Info("Start telegram bot by polling...")
+	}
+	go b.Start()
+	return nil
 }
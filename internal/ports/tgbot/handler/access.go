@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/pocketbase/pocketbase/daos"
+	tb "gopkg.in/telebot.v3"
+)
+
+// OnLink binds the sender to the PocketBase user of an api key, the message of the key is deleted.
+func OnLink(c tb.Context, apiKey string) error {
+	if c.Chat().Type != tb.ChatPrivate {
+		if err := c.Delete(); err != nil {
+			slog.Warn("delete api key message error", "err", err)
+		}
+		return c.Send(fmt.Sprintf("Please send /%s in a private chat with the bot", CommandLink), sendOptions(c))
+	}
+	if apiKey == "" {
+		return c.Reply(fmt.Sprintf("Usage: /%s <api_key>", CommandLink))
+	}
+	if err := c.Delete(); err != nil {
+		slog.Warn("delete api key message error", "err", err)
+	}
+
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	telegramId := strconv.FormatInt(c.Sender().ID, 10)
+	user, err := auth.GetChatUser(ctx, tx, PlatformTelegram, telegramId)
+	if err != nil {
+		user = auth.ChatUser{Platform: PlatformTelegram, ExternalId: telegramId, Status: auth.ChatUserPending}
+	}
+	user.Username = c.Sender().Username
+	if _, err := auth.LinkChatUser(ctx, tx, user, apiKey); err != nil {
+		slog.Info("link telegram user error", "err", err, "telegram_id", telegramId)
+		return c.Send("Invalid api key")
+	}
+	return c.Send("Your telegram account is linked, your messages are served with your api key now")
+}
+
+// OnApprove allows a telegram user, only the admins of the config may approve users.
+func OnApprove(c tb.Context, telegramId string) error {
+	return setTelegramUserStatus(c, CommandApprove, telegramId, auth.ChatUserApproved)
+}
+
+// OnBan ignores all messages of a telegram user, only the admins of the config may ban users.
+func OnBan(c tb.Context, telegramId string) error {
+	return setTelegramUserStatus(c, CommandBan, telegramId, auth.ChatUserBanned)
+}
+
+func setTelegramUserStatus(c tb.Context, command, telegramId, status string) error {
+	if !slices.Contains(config.GetConfig().Telegram.Admins, c.Sender().ID) {
+		return c.Reply("Only admins can do this")
+	}
+	if _, err := strconv.ParseInt(telegramId, 10, 64); err != nil {
+		return c.Reply(fmt.Sprintf("Usage: /%s <telegram_id>", command))
+	}
+
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	if _, err := auth.SetChatUserStatus(ctx, ctx.Value(config.ContextKeyDao).(*daos.Dao), PlatformTelegram, telegramId, status); err != nil {
+		return fmt.Errorf("set telegram user status err: %v", err)
+	}
+	return c.Reply(fmt.Sprintf("User %s is %s", telegramId, status))
+}
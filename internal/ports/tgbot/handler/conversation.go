@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/core/chatstate"
+	"github.com/Vaayne/aienvoy/internal/core/llms"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/pocketbase/pocketbase/daos"
+	tb "gopkg.in/telebot.v3"
+)
+
+const historyLimit = 10
+
+// OnNewConversation starts a new conversation with the current model, a prompt after the command is sent to it.
+func OnNewConversation(c tb.Context, prompt string) error {
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	state, err := getChatState(c)
+	if err != nil {
+		return fmt.Errorf("get chat state err: %v", err)
+	}
+	if state.Model == "" {
+		return c.Reply("Please choose a model by /model <name> first")
+	}
+	state.ConversationId = ""
+	if prompt != "" {
+		return onLLMChat(c, state, state.Model, prompt)
+	}
+	if _, err := chatstate.Save(ctx, ctx.Value(config.ContextKeyDao).(*daos.Dao), state); err != nil {
+		return fmt.Errorf("save chat state err: %v", err)
+	}
+	return c.Reply(fmt.Sprintf("New conversation with %s started", state.Model))
+}
+
+// OnHistory lists the latest conversations of the chat
+func OnHistory(c tb.Context) error {
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	state, err := getChatState(c)
+	if err != nil {
+		return fmt.Errorf("get chat state err: %v", err)
+	}
+	conversations, err := chatstate.ListConversations(ctx, ctx.Value(config.ContextKeyDao).(*daos.Dao), state.Key(), historyLimit)
+	if err != nil {
+		return fmt.Errorf("list conversations err: %v", err)
+	}
+	if len(conversations) == 0 {
+		return c.Reply("No conversations yet")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Latest conversations, switch by /switch <id>\n")
+	for _, cov := range conversations {
+		active := ""
+		if cov.Id == state.ConversationId {
+			active = " (active)"
+		}
+		sb.WriteString(fmt.Sprintf("\n%s%s\n%s · %s · %s\n", cov.Id, active, cov.Name, cov.Model, cov.CreatedAt.Format("2006-01-02 15:04")))
+	}
+	return c.Reply(sb.String())
+}
+
+// OnSwitch continues a previous conversation of the chat
+func OnSwitch(c tb.Context, conversationId string) error {
+	if conversationId == "" {
+		return c.Reply("Usage: /switch <id>, list the ids by /history")
+	}
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	state, err := getChatState(c)
+	if err != nil {
+		return fmt.Errorf("get chat state err: %v", err)
+	}
+	state, err = chatstate.Switch(ctx, ctx.Value(config.ContextKeyDao).(*daos.Dao), state, conversationId)
+	if err != nil {
+		return c.Reply(err.Error())
+	}
+	return c.Reply(fmt.Sprintf("Switched to conversation %s with %s", state.ConversationId, state.Model))
+}
+
+// OnModel changes the model of the chat, the active conversation is continued by it.
+// Without a model it shows the current model and a picker of the served models.
+func OnModel(c tb.Context, model string) error {
+	if model == "" {
+		ctx := c.Get(config.ContextKeyContext).(context.Context)
+		state, err := getChatState(c)
+		if err != nil {
+			return fmt.Errorf("get chat state err: %v", err)
+		}
+		current := state.Model
+		if current == "" {
+			current = "none"
+		}
+		return c.Reply(fmt.Sprintf("Current model is %s, pick one or change it by /model <name>", current),
+			modelPicker(ctx.Value(config.ContextKeyDao).(*daos.Dao), state.Model))
+	}
+	reply, err := changeModel(c, model)
+	if err != nil {
+		return err
+	}
+	return c.Reply(reply)
+}
+
+// changeModel saves model as the model of the chat, it returns the reply to the user.
+func changeModel(c tb.Context, model string) (string, error) {
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	if _, err := llms.New(model); err != nil {
+		return fmt.Sprintf("Model %s is not supported", model), nil
+	}
+	if !auth.ContextAllowsModel(ctx, model) {
+		return fmt.Sprintf("Model %s is not allowed for your api key", model), nil
+	}
+	state, err := getChatState(c)
+	if err != nil {
+		return "", fmt.Errorf("get chat state err: %v", err)
+	}
+	state.Model = model
+	if _, err := chatstate.Save(ctx, ctx.Value(config.ContextKeyDao).(*daos.Dao), state); err != nil {
+		return "", fmt.Errorf("save chat state err: %v", err)
+	}
+	return fmt.Sprintf("Model changed to %s", model), nil
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Vaayne/aienvoy/internal/core/llms"
+	"github.com/Vaayne/aienvoy/internal/core/readease"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/pkg/docextract"
+	"github.com/pocketbase/pocketbase/daos"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	// maxDocumentSize is the limit of files which bots can download
+	maxDocumentSize = 20 << 20
+	// documentChunkSize and defaultContextLength size the parts of documents attached to conversations,
+	// documents take at most half of the context, about 4 bytes per token
+	documentChunkSize    = 2000
+	defaultContextLength = 8192
+	documentURLPrefix    = "telegram://document/"
+)
+
+// OnDocument reads PDF, DOCX, markdown, code and CSV files. The caption of a document is a question about it,
+// the document is attached to the active conversation for follow-up questions. Documents without caption are
+// summarized by readease.
+func OnDocument(c tb.Context) error {
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	ctx, cancel := context.WithTimeout(ctx, 60*10*time.Second)
+	defer cancel()
+	doc := c.Message().Document
+	if doc == nil {
+		return c.Reply("empty document")
+	}
+
+	article, err := readDocument(c, ctx, doc)
+	if errors.Is(err, docextract.ErrUnsupported) {
+		return c.Reply(fmt.Sprintf("Unsupported document %s, send PDF, DOCX, markdown, code or CSV files", doc.FileName))
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "read telegram document error", "err", err, "name", doc.FileName)
+		return c.Reply(fmt.Sprintf("Failed to read %s: %v", doc.FileName, err))
+	}
+
+	state, err := getChatState(c)
+	if err != nil {
+		return fmt.Errorf("get chat state err: %v", err)
+	}
+	if state.Model == "" {
+		return c.Reply("Please choose a model by /model <name> first")
+	}
+
+	question := strings.TrimSpace(c.Message().Caption)
+	if question == "" {
+		return readEase(c, ctx, article.Url, state.Model, fmt.Sprintf("please wait a moment, I am reading %s...", doc.FileName))
+	}
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	prompt := documentPrompt(question, article.Title, article.Content, contextBudget(tx, state.Model))
+	return onLLMChat(c, state, state.Model, prompt)
+}
+
+// readDocument returns the stored content of a document, new documents are downloaded and extracted once.
+func readDocument(c tb.Context, ctx context.Context, doc *tb.Document) (*readease.Article, error) {
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	url := documentURLPrefix + doc.UniqueID
+	article, err := readease.GetArticleByUrl(ctx, tx, url)
+	if err != nil {
+		slog.ErrorContext(ctx, "get document from db error", "err", err)
+	}
+	if article != nil && article.Content != "" {
+		return article, nil
+	}
+
+	if doc.FileSize > maxDocumentSize {
+		return nil, fmt.Errorf("the document is larger than %d MB", maxDocumentSize>>20)
+	}
+	file, err := c.Bot().File(&doc.File)
+	if err != nil {
+		return nil, fmt.Errorf("download document error: %w", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxDocumentSize))
+	if err != nil {
+		return nil, fmt.Errorf("download document error: %w", err)
+	}
+	content, err := docextract.Extract(doc.FileName, doc.MIME, data)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("no text found in the document")
+	}
+
+	article = &readease.Article{Url: url, OriginalUrl: url, Title: doc.FileName, Content: content}
+	if err := readease.UpsertArticle(ctx, tx, article); err != nil {
+		slog.ErrorContext(ctx, "save document error", "err", err)
+	}
+	return article, nil
+}
+
+// contextBudget is the size of the document parts of a prompt for model
+func contextBudget(tx *daos.Dao, model string) int {
+	contextLength := defaultContextLength
+	for _, m := range llms.DescribeModels(llms.NewDao(tx)) {
+		if m.ID == model && m.ContextLength > 0 {
+			contextLength = m.ContextLength
+			break
+		}
+	}
+	return contextLength * 2
+}
+
+// documentPrompt attaches the document to the question, long documents are cut to the parts related to it
+func documentPrompt(question, name, content string, budget int) string {
+	if len(content) > budget {
+		parts := docextract.Select(docextract.Chunk(content, documentChunkSize), question, budget)
+		content = strings.Join(parts, "\n...\n")
+	}
+	return fmt.Sprintf("%s\n\nAnswer by the document enclosed within the XML tag <document>.\n<document name=%q>\n%s\n</document>", question, name, content)
+}
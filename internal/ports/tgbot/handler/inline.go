@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/internal/core/chatstate"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/google/uuid"
+	"github.com/pocketbase/pocketbase/daos"
+	tb "gopkg.in/telebot.v3"
+)
+
+// OnQuery offers to ask the model of the private chat with the bot, the answer is streamed into the
+// inline message after the result is chosen. Queries are sent while typing, so they don't call models.
+func OnQuery(c tb.Context) error {
+	question := strings.TrimSpace(c.Query().Text)
+	resp := &tb.QueryResponse{CacheTime: 0, IsPersonal: true, Results: tb.Results{}}
+	model, err := inlineModel(c)
+	if err != nil {
+		return err
+	}
+	if model == "" {
+		resp.SwitchPMText = "Choose a model first"
+		resp.SwitchPMParameter = CommandModel
+		return c.Answer(resp)
+	}
+	if question == "" {
+		return c.Answer(resp)
+	}
+
+	// an inline keyboard is required for the id of the inline message
+	markup := &tb.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.URL("Open "+c.Bot().Me.Username, "https://t.me/"+c.Bot().Me.Username)))
+	result := &tb.ArticleResult{
+		Title:       "Ask " + model,
+		Description: question,
+		Text:        fmt.Sprintf("%s\n\nWaiting for %s ...", question, model),
+	}
+	result.SetResultID(uuid.NewString())
+	result.SetReplyMarkup(markup)
+	resp.Results = append(resp.Results, result)
+	return c.Answer(resp)
+}
+
+// OnInlineResult answers the chosen inline query, the inline feedback of the bot has to be enabled.
+func OnInlineResult(c tb.Context) error {
+	result := c.InlineResult()
+	if result.MessageID == "" {
+		return nil
+	}
+	model, err := inlineModel(c)
+	if err != nil || model == "" {
+		return err
+	}
+	state, err := getChatState(c)
+	if err != nil {
+		return fmt.Errorf("get chat state err: %v", err)
+	}
+	// every inline query is a conversation of its own
+	state.ConversationId = ""
+	return llmChat(c, state, model, result.Query, result)
+}
+
+// inlineModel is the model of the private chat of the user with the bot
+func inlineModel(c tb.Context) (string, error) {
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	key := chatstate.Key{Platform: PlatformTelegram, ChatId: strconv.FormatInt(c.Sender().ID, 10)}
+	state, err := chatstate.Get(ctx, ctx.Value(config.ContextKeyDao).(*daos.Dao), key)
+	if err != nil {
+		return "", fmt.Errorf("get chat state err: %v", err)
+	}
+	return state.Model, nil
+}
@@ -6,26 +6,45 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/Vaayne/aienvoy/internal/core/auth"
+	"github.com/Vaayne/aienvoy/internal/core/chatstate"
 	"github.com/Vaayne/aienvoy/internal/core/llms"
 	"github.com/Vaayne/aienvoy/internal/pkg/config"
 	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/llm/semanticcache"
 	"github.com/pocketbase/pocketbase/daos"
 	tb "gopkg.in/telebot.v3"
 )
 
-func onLLMChat(c tb.Context, conversationId, model, prompt string) error {
+// onLLMChat sends prompt to the active conversation of the chat, a new one is created when there is none.
+func onLLMChat(c tb.Context, state chatstate.State, model, prompt string) error {
+	return llmChat(c, state, model, prompt, nil)
+}
+
+// llmChat streams the answer to msg, a new message is sent when msg is nil
+func llmChat(c tb.Context, state chatstate.State, model, prompt string, msg tb.Editable) error {
 	ctx := c.Get(config.ContextKeyContext).(context.Context)
-	svc, err := llms.NewWithDao(model, llms.NewDao(ctx.Value(config.ContextKeyDao).(*daos.Dao)))
+	tx := ctx.Value(config.ContextKeyDao).(*daos.Dao)
+	if !auth.ContextAllowsModel(ctx, model) {
+		return c.Reply(fmt.Sprintf("Model %s is not allowed for your api key", model))
+	}
+	svc, err := llms.NewWithDao(model, llms.NewDao(tx))
 	if err != nil {
 		return fmt.Errorf("init llm service err: %v", err)
 	}
-	if conversationId == "" {
-		cov, err := svc.CreateConversation(ctx, "")
-		if err != nil {
-			return fmt.Errorf("create conversation err: %v", err)
+	// the answers of continued conversations depend on their history, they are not cached
+	newConversation := state.ConversationId == ""
+	if newConversation {
+		if state, err = chatstate.NewConversation(ctx, tx, state, model, prompt); err != nil {
+			return err
+		}
+	} else if state.Model != model {
+		state.Model = model
+		if state, err = chatstate.Save(ctx, tx, state); err != nil {
+			return fmt.Errorf("save chat state err: %v", err)
 		}
-		conversationId = cov.Id
 	}
+	conversationId := state.ConversationId
 	req := llm.ChatCompletionRequest{
 		Model: model,
 		Messages: []llm.ChatCompletionMessage{
@@ -37,29 +56,49 @@ func onLLMChat(c tb.Context, conversationId, model, prompt string) error {
 		Stream: true,
 	}
 
+	var cache *semanticcache.Cache
+	var cacheResult semanticcache.Result
+	if newConversation {
+		cache, cacheResult = lookupSemanticCache(ctx, req)
+	}
+	if cacheResult.Hit && len(cacheResult.Response.Choices) > 0 {
+		saveCachedMessage(ctx, conversationId, req, cacheResult.Response)
+		resp := newStreamResponse(c, ctx, msg)
+		resp.footer = model
+		resp.text = cacheResult.Response.Choices[0].Message.Content
+		if err := resp.finish(io.EOF); err != nil {
+			return err
+		}
+		if state.VoiceReply {
+			replyVoice(c, ctx, resp.text)
+		}
+		return nil
+	}
+
 	respChan := make(chan llm.ChatCompletionStreamResponse)
 	defer close(respChan)
 	errChan := make(chan error)
 	defer close(errChan)
-	msg, err := c.Bot().Send(c.Sender(), "Waiting for response ...")
-	if err != nil {
-		return fmt.Errorf("chat with ChatGPT err: %v", err)
+	if msg == nil {
+		if msg, err = c.Bot().Send(c.Recipient(), fmt.Sprintf("Waiting for %s ...", model), sendOptions(c)); err != nil {
+			return fmt.Errorf("chat with ChatGPT err: %v", err)
+		}
 	}
 	go svc.CreateMessageStream(ctx, conversationId, req, respChan, errChan)
-	text := ""
-	chunk := ""
+	stream := newStreamResponse(c, ctx, msg)
+	stream.footer = model
 
 	for {
 		select {
 		case resp := <-respChan:
-			text, chunk = processResponse(c, ctx, msg, resp.Choices[0].Delta.Content, text, chunk)
+			stream.process(resp.DeltaContent())
 		case err := <-errChan:
-			newErr := processError(c, ctx, msg, text, err)
+			newErr := stream.finish(err)
 			if errors.Is(err, io.EOF) {
-				setLLMConversationToCache(LLMCache{
-					Model:          model,
-					ConversationId: conversationId,
-				})
+				saveSemanticCache(ctx, cache, cacheResult, model, stream.text)
+				if state.VoiceReply && newErr == nil {
+					replyVoice(c, ctx, stream.text)
+				}
 			}
 			return newErr
 		case <-ctx.Done():
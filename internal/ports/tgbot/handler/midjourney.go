@@ -32,7 +32,7 @@ func OnMidJourneyImagine(c tb.Context) error {
 		return c.Send(fmt.Sprintf("create midjourney job error: %s", err))
 	}
 
-	msg, err := c.Bot().Send(c.Sender(), "MidJourney job started, please wait ...")
+	msg, err := c.Bot().Send(c.Recipient(), "MidJourney job started, please wait ...", sendOptions(c))
 	if err != nil {
 		return fmt.Errorf("send message to user err: %v", err)
 	}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/internal/core/llms"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/pocketbase/pocketbase/daos"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	// CallbackModel is the unique of the buttons of the model picker
+	CallbackModel = "model"
+	// maxCommandLen and maxCallbackDataLen are the limits of the telegram bot api
+	maxCommandLen      = 32
+	maxCallbackDataLen = 64
+	modelPickerColumns = 2
+)
+
+var builtinCommands = []string{
+	CommandNew, CommandHistory, CommandSwitch, CommandModel, CommandLink,
+	CommandApprove, CommandBan, CommandRead, CommandImagine, CommandVoice, CommandTranscript,
+	CommandExplain, CommandTranslate, CommandStart,
+}
+
+// commandName converts model to a bot command, commands only have lowercase letters, digits and underscores.
+func commandName(model string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '_'
+	}, model)
+	if len(name) > maxCommandLen {
+		name = name[:maxCommandLen]
+	}
+	return name
+}
+
+// modelCommands maps the commands of the served models and the aliases of the config to the models,
+// aliases take precedence and models of clashing commands are only picked by /model.
+func modelCommands(tx *daos.Dao) map[string]string {
+	commands := make(map[string]string)
+	for _, model := range llms.Models(llms.NewDao(tx)) {
+		cmd := commandName(model)
+		if _, ok := commands[cmd]; ok || slices.Contains(builtinCommands, cmd) {
+			continue
+		}
+		commands[cmd] = model
+	}
+	for alias, model := range config.GetConfig().Telegram.Aliases {
+		if cmd := commandName(alias); !slices.Contains(builtinCommands, cmd) {
+			commands[cmd] = model
+		}
+	}
+	return commands
+}
+
+// ModelCommands returns the bot commands of the served models and aliases, sorted by command.
+func ModelCommands(tx *daos.Dao) []tb.Command {
+	commands := modelCommands(tx)
+	cmds := make([]tb.Command, 0, len(commands))
+	for cmd, model := range commands {
+		cmds = append(cmds, tb.Command{Text: cmd, Description: "Chat using " + model})
+	}
+	slices.SortFunc(cmds, func(a, b tb.Command) int {
+		return strings.Compare(a.Text, b.Text)
+	})
+	return cmds
+}
+
+// modelPicker is an inline keyboard of the served models, the current model is marked.
+func modelPicker(tx *daos.Dao, current string) *tb.ReplyMarkup {
+	markup := &tb.ReplyMarkup{}
+	btns := make([]tb.Btn, 0)
+	for _, model := range llms.Models(llms.NewDao(tx)) {
+		// the callback data is "\f<unique>|<model>"
+		if len(model)+len(CallbackModel)+2 > maxCallbackDataLen {
+			continue
+		}
+		text := model
+		if model == current {
+			text = "✅ " + model
+		}
+		btns = append(btns, markup.Data(text, CallbackModel, model))
+	}
+	markup.Inline(markup.Split(modelPickerColumns, btns)...)
+	return markup
+}
+
+// OnModelCallback changes the model of the chat by the button of the model picker
+func OnModelCallback(c tb.Context) error {
+	reply, err := changeModel(c, c.Data())
+	if err != nil {
+		return err
+	}
+	if err := c.Respond(&tb.CallbackResponse{Text: reply}); err != nil {
+		return fmt.Errorf("respond model callback err: %v", err)
+	}
+	return c.Edit(reply)
+}
@@ -1,26 +1,30 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"strings"
 
-	"github.com/Vaayne/aienvoy/pkg/llm/bard"
-	"github.com/Vaayne/aienvoy/pkg/llm/claude"
-	"github.com/Vaayne/aienvoy/pkg/llm/claudeweb"
-	"github.com/Vaayne/aienvoy/pkg/llm/openai"
-
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/pocketbase/pocketbase/daos"
 	tb "gopkg.in/telebot.v3"
 )
 
 const (
-	CommandBard          = "bard"
-	CommandRead          = "read"
-	CommandChatGPT35     = "gpt35"
-	CommandChatGPT4      = "gpt4"
-	CommandClaudeWeb     = "claude_web"
-	CommandClaudeV2      = "claude_v2"
-	CommandClaudeV1      = "claude_v1"
-	CommandClaudeInstant = "claude_instant"
-	CommandImagine       = "imagine"
+	CommandRead       = "read"
+	CommandImagine    = "imagine"
+	CommandNew        = "new"
+	CommandHistory    = "history"
+	CommandSwitch     = "switch"
+	CommandModel      = "model"
+	CommandLink       = "link"
+	CommandApprove    = "approve"
+	CommandBan        = "ban"
+	CommandVoice      = "voice"
+	CommandTranscript = "transcript"
+	CommandExplain    = "explain"
+	CommandTranslate  = "translate"
+	CommandStart      = "start"
 )
 
 func OnText(c tb.Context) error {
@@ -33,50 +37,70 @@ func OnText(c tb.Context) error {
 	prompt := text
 	if text[0] == '/' {
 		texts := strings.Split(text, " ")
-		model = texts[0][1:]
-		if len(texts) > 1 {
-			prompt = strings.Join(texts[1:], " ")
-		} else {
+		// commands of groups are like /model@bot
+		model, _, _ = strings.Cut(texts[0][1:], "@")
+		args := strings.TrimSpace(strings.Join(texts[1:], " "))
+		prompt = args
+		if prompt == "" {
 			prompt = "hello"
 		}
 
 		switch model {
-		case CommandBard:
-			model = bard.ModelBard
+		case CommandStart:
+			// the inline mode sends users without model to /start model
+			return OnModel(c, "")
+		case CommandExplain:
+			return OnExplain(c, args)
+		case CommandTranslate:
+			return OnTranslate(c, args)
+		case CommandNew:
+			return OnNewConversation(c, args)
+		case CommandHistory:
+			return OnHistory(c)
+		case CommandSwitch:
+			return OnSwitch(c, args)
+		case CommandModel:
+			return OnModel(c, args)
+		case CommandLink:
+			return OnLink(c, args)
+		case CommandApprove:
+			return OnApprove(c, args)
+		case CommandBan:
+			return OnBan(c, args)
+		case CommandVoice:
+			return OnVoiceReply(c, args)
+		case CommandTranscript:
+			return OnShowTranscript(c, args)
 		case CommandRead:
 			return OnReadEase(c)
-		case CommandChatGPT35:
-			model = openai.ModelGPT3Dot5Turbo
-		case CommandChatGPT4:
-			model = openai.ModelGPT4
-		case CommandClaudeWeb:
-			model = claudeweb.ModelClaude2
-		case CommandClaudeV2:
-			model = claude.ModelClaudeV2
-		case CommandClaudeV1:
-			model = claude.ModelClaudeV1Dot3
-		case CommandClaudeInstant:
-			model = claude.ModelClaudeInstantV1Dot2
 		case CommandImagine:
 			return OnMidJourneyImagine(c)
 		default:
-			return c.Reply("Unsupported command!")
+			// other commands are the models of the registry and the aliases of the config
+			ctx := c.Get(config.ContextKeyContext).(context.Context)
+			var ok bool
+			if model, ok = modelCommands(ctx.Value(config.ContextKeyDao).(*daos.Dao))[model]; !ok {
+				return c.Reply("Unsupported command!")
+			}
 		}
 	}
 
-	// 1. create new conversation, no cache, model != ""
-	// 2. create new conversation, use cache, model != ""
-	// 3. use cache, model == ""
-	llmCache, ok := getLLMConversationFromCache()
-	if ok {
-		if model == "" {
-			model = llmCache.Model
-		}
-		return onLLMChat(c, llmCache.ConversationId, model, prompt)
+	return chat(c, model, withReplyContext(c, prompt))
+}
+
+// chat sends prompt to the chat, a model starts a new conversation of the chat and an empty one
+// continues the active conversation.
+func chat(c tb.Context, model, prompt string) error {
+	state, err := getChatState(c)
+	if err != nil {
+		return fmt.Errorf("get chat state err: %v", err)
 	}
 	if model != "" {
-		return onLLMChat(c, "", model, prompt)
+		state.ConversationId = ""
+		return onLLMChat(c, state, model, prompt)
 	}
-
-	return c.Reply("Unsupported message")
+	if state.Model == "" {
+		return c.Reply("Please choose a model by /model <name> first")
+	}
+	return onLLMChat(c, state, state.Model, prompt)
 }
@@ -28,7 +28,12 @@ func OnReadEase(c tb.Context) error {
 		return c.Reply(fmt.Sprintf("invalid url %s, please check and try again", urlStr))
 	}
 
-	msg, err := c.Bot().Send(c.Sender(), "please wait a moment, I am reading the article...")
+	return readEase(c, ctx, urlStr, "gemini-pro", "please wait a moment, I am reading the article...")
+}
+
+// readEase streams the readease summary of url, documents are read by their stored url too
+func readEase(c tb.Context, ctx context.Context, urlStr, model, waiting string) error {
+	msg, err := c.Bot().Send(c.Recipient(), waiting, sendOptions(c))
 	if err != nil {
 		return fmt.Errorf("summary article err: %v", err)
 	}
@@ -40,17 +45,16 @@ func OnReadEase(c tb.Context) error {
 	defer close(respChan)
 	defer close(errChan)
 
-	go reader.ReadStream(ctx, urlStr, "gemini-pro", respChan, errChan)
+	go reader.ReadStream(ctx, urlStr, model, respChan, errChan)
 
-	text := ""
-	chunk := ""
+	stream := newStreamResponse(c, ctx, msg)
 
 	for {
 		select {
 		case resp := <-respChan:
-			text, chunk = processResponse(c, ctx, msg, resp.Choices[0].Delta.Content, text, chunk)
+			stream.process(resp.DeltaContent())
 		case err := <-errChan:
-			return processError(c, ctx, msg, text, err)
+			return stream.finish(err)
 		case <-ctx.Done():
 			return processContextDone(ctx)
 		}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// OnExplain explains the message replied to, or the text after the command
+func OnExplain(c tb.Context, args string) error {
+	text, notes := repliedMessage(c), ""
+	if text == "" {
+		text = args
+	} else {
+		notes = args
+	}
+	if text == "" {
+		return c.Reply(fmt.Sprintf("Reply to a message with /%s, or send /%s <text>", CommandExplain, CommandExplain))
+	}
+	prompt := "Explain the following message enclosed within the XML tag <message>."
+	if notes != "" {
+		prompt += " " + notes
+	}
+	return chat(c, "", fmt.Sprintf("%s\n%s", prompt, quote(text)))
+}
+
+// OnTranslate translates the message replied to into the language after the command, or the text after
+// the command. The language defaults to the one of the user.
+func OnTranslate(c tb.Context, args string) error {
+	text, language := repliedMessage(c), args
+	if text == "" {
+		text, language = args, ""
+	}
+	if text == "" {
+		return c.Reply(fmt.Sprintf("Reply to a message with /%s [language], or send /%s <text>", CommandTranslate, CommandTranslate))
+	}
+	if language == "" {
+		language = c.Sender().LanguageCode
+	}
+	if language == "" {
+		language = "en"
+	}
+	prompt := fmt.Sprintf("Translate the following message enclosed within the XML tag <message> to the language %s, "+
+		"if it already is in that language translate it to English. Only answer the translation.", language)
+	return chat(c, "", fmt.Sprintf("%s\n%s", prompt, quote(text)))
+}
+
+// withReplyContext adds the message replied to to prompt, replies to answers of the bot are already
+// in the conversation.
+func withReplyContext(c tb.Context, prompt string) string {
+	text := repliedMessage(c)
+	if text == "" {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n\nThe message I reply to is enclosed within the XML tag <message>.\n%s", prompt, quote(text))
+}
+
+// repliedMessage returns the message replied to with its sender, forwarded messages keep the original sender.
+func repliedMessage(c tb.Context) string {
+	msg := c.Message()
+	if msg == nil || msg.ReplyTo == nil {
+		return ""
+	}
+	reply := msg.ReplyTo
+	if reply.Sender != nil && reply.Sender.ID == c.Bot().Me.ID {
+		return ""
+	}
+	text := reply.Text
+	if text == "" {
+		text = reply.Caption
+	}
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+
+	var from string
+	switch {
+	case reply.OriginalSender != nil:
+		from = userName(reply.OriginalSender)
+	case reply.OriginalSenderName != "":
+		from = reply.OriginalSenderName
+	case reply.OriginalChat != nil:
+		from = reply.OriginalChat.Title
+	case reply.Sender != nil:
+		from = userName(reply.Sender)
+	}
+	if from == "" {
+		return text
+	}
+	return fmt.Sprintf("%s: %s", from, text)
+}
+
+func userName(u *tb.User) string {
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" {
+		name = u.Username
+	}
+	return name
+}
+
+func quote(text string) string {
+	return "<message>\n" + text + "\n</message>"
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Vaayne/aienvoy/internal/core/llms"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/llm/semanticcache"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// lookupSemanticCache returns nil cache when semantic cache is not enabled for telegram
+func lookupSemanticCache(ctx context.Context, req llm.ChatCompletionRequest) (*semanticcache.Cache, semanticcache.Result) {
+	cfg := config.GetConfig().SemanticCache
+	if !cfg.Enabled || !cfg.Telegram {
+		return nil, semanticcache.Result{}
+	}
+	cache, err := llms.GetSemanticCache(ctx.Value(config.ContextKeyDao).(*daos.Dao))
+	if err != nil {
+		slog.ErrorContext(ctx, "get semantic cache error", "err", err)
+		return nil, semanticcache.Result{}
+	}
+	result, err := cache.Lookup(ctx, semanticCacheScope(ctx), req)
+	if err != nil {
+		slog.ErrorContext(ctx, "semantic cache lookup error", "err", err, "model", req.Model)
+		return nil, semanticcache.Result{}
+	}
+	return cache, result
+}
+
+// semanticCacheScope is the api key of linked users, the other users share the llm keys of the bot
+func semanticCacheScope(ctx context.Context) string {
+	if record, ok := ctx.Value(config.ContextKeyAuthRecord).(*models.Record); ok {
+		return record.Id
+	}
+	return PlatformTelegram
+}
+
+func saveSemanticCache(ctx context.Context, cache *semanticcache.Cache, result semanticcache.Result, model, text string) {
+	if cache == nil {
+		return
+	}
+	resp := llm.ChatCompletionResponse{
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []llm.ChatCompletionChoice{
+			{
+				Message: llm.ChatCompletionMessage{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: text,
+				},
+				FinishReason: llm.FinishReasonStop,
+			},
+		},
+	}
+	if err := cache.Save(ctx, result, resp, ""); err != nil {
+		slog.ErrorContext(ctx, "save semantic cache error", "err", err)
+	}
+}
+
+// saveCachedMessage keeps the conversation history complete when answer is from semantic cache
+func saveCachedMessage(ctx context.Context, conversationId string, req llm.ChatCompletionRequest, resp llm.ChatCompletionResponse) {
+	dao := llms.NewDao(ctx.Value(config.ContextKeyDao).(*daos.Dao))
+	if _, err := dao.SaveMessage(ctx, llm.Message{
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		ConversationId: conversationId,
+		Model:          req.Model,
+		Description:    "semantic cache",
+		Request:        req,
+		Response:       resp,
+	}); err != nil {
+		slog.ErrorContext(ctx, "save cached message error", "err", err, "conversation_id", conversationId)
+	}
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Vaayne/aienvoy/internal/core/chatstate"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/pocketbase/pocketbase/daos"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	// PlatformTelegram is the platform of the chats and the accounts of telegram
+	PlatformTelegram = "telegram"
+	// threadInline is the thread of the inline queries of a user, they are kept apart from the private chat
+	threadInline = "inline"
+)
+
+// chatKey keys the state by chat and forum topic, so that users and groups don't share conversations
+func chatKey(c tb.Context) chatstate.Key {
+	if c.Chat() == nil {
+		return chatstate.Key{Platform: PlatformTelegram, ChatId: strconv.FormatInt(c.Sender().ID, 10), ThreadId: threadInline}
+	}
+	key := chatstate.Key{Platform: PlatformTelegram, ChatId: strconv.FormatInt(c.Chat().ID, 10)}
+	if msg := c.Message(); msg != nil && msg.ThreadID != 0 {
+		key.ThreadId = strconv.Itoa(msg.ThreadID)
+	}
+	return key
+}
+
+func getChatState(c tb.Context) (chatstate.State, error) {
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	return chatstate.Get(ctx, ctx.Value(config.ContextKeyDao).(*daos.Dao), chatKey(c))
+}
+
+// sendOptions sends messages to the forum topic of the update
+func sendOptions(c tb.Context) *tb.SendOptions {
+	opts := &tb.SendOptions{}
+	if msg := c.Message(); msg != nil {
+		opts.ThreadID = msg.ThreadID
+	}
+	return opts
+}
@@ -4,49 +4,185 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"log/slog"
 	"strings"
 
+	"github.com/Vaayne/aienvoy/pkg/tgmarkdown"
 	tb "gopkg.in/telebot.v3"
 )
 
-func processResponse(c tb.Context, ctx context.Context, msg *tb.Message, textChan, text, chunk string) (string, string) {
-	newText := text + textChan
-	newChunk := chunk + textChan
-	if strings.TrimSpace(chunk) == "" {
-		return newText, newChunk
+const (
+	// editInterval is the length of the new text of a stream before the messages are edited again
+	editInterval = 200
+	// codeFileThreshold sends longer code blocks of answers as files
+	codeFileThreshold = 3000
+)
+
+// streamResponse renders a streaming answer as HTML, long answers continue in new messages.
+// Inline messages can't have more messages, their answers are cut instead.
+type streamResponse struct {
+	c   tb.Context
+	ctx context.Context
+	// footer is shown at the end of the answer, like the model of it
+	footer string
+
+	text   string
+	chunk  string
+	msgs   []tb.Editable
+	sent   []string
+	inline bool
+}
+
+// newStreamResponse continues in msg, the first message is sent by the first edit when msg is nil
+func newStreamResponse(c tb.Context, ctx context.Context, msg tb.Editable) *streamResponse {
+	r := &streamResponse{c: c, ctx: ctx}
+	switch m := msg.(type) {
+	case *tb.Message:
+		r.msgs = append(r.msgs, m)
+		r.sent = append(r.sent, m.Text)
+	case *tb.InlineResult:
+		r.msgs = append(r.msgs, m)
+		r.sent = append(r.sent, "")
+		r.inline = true
 	}
-	if len(newChunk) >= 200 {
-		// slog.DebugContext(ctx, "response with text", "text", text)
-		//nolint
-		newMsg, err := c.Bot().Edit(msg, newText)
-		if err != nil {
-			slog.WarnContext(ctx, "telegram bot edit msg err", "err", err)
+	return r
+}
+
+func (r *streamResponse) process(delta string) {
+	r.text += delta
+	r.chunk += delta
+	if len(r.chunk) < editInterval || strings.TrimSpace(r.chunk) == "" {
+		return
+	}
+	messages, _ := tgmarkdown.Format(r.text, tgmarkdown.Options{})
+	_ = r.update(messages)
+	r.chunk = ""
+}
+
+// finish sends the whole answer after the stream ends, long code blocks are sent as files
+func (r *streamResponse) finish(err error) error {
+	if errors.Is(err, InvalidURLError) {
+		return r.c.Reply("invalid url, please check and try again")
+	}
+	if !errors.Is(err, io.EOF) {
+		if len(r.msgs) > 0 {
+			if _, editErr := r.c.Bot().Edit(r.msgs[len(r.msgs)-1], err.Error()); editErr != nil && !isEdited(editErr) {
+				slog.ErrorContext(r.ctx, "telegram bot edit msg err", "err", editErr, "text", r.text)
+			}
+		}
+		return fmt.Errorf("telegram bot process err: %v", err)
+	}
+
+	opts := tgmarkdown.Options{FileThreshold: codeFileThreshold}
+	if r.inline {
+		opts.FileThreshold = 0
+	}
+	messages, files := tgmarkdown.Format(r.text, opts)
+	if r.footer != "" {
+		footer := "<i>— " + html.EscapeString(r.footer) + "</i>"
+		if n := len(messages); n > 0 && len(messages[n-1])+len(footer)+2 <= tgmarkdown.MaxMessageLength {
+			messages[n-1] += "\n\n" + footer
 		} else {
-			//nolint
-			msg = newMsg
+			messages = append(messages, footer)
 		}
-		newChunk = ""
 	}
-	return newText, newChunk
+	if err := r.update(messages); err != nil {
+		return err
+	}
+	// the parts of streaming code which moved to files are deleted
+	for _, msg := range r.msgs[len(messages):] {
+		if err := r.c.Bot().Delete(msg); err != nil {
+			slog.WarnContext(r.ctx, "telegram bot delete msg err", "err", err)
+		}
+	}
+	r.msgs, r.sent = r.msgs[:len(messages)], r.sent[:len(messages)]
+
+	for _, file := range files {
+		doc := &tb.Document{File: tb.FromReader(strings.NewReader(file.Content)), FileName: file.Name}
+		if _, err := r.c.Bot().Send(r.c.Recipient(), doc, sendOptions(r.c)); err != nil {
+			slog.ErrorContext(r.ctx, "telegram send code file err", "err", err, "file", file.Name)
+			return err
+		}
+	}
+	return nil
 }
 
-func processError(c tb.Context, ctx context.Context, msg *tb.Message, text string, err error) error {
-	if errors.Is(err, InvalidURLError) {
-		return c.Reply("invalid url, please check and try again")
-	} else if errors.Is(err, io.EOF) {
-		// send last message
-		if _, err := c.Bot().Edit(msg, text); err != nil {
-			slog.WarnContext(ctx, "telegram send last msg err", "err", err)
+// update edits the sent messages which changed and sends the new ones
+func (r *streamResponse) update(messages []string) error {
+	if r.inline && len(messages) > 1 {
+		messages = []string{messages[0] + "\n\n<i>…</i>"}
+	}
+	for i, text := range messages {
+		if i < len(r.msgs) {
+			if r.sent[i] == text {
+				continue
+			}
+			msg, err := r.edit(r.msgs[i], text)
+			if err != nil {
+				slog.WarnContext(r.ctx, "telegram bot edit msg err", "err", err)
+				return err
+			}
+			r.msgs[i], r.sent[i] = msg, text
+			continue
+		}
+		msg, err := r.send(text)
+		if err != nil {
+			slog.WarnContext(r.ctx, "telegram bot send msg err", "err", err)
 			return err
 		}
-		return nil
+		r.msgs = append(r.msgs, msg)
+		r.sent = append(r.sent, text)
+	}
+	return nil
+}
+
+// edit falls back to plain text when telegram rejects the HTML, edits of inline messages return no message
+func (r *streamResponse) edit(msg tb.Editable, text string) (tb.Editable, error) {
+	newMsg, err := r.c.Bot().Edit(msg, text, tb.ModeHTML)
+	if err != nil && !isEdited(err) {
+		slog.WarnContext(r.ctx, "telegram bot edit html msg err, retry as text", "err", err)
+		if newMsg, err = r.c.Bot().Edit(msg, plainText(text)); err != nil && !isEdited(err) {
+			return nil, err
+		}
+	}
+	if newMsg == nil {
+		return msg, nil
+	}
+	return newMsg, nil
+}
+
+func isEdited(err error) bool {
+	return errors.Is(err, tb.ErrTrueResult) || errors.Is(err, tb.ErrSameMessageContent) || errors.Is(err, tb.ErrMessageNotModified)
+}
+
+func (r *streamResponse) send(text string) (tb.Editable, error) {
+	opts := sendOptions(r.c)
+	opts.ParseMode = tb.ModeHTML
+	msg, err := r.c.Bot().Send(r.c.Recipient(), text, opts)
+	if err != nil {
+		slog.WarnContext(r.ctx, "telegram bot send html msg err, retry as text", "err", err)
+		return r.c.Bot().Send(r.c.Recipient(), plainText(text), sendOptions(r.c))
 	}
-	if _, err = c.Bot().Edit(msg, err.Error()); err != nil {
-		slog.ErrorContext(ctx, "telegram bot edit msg err", "err", err, "text", text)
+	return msg, nil
+}
+
+// plainText drops the tags of rendered HTML
+func plainText(s string) string {
+	var sb strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			sb.WriteRune(r)
+		}
 	}
-	return fmt.Errorf("telegram bot process err: %v", err)
+	return html.UnescapeString(sb.String())
 }
 
 func processContextDone(ctx context.Context) error {
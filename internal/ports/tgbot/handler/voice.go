@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/internal/core/chatstate"
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/Vaayne/aienvoy/pkg/speech"
+	"github.com/Vaayne/aienvoy/pkg/tgmarkdown"
+	"github.com/pocketbase/pocketbase/daos"
+	tb "gopkg.in/telebot.v3"
+)
+
+// OnVoice transcribes voice messages and audio files, the transcript is the prompt of the chat.
+func OnVoice(c tb.Context) error {
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	cfg := config.GetConfig().Speech.Stt
+	if !cfg.Enabled() {
+		return c.Reply("Voice messages are not supported, speech to text is not configured")
+	}
+	transcriber, err := speech.NewTranscriber(cfg)
+	if err != nil {
+		return fmt.Errorf("init transcriber err: %v", err)
+	}
+
+	file, filename := voiceFile(c.Message())
+	if file == nil {
+		return c.Reply("empty voice message")
+	}
+	audio, err := c.Bot().File(file)
+	if err != nil {
+		return fmt.Errorf("download voice err: %v", err)
+	}
+	defer audio.Close()
+	text, err := transcriber.Transcribe(ctx, audio, filename)
+	if err != nil {
+		slog.ErrorContext(ctx, "transcribe voice error", "err", err)
+		return c.Reply("Failed to transcribe the voice message, please try again")
+	}
+	if text == "" {
+		return c.Reply("No speech found in the voice message")
+	}
+
+	state, err := getChatState(c)
+	if err != nil {
+		return fmt.Errorf("get chat state err: %v", err)
+	}
+	if state.ShowTranscript {
+		if err := c.Reply("🎙 " + text); err != nil {
+			return err
+		}
+	}
+	if state.Model == "" {
+		return c.Reply("Please choose a model by /model <name> first")
+	}
+	return onLLMChat(c, state, state.Model, text)
+}
+
+// voiceFile returns the file of a voice message or an audio file and its name
+func voiceFile(msg *tb.Message) (*tb.File, string) {
+	switch {
+	case msg == nil:
+		return nil, ""
+	case msg.Voice != nil:
+		return &msg.Voice.File, "voice.ogg"
+	case msg.Audio != nil:
+		name := msg.Audio.FileName
+		if name == "" {
+			name = "audio.mp3"
+		}
+		return &msg.Audio.File, name
+	}
+	return nil, ""
+}
+
+// OnVoiceReply toggles the voice replies of the chat
+func OnVoiceReply(c tb.Context, arg string) error {
+	if !config.GetConfig().Speech.Tts.Enabled() {
+		return c.Reply("Voice replies are not supported, text to speech is not configured")
+	}
+	return toggle(c, CommandVoice, arg, func(s *chatstate.State) *bool { return &s.VoiceReply })
+}
+
+// OnShowTranscript toggles showing the transcripts of voice messages in the chat
+func OnShowTranscript(c tb.Context, arg string) error {
+	return toggle(c, CommandTranscript, arg, func(s *chatstate.State) *bool { return &s.ShowTranscript })
+}
+
+// toggle switches a setting of the chat by on or off, without an argument it flips the setting
+func toggle(c tb.Context, command, arg string, field func(s *chatstate.State) *bool) error {
+	ctx := c.Get(config.ContextKeyContext).(context.Context)
+	state, err := getChatState(c)
+	if err != nil {
+		return fmt.Errorf("get chat state err: %v", err)
+	}
+	value := field(&state)
+	switch strings.ToLower(arg) {
+	case "":
+		*value = !*value
+	case "on":
+		*value = true
+	case "off":
+		*value = false
+	default:
+		return c.Reply(fmt.Sprintf("Usage: /%s [on|off]", command))
+	}
+	if _, err := chatstate.Save(ctx, ctx.Value(config.ContextKeyDao).(*daos.Dao), state); err != nil {
+		return fmt.Errorf("save chat state err: %v", err)
+	}
+	status := "off"
+	if *value {
+		status = "on"
+	}
+	return c.Reply(fmt.Sprintf("/%s is %s for this chat", command, status))
+}
+
+// replyVoice sends the answer as a voice message, failures only lose the voice
+func replyVoice(c tb.Context, ctx context.Context, answer string) {
+	synthesizer, err := speech.NewSynthesizer(config.GetConfig().Speech.Tts)
+	if err != nil {
+		slog.ErrorContext(ctx, "init synthesizer error", "err", err)
+		return
+	}
+	text := plainText(tgmarkdown.Render(answer))
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	audio, err := synthesizer.Synthesize(ctx, text)
+	if err != nil {
+		slog.ErrorContext(ctx, "synthesize voice reply error", "err", err)
+		return
+	}
+	voice := &tb.Voice{File: tb.FromReader(bytes.NewReader(audio)), MIME: "audio/ogg"}
+	if _, err := c.Bot().Send(c.Recipient(), voice, sendOptions(c)); err != nil {
+		slog.ErrorContext(ctx, "send voice reply error", "err", err)
+	}
+}
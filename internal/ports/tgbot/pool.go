@@ -0,0 +1,135 @@
+package tgbot
+
+import (
+	"log/slog"
+	"sync"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	defaultWorkers = 8
+	// workerQueueSize bounds the waiting updates of a worker, full queues hold back the poller
+	workerQueueSize = 64
+)
+
+// updatePool processes the updates of a poller by a bounded pool of workers. The updates of a chat always
+// go to the same worker, so they are processed in order, and a slow answer only delays the chats of its worker.
+// The bot has to be synchronous, so that the handlers run in the workers.
+type updatePool struct {
+	poller tb.Poller
+	queues []chan tb.Update
+
+	// mu guards closed, the queues are closed once the poller stopped
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newUpdatePool(poller tb.Poller, workers int) *updatePool {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	queues := make([]chan tb.Update, workers)
+	for i := range queues {
+		queues[i] = make(chan tb.Update, workerQueueSize)
+	}
+	return &updatePool{poller: poller, queues: queues}
+}
+
+// Poll satisfies tb.Poller, the updates of the inner poller are processed by the workers instead of dest
+func (p *updatePool) Poll(b *tb.Bot, dest chan tb.Update, stop chan struct{}) {
+	for _, queue := range p.queues {
+		go p.work(b, queue)
+	}
+
+	updates := make(chan tb.Update, workerQueueSize)
+	done := make(chan struct{})
+	go func() {
+		p.poller.Poll(b, updates, stop)
+		close(done)
+	}()
+	for {
+		select {
+		case upd := <-updates:
+			p.dispatch(upd)
+		case <-done:
+			// the poller may have sent updates right before it stopped
+			for len(updates) > 0 {
+				p.dispatch(<-updates)
+			}
+			p.close()
+			return
+		}
+	}
+}
+
+// dispatch queues upd to the worker of its chat, it blocks when the queue of the worker is full.
+// It reports false when the pool is closed and upd is dropped.
+func (p *updatePool) dispatch(upd tb.Update) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	id := updateChatID(upd)
+	if id < 0 {
+		id = -id
+	}
+	p.queues[id%int64(len(p.queues))] <- upd
+	return true
+}
+
+// close stops the workers after they processed the queued updates
+func (p *updatePool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+}
+
+func (p *updatePool) work(b *tb.Bot, queue chan tb.Update) {
+	for upd := range queue {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("process telegram update panic", "err", r, "update_id", upd.ID)
+				}
+			}()
+			b.ProcessUpdate(upd)
+		}()
+	}
+}
+
+// updateChatID is the chat of upd, updates without chat like inline queries are keyed by their user
+func updateChatID(upd tb.Update) int64 {
+	switch {
+	case upd.Message != nil:
+		return upd.Message.Chat.ID
+	case upd.EditedMessage != nil:
+		return upd.EditedMessage.Chat.ID
+	case upd.ChannelPost != nil:
+		return upd.ChannelPost.Chat.ID
+	case upd.EditedChannelPost != nil:
+		return upd.EditedChannelPost.Chat.ID
+	case upd.Callback != nil && upd.Callback.Message != nil:
+		return upd.Callback.Message.Chat.ID
+	case upd.Callback != nil && upd.Callback.Sender != nil:
+		return upd.Callback.Sender.ID
+	case upd.Query != nil:
+		return upd.Query.Sender.ID
+	case upd.InlineResult != nil:
+		return upd.InlineResult.Sender.ID
+	case upd.MyChatMember != nil:
+		return upd.MyChatMember.Chat.ID
+	case upd.ChatMember != nil:
+		return upd.ChatMember.Chat.ID
+	case upd.ChatJoinRequest != nil:
+		return upd.ChatJoinRequest.Chat.ID
+	}
+	return int64(upd.ID)
+}
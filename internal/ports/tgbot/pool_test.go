@@ -0,0 +1,94 @@
+package tgbot
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tb "gopkg.in/telebot.v3"
+)
+
+// slicePoller sends its updates and stops
+type slicePoller []tb.Update
+
+func (p slicePoller) Poll(_ *tb.Bot, dest chan tb.Update, _ chan struct{}) {
+	for _, upd := range p {
+		dest <- upd
+	}
+}
+
+func textUpdate(id int, chatID int64, text string) tb.Update {
+	return tb.Update{ID: id, Message: &tb.Message{
+		Sender: &tb.User{ID: chatID},
+		Chat:   &tb.Chat{ID: chatID, Type: tb.ChatPrivate},
+		Text:   text,
+	}}
+}
+
+func TestUpdatePoolOrdersChats(t *testing.T) {
+	const chats, messages = 5, 20
+	var updates slicePoller
+	for i := 0; i < messages; i++ {
+		for chat := int64(1); chat <= chats; chat++ {
+			id := chat
+			// negative ids are groups
+			if chat%2 == 0 {
+				id = -chat
+			}
+			updates = append(updates, textUpdate(len(updates), id, strconv.Itoa(i)))
+		}
+	}
+
+	bot, err := tb.NewBot(tb.Settings{Offline: true, Synchronous: true})
+	require.NoError(t, err)
+	var mu sync.Mutex
+	got := make(map[int64][]string)
+	bot.Handle(tb.OnText, func(c tb.Context) error {
+		// slow chats must not reorder the others
+		time.Sleep(time.Duration(c.Chat().ID%3+2) * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		got[c.Chat().ID] = append(got[c.Chat().ID], c.Text())
+		return nil
+	})
+
+	pool := newUpdatePool(updates, 2)
+	pool.Poll(bot, bot.Updates, make(chan struct{}))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		count := 0
+		for _, texts := range got {
+			count += len(texts)
+		}
+		return count == len(updates)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for chat, texts := range got {
+		for i, text := range texts {
+			assert.Equal(t, strconv.Itoa(i), text, "chat %d", chat)
+		}
+	}
+}
+
+func TestUpdatePoolClose(t *testing.T) {
+	pool := newUpdatePool(slicePoller{}, 1)
+	assert.True(t, pool.dispatch(textUpdate(1, 1, "before")))
+
+	pool.close()
+	// closing twice must not panic on the closed queues
+	pool.close()
+	assert.False(t, pool.dispatch(textUpdate(2, 1, "after")))
+
+	var queued []string
+	for upd := range pool.queues[0] {
+		queued = append(queued, upd.Message.Text)
+	}
+	assert.Equal(t, []string{"before"}, queued)
+}
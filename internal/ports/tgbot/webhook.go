@@ -0,0 +1,75 @@
+package tgbot
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/labstack/echo/v5"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	// WebhookPath is the route of the webhook on the http server
+	WebhookPath = "/telegram/webhook"
+	// secretTokenHeader carries the secret token of the webhook in the requests of telegram
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+	ModeWebhook = "webhook"
+	ModePolling = "polling"
+)
+
+// mode is the configured mode, the bot polls unless the webhook is chosen explicitly,
+// so that deployments without a public url keep receiving updates.
+func mode(cfg *config.Config) (string, error) {
+	switch cfg.Telegram.Mode {
+	case "", ModePolling:
+		return ModePolling, nil
+	case ModeWebhook:
+		if cfg.Service.URL == "" {
+			return "", errors.New("telegram webhook mode requires service.url")
+		}
+		// without the secret every request would pass the verification
+		if cfg.Telegram.SecretToken == "" {
+			return "", errors.New("telegram webhook mode requires telegram.secretToken")
+		}
+		return ModeWebhook, nil
+	default:
+		return "", fmt.Errorf("invalid telegram mode %q, it is %s or %s", cfg.Telegram.Mode, ModePolling, ModeWebhook)
+	}
+}
+
+// webhookPoller sets the webhook of the bot to Service.URL, the updates are received by webhookHandler
+func webhookPoller(cfg *config.Config) *tb.Webhook {
+	return &tb.Webhook{
+		SecretToken: cfg.Telegram.SecretToken,
+		Endpoint: &tb.WebhookEndpoint{
+			PublicURL: strings.TrimSuffix(cfg.Service.URL, "/") + WebhookPath,
+		},
+	}
+}
+
+// webhookHandler verifies the secret token of telegram and queues the update to the workers,
+// after the bot stopped the updates are refused.
+func webhookHandler(pool *updatePool, token string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if subtle.ConstantTimeCompare([]byte(c.Request().Header.Get(secretTokenHeader)), []byte(token)) != 1 {
+			slog.Warn("telegram webhook request with invalid secret token", "ip", c.RealIP())
+			return c.NoContent(http.StatusUnauthorized)
+		}
+		var upd tb.Update
+		if err := c.Bind(&upd); err != nil {
+			slog.Error("bind telegram update error", "err", err)
+			return c.NoContent(http.StatusBadRequest)
+		}
+		// telegram retries refused updates later
+		if !pool.dispatch(upd) {
+			return c.NoContent(http.StatusServiceUnavailable)
+		}
+		return c.NoContent(http.StatusOK)
+	}
+}
@@ -0,0 +1,76 @@
+package tgbot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Vaayne/aienvoy/internal/pkg/config"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		telegram config.Telegram
+		url      string
+		want     string
+		err      string
+	}{
+		{name: "polling by default", url: "https://example.com", want: ModePolling},
+		{name: "polling", telegram: config.Telegram{Mode: ModePolling}, want: ModePolling},
+		{name: "webhook", telegram: config.Telegram{Mode: ModeWebhook, SecretToken: "secret"}, url: "https://example.com", want: ModeWebhook},
+		{name: "webhook without url", telegram: config.Telegram{Mode: ModeWebhook, SecretToken: "secret"}, err: "service.url"},
+		{name: "webhook without secret", telegram: config.Telegram{Mode: ModeWebhook}, url: "https://example.com", err: "telegram.secretToken"},
+		{name: "invalid mode", telegram: config.Telegram{Mode: "hook"}, err: "invalid telegram mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mode(&config.Config{Service: config.ServiceConfig{URL: tt.url}, Telegram: tt.telegram})
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		closed bool
+		want   int
+	}{
+		{name: "missing secret", body: `{"update_id":1}`, want: http.StatusUnauthorized},
+		{name: "wrong secret", secret: "wrong", body: `{"update_id":1}`, want: http.StatusUnauthorized},
+		{name: "invalid update", secret: "secret", body: `{`, want: http.StatusBadRequest},
+		{name: "closed pool", secret: "secret", body: `{"update_id":1}`, closed: true, want: http.StatusServiceUnavailable},
+		{name: "valid secret", secret: "secret", body: `{"update_id":1}`, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newUpdatePool(nil, 1)
+			if tt.closed {
+				pool.close()
+			}
+			req := httptest.NewRequest(http.MethodPost, WebhookPath, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.secret != "" {
+				req.Header.Set(secretTokenHeader, tt.secret)
+			}
+			rec := httptest.NewRecorder()
+			err := webhookHandler(pool, "secret")(echo.New().NewContext(req, rec))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, rec.Code)
+
+			// only accepted updates are queued
+			assert.Equal(t, tt.want == http.StatusOK, len(pool.queues[0]) == 1)
+		})
+	}
+}
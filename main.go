@@ -1,18 +1,30 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"log/slog"
 	"os/exec"
 	"runtime"
+	"time"
 
+	"github.com/Vaayne/aienvoy/internal/core/audit"
+	"github.com/Vaayne/aienvoy/internal/core/copilot"
+	"github.com/Vaayne/aienvoy/internal/core/credential"
 	"github.com/Vaayne/aienvoy/internal/core/midjourney"
 	"github.com/Vaayne/aienvoy/internal/core/readease"
 	"github.com/Vaayne/aienvoy/internal/pkg/config"
 	_ "github.com/Vaayne/aienvoy/internal/pkg/logger"
+	"github.com/Vaayne/aienvoy/internal/pkg/telemetry"
+	"github.com/Vaayne/aienvoy/internal/ports/discordbot"
 	"github.com/Vaayne/aienvoy/internal/ports/httpserver"
+	"github.com/Vaayne/aienvoy/internal/ports/slackbot"
 	"github.com/Vaayne/aienvoy/internal/ports/tgbot"
 	_ "github.com/Vaayne/aienvoy/migrations"
+	"github.com/Vaayne/aienvoy/pkg/llm/client"
+	"github.com/Vaayne/aienvoy/pkg/llm/githubcopilot"
+	vaultpkg "github.com/Vaayne/aienvoy/pkg/vault"
 	"github.com/pocketbase/pocketbase/tools/cron"
 	tb "gopkg.in/telebot.v3"
 
@@ -36,8 +48,8 @@ func RegisterRoutes(app *pocketbase.PocketBase) {
 func SetScheduledJobs(app *pocketbase.PocketBase) {
 	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
 		scheduler := cron.New()
-		// hourly readease job
-		if config.GetConfig().ReadEase.TelegramChannel != 0 {
+		// hourly readease job, the summaries are sent to the channel by the telegram bot
+		if config.GetConfig().ReadEase.TelegramChannel != 0 && config.GetConfig().Telegram.Token != "" {
 			scheduler.MustAdd("readease", "0 * * * *", func() {
 				summaries, err := readease.PeriodJob(app, "gemini-pro")
 				if err != nil {
@@ -58,11 +70,121 @@ func SetScheduledJobs(app *pocketbase.PocketBase) {
 	})
 }
 
-// StartTelegramBot starts the Telegram bot for the application.
+// StartModelsRefresh lists the provider models again, so new catalogue models are served without restarts.
+func StartModelsRefresh(app *pocketbase.PocketBase) {
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		scheduler := cron.New()
+		scheduler.MustAdd("llm_models_refresh", "*/15 * * * *", client.Refresh)
+		scheduler.Start()
+		return nil
+	})
+}
+
+// StartTelemetry exports traces and measures every upstream llm call.
+func StartTelemetry(app *pocketbase.PocketBase) {
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		cfg := config.GetConfig()
+		shutdown, err := telemetry.Init(context.Background(), cfg.Service, cfg.Axiom)
+		if err != nil {
+			return err
+		}
+		client.Use(telemetry.Middleware)
+
+		app.OnTerminate().Add(func(e *core.TerminateEvent) error {
+			return shutdown(context.Background())
+		})
+		return nil
+	})
+}
+
+// StartAudit records every upstream llm call when audit is enabled.
+func StartAudit(app *pocketbase.PocketBase) {
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		cfg := config.GetConfig().Audit
+		if !cfg.Enabled {
+			return nil
+		}
+		recorder, err := audit.New(app.Dao(), cfg)
+		if err != nil {
+			return err
+		}
+		client.Use(recorder.Middleware)
+
+		// daily retention cleanup
+		if cfg.RetentionDays > 0 {
+			scheduler := cron.New()
+			scheduler.MustAdd("audit_cleanup", "0 3 * * *", func() {
+				retention := time.Duration(cfg.RetentionDays) * 24 * time.Hour
+				deleted, err := recorder.Cleanup(context.Background(), retention)
+				if err != nil {
+					slog.Error("cleanup audit records error", "err", err)
+					return
+				}
+				slog.Info("cleanup audit records success", "deleted", deleted)
+			})
+			scheduler.Start()
+		}
+		return nil
+	})
+}
+
+// StartVault resolves the secret:// references of the settings by the encrypted credentials of PocketBase.
+func StartVault(app *pocketbase.PocketBase) {
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		vault, err := credential.New(app.Dao(), config.GetConfig().Vault)
+		if errors.Is(err, vaultpkg.ErrNoMasterKey) {
+			// references are kept as they are, report them instead of failing at the providers
+			if err := vaultpkg.Resolve(config.GetConfig(), func(string) (string, error) { return "", err }); err != nil {
+				slog.Warn("secret references of config are not resolved", "err", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ctx := credential.WithActor(context.Background(), "config")
+		config.OnLoad(func(cfg *config.Config) {
+			if err := vault.ResolveConfig(ctx, cfg); err != nil {
+				slog.Error("resolve secrets of config error", "err", err)
+			}
+		})
+		return nil
+	})
+}
+
+// StartGithubCopilot reads the github tokens of copilot logins from PocketBase.
+func StartGithubCopilot(app *pocketbase.PocketBase) {
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		githubcopilot.DefaultTokenStore = copilot.NewDao(app.Dao())
+		return nil
+	})
+}
+
+// StartTelegramBot starts the Telegram bot for the application, its webhook is served by the router.
 func StartTelegramBot(app *pocketbase.PocketBase) {
 	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
 		if config.GetConfig().Telegram.Token != "" {
-			go tgbot.Serve(app)
+			return tgbot.Serve(app, e.Router)
+		}
+		return nil
+	})
+}
+
+// StartDiscordBot starts the Discord bot for the application.
+func StartDiscordBot(app *pocketbase.PocketBase) {
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		if config.GetConfig().Discord.Token != "" {
+			discordbot.Serve(app)
+		}
+		return nil
+	})
+}
+
+// StartSlackBot starts the Slack bot for the application, its events are served by the router.
+func StartSlackBot(app *pocketbase.PocketBase) {
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		if config.GetConfig().Slack.BotToken != "" {
+			slackbot.Serve(app, e.Router)
 		}
 		return nil
 	})
@@ -109,8 +231,15 @@ func main() {
 	})
 
 	// before serve hooks
+	StartVault(app)
+	StartAudit(app)
+	StartTelemetry(app)
+	StartGithubCopilot(app)
+	StartModelsRefresh(app)
 	RegisterRoutes(app)
 	StartTelegramBot(app)
+	StartDiscordBot(app)
+	StartSlackBot(app)
 	StartMidjourneyServer(app)
 	// SetScheduledJobs(app)
 	// OpenBrowser(config.GetConfig().Service.URL)
@@ -0,0 +1,97 @@
+package migrations
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const tableNameMessageEmbeddings = "conversation_message_embeddings"
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		collection := &models.Collection{
+			Name: tableNameMessageEmbeddings,
+			Type: models.CollectionTypeBase,
+			Indexes: types.JsonArray[string]{
+				"CREATE INDEX idx_model_embedding_model ON conversation_message_embeddings (model, embedding_model)",
+				"CREATE INDEX idx_prompt_hash ON conversation_message_embeddings (prompt_hash)",
+			},
+			Schema: schema.NewSchema(&schema.SchemaField{
+				Name:     "model",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name:     "embedding_model",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name:     "message_id",
+				Type:     schema.FieldTypeText,
+				Required: false,
+			}, &schema.SchemaField{
+				Name:     "prompt",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name:     "prompt_hash",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name:     "vector",
+				Type:     schema.FieldTypeText,
+				Required: false,
+			}, &schema.SchemaField{
+				Name:     "response",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}),
+		}
+		if err := daos.New(db).SaveCollection(collection); err != nil {
+			slog.Error("create table error", "err", err, "table", tableNameMessageEmbeddings)
+			return err
+		}
+		slog.Info("create table success", "table", tableNameMessageEmbeddings)
+
+		// semantic cache is enabled per api key
+		dao := daos.New(db)
+		apiKeys, err := dao.FindCollectionByNameOrId("api_keys")
+		if err != nil {
+			return err
+		}
+		apiKeys.Schema.AddField(&schema.SchemaField{
+			Name:     "semantic_cache",
+			Type:     schema.FieldTypeBool,
+			Required: false,
+		})
+		return dao.SaveCollection(apiKeys)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+		apiKeys, err := dao.FindCollectionByNameOrId("api_keys")
+		if err != nil {
+			return err
+		}
+		if field := apiKeys.Schema.GetFieldByName("semantic_cache"); field != nil {
+			apiKeys.Schema.RemoveField(field.Id)
+			if err := dao.SaveCollection(apiKeys); err != nil {
+				return err
+			}
+		}
+
+		collection, err := dao.FindCollectionByNameOrId(tableNameMessageEmbeddings)
+		if err != nil {
+			return err
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			slog.Error("drop table error", "err", err, "table", tableNameMessageEmbeddings)
+			return err
+		}
+		slog.Info("drop table success", "table", tableNameMessageEmbeddings)
+		return nil
+	})
+}
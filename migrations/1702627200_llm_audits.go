@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const tableNameLLMAudits = "llm_audits"
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		collection := &models.Collection{
+			Name: tableNameLLMAudits,
+			Type: models.CollectionTypeBase,
+			Indexes: types.JsonArray[string]{
+				"CREATE INDEX idx_llm_audits_request_id ON llm_audits (request_id)",
+				"CREATE INDEX idx_llm_audits_created ON llm_audits (created)",
+			},
+			Schema: schema.NewSchema(&schema.SchemaField{
+				Name: "request_id",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "user_id",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name:     "provider",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name:     "model",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name: "stream",
+				Type: schema.FieldTypeBool,
+			}, &schema.SchemaField{
+				Name: "request",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "response",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "error",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "latency_ms",
+				Type: schema.FieldTypeNumber,
+			}, &schema.SchemaField{
+				Name: "prompt_tokens",
+				Type: schema.FieldTypeNumber,
+			}, &schema.SchemaField{
+				Name: "completion_tokens",
+				Type: schema.FieldTypeNumber,
+			}, &schema.SchemaField{
+				Name: "total_tokens",
+				Type: schema.FieldTypeNumber,
+			}),
+		}
+		if err := daos.New(db).SaveCollection(collection); err != nil {
+			slog.Error("create table error", "err", err, "table", tableNameLLMAudits)
+			return err
+		}
+		slog.Info("create table success", "table", tableNameLLMAudits)
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+		collection, err := dao.FindCollectionByNameOrId(tableNameLLMAudits)
+		if err != nil {
+			return err
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			slog.Error("drop table error", "err", err, "table", tableNameLLMAudits)
+			return err
+		}
+		slog.Info("drop table success", "table", tableNameLLMAudits)
+		return nil
+	})
+}
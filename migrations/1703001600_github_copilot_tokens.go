@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const tableNameGithubCopilotTokens = "github_copilot_tokens"
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		collection := &models.Collection{
+			Name: tableNameGithubCopilotTokens,
+			Type: models.CollectionTypeBase,
+			Indexes: types.JsonArray[string]{
+				"CREATE UNIQUE INDEX idx_github_copilot_tokens_account ON github_copilot_tokens (account)",
+			},
+			Schema: schema.NewSchema(&schema.SchemaField{
+				Name:     "account",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name:     "token",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}),
+		}
+		if err := daos.New(db).SaveCollection(collection); err != nil {
+			slog.Error("create table error", "err", err, "table", tableNameGithubCopilotTokens)
+			return err
+		}
+		slog.Info("create table success", "table", tableNameGithubCopilotTokens)
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+		collection, err := dao.FindCollectionByNameOrId(tableNameGithubCopilotTokens)
+		if err != nil {
+			return err
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			slog.Error("drop table error", "err", err, "table", tableNameGithubCopilotTokens)
+			return err
+		}
+		slog.Info("drop table success", "table", tableNameGithubCopilotTokens)
+		return nil
+	})
+}
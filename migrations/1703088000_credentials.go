@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const tableNameCredentials = "credentials"
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		collection := &models.Collection{
+			Name: tableNameCredentials,
+			Type: models.CollectionTypeBase,
+			Indexes: types.JsonArray[string]{
+				"CREATE UNIQUE INDEX idx_credentials_name ON credentials (name)",
+			},
+			Schema: schema.NewSchema(&schema.SchemaField{
+				Name:     "name",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name: "value",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "description",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "version",
+				Type: schema.FieldTypeNumber,
+			}, &schema.SchemaField{
+				Name: "revoked",
+				Type: schema.FieldTypeBool,
+			}, &schema.SchemaField{
+				Name: "updated_by",
+				Type: schema.FieldTypeText,
+			}),
+		}
+		if err := daos.New(db).SaveCollection(collection); err != nil {
+			slog.Error("create table error", "err", err, "table", tableNameCredentials)
+			return err
+		}
+		slog.Info("create table success", "table", tableNameCredentials)
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+		collection, err := dao.FindCollectionByNameOrId(tableNameCredentials)
+		if err != nil {
+			return err
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			slog.Error("drop table error", "err", err, "table", tableNameCredentials)
+			return err
+		}
+		slog.Info("drop table success", "table", tableNameCredentials)
+		return nil
+	})
+}
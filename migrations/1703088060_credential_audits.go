@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const tableNameCredentialAudits = "credential_audits"
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		collection := &models.Collection{
+			Name: tableNameCredentialAudits,
+			Type: models.CollectionTypeBase,
+			Indexes: types.JsonArray[string]{
+				"CREATE INDEX idx_credential_audits_name ON credential_audits (name)",
+				"CREATE INDEX idx_credential_audits_created ON credential_audits (created)",
+			},
+			Schema: schema.NewSchema(&schema.SchemaField{
+				Name:     "name",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name:     "action",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name: "actor",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "version",
+				Type: schema.FieldTypeNumber,
+			}, &schema.SchemaField{
+				Name: "error",
+				Type: schema.FieldTypeText,
+			}),
+		}
+		if err := daos.New(db).SaveCollection(collection); err != nil {
+			slog.Error("create table error", "err", err, "table", tableNameCredentialAudits)
+			return err
+		}
+		slog.Info("create table success", "table", tableNameCredentialAudits)
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+		collection, err := dao.FindCollectionByNameOrId(tableNameCredentialAudits)
+		if err != nil {
+			return err
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			slog.Error("drop table error", "err", err, "table", tableNameCredentialAudits)
+			return err
+		}
+		slog.Info("drop table success", "table", tableNameCredentialAudits)
+		return nil
+	})
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const tableNameChatStates = "chat_states"
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		collection := &models.Collection{
+			Name: tableNameChatStates,
+			Type: models.CollectionTypeBase,
+			Indexes: types.JsonArray[string]{
+				"CREATE UNIQUE INDEX idx_chat_states_chat ON chat_states (platform, chat_id, thread_id)",
+			},
+			Schema: schema.NewSchema(&schema.SchemaField{
+				Name:     "platform",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name:     "chat_id",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name: "thread_id",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "model",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "conversation_id",
+				Type: schema.FieldTypeText,
+			}),
+		}
+		if err := daos.New(db).SaveCollection(collection); err != nil {
+			slog.Error("create table error", "err", err, "table", tableNameChatStates)
+			return err
+		}
+		slog.Info("create table success", "table", tableNameChatStates)
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+		collection, err := dao.FindCollectionByNameOrId(tableNameChatStates)
+		if err != nil {
+			return err
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			slog.Error("drop table error", "err", err, "table", tableNameChatStates)
+			return err
+		}
+		slog.Info("drop table success", "table", tableNameChatStates)
+		return nil
+	})
+}
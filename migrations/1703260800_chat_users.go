@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const tableNameChatUsers = "chat_users"
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		collection := &models.Collection{
+			Name: tableNameChatUsers,
+			Type: models.CollectionTypeBase,
+			Indexes: types.JsonArray[string]{
+				"CREATE UNIQUE INDEX idx_chat_users_platform_external_id ON chat_users (platform, external_id)",
+			},
+			Schema: schema.NewSchema(&schema.SchemaField{
+				Name:     "platform",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name:     "external_id",
+				Type:     schema.FieldTypeText,
+				Required: true,
+			}, &schema.SchemaField{
+				Name: "username",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				// status is the approval of users by the telegram admins
+				Name: "status",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "user_id",
+				Type: schema.FieldTypeText,
+			}, &schema.SchemaField{
+				Name: "api_key_id",
+				Type: schema.FieldTypeText,
+			}),
+		}
+		if err := daos.New(db).SaveCollection(collection); err != nil {
+			slog.Error("create table error", "err", err, "table", tableNameChatUsers)
+			return err
+		}
+		slog.Info("create table success", "table", tableNameChatUsers)
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+		collection, err := dao.FindCollectionByNameOrId(tableNameChatUsers)
+		if err != nil {
+			return err
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			slog.Error("drop table error", "err", err, "table", tableNameChatUsers)
+			return err
+		}
+		slog.Info("drop table success", "table", tableNameChatUsers)
+		return nil
+	})
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// voice toggles of chats
+var chatStatesVoiceFields = []string{"voice_reply", "show_transcript"}
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+		collection, err := dao.FindCollectionByNameOrId(tableNameChatStates)
+		if err != nil {
+			return err
+		}
+		for _, name := range chatStatesVoiceFields {
+			collection.Schema.AddField(&schema.SchemaField{
+				Name: name,
+				Type: schema.FieldTypeBool,
+			})
+		}
+		if err := dao.SaveCollection(collection); err != nil {
+			slog.Error("alter table error", "err", err, "table", tableNameChatStates)
+			return err
+		}
+		slog.Info("alter table success", "table", tableNameChatStates)
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+		collection, err := dao.FindCollectionByNameOrId(tableNameChatStates)
+		if err != nil {
+			return err
+		}
+		for _, name := range chatStatesVoiceFields {
+			if field := collection.Schema.GetFieldByName(name); field != nil {
+				collection.Schema.RemoveField(field.Id)
+			}
+		}
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const (
+	// context_hash keys cached answers by the system prompt and sampling parameters of their request
+	fieldContextHash = "context_hash"
+	// scope keeps the cached answers of an api key from the others
+	fieldScope = "scope"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+		collection, err := dao.FindCollectionByNameOrId(tableNameMessageEmbeddings)
+		if err != nil {
+			return err
+		}
+		collection.Schema.AddField(&schema.SchemaField{
+			Name: fieldContextHash,
+			Type: schema.FieldTypeText,
+		})
+		collection.Schema.AddField(&schema.SchemaField{
+			Name: fieldScope,
+			Type: schema.FieldTypeText,
+		})
+		if err := dao.SaveCollection(collection); err != nil {
+			slog.Error("alter table error", "err", err, "table", tableNameMessageEmbeddings)
+			return err
+		}
+		slog.Info("alter table success", "table", tableNameMessageEmbeddings)
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+		collection, err := dao.FindCollectionByNameOrId(tableNameMessageEmbeddings)
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{fieldContextHash, fieldScope} {
+			if field := collection.Schema.GetFieldByName(name); field != nil {
+				collection.Schema.RemoveField(field.Id)
+			}
+		}
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,173 @@
+// Package chatstream streams llm answers into chat messages by editing them,
+// answers longer than a message continue in new messages.
+package chatstream
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Sink sends and edits the messages of a chat, ids are the ids of the messages in the chat.
+type Sink interface {
+	Send(content string) (string, error)
+	Edit(id, content string) error
+}
+
+// Stream edits the messages of an answer while it is generated
+type Stream struct {
+	sink Sink
+	// limit is the max characters of a message
+	limit int
+	// interval keeps the edits below the rate limit of the chat
+	interval time.Duration
+	// Footer is appended to the finished answer
+	Footer string
+	// Text is the answer so far
+	Text string
+
+	ids    []string
+	sent   []string
+	edited time.Time
+}
+
+func New(sink Sink, limit int, interval time.Duration) *Stream {
+	return &Stream{sink: sink, limit: limit, interval: interval}
+}
+
+// Start sends the placeholder of the answer
+func (st *Stream) Start(placeholder string) error {
+	id, err := st.sink.Send(placeholder)
+	if err != nil {
+		return fmt.Errorf("send message error: %w", err)
+	}
+	st.ids = append(st.ids, id)
+	st.sent = append(st.sent, placeholder)
+	st.edited = time.Now()
+	return nil
+}
+
+// Process adds delta to the answer, the messages are edited once the interval passed
+func (st *Stream) Process(delta string) {
+	st.Text += delta
+	if time.Since(st.edited) < st.interval {
+		return
+	}
+	if err := st.flush(st.Text); err != nil {
+		slog.Warn("edit message error", "err", err)
+	}
+}
+
+// Finish sends the rest of the answer with the footer, errors other than io.EOF are shown below the answer.
+func (st *Stream) Finish(err error) error {
+	text := st.Text
+	if errors.Is(err, io.EOF) {
+		if st.Footer != "" {
+			text += "\n\n" + st.Footer
+		}
+	} else if err != nil {
+		slog.Error("chat stream error", "err", err)
+		text += fmt.Sprintf("\n\nError: %s", err)
+	}
+	if strings.TrimSpace(text) == "" {
+		text = "Empty response"
+	}
+	return st.flush(text)
+}
+
+// flush edits the changed pages of text, pages without message are sent
+func (st *Stream) flush(text string) error {
+	st.edited = time.Now()
+	for idx, page := range Split(text, st.limit) {
+		if idx < len(st.ids) {
+			if st.sent[idx] == page {
+				continue
+			}
+			if err := st.sink.Edit(st.ids[idx], page); err != nil {
+				return fmt.Errorf("edit message error: %w", err)
+			}
+			st.sent[idx] = page
+			continue
+		}
+		id, err := st.sink.Send(page)
+		if err != nil {
+			return fmt.Errorf("send message error: %w", err)
+		}
+		st.ids = append(st.ids, id)
+		st.sent = append(st.sent, page)
+	}
+	return nil
+}
+
+// closeFence closes the code blocks cut by a page
+const closeFence = "\n```"
+
+// Split splits text into pages of at most limit characters, between lines where possible.
+// Code blocks cut by a page are closed and opened again on the next page.
+func Split(text string, limit int) []string {
+	var pages []string
+	fence := ""
+	for text != "" {
+		page := text
+		if fence != "" {
+			page = fence + "\n" + text
+		}
+		if utf8.RuneCountInString(page) <= limit {
+			pages = append(pages, page)
+			break
+		}
+
+		// room for closing the code block
+		cut := cutIndex(page, limit-len(closeFence))
+		head := page[:cut]
+		consumed := len(head) - (len(page) - len(text))
+		text = strings.TrimPrefix(text[consumed:], "\n")
+
+		// an odd number of fences leaves a code block open
+		if open := openFence(head); open != "" {
+			head += closeFence
+			fence = open
+		} else {
+			fence = ""
+		}
+		pages = append(pages, head)
+	}
+	return pages
+}
+
+// cutIndex returns the byte index of the last line break before limit characters, or limit characters if there is none
+func cutIndex(s string, limit int) int {
+	end := len(s)
+	for idx := range s {
+		if limit == 0 {
+			end = idx
+			break
+		}
+		limit--
+	}
+	if nl := strings.LastIndexByte(s[:end], '\n'); nl > end/2 {
+		return nl
+	}
+	return end
+}
+
+// openFence returns the opening line of the code block which is still open at the end of s
+func openFence(s string) string {
+	open := ""
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "```") {
+			continue
+		}
+		if open == "" {
+			open = trimmed
+		} else {
+			open = ""
+		}
+	}
+	return open
+}
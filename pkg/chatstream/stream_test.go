@@ -0,0 +1,126 @@
+package chatstream
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSink struct {
+	messages map[string]string
+	order    []string
+	edits    int
+}
+
+func (f *fakeSink) Send(content string) (string, error) {
+	if f.messages == nil {
+		f.messages = map[string]string{}
+	}
+	id := fmt.Sprintf("%d", len(f.order))
+	f.messages[id] = content
+	f.order = append(f.order, id)
+	return id, nil
+}
+
+func (f *fakeSink) Edit(id, content string) error {
+	f.messages[id] = content
+	f.edits++
+	return nil
+}
+
+func (f *fakeSink) contents() []string {
+	contents := make([]string, 0, len(f.order))
+	for _, id := range f.order {
+		contents = append(contents, f.messages[id])
+	}
+	return contents
+}
+
+func TestSplitShortText(t *testing.T) {
+	assert.Equal(t, []string{"hello"}, Split("hello", 100))
+}
+
+func TestSplitBetweenLines(t *testing.T) {
+	text := strings.Repeat("line of text\n", 50)
+	pages := Split(text, 100)
+	assert.Greater(t, len(pages), 1)
+	for _, page := range pages {
+		assert.LessOrEqual(t, utf8.RuneCountInString(page), 100)
+		assert.True(t, strings.HasSuffix(page, "text") || page == pages[len(pages)-1], page)
+	}
+	assert.Equal(t, strings.TrimSuffix(text, "\n"), strings.TrimSuffix(strings.Join(pages, "\n"), "\n"))
+}
+
+func TestSplitKeepsCodeBlocks(t *testing.T) {
+	text := "intro\n```go\n" + strings.Repeat("fmt.Println(\"héllo\")\n", 30) + "```\nafter"
+	pages := Split(text, 200)
+	assert.Greater(t, len(pages), 2)
+	for idx, page := range pages {
+		assert.LessOrEqual(t, utf8.RuneCountInString(page), 200)
+		assert.Equal(t, 0, strings.Count(page, "```")%2, page)
+		if idx > 0 && idx < len(pages)-1 {
+			assert.True(t, strings.HasPrefix(page, "```go\n"), page)
+		}
+	}
+	assert.True(t, strings.HasSuffix(pages[len(pages)-1], "after"))
+}
+
+func TestSplitLongLine(t *testing.T) {
+	pages := Split(strings.Repeat("界", 250), 100)
+	require.Len(t, pages, 3)
+	for _, page := range pages {
+		assert.LessOrEqual(t, utf8.RuneCountInString(page), 100)
+	}
+}
+
+func TestStreamFinish(t *testing.T) {
+	sink := &fakeSink{}
+	st := New(sink, 100, 0)
+	st.Footer = "-- model"
+	require.NoError(t, st.Start("Waiting ..."))
+	st.Process("Hello")
+	st.Process(" world")
+	require.NoError(t, st.Finish(io.EOF))
+	assert.Equal(t, []string{"Hello world\n\n-- model"}, sink.contents())
+}
+
+func TestStreamContinuesInNewMessages(t *testing.T) {
+	sink := &fakeSink{}
+	st := New(sink, 100, 0)
+	require.NoError(t, st.Start("Waiting ..."))
+	for i := 0; i < 30; i++ {
+		st.Process("line of text\n")
+	}
+	require.NoError(t, st.Finish(io.EOF))
+	contents := sink.contents()
+	assert.Greater(t, len(contents), 1)
+	assert.Equal(t, strings.TrimSuffix(st.Text, "\n"), strings.TrimSuffix(strings.Join(contents, "\n"), "\n"))
+}
+
+func TestStreamError(t *testing.T) {
+	sink := &fakeSink{}
+	st := New(sink, 100, 0)
+	require.NoError(t, st.Start("Waiting ..."))
+	st.Process("partial")
+	require.NoError(t, st.Finish(errors.New("boom")))
+	assert.Equal(t, []string{"partial\n\nError: boom"}, sink.contents())
+}
+
+func TestStreamThrottlesEdits(t *testing.T) {
+	sink := &fakeSink{}
+	st := New(sink, 100, time.Hour)
+	require.NoError(t, st.Start("Waiting ..."))
+	st.Process("a")
+	st.Process("b")
+	assert.Equal(t, 0, sink.edits)
+	require.NoError(t, st.Finish(io.EOF))
+	assert.Equal(t, 1, sink.edits)
+	assert.Equal(t, []string{"ab"}, sink.contents())
+}
@@ -25,6 +25,23 @@ const (
 // for example APP_TELEGRAM_TOKEN will map to telegram.token
 // avoid to use "_" in config name for better env reading
 func Load(cfg interface{}, onChanges ...func(e fsnotify.Event)) {
+	loadConfig := func() {
+		if err := Unmarshal(cfg); err != nil {
+			panic(err)
+		}
+	}
+	Watch(func(e fsnotify.Event) {
+		loadConfig()
+		for _, chnage := range onChanges {
+			chnage(e)
+		}
+	})
+	loadConfig()
+}
+
+// Watch reads the settings like Load, but leaves the decoding to the caller,
+// onChange is called after the settings files changed.
+func Watch(onChange func(e fsnotify.Event)) {
 	// read configs from seetings in root directory
 
 	viper.AddConfigPath(getConfigDir())
@@ -46,25 +63,25 @@ func Load(cfg interface{}, onChanges ...func(e fsnotify.Event)) {
 	viper.SetEnvPrefix(SettingsEnvPrefix)
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
-	loadConfig := func() {
-		if err := viper.Unmarshal(cfg); err != nil {
-			panic(fmt.Errorf("unable to decode into struct: %v", err))
-		}
-	}
 	// autoreload watch config change
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		fmt.Println("Reload config since file changed:", e.Name)
-		loadConfig()
-		for _, chnage := range onChanges {
-			chnage(e)
-		}
+		onChange(e)
 	})
-	loadConfig()
 	viper.WatchConfig()
 }
 
+// Unmarshal decodes the loaded settings into cfg again
+func Unmarshal(cfg interface{}) error {
+	if err := viper.Unmarshal(cfg); err != nil {
+		return fmt.Errorf("unable to decode into struct: %v", err)
+	}
+	return nil
+}
+
 func getConfigDir() string {
 	_, filename, _, _ := runtime.Caller(1)
-	filepath := path.Join(path.Dir(filename), "../../../")
+	// settings are in the root of the module, two levels above pkg/config
+	filepath := path.Join(path.Dir(filename), "../../")
 	return filepath
 }
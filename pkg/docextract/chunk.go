@@ -0,0 +1,109 @@
+package docextract
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Chunk splits text to chunks of at most size bytes, between paragraphs, or else lines and words.
+func Chunk(text string, size int) []string {
+	chunks := make([]string, 0)
+	current := ""
+	add := func(s, sep string) {
+		if current != "" && len(current)+len(sep)+len(s) > size {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		if current != "" {
+			current += sep
+		}
+		current += s
+	}
+
+	var split func(s string, seps []string)
+	split = func(s string, seps []string) {
+		if len(s) <= size || len(seps) == 0 {
+			for len(s) > size {
+				cut := size
+				for cut > 0 && !isRuneStart(s[cut]) {
+					cut--
+				}
+				add(s[:cut], "")
+				s = s[cut:]
+			}
+			add(s, "")
+			return
+		}
+		for _, part := range strings.Split(s, seps[0]) {
+			if len(part) <= size {
+				add(part, seps[0])
+				continue
+			}
+			split(part, seps[1:])
+		}
+	}
+	split(strings.TrimSpace(text), []string{"\n\n", "\n", " "})
+	if strings.TrimSpace(current) != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// Select picks the chunks sharing most words with query until budget bytes, they are kept in the
+// order of the document.
+func Select(chunks []string, query string, budget int) []string {
+	terms := words(query)
+	type scored struct {
+		idx   int
+		score int
+	}
+	ranked := make([]scored, 0, len(chunks))
+	for i, chunk := range chunks {
+		score := 0
+		for w := range words(chunk) {
+			if terms[w] {
+				score++
+			}
+		}
+		ranked = append(ranked, scored{idx: i, score: score})
+	}
+	// stable keeps the earlier chunks first between equal scores
+	slices.SortStableFunc(ranked, func(a, b scored) int {
+		return b.score - a.score
+	})
+
+	picked := make([]int, 0)
+	used := 0
+	for _, r := range ranked {
+		if used+len(chunks[r.idx]) > budget {
+			continue
+		}
+		used += len(chunks[r.idx])
+		picked = append(picked, r.idx)
+	}
+	slices.Sort(picked)
+
+	selected := make([]string, 0, len(picked))
+	for _, idx := range picked {
+		selected = append(selected, chunks[idx])
+	}
+	return selected
+}
+
+// words are the lowercase words of s longer than two letters
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) > 2 {
+			set[w] = true
+		}
+	}
+	return set
+}
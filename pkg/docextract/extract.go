@@ -0,0 +1,117 @@
+// Package docextract extracts the text of documents sent to the bots, like PDF, DOCX, markdown, code and CSV.
+package docextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+var ErrUnsupported = errors.New("unsupported document type")
+
+// Extract returns the text of a document, its type is told by the extension of name, or else by mime.
+// Other UTF-8 files, like source files of any language, are text.
+func Extract(name, mime string, data []byte) (string, error) {
+	ext := strings.ToLower(path.Ext(name))
+	switch {
+	case ext == ".pdf" || mime == "application/pdf":
+		return extractPDF(data)
+	case ext == ".docx" || mime == "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return extractDOCX(data)
+	case isText(data):
+		return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupported, name)
+}
+
+// isText reports if data is UTF-8 without NUL bytes
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
+
+func extractPDF(data []byte) (string, error) {
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("read pdf error: %w", err)
+	}
+	var sb strings.Builder
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		rows, err := page.GetTextByRow()
+		if err != nil {
+			return "", fmt.Errorf("read pdf page %d error: %w", i, err)
+		}
+		for _, row := range rows {
+			for _, word := range row.Content {
+				sb.WriteString(word.S)
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	text := strings.TrimSpace(sb.String())
+	if text == "" {
+		return "", fmt.Errorf("no text found in pdf, scanned documents are not supported")
+	}
+	return text, nil
+}
+
+// extractDOCX reads the paragraphs of word/document.xml
+func extractDOCX(data []byte) (string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("read docx error: %w", err)
+	}
+	doc, err := reader.Open("word/document.xml")
+	if err != nil {
+		return "", fmt.Errorf("read docx error: %w", err)
+	}
+	defer doc.Close()
+
+	var sb strings.Builder
+	decoder := xml.NewDecoder(doc)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("decode docx error: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br", "cr":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
@@ -0,0 +1,75 @@
+package docextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractText(t *testing.T) {
+	text, err := Extract("main.go", "", []byte("package main\r\n\r\nfunc main() {}\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {}\n", text)
+
+	text, err = Extract("data.csv", "text/csv", []byte("name,age\nbob,42\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "name,age\nbob,42\n", text)
+}
+
+func TestExtractDOCX(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("word/document.xml")
+	require.NoError(t, err)
+	_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:tab/><w:t xml:space="preserve">world &amp; more</w:t></w:r></w:p>
+<w:p><w:r><w:t>Second</w:t><w:br/><w:t>line</w:t></w:r></w:p>
+</w:body></w:document>`))
+	require.NoError(t, zw.Close())
+
+	text, err := Extract("report.docx", "", buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "Hello\tworld & more\nSecond\nline", text)
+}
+
+func TestExtractUnsupported(t *testing.T) {
+	_, err := Extract("image.png", "image/png", []byte{0x89, 'P', 'N', 'G', 0, 0, 0xff})
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = Extract("broken.pdf", "", []byte("not a pdf"))
+	assert.Error(t, err)
+}
+
+func TestChunk(t *testing.T) {
+	para := strings.Repeat("lorem ipsum ", 20)
+	text := strings.Join([]string{para, para, para, strings.Repeat("x", 250)}, "\n\n")
+	chunks := Chunk(text, 300)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), 300)
+	}
+	assert.Equal(t, strings.Count(text, "lorem"), strings.Count(strings.Join(chunks, " "), "lorem"))
+	assert.Len(t, Chunk("short text", 300), 1)
+
+	// multi-byte runes are not cut
+	for _, chunk := range Chunk(strings.Repeat("文", 200), 100) {
+		assert.True(t, len([]rune(chunk)) > 0 && strings.Trim(chunk, "文") == "")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	chunks := []string{
+		"the introduction of the paper",
+		"kubernetes pods are scheduled on nodes",
+		"results of the benchmark",
+		"pods restart when the liveness probe fails",
+	}
+	selected := Select(chunks, "Why do my pods restart?", 80)
+	assert.Equal(t, []string{chunks[1], chunks[3]}, selected)
+
+	assert.Equal(t, chunks, Select(chunks, "", 1000))
+}
@@ -0,0 +1,142 @@
+package aigateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/llm/awsbedrock"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/google/uuid"
+)
+
+// bedrockProvider is the api of aws bedrock, requests and responses are mapped by the codec of the model family,
+// the responses of streams are aws eventstream
+type bedrockProvider struct {
+	config      llmconfig.AWSBedrockConfig
+	credentials aws.CredentialsProvider
+}
+
+func (p bedrockProvider) payload(req llm.ChatCompletionRequest) ([]byte, error) {
+	codec, err := awsbedrock.CodecFor(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Encode(req)
+}
+
+func (p bedrockProvider) decodeResponse(body io.Reader, model string) (llm.ChatCompletionResponse, error) {
+	codec, err := awsbedrock.CodecFor(model)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("read response error: %w", err)
+	}
+	resp, err := codec.DecodeResponse(data)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, err
+	}
+	resp.ID = uuid.NewString()
+	resp.Created = time.Now().Unix()
+	resp.Model = model
+	return resp, nil
+}
+
+// streaming is false for models of families which can not stream
+func (p bedrockProvider) streaming(model string) bool {
+	codec, err := awsbedrock.CodecFor(model)
+	return err == nil && codec.Streaming()
+}
+
+// BedrockChunk is the payload of a chunk event, Bytes is the json of a chunk of the model family
+type BedrockChunk struct {
+	Bytes []byte `json:"bytes"`
+}
+
+func (p bedrockProvider) decodeStream(body io.Reader, model string, dataChan chan llm.ChatCompletionStreamResponse) error {
+	codec, err := awsbedrock.CodecFor(model)
+	if err != nil {
+		return err
+	}
+	id, created := uuid.NewString(), time.Now().Unix()
+	decoder := eventstream.NewDecoder()
+	var payloadBuf []byte
+	for {
+		msg, err := decoder.Decode(body, payloadBuf)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("decode eventstream error: %w", err)
+		}
+
+		switch headerString(msg.Headers, ":message-type") {
+		case "event":
+			if headerString(msg.Headers, ":event-type") != "chunk" {
+				continue
+			}
+			var chunk BedrockChunk
+			if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+				return fmt.Errorf("decode bedrock chunk error: %w", err)
+			}
+			data, err := codec.DecodeChunk(chunk.Bytes)
+			if err != nil {
+				return err
+			}
+			data.ID, data.Created, data.Model = id, created, model
+			dataChan <- data
+		case "exception":
+			return fmt.Errorf("bedrock %s: %s", headerString(msg.Headers, ":exception-type"), msg.Payload)
+		case "error":
+			return fmt.Errorf("bedrock %s: %s", headerString(msg.Headers, ":error-code"), headerString(msg.Headers, ":error-message"))
+		}
+	}
+}
+
+func (p bedrockProvider) streamAccept() string {
+	return "application/vnd.amazon.eventstream"
+}
+
+// sign signs the request as if it was sent to bedrock directly, which is what the gateway forwards
+func (p bedrockProvider) sign(ctx context.Context, request *http.Request, payload []byte, model string, stream bool) error {
+	action := "invoke"
+	if stream {
+		action = "invoke-with-response-stream"
+	}
+	awsUrl := fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/%s", p.config.Region, model, action)
+	awsReq, err := http.NewRequestWithContext(ctx, request.Method, awsUrl, nil)
+	if err != nil {
+		return err
+	}
+	awsReq.Header = request.Header.Clone()
+
+	credentials, err := p.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieve aws credentials error: %w", err)
+	}
+	sum := sha256.Sum256(payload)
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, awsReq, hex.EncodeToString(sum[:]), "bedrock", p.config.Region, time.Now()); err != nil {
+		return fmt.Errorf("sign request error: %w", err)
+	}
+	request.Header = awsReq.Header
+	return nil
+}
+
+func headerString(headers eventstream.Headers, name string) string {
+	if v := headers.Get(name); v != nil {
+		return v.String()
+	}
+	return ""
+}
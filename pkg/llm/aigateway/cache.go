@@ -0,0 +1,55 @@
+package aigateway
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderSkipCache = "cf-skip-cache"
+	HeaderCacheTTL  = "cf-cache-ttl"
+)
+
+// CacheOptions control the response cache of the gateway
+type CacheOptions struct {
+	// Skip never serves the response from the gateway cache
+	Skip bool
+	// TTL is how long the response is cached, zero uses the ttl of the gateway settings
+	TTL time.Duration
+}
+
+type cacheOptionsKey struct{}
+
+// WithCacheOptions overrides the cache options of the config for the calls with ctx.
+func WithCacheOptions(ctx context.Context, opts CacheOptions) context.Context {
+	return context.WithValue(ctx, cacheOptionsKey{}, opts)
+}
+
+func getCacheOptions(ctx context.Context, defaults CacheOptions) CacheOptions {
+	if opts, ok := ctx.Value(cacheOptionsKey{}).(CacheOptions); ok {
+		return opts
+	}
+	return defaults
+}
+
+// cacheTransport sets the cache headers of the gateway on every request
+type cacheTransport struct {
+	base     http.RoundTripper
+	defaults CacheOptions
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	opts := getCacheOptions(req.Context(), t.defaults)
+	if opts.Skip || opts.TTL > 0 {
+		req = req.Clone(req.Context())
+		if opts.Skip {
+			req.Header.Set(HeaderSkipCache, "true")
+		}
+		if opts.TTL > 0 {
+			req.Header.Set(HeaderCacheTTL, strconv.Itoa(int(opts.TTL.Seconds())))
+		}
+	}
+	return t.base.RoundTrip(req)
+}
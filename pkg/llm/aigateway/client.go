@@ -3,27 +3,35 @@ package aigateway
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/Vaayne/aienvoy/pkg/llm"
-	"github.com/Vaayne/aienvoy/pkg/llm/claude"
+	"github.com/Vaayne/aienvoy/pkg/llm/awsbedrock"
 	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
-	"github.com/mitchellh/mapstructure"
+	"github.com/Vaayne/aienvoy/pkg/llm/replicate"
+	"github.com/Vaayne/aienvoy/pkg/redact"
 )
 
+// workersAIModelsTTL is how long discovered workers ai models are used before they are listed again
+const workersAIModelsTTL = time.Hour
+
 type Client struct {
-	session *http.Client
-	config  llmconfig.AiGatewayConfig
-	Models  []string `json:"models"`
+	session  *http.Client
+	config   llmconfig.AiGatewayConfig
+	provider provider
+	// replicate predictions are created through the gateway by the replicate client
+	replicate        *replicate.Client
+	cloudflareApiUrl string
+	Models           []string `json:"models"`
+
+	mu                sync.Mutex
+	workersAIModels   []WorkersAIModel
+	workersAIListedAt time.Time
 }
 
 func NewClient(cfg llmconfig.Config) (*Client, error) {
@@ -34,197 +42,216 @@ func NewClient(cfg llmconfig.Config) (*Client, error) {
 		return nil, err
 	}
 
+	gateway := cfg.AiGateway
 	client := &Client{
-		session: http.DefaultClient,
-		config:  cfg.AiGateway,
+		session: &http.Client{Transport: &cacheTransport{
+			base:     http.DefaultTransport,
+			defaults: CacheOptions{Skip: gateway.SkipCache, TTL: gateway.CacheTTL},
+		}},
+		config:           gateway,
+		cloudflareApiUrl: cloudflareApiUrl,
+		Models:           cfg.Models,
+	}
+	// base url overrides the cloudflare api which workers ai models are discovered from
+	if cfg.BaseUrl != "" {
+		client.cloudflareApiUrl = cfg.BaseUrl
+	}
+
+	switch gateway.Provider.Type {
+	case llmconfig.AiGatewayProviderOpenAI, llmconfig.AiGatewayProviderAzureOpenAI:
+		client.provider = openaiProvider{}
+	case llmconfig.AiGatewayProviderWorkersAI:
+		client.provider = workersAIProvider{}
+	case llmconfig.AiGatewayProviderHuggingFace:
+		p, err := newHuggingFaceProvider(gateway.Provider.ChatTemplate)
+		if err != nil {
+			return nil, err
+		}
+		client.provider = p
+	case llmconfig.AiGatewayProviderAWSBedrock:
+		awsConfig, err := awsbedrock.LoadConfig(context.Background(), gateway.Provider.AWSBedrock)
+		if err != nil {
+			return nil, err
+		}
+		client.provider = bedrockProvider{config: gateway.Provider.AWSBedrock, credentials: awsConfig.Credentials}
+	case llmconfig.AiGatewayProviderReplicate:
+		rc, err := replicate.NewClient(llmconfig.Config{
+			LLMType:   llmconfig.LLMTypeReplicate,
+			ApiKey:    gateway.Provider.ApiKey,
+			BaseUrl:   gateway.GetProviderURL(),
+			Replicate: gateway.Provider.Replicate,
+		})
+		if err != nil {
+			return nil, err
+		}
+		client.replicate = rc.WithSession(client.session)
+	default:
+		return nil, fmt.Errorf("ai gateway provider %s not supported", gateway.Provider.Type)
 	}
 
 	return client, nil
 }
 
 func (c *Client) ListModels() []string {
-	if len(c.Models) == 0 {
-		c.Models = c.config.ListModels()
+	if len(c.Models) > 0 {
+		return c.Models
+	}
+	switch {
+	case c.replicate != nil:
+		return c.replicate.ListModels()
+	case c.config.Provider.Type == llmconfig.AiGatewayProviderWorkersAI:
+		models := make([]string, 0)
+		for _, m := range c.getWorkersAIModels() {
+			models = append(models, m.Name)
+		}
+		if len(models) > 0 {
+			return models
+		}
 	}
-	return c.Models
+	return c.config.ListModels()
 }
 
-func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
-	config := c.config
-	payload, err := buildRequestPayload(req, config)
-	if err != nil {
-		return llm.ChatCompletionResponse{}, fmt.Errorf("build request payload error: %w", err)
+func (c *Client) DescribeModels() []llm.Model {
+	if c.replicate != nil && len(c.Models) == 0 {
+		return c.replicate.DescribeModels()
 	}
+	described := make(map[string]llm.Model)
+	if c.config.Provider.Type == llmconfig.AiGatewayProviderWorkersAI {
+		for _, m := range c.getWorkersAIModels() {
+			described[m.Name] = m.ToLLMModel()
+		}
+	}
+	models := make([]llm.Model, 0)
+	for _, id := range c.ListModels() {
+		if m, ok := described[id]; ok {
+			models = append(models, m)
+		} else {
+			models = append(models, llm.Model{ID: id, Name: id})
+		}
+	}
+	return models
+}
 
-	url := config.GetChatURL(req.Model)
-	slog.DebugContext(ctx, "chat request", "url", url, "req", string(payload))
-	requestBody := bytes.NewReader(payload)
-	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, requestBody)
+// getWorkersAIModels returns the discovered workers ai models, discovery errors keep the former models
+func (c *Client) getWorkersAIModels() []WorkersAIModel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.workersAIListedAt) < workersAIModelsTTL {
+		return c.workersAIModels
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	models, err := c.listWorkersAIModels(ctx)
 	if err != nil {
-		return llm.ChatCompletionResponse{}, fmt.Errorf("create request error: %w", err)
+		slog.Error("list workers ai models error", "err", err)
+		return c.workersAIModels
 	}
+	c.workersAIModels = models
+	c.workersAIListedAt = time.Now()
+	return models
+}
 
-	if err := setRequestHeaders(ctx, request, config, false, requestBody); err != nil {
-		return llm.ChatCompletionResponse{}, fmt.Errorf("set request headers error: %w", err)
+func (c *Client) newRequest(ctx context.Context, req llm.ChatCompletionRequest) (*http.Request, error) {
+	payload, err := c.provider.payload(req)
+	if err != nil {
+		return nil, fmt.Errorf("build request payload error: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(request)
+	url := c.config.GetChatURL(req.Model)
+	if req.Stream {
+		url = c.config.GetStreamURL(req.Model)
+	}
+	slog.DebugContext(ctx, "chat request", "url", url, "req", string(payload))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
 	if err != nil {
-		return llm.ChatCompletionResponse{}, fmt.Errorf("do request error: %w", err)
+		return nil, fmt.Errorf("create request error: %w", err)
 	}
-	defer resp.Body.Close()
 
-	// check response status, if not 200, return error
-	if resp.StatusCode != http.StatusOK {
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return llm.ChatCompletionResponse{}, fmt.Errorf("decode response error: %w", err)
+	for k, v := range c.config.GetAuthHeader() {
+		request.Header.Set(k, v)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if req.Stream {
+		request.Header.Set("Accept", c.provider.streamAccept())
+	} else {
+		request.Header.Set("Accept", "application/json")
+	}
+	if p, ok := c.provider.(bedrockProvider); ok {
+		request.Header.Del("Authorization")
+		if err := p.sign(ctx, request, payload, req.Model, req.Stream); err != nil {
+			return nil, err
 		}
-		return llm.ChatCompletionResponse{}, fmt.Errorf("chat error, status: %s, body: %s, headers: %v", resp.Status, string(respBody), resp.Header)
 	}
-	return processResponse(ctx, resp.Body, config)
+	slog.DebugContext(ctx, "chat request headers", "headers", redact.Headers(request.Header))
+	return request, nil
 }
 
-func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
-	req.Stream = true
-	config := c.config
-	payload, err := buildRequestPayload(req, config)
+func (c *Client) do(ctx context.Context, req llm.ChatCompletionRequest) (*http.Response, error) {
+	request, err := c.newRequest(ctx, req)
 	if err != nil {
-		errChan <- fmt.Errorf("build request payload error: %w", err)
-		return
+		return nil, err
 	}
-
-	url := config.GetChatURL(req.Model)
-	slog.DebugContext(ctx, "chat request", "url", url, "req", string(payload))
-	requestBody := bytes.NewReader(payload)
-	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, requestBody)
+	resp, err := c.session.Do(request)
 	if err != nil {
-		errChan <- fmt.Errorf("create request error: %w", err)
-		return
+		return nil, fmt.Errorf("do request error: %w", err)
 	}
-	if err := setRequestHeaders(ctx, request, config, false, requestBody); err != nil {
-		errChan <- fmt.Errorf("set request headers error: %w", err)
-		return
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		httpErr := llm.NewHTTPError(resp)
+		slog.DebugContext(ctx, "chat error response headers", "headers", redact.Headers(resp.Header))
+		return nil, fmt.Errorf("chat error: %w", httpErr)
 	}
+	return resp, nil
+}
 
-	resp, err := http.DefaultClient.Do(request)
+func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	if c.replicate != nil {
+		return c.replicate.CreateChatCompletion(ctx, req)
+	}
+	req.Stream = false
+	resp, err := c.do(ctx, req)
 	if err != nil {
-		errChan <- fmt.Errorf("do request error: %w", err)
-		return
+		return llm.ChatCompletionResponse{}, err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			errChan <- fmt.Errorf("decode response error: %w", err)
-			return
-		}
-		errChan <- fmt.Errorf("chat error, status: %s, body: %s, headers: %v", resp.Status, string(respBody), resp.Header)
-		return
-	}
-
-	innerDataChan := make(chan any)
-	innerErrChan := make(chan error)
 
-	go llm.ParseSSE[any](resp.Body, innerDataChan, innerErrChan)
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case data := <-innerDataChan:
-			switch config.Provider.Type {
-			case llmconfig.AiGatewayProviderAWSBedrock:
-				var val claude.BedrockResponse
-				if err := mapstructure.Decode(data, &val); err != nil {
-					errChan <- fmt.Errorf("parse response error: %w", err)
-					return
-				}
-				dataChan <- val.ToChatCompletionStreamResponse()
-			case llmconfig.AiGatewayProviderOpenAI, llmconfig.AiGatewayProviderAzureOpenAI:
-				// convert any to ChatCompletionStreamResponse
-				// the any may response as map[string]interface{}, so we have to convert it manually
-				var val llm.ChatCompletionStreamResponse
-				if err := mapstructure.Decode(data, &val); err != nil {
-					errChan <- fmt.Errorf("parse response error: %w", err)
-					return
-				}
-				dataChan <- val
-			default:
-				errChan <- fmt.Errorf("provider %s not supported", config.Provider)
-			}
-		case err := <-innerErrChan:
-			errChan <- err
-			return
-		}
-	}
-}
-
-func buildRequestPayload(req llm.ChatCompletionRequest, config llmconfig.AiGatewayConfig) ([]byte, error) {
-	var payload []byte
-	var err error
-
-	switch config.Provider.Type {
-	case llmconfig.AiGatewayProviderAWSBedrock:
-		bedrockRequest := &claude.BedrockRequest{}
-		bedrockRequest.FromChatCompletionRequest(req)
-		payload = bedrockRequest.Marshal()
-	default:
-		payload, _ = json.Marshal(req)
+	result, err := c.provider.decodeResponse(resp.Body, req.Model)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, err
 	}
-
-	return payload, err
+	slog.DebugContext(ctx, "chat response success", "resp", result)
+	return result, nil
 }
 
-func setRequestHeaders(ctx context.Context, request *http.Request, config llmconfig.AiGatewayConfig, stream bool, requestBody io.Reader) error {
-	// set auth header
-	for k, v := range config.GetAuthHeader() {
-		request.Header.Set(k, v)
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
+	if c.replicate != nil {
+		c.replicate.CreateChatCompletionStream(ctx, req, dataChan, errChan)
+		return
 	}
-	// set content type and accept
-	request.Header.Set("Content-Type", "application/json")
-	if stream {
-		if config.Provider.Type == llmconfig.AiGatewayProviderAWSBedrock {
-			request.Header.Set("accept", "application/vnd.amazon.eventstream")
-		} else {
-			request.Header.Set("accept", "text/event-stream")
-		}
-	} else {
-		request.Header.Set("accept", "application/json")
-	}
-
-	// set bedrock headers
-	if config.Provider.Type == llmconfig.AiGatewayProviderAWSBedrock {
-		// Create a signer with the credentials
-		signer := v4.NewSigner()
-		h := sha256.New()
-		_, _ = io.Copy(h, requestBody)
-		payloadHash := hex.EncodeToString(h.Sum(nil))
-		ab := config.Provider.AWSBedrock
-		privider := aws.Credentials{AccessKeyID: ab.AccessKey, SecretAccessKey: ab.SecretKey, SessionToken: ""}
-		if err := signer.SignHTTP(ctx, privider, request, payloadHash, "bedrock", ab.Region, time.Now()); err != nil {
-			return fmt.Errorf("sign request error: %w", err)
+	if p, ok := c.provider.(bedrockProvider); ok && !p.streaming(req.Model) {
+		resp, err := c.CreateChatCompletion(ctx, req)
+		if err != nil {
+			errChan <- err
+			return
 		}
+		dataChan <- resp.ToChatCompletionStreamResponse()
+		errChan <- io.EOF
+		return
 	}
-	slog.DebugContext(ctx, "chat request headers", "headers", request.Header)
-	return nil
-}
+	req.Stream = true
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	defer resp.Body.Close()
 
-func processResponse(ctx context.Context, body io.ReadCloser, config llmconfig.AiGatewayConfig) (llm.ChatCompletionResponse, error) {
-	var respBody llm.ChatCompletionResponse
-	switch config.Provider.Type {
-	case llmconfig.AiGatewayProviderAWSBedrock:
-		var bedrockResp claude.BedrockResponse
-		err := json.NewDecoder(body).Decode(&bedrockResp)
-		if err != nil {
-			return llm.ChatCompletionResponse{}, fmt.Errorf("decode response error: %w", err)
-		}
-		respBody = bedrockResp.ToChatCompletionResponse()
-	default:
-		err := json.NewDecoder(body).Decode(&respBody)
-		if err != nil {
-			return llm.ChatCompletionResponse{}, fmt.Errorf("decode response error: %w", err)
+	if err := c.provider.decodeStream(resp.Body, req.Model, dataChan); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
 		}
+		errChan <- err
+		return
 	}
-	slog.DebugContext(ctx, "chat response success", "resp", respBody)
-	return respBody, nil
+	errChan <- io.EOF
 }
@@ -0,0 +1,166 @@
+package aigateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var messages = []llm.ChatCompletionMessage{
+	{Role: llm.ChatMessageRoleSystem, Content: "be brief"},
+	{Role: llm.ChatMessageRoleUser, Content: "hi"},
+}
+
+// newGateway fakes the gateway with handler serving all providers
+func newGateway(t *testing.T, handler http.HandlerFunc, provider llmconfig.AiGatewayProvider) (*Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cli, err := NewClient(llmconfig.Config{
+		LLMType: llmconfig.LLMTypeAiGateway,
+		BaseUrl: server.URL + "/client/v4",
+		AiGateway: llmconfig.AiGatewayConfig{
+			AccountId: "acc",
+			Name:      "gw",
+			Host:      server.URL,
+			SkipCache: true,
+			Provider:  provider,
+		},
+	})
+	require.NoError(t, err)
+	return cli, server
+}
+
+func collect(t *testing.T, cli *Client, model string) string {
+	dataChan := make(chan llm.ChatCompletionStreamResponse)
+	errChan := make(chan error)
+	go cli.CreateChatCompletionStream(context.Background(), llm.ChatCompletionRequest{Model: model, Messages: messages}, dataChan, errChan)
+	sb := strings.Builder{}
+	for {
+		select {
+		case data := <-dataChan:
+			sb.WriteString(data.Choices[0].Delta.Content)
+		case err := <-errChan:
+			require.True(t, errors.Is(err, io.EOF), err)
+			return sb.String()
+		}
+	}
+}
+
+func TestWorkersAI(t *testing.T) {
+	model := "@cf/meta/llama-2-7b-chat-int8"
+	var headers []http.Header
+	cli, _ := newGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		if strings.HasPrefix(r.URL.Path, "/client/v4/") {
+			assert.Equal(t, "/client/v4/accounts/acc/ai/models/search", r.URL.Path)
+			assert.Equal(t, "Text Generation", r.URL.Query().Get("task"))
+			_, _ = w.Write([]byte(`{"success": true, "result": [{"name": "@cf/meta/llama-2-7b-chat-int8", "description": "llama", "properties": [{"property_id": "context_window", "value": "4096"}]}]}`))
+			return
+		}
+		headers = append(headers, r.Header)
+		assert.Equal(t, "/acc/gw/workers-ai/"+model, r.URL.Path)
+		var req WorkersAIRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		assert.Equal(t, "be brief", req.Messages[0].Content)
+		if !req.Stream {
+			_, _ = w.Write([]byte(`{"success": true, "result": {"response": "hello"}}`))
+			return
+		}
+		_, _ = io.WriteString(w, "data: {\"response\":\"hel\"}\n\ndata: {\"response\":\"lo\"}\n\ndata: [DONE]\n\n")
+	}, llmconfig.AiGatewayProvider{Type: llmconfig.AiGatewayProviderWorkersAI, ApiKey: "token"})
+
+	assert.Equal(t, []string{model}, cli.ListModels())
+	assert.Equal(t, 4096, cli.DescribeModels()[0].ContextLength)
+
+	resp, err := cli.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: model, Messages: messages})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, "hello", collect(t, cli, model))
+
+	// cache options of the context override the config
+	ctx := WithCacheOptions(context.Background(), CacheOptions{TTL: time.Minute})
+	_, err = cli.CreateChatCompletion(ctx, llm.ChatCompletionRequest{Model: model, Messages: messages})
+	require.NoError(t, err)
+	assert.Equal(t, "true", headers[0].Get(HeaderSkipCache))
+	assert.Empty(t, headers[2].Get(HeaderSkipCache))
+	assert.Equal(t, "60", headers[2].Get(HeaderCacheTTL))
+}
+
+func TestHuggingFace(t *testing.T) {
+	model := "mistralai/Mistral-7B-Instruct-v0.1"
+	cli, _ := newGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/acc/gw/huggingface/"+model, r.URL.Path)
+		var req TGIRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		assert.Equal(t, "<s>[INST] <<SYS>>\nbe brief\n<</SYS>>\n\nhi [/INST]", req.Inputs)
+		if !req.Stream {
+			_, _ = w.Write([]byte(`[{"generated_text": "hello"}]`))
+			return
+		}
+		_, _ = io.WriteString(w, "data:{\"token\":{\"id\":1,\"text\":\"hel\",\"special\":false},\"generated_text\":null}\n\n")
+		_, _ = io.WriteString(w, "data:{\"token\":{\"id\":2,\"text\":\"lo\",\"special\":false},\"generated_text\":null}\n\n")
+		_, _ = io.WriteString(w, "data:{\"token\":{\"id\":3,\"text\":\"</s>\",\"special\":true},\"generated_text\":\"hello\",\"details\":{\"finish_reason\":\"eos_token\"}}\n\n")
+	}, llmconfig.AiGatewayProvider{Type: llmconfig.AiGatewayProviderHuggingFace, ApiKey: "key"})
+
+	resp, err := cli.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: model, Messages: messages})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, "hello", collect(t, cli, model))
+}
+
+func TestBedrockStream(t *testing.T) {
+	model := "anthropic.claude-v2"
+	cli, _ := newGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/acc/gw/aws-bedrock/bedrock-runtime/us-east-1/model/"+model+"/invoke-with-response-stream", r.URL.Path)
+		assert.Equal(t, "application/vnd.amazon.eventstream", r.Header.Get("Accept"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256"))
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), "Human: hi")
+
+		encoder := eventstream.NewEncoder()
+		for _, completion := range []string{"hel", "lo"} {
+			chunk, _ := json.Marshal(BedrockChunk{Bytes: []byte(fmt.Sprintf(`{"completion": %q}`, completion))})
+			msg := eventstream.Message{Payload: chunk}
+			msg.Headers.Set(":message-type", eventstream.StringValue("event"))
+			msg.Headers.Set(":event-type", eventstream.StringValue("chunk"))
+			require.NoError(t, encoder.Encode(w, msg))
+		}
+	}, llmconfig.AiGatewayProvider{Type: llmconfig.AiGatewayProviderAWSBedrock, AWSBedrock: llmconfig.AWSBedrockConfig{
+		AccessKey: "ak", SecretKey: "sk", Region: "us-east-1",
+	}})
+
+	assert.Equal(t, "hello", collect(t, cli, model))
+}
+
+func TestBedrockSessionToken(t *testing.T) {
+	model := "meta.llama2-70b-chat-v1"
+	cli, _ := newGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/acc/gw/aws-bedrock/bedrock-runtime/us-east-1/model/"+model+"/invoke", r.URL.Path)
+		assert.Equal(t, "token", r.Header.Get("X-Amz-Security-Token"))
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=ak/")
+		_, _ = w.Write([]byte(`{"generation": "hello", "prompt_token_count": 10, "generation_token_count": 1, "stop_reason": "stop"}`))
+	}, llmconfig.AiGatewayProvider{Type: llmconfig.AiGatewayProviderAWSBedrock, AWSBedrock: llmconfig.AWSBedrockConfig{
+		AccessKey: "ak", SecretKey: "sk", SessionToken: "token", Region: "us-east-1",
+	}})
+
+	resp, err := cli.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{
+		Model:    model,
+		Messages: []llm.ChatCompletionMessage{{Role: llm.ChatMessageRoleUser, Content: "hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, 11, resp.Usage.TotalTokens)
+}
@@ -0,0 +1,167 @@
+package aigateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/llm/chattemplate"
+	"github.com/google/uuid"
+)
+
+type TGIParameters struct {
+	MaxNewTokens   int      `json:"max_new_tokens,omitempty"`
+	Temperature    float32  `json:"temperature,omitempty"`
+	TopP           float32  `json:"top_p,omitempty"`
+	Stop           []string `json:"stop,omitempty"`
+	ReturnFullText bool     `json:"return_full_text"`
+}
+
+// TGIRequest is the request of huggingface text generation inference
+type TGIRequest struct {
+	Inputs     string        `json:"inputs"`
+	Parameters TGIParameters `json:"parameters"`
+	Stream     bool          `json:"stream,omitempty"`
+}
+
+type TGIDetails struct {
+	FinishReason    string `json:"finish_reason"`
+	GeneratedTokens int    `json:"generated_tokens"`
+}
+
+type TGIResponse struct {
+	GeneratedText string      `json:"generated_text"`
+	Details       *TGIDetails `json:"details"`
+}
+
+type TGIStreamResponse struct {
+	Token struct {
+		Id      int    `json:"id"`
+		Text    string `json:"text"`
+		Special bool   `json:"special"`
+	} `json:"token"`
+	GeneratedText *string     `json:"generated_text"`
+	Details       *TGIDetails `json:"details"`
+	Error         string      `json:"error"`
+}
+
+func tgiFinishReason(details *TGIDetails) llm.FinishReason {
+	if details == nil {
+		return llm.FinishReasonStop
+	}
+	if details.FinishReason == "length" {
+		return llm.FinishReasonLength
+	}
+	return llm.FinishReasonStop
+}
+
+// huggingFaceProvider is the api of text generation inference, messages are rendered by a chat template
+type huggingFaceProvider struct {
+	template *chattemplate.Template
+}
+
+func newHuggingFaceProvider(chatTemplate string) (*huggingFaceProvider, error) {
+	if chatTemplate == "" {
+		chatTemplate = chattemplate.Llama2
+	}
+	t, err := chattemplate.Parse(chatTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parse huggingface chat template error: %w", err)
+	}
+	return &huggingFaceProvider{template: t}, nil
+}
+
+func (p *huggingFaceProvider) payload(req llm.ChatCompletionRequest) ([]byte, error) {
+	prompt, err := p.template.Render(req.Messages, chattemplate.Options{
+		BosToken:            "<s>",
+		EosToken:            "</s>",
+		AddGenerationPrompt: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render prompt error: %w", err)
+	}
+	return json.Marshal(TGIRequest{
+		Inputs: prompt,
+		Parameters: TGIParameters{
+			MaxNewTokens: req.MaxTokens,
+			Temperature:  req.Temperature,
+			TopP:         req.TopP,
+			Stop:         req.Stop,
+		},
+		Stream: req.Stream,
+	})
+}
+
+func (p *huggingFaceProvider) decodeResponse(body io.Reader, model string) (llm.ChatCompletionResponse, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("read response error: %w", err)
+	}
+	// the inference api responds a list, text generation inference an object
+	var resp TGIResponse
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var list []TGIResponse
+		if err := json.Unmarshal(data, &list); err != nil {
+			return llm.ChatCompletionResponse{}, fmt.Errorf("decode response error: %w", err)
+		}
+		if len(list) > 0 {
+			resp = list[0]
+		}
+	} else if err := json.Unmarshal(data, &resp); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("decode response error: %w", err)
+	}
+
+	result := llm.ChatCompletionResponse{
+		ID:      "chatcmpl-" + uuid.NewString(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []llm.ChatCompletionChoice{
+			{
+				Message: llm.ChatCompletionMessage{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: resp.GeneratedText,
+				},
+				FinishReason: tgiFinishReason(resp.Details),
+			},
+		},
+	}
+	if resp.Details != nil {
+		result.Usage.CompletionTokens = resp.Details.GeneratedTokens
+		result.Usage.TotalTokens = resp.Details.GeneratedTokens
+	}
+	return result, nil
+}
+
+func (p *huggingFaceProvider) decodeStream(body io.Reader, model string, dataChan chan llm.ChatCompletionStreamResponse) error {
+	id := "chatcmpl-" + uuid.NewString()
+	return readSSE(body, func(data []byte) (bool, error) {
+		var resp TGIStreamResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return true, fmt.Errorf("decode stream response error: %w, data: %s", err, data)
+		}
+		if resp.Error != "" {
+			return true, fmt.Errorf("huggingface stream error: %s", resp.Error)
+		}
+		content := resp.Token.Text
+		if resp.Token.Special {
+			content = ""
+		}
+		// the last event has the generated text and details
+		if resp.GeneratedText != nil {
+			dataChan <- newStreamResponse(id, model, content, tgiFinishReason(resp.Details))
+			return true, nil
+		}
+		if content != "" {
+			dataChan <- newStreamResponse(id, model, content, "")
+		}
+		return false, nil
+	})
+}
+
+func (p *huggingFaceProvider) streamAccept() string {
+	return "text/event-stream"
+}
@@ -0,0 +1,49 @@
+package aigateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+// provider converts between llm requests and the api of a provider behind the gateway
+type provider interface {
+	payload(req llm.ChatCompletionRequest) ([]byte, error)
+	decodeResponse(body io.Reader, model string) (llm.ChatCompletionResponse, error)
+	// decodeStream relays the streamed body to dataChan until it ends
+	decodeStream(body io.Reader, model string, dataChan chan llm.ChatCompletionStreamResponse) error
+	// streamAccept is the accept header of streaming requests
+	streamAccept() string
+}
+
+// openaiProvider is the api of openai and azure openai
+type openaiProvider struct{}
+
+func (openaiProvider) payload(req llm.ChatCompletionRequest) ([]byte, error) {
+	return json.Marshal(req)
+}
+
+func (openaiProvider) decodeResponse(body io.Reader, _ string) (llm.ChatCompletionResponse, error) {
+	var resp llm.ChatCompletionResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("decode response error: %w", err)
+	}
+	return resp, nil
+}
+
+func (openaiProvider) decodeStream(body io.Reader, _ string, dataChan chan llm.ChatCompletionStreamResponse) error {
+	return readSSE(body, func(data []byte) (bool, error) {
+		var resp llm.ChatCompletionStreamResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return true, fmt.Errorf("decode stream response error: %w, data: %s", err, data)
+		}
+		dataChan <- resp
+		return false, nil
+	})
+}
+
+func (openaiProvider) streamAccept() string {
+	return "text/event-stream"
+}
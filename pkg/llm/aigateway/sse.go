@@ -0,0 +1,32 @@
+package aigateway
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// readSSE calls onData with the data of every event until it returns true or the stream ends,
+// unlike llm.ParseSSE it accepts `data:` without the space which text generation inference sends.
+func readSSE(body io.Reader, onData func(data []byte) (bool, error)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(line[len("data:"):])
+		if bytes.Equal(data, []byte("[DONE]")) {
+			return nil
+		}
+		if len(data) == 0 {
+			continue
+		}
+		stop, err := onData(data)
+		if stop || err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
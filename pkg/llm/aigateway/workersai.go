@@ -0,0 +1,182 @@
+package aigateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/google/uuid"
+)
+
+const cloudflareApiUrl = "https://api.cloudflare.com/client/v4"
+
+type WorkersAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type WorkersAIRequest struct {
+	Messages    []WorkersAIMessage `json:"messages"`
+	Stream      bool               `json:"stream,omitempty"`
+	MaxTokens   int                `json:"max_tokens,omitempty"`
+	Temperature float32            `json:"temperature,omitempty"`
+}
+
+type WorkersAIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type WorkersAIResponse struct {
+	Result struct {
+		Response string `json:"response"`
+	} `json:"result"`
+	Success bool             `json:"success"`
+	Errors  []WorkersAIError `json:"errors"`
+}
+
+type WorkersAIStreamResponse struct {
+	Response string `json:"response"`
+}
+
+type WorkersAIModel struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Task        struct {
+		Name string `json:"name"`
+	} `json:"task"`
+	Properties []struct {
+		PropertyId string `json:"property_id"`
+		Value      any    `json:"value"`
+	} `json:"properties"`
+}
+
+func (m WorkersAIModel) ToLLMModel() llm.Model {
+	model := llm.Model{
+		ID:          m.Name,
+		Name:        m.Name,
+		Description: m.Description,
+	}
+	for _, p := range m.Properties {
+		if p.PropertyId == "context_window" || p.PropertyId == "max_total_tokens" {
+			if v, err := strconv.Atoi(fmt.Sprint(p.Value)); err == nil {
+				model.ContextLength = v
+			}
+		}
+	}
+	return model
+}
+
+type WorkersAIModelsResponse struct {
+	Result  []WorkersAIModel `json:"result"`
+	Success bool             `json:"success"`
+	Errors  []WorkersAIError `json:"errors"`
+}
+
+// workersAIProvider is the native api of workers ai text generation models
+type workersAIProvider struct{}
+
+func (workersAIProvider) payload(req llm.ChatCompletionRequest) ([]byte, error) {
+	r := WorkersAIRequest{
+		Stream:      req.Stream,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	for _, m := range req.Messages {
+		r.Messages = append(r.Messages, WorkersAIMessage{Role: m.Role, Content: m.Content})
+	}
+	return json.Marshal(r)
+}
+
+func (workersAIProvider) decodeResponse(body io.Reader, model string) (llm.ChatCompletionResponse, error) {
+	var resp WorkersAIResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("decode response error: %w", err)
+	}
+	if !resp.Success {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("workers ai error: %v", resp.Errors)
+	}
+	return llm.ChatCompletionResponse{
+		ID:      "chatcmpl-" + uuid.NewString(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []llm.ChatCompletionChoice{
+			{
+				Message: llm.ChatCompletionMessage{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: resp.Result.Response,
+				},
+				FinishReason: llm.FinishReasonStop,
+			},
+		},
+	}, nil
+}
+
+func (workersAIProvider) decodeStream(body io.Reader, model string, dataChan chan llm.ChatCompletionStreamResponse) error {
+	id := "chatcmpl-" + uuid.NewString()
+	return readSSE(body, func(data []byte) (bool, error) {
+		var resp WorkersAIStreamResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return true, fmt.Errorf("decode stream response error: %w, data: %s", err, data)
+		}
+		dataChan <- newStreamResponse(id, model, resp.Response, "")
+		return false, nil
+	})
+}
+
+func (workersAIProvider) streamAccept() string {
+	return "text/event-stream"
+}
+
+// listWorkersAIModels discovers the text generation models of workers ai
+func (c *Client) listWorkersAIModels(ctx context.Context) ([]WorkersAIModel, error) {
+	query := url.Values{"task": {"Text Generation"}, "per_page": {"100"}}
+	u := fmt.Sprintf("%s/accounts/%s/ai/models/search?%s", c.cloudflareApiUrl, c.config.AccountId, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.Provider.ApiKey)
+	resp, err := c.session.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, llm.NewHTTPError(resp)
+	}
+	var models WorkersAIModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&models); err != nil {
+		return nil, fmt.Errorf("decode workers ai models error: %w", err)
+	}
+	if !models.Success {
+		return nil, fmt.Errorf("list workers ai models error: %v", models.Errors)
+	}
+	return models.Result, nil
+}
+
+func newStreamResponse(id, model, content string, finishReason llm.FinishReason) llm.ChatCompletionStreamResponse {
+	return llm.ChatCompletionStreamResponse{
+		ID:      id,
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []llm.ChatCompletionStreamChoice{
+			{
+				Delta: llm.ChatCompletionStreamChoiceDelta{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: content,
+				},
+				FinishReason: finishReason,
+			},
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package awsbedrock
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+// AI21Request is the request of ai21 jurassic-2 models, messages are rendered to a `User:` and `Assistant:` transcript
+type AI21Request struct {
+	Prompt        string   `json:"prompt"`
+	MaxTokens     int      `json:"maxTokens,omitempty"`
+	Temperature   float32  `json:"temperature,omitempty"`
+	TopP          float32  `json:"topP,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+type AI21Completion struct {
+	Data struct {
+		Text   string            `json:"text"`
+		Tokens []json.RawMessage `json:"tokens"`
+	} `json:"data"`
+	FinishReason struct {
+		Reason string `json:"reason"`
+	} `json:"finishReason"`
+}
+
+type AI21Response struct {
+	Id     any `json:"id"`
+	Prompt struct {
+		Text   string            `json:"text"`
+		Tokens []json.RawMessage `json:"tokens"`
+	} `json:"prompt"`
+	Completions []AI21Completion `json:"completions"`
+}
+
+// ai21Codec can not stream, jurassic-2 models do not support invoke with response stream
+type ai21Codec struct{}
+
+func (ai21Codec) Encode(req llm.ChatCompletionRequest) ([]byte, error) {
+	return json.Marshal(AI21Request{
+		Prompt:        rolePrompt(req.Messages, "User", "Assistant"),
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: req.Stop,
+	})
+}
+
+func (ai21Codec) DecodeResponse(body []byte) (llm.ChatCompletionResponse, error) {
+	var r AI21Response
+	if err := json.Unmarshal(body, &r); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("decode ai21 response error: %w", err)
+	}
+	if len(r.Completions) == 0 {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("ai21 response has no completions")
+	}
+	completion := r.Completions[0]
+	finishReason := llm.FinishReasonStop
+	if completion.FinishReason.Reason == "length" {
+		finishReason = llm.FinishReasonLength
+	}
+	return newResponse(completion.Data.Text, finishReason, usage(len(r.Prompt.Tokens), len(completion.Data.Tokens))), nil
+}
+
+func (ai21Codec) DecodeChunk(body []byte) (llm.ChatCompletionStreamResponse, error) {
+	resp, err := ai21Codec{}.DecodeResponse(body)
+	if err != nil {
+		return llm.ChatCompletionStreamResponse{}, err
+	}
+	return resp.ToChatCompletionStreamResponse(), nil
+}
+
+func (ai21Codec) Streaming() bool {
+	return false
+}
@@ -0,0 +1,89 @@
+package awsbedrock
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+// BedrockRequest is the text completion request of anthropic claude models
+type BedrockRequest struct {
+	Prompt            string   `json:"prompt"`
+	MaxTokensToSample int      `json:"max_tokens_to_sample"`
+	Temperature       float64  `json:"temperature,omitempty"`
+	TopP              float64  `json:"top_p,omitempty"`
+	TopK              int      `json:"top_k,omitempty"`
+	StopSequences     []string `json:"stop_sequences,omitempty"`
+}
+
+func (b *BedrockRequest) FromChatCompletionRequest(req llm.ChatCompletionRequest) {
+	b.MaxTokensToSample = req.MaxTokens
+	if b.MaxTokensToSample == 0 {
+		b.MaxTokensToSample = 4000
+	}
+	b.Temperature = float64(req.Temperature)
+	b.TopP = float64(req.TopP)
+	b.StopSequences = req.Stop
+
+	sb := strings.Builder{}
+	for _, m := range req.Messages {
+		switch m.Role {
+		case "user":
+			sb.WriteString(fmt.Sprintf("\n\nHuman: %s", m.Content))
+		case "assistant":
+			sb.WriteString(fmt.Sprintf("\n\nAssistant: %s", m.Content))
+		case "system":
+			sb.WriteString(fmt.Sprintf("\n\nSystem: %s", m.Content))
+		}
+	}
+	sb.WriteString("\n\nAssistant:")
+	b.Prompt = sb.String()
+}
+
+// BedrockResponse is the response and the chunk of streams of anthropic claude models
+type BedrockResponse struct {
+	Completion string `json:"completion"`
+	Stop       string `json:"stop"`
+	StopReason string `json:"stop_reason"`
+}
+
+func (b *BedrockResponse) finishReason() llm.FinishReason {
+	switch b.StopReason {
+	case "stop_sequence":
+		return llm.FinishReasonStop
+	case "max_tokens":
+		return llm.FinishReasonLength
+	default:
+		return llm.FinishReason(b.StopReason)
+	}
+}
+
+type claudeCodec struct{}
+
+func (claudeCodec) Encode(req llm.ChatCompletionRequest) ([]byte, error) {
+	b := &BedrockRequest{}
+	b.FromChatCompletionRequest(req)
+	return json.Marshal(b)
+}
+
+func (claudeCodec) DecodeResponse(body []byte) (llm.ChatCompletionResponse, error) {
+	var b BedrockResponse
+	if err := json.Unmarshal(body, &b); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("decode claude response error: %w", err)
+	}
+	return newResponse(b.Completion, b.finishReason(), llm.Usage{}), nil
+}
+
+func (claudeCodec) DecodeChunk(body []byte) (llm.ChatCompletionStreamResponse, error) {
+	var b BedrockResponse
+	if err := json.Unmarshal(body, &b); err != nil {
+		return llm.ChatCompletionStreamResponse{}, fmt.Errorf("decode claude chunk error: %w", err)
+	}
+	return withMetrics(newChunk(b.Completion, b.finishReason()), body), nil
+}
+
+func (claudeCodec) Streaming() bool {
+	return true
+}
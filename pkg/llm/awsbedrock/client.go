@@ -1,21 +1,19 @@
 package awsbedrock
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/Vaayne/aienvoy/pkg/llm"
 	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/google/uuid"
 )
 
 type Client struct {
@@ -30,17 +28,15 @@ func NewClient(cfg llmconfig.Config) (*Client, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
+	for _, model := range cfg.Models {
+		if _, err := CodecFor(model); err != nil {
+			return nil, err
+		}
+	}
 
-	ab := cfg.AWSBedrock
-	awsConfig, err := awsconfig.LoadDefaultConfig(context.Background(),
-		awsconfig.WithRegion(ab.Region),
-		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			ab.AccessKey,
-			ab.SecretKey,
-			"",
-		)))
+	awsConfig, err := LoadConfig(context.Background(), cfg.AWSBedrock)
 	if err != nil {
-		return nil, fmt.Errorf("get aws config error: %w", err)
+		return nil, err
 	}
 	return &Client{
 		Client: bedrockruntime.NewFromConfig(awsConfig),
@@ -57,66 +53,108 @@ func (c *Client) ListModels() []string {
 
 func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
 	slog.DebugContext(ctx, "chat start", "model", req.Model, "is_stream", false)
-	bedrockRequest := &BedrockRequest{}
-	bedrockRequest.FromChatCompletionRequest(req)
+	req.Stream = false
+	resp, err := c.invoke(ctx, req)
+	if err != nil {
+		slog.ErrorContext(ctx, "chat error", "model", req.Model, "is_stream", false, "err", err)
+		return llm.ChatCompletionResponse{}, err
+	}
+	slog.DebugContext(ctx, "chat success", "model", req.Model, "is_stream", false)
+	return resp, nil
+}
+
+func (c *Client) invoke(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	codec, err := CodecFor(req.Model)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, err
+	}
+	body, err := codec.Encode(req)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, err
+	}
 
 	output, err := c.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
 		ModelId:     aws.String(req.Model),
-		Body:        bedrockRequest.Marshal(),
+		Body:        body,
 		Accept:      aws.String("application/json"),
 		ContentType: aws.String("application/json"),
 	})
 	if err != nil {
-		slog.ErrorContext(ctx, "chat start", "model", req.Model, "is_stream", false, "err", err)
 		return llm.ChatCompletionResponse{}, err
 	}
-	resp := &BedrockResponse{}
-	resp.Unmarshal(output.Body)
-	slog.DebugContext(ctx, "chat success", "model", req.Model, "is_stream", false)
-	return resp.ToChatCompletionResponse(), nil
+	resp, err := codec.DecodeResponse(output.Body)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, err
+	}
+	resp.ID = newId()
+	resp.Created = time.Now().Unix()
+	resp.Model = req.Model
+	return resp, nil
 }
 
 func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
 	slog.DebugContext(ctx, "chat start", "model", req.Model, "is_stream", true)
-	bedrockRequest := &BedrockRequest{}
-	bedrockRequest.FromChatCompletionRequest(req)
+	req.Stream = true
+	codec, err := CodecFor(req.Model)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	if !codec.Streaming() {
+		resp, err := c.invoke(ctx, req)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		dataChan <- resp.ToChatCompletionStreamResponse()
+		errChan <- io.EOF
+		return
+	}
 
+	body, err := codec.Encode(req)
+	if err != nil {
+		errChan <- err
+		return
+	}
 	output, err := c.InvokeModelWithResponseStream(ctx, &bedrockruntime.InvokeModelWithResponseStreamInput{
 		ModelId:     aws.String(req.Model),
-		Body:        bedrockRequest.Marshal(),
+		Body:        body,
 		ContentType: aws.String("application/json"),
 	})
 	if err != nil {
 		errChan <- err
 		return
 	}
+	stream := output.GetStream()
+	defer stream.Close()
 
-	sb := &strings.Builder{}
-
-	for event := range output.GetStream().Events() {
+	id, created := newId(), time.Now().Unix()
+	for event := range stream.Events() {
 		switch v := event.(type) {
 		case *types.ResponseStreamMemberChunk:
-			var resp BedrockResponse
-			err := json.NewDecoder(bytes.NewReader(v.Value.Bytes)).Decode(&resp)
+			chunk, err := codec.DecodeChunk(v.Value.Bytes)
 			if err != nil {
-				slog.ErrorContext(ctx, "chat start", "model", req.Model, "is_stream", true, "err", err)
+				slog.ErrorContext(ctx, "chat error", "model", req.Model, "is_stream", true, "err", err)
 				errChan <- err
 				return
 			}
-			sb.WriteString(resp.Completion)
-			dataChan <- resp.ToChatCompletionStreamResponse()
-		case *types.UnknownUnionMember:
-			err = fmt.Errorf("unknown event type: %T", v)
-			slog.ErrorContext(ctx, "chat start", "model", req.Model, "is_stream", true, "err", err)
-			errChan <- err
-			return
+			chunk.ID, chunk.Created, chunk.Model = id, created, req.Model
+			dataChan <- chunk
 		default:
 			err = fmt.Errorf("unknown event type: %T", v)
-			slog.ErrorContext(ctx, "chat start", "model", req.Model, "is_stream", true, "err", err)
+			slog.ErrorContext(ctx, "chat error", "model", req.Model, "is_stream", true, "err", err)
 			errChan <- err
 			return
 		}
 	}
+	if err := stream.Err(); err != nil {
+		errChan <- err
+		return
+	}
 	slog.DebugContext(ctx, "chat success", "model", req.Model, "is_stream", true)
 	errChan <- io.EOF
 }
+
+func newId() string {
+	return "chatcmpl-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
@@ -0,0 +1,118 @@
+package awsbedrock
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+// Codec maps chat requests and responses to the native api of a bedrock model family.
+type Codec interface {
+	// Encode returns the body of invoke requests, req.Stream is set for streams
+	Encode(req llm.ChatCompletionRequest) ([]byte, error)
+	// DecodeResponse maps the body of invoke responses
+	DecodeResponse(body []byte) (llm.ChatCompletionResponse, error)
+	// DecodeChunk maps the bytes of a chunk event of streams
+	DecodeChunk(body []byte) (llm.ChatCompletionStreamResponse, error)
+	// Streaming is false for families which can not stream, their streams are the full response in a single chunk
+	Streaming() bool
+}
+
+// CodecFor returns the codec of the family of model, which is the prefix of the model id.
+func CodecFor(model string) (Codec, error) {
+	family, _, _ := strings.Cut(model, ".")
+	switch family {
+	case "anthropic":
+		return claudeCodec{}, nil
+	case "meta":
+		return llamaCodec{}, nil
+	case "amazon":
+		return titanCodec{}, nil
+	case "cohere":
+		return cohereCodec{}, nil
+	case "ai21":
+		return ai21Codec{}, nil
+	}
+	return nil, fmt.Errorf("unsupported bedrock model %s", model)
+}
+
+// invocationMetrics are appended to the last chunk of streams of all families
+type invocationMetrics struct {
+	Metrics *struct {
+		InputTokenCount  int `json:"inputTokenCount"`
+		OutputTokenCount int `json:"outputTokenCount"`
+	} `json:"amazon-bedrock-invocationMetrics"`
+}
+
+// withMetrics sets the usage of chunk from the invocation metrics in body
+func withMetrics(chunk llm.ChatCompletionStreamResponse, body []byte) llm.ChatCompletionStreamResponse {
+	var m invocationMetrics
+	if err := json.Unmarshal(body, &m); err != nil || m.Metrics == nil {
+		return chunk
+	}
+	chunk.Usage = &llm.Usage{
+		PromptTokens:     m.Metrics.InputTokenCount,
+		CompletionTokens: m.Metrics.OutputTokenCount,
+		TotalTokens:      m.Metrics.InputTokenCount + m.Metrics.OutputTokenCount,
+	}
+	return chunk
+}
+
+// rolePrompt renders messages as a transcript with the prefixes of the roles, ending with the assistant prefix
+func rolePrompt(messages []llm.ChatCompletionMessage, user, assistant string) string {
+	sb := strings.Builder{}
+	for _, m := range messages {
+		switch m.Role {
+		case llm.ChatMessageRoleSystem:
+			sb.WriteString(m.Content)
+		case llm.ChatMessageRoleAssistant:
+			sb.WriteString(assistant + ": " + m.Content)
+		default:
+			sb.WriteString(user + ": " + m.Content)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(assistant + ":")
+	return sb.String()
+}
+
+func newResponse(content string, finishReason llm.FinishReason, usage llm.Usage) llm.ChatCompletionResponse {
+	return llm.ChatCompletionResponse{
+		Object: "chat.completion",
+		Choices: []llm.ChatCompletionChoice{
+			{
+				Message: llm.ChatCompletionMessage{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: content,
+				},
+				FinishReason: finishReason,
+			},
+		},
+		Usage: usage,
+	}
+}
+
+func newChunk(content string, finishReason llm.FinishReason) llm.ChatCompletionStreamResponse {
+	return llm.ChatCompletionStreamResponse{
+		Object: "chat.completion.chunk",
+		Choices: []llm.ChatCompletionStreamChoice{
+			{
+				Delta: llm.ChatCompletionStreamChoiceDelta{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: content,
+				},
+				FinishReason: finishReason,
+			},
+		},
+	}
+}
+
+func usage(prompt, completion int) llm.Usage {
+	return llm.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}
+}
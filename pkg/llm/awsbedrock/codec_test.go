@@ -0,0 +1,114 @@
+package awsbedrock
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var messages = []llm.ChatCompletionMessage{
+	{Role: llm.ChatMessageRoleSystem, Content: "Be brief."},
+	{Role: llm.ChatMessageRoleUser, Content: "Hi"},
+	{Role: llm.ChatMessageRoleAssistant, Content: "Hello"},
+	{Role: llm.ChatMessageRoleUser, Content: "How are you?"},
+}
+
+func TestCodecFor(t *testing.T) {
+	for model, expected := range map[string]Codec{
+		"anthropic.claude-v2":          claudeCodec{},
+		"meta.llama2-70b-chat-v1":      llamaCodec{},
+		"amazon.titan-text-express-v1": titanCodec{},
+		"cohere.command-text-v14":      cohereCodec{},
+		"ai21.j2-ultra-v1":             ai21Codec{},
+	} {
+		codec, err := CodecFor(model)
+		require.NoError(t, err)
+		assert.Equal(t, expected, codec, model)
+	}
+	_, err := CodecFor("stability.stable-diffusion-xl-v1")
+	assert.Error(t, err)
+}
+
+func TestEncode(t *testing.T) {
+	req := llm.ChatCompletionRequest{Messages: messages, MaxTokens: 100, Temperature: 0.5, Stream: true}
+	for _, tc := range []struct {
+		codec    Codec
+		expected string
+	}{
+		{llamaCodec{}, `{"prompt":"<s>[INST] <<SYS>>\nBe brief.\n<</SYS>>\n\nHi [/INST] Hello </s><s>[INST] How are you? [/INST]","max_gen_len":100,"temperature":0.5}`},
+		{titanCodec{}, `{"inputText":"Be brief.\nUser: Hi\nBot: Hello\nUser: How are you?\nBot:","textGenerationConfig":{"maxTokenCount":100,"temperature":0.5}}`},
+		{cohereCodec{}, `{"prompt":"Be brief.\nUser: Hi\nChatbot: Hello\nUser: How are you?\nChatbot:","max_tokens":100,"temperature":0.5,"stream":true}`},
+		{ai21Codec{}, `{"prompt":"Be brief.\nUser: Hi\nAssistant: Hello\nUser: How are you?\nAssistant:","maxTokens":100,"temperature":0.5}`},
+	} {
+		body, err := tc.codec.Encode(req)
+		require.NoError(t, err)
+		assert.JSONEq(t, tc.expected, string(body), "%T", tc.codec)
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	for _, tc := range []struct {
+		codec  Codec
+		body   string
+		reason llm.FinishReason
+		usage  llm.Usage
+	}{
+		{claudeCodec{}, `{"completion":" Fine.","stop_reason":"stop_sequence","stop":"\n\nHuman:"}`, llm.FinishReasonStop, llm.Usage{}},
+		{llamaCodec{}, `{"generation":" Fine.","prompt_token_count":38,"generation_token_count":3,"stop_reason":"stop"}`, llm.FinishReasonStop, usage(38, 3)},
+		{titanCodec{}, `{"inputTextTokenCount":21,"results":[{"tokenCount":2,"outputText":" Fine.","completionReason":"FINISH"}]}`, llm.FinishReasonStop, usage(21, 2)},
+		{cohereCodec{}, `{"id":"1","generations":[{"id":"2","text":" Fine.","finish_reason":"MAX_TOKENS"}],"prompt":"Hi"}`, llm.FinishReasonLength, llm.Usage{}},
+		{ai21Codec{}, `{"id":1234,"prompt":{"text":"Hi","tokens":[{},{}]},"completions":[{"data":{"text":" Fine.","tokens":[{},{}]},"finishReason":{"reason":"endoftext"}}]}`, llm.FinishReasonStop, usage(2, 2)},
+	} {
+		resp, err := tc.codec.DecodeResponse([]byte(tc.body))
+		require.NoError(t, err, "%T", tc.codec)
+		assert.Equal(t, " Fine.", resp.Choices[0].Message.Content, "%T", tc.codec)
+		assert.Equal(t, tc.reason, resp.Choices[0].FinishReason, "%T", tc.codec)
+		assert.Equal(t, tc.usage, resp.Usage, "%T", tc.codec)
+	}
+}
+
+func TestDecodeChunk(t *testing.T) {
+	metrics := `"amazon-bedrock-invocationMetrics":{"inputTokenCount":21,"outputTokenCount":7,"invocationLatency":812,"firstByteLatency":301}`
+	for _, tc := range []struct {
+		codec  Codec
+		chunks []string
+	}{
+		{llamaCodec{}, []string{
+			`{"generation":" Fine","prompt_token_count":21,"generation_token_count":1,"stop_reason":null}`,
+			`{"generation":".","prompt_token_count":null,"generation_token_count":2,"stop_reason":"stop",` + metrics + `}`,
+		}},
+		{titanCodec{}, []string{
+			`{"outputText":" Fine","index":0,"totalOutputTextTokenCount":1,"completionReason":null,"inputTextTokenCount":21}`,
+			`{"outputText":".","index":0,"totalOutputTextTokenCount":2,"completionReason":"FINISH",` + metrics + `}`,
+		}},
+		{cohereCodec{}, []string{
+			`{"text":" Fine","is_finished":false,"index":0}`,
+			`{"text":".","is_finished":true,"finish_reason":"COMPLETE",` + metrics + `}`,
+		}},
+	} {
+		var acc llm.StreamAccumulator
+		for _, chunk := range tc.chunks {
+			data, err := tc.codec.DecodeChunk([]byte(chunk))
+			require.NoError(t, err, "%T", tc.codec)
+			acc.Add(data)
+		}
+		resp := acc.Response()
+		assert.Equal(t, " Fine.", resp.Choices[0].Message.Content, "%T", tc.codec)
+		assert.Equal(t, llm.FinishReasonStop, resp.Choices[0].FinishReason, "%T", tc.codec)
+		assert.Equal(t, usage(21, 7), resp.Usage, "%T", tc.codec)
+	}
+}
+
+func TestAI21Chunk(t *testing.T) {
+	assert.False(t, ai21Codec{}.Streaming())
+	body, _ := json.Marshal(map[string]any{
+		"completions": []any{map[string]any{"data": map[string]any{"text": "Fine."}, "finishReason": map[string]any{"reason": "length"}}},
+	})
+	chunk, err := ai21Codec{}.DecodeChunk(body)
+	require.NoError(t, err)
+	assert.Equal(t, "Fine.", chunk.Choices[0].Delta.Content)
+	assert.Equal(t, llm.FinishReasonLength, chunk.Choices[0].FinishReason)
+}
@@ -0,0 +1,92 @@
+package awsbedrock
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+// CohereRequest is the request of cohere command models, messages are rendered to a `User:` and `Chatbot:` transcript
+type CohereRequest struct {
+	Prompt        string   `json:"prompt"`
+	MaxTokens     int      `json:"max_tokens,omitempty"`
+	Temperature   float32  `json:"temperature,omitempty"`
+	P             float32  `json:"p,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+	Stream        bool     `json:"stream,omitempty"`
+}
+
+type CohereGeneration struct {
+	Id           string `json:"id"`
+	Text         string `json:"text"`
+	FinishReason string `json:"finish_reason"`
+	IsFinished   bool   `json:"is_finished"`
+	Index        int    `json:"index"`
+}
+
+// CohereResponse is the response of cohere command models, chunks of streams are a single generation
+type CohereResponse struct {
+	Id          string             `json:"id"`
+	Generations []CohereGeneration `json:"generations"`
+}
+
+func cohereFinishReason(reason string) llm.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "MAX_TOKENS":
+		return llm.FinishReasonLength
+	case "ERROR_TOXIC":
+		return llm.FinishReasonContentFilter
+	default:
+		return llm.FinishReasonStop
+	}
+}
+
+type cohereCodec struct{}
+
+func (cohereCodec) Encode(req llm.ChatCompletionRequest) ([]byte, error) {
+	return json.Marshal(CohereRequest{
+		Prompt:        rolePrompt(req.Messages, "User", "Chatbot"),
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		P:             req.TopP,
+		StopSequences: req.Stop,
+		Stream:        req.Stream,
+	})
+}
+
+func (cohereCodec) DecodeResponse(body []byte) (llm.ChatCompletionResponse, error) {
+	var r CohereResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("decode cohere response error: %w", err)
+	}
+	if len(r.Generations) == 0 {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("cohere response has no generations")
+	}
+	generation := r.Generations[0]
+	return newResponse(generation.Text, cohereFinishReason(generation.FinishReason), llm.Usage{}), nil
+}
+
+// cohereChunk is a generation, or a response with a generation in older versions of the api
+type cohereChunk struct {
+	CohereGeneration
+	Generations []CohereGeneration `json:"generations"`
+}
+
+func (cohereCodec) DecodeChunk(body []byte) (llm.ChatCompletionStreamResponse, error) {
+	var c cohereChunk
+	if err := json.Unmarshal(body, &c); err != nil {
+		return llm.ChatCompletionStreamResponse{}, fmt.Errorf("decode cohere chunk error: %w", err)
+	}
+	g := c.CohereGeneration
+	if len(c.Generations) > 0 {
+		g = c.Generations[0]
+	}
+	return withMetrics(newChunk(g.Text, cohereFinishReason(g.FinishReason)), body), nil
+}
+
+func (cohereCodec) Streaming() bool {
+	return true
+}
@@ -0,0 +1,50 @@
+package awsbedrock
+
+import (
+	"context"
+	"fmt"
+
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// LoadConfig loads the aws config of cfg, static keys take precedence over the default credential chain,
+// which covers environment, shared profile, web identity (IRSA) and EC2 or ECS metadata.
+// With RoleArn the loaded credentials assume the role, the temporary credentials are refreshed before they expire.
+func LoadConfig(ctx context.Context, cfg llmconfig.AWSBedrockConfig) (aws.Config, error) {
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(cfg.Region)}
+	if cfg.Profile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(cfg.Profile))
+	}
+	if cfg.AccessKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			cfg.AccessKey,
+			cfg.SecretKey,
+			cfg.SessionToken,
+		)))
+	}
+	awsConfig, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("get aws config error: %w", err)
+	}
+	if cfg.RoleArn != "" {
+		awsConfig.Credentials = assumeRole(awsConfig, cfg)
+	}
+	return awsConfig, nil
+}
+
+func assumeRole(awsConfig aws.Config, cfg llmconfig.AWSBedrockConfig, optFns ...func(*sts.Options)) aws.CredentialsProvider {
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsConfig, optFns...), cfg.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+		if cfg.ExternalId != "" {
+			o.ExternalID = aws.String(cfg.ExternalId)
+		}
+		if cfg.RoleSessionName != "" {
+			o.RoleSessionName = cfg.RoleSessionName
+		}
+	})
+	return aws.NewCredentialsCache(provider)
+}
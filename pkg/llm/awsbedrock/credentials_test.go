@@ -0,0 +1,75 @@
+package awsbedrock
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigStaticKeys(t *testing.T) {
+	awsConfig, err := LoadConfig(context.Background(), llmconfig.AWSBedrockConfig{
+		AccessKey: "ak", SecretKey: "sk", SessionToken: "token", Region: "us-west-2",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", awsConfig.Region)
+
+	creds, err := awsConfig.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ak", creds.AccessKeyID)
+	assert.Equal(t, "token", creds.SessionToken)
+}
+
+func TestAssumeRole(t *testing.T) {
+	var calls int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "AssumeRole", r.Form.Get("Action"))
+		assert.Equal(t, "arn:aws:iam::123456789012:role/bedrock", r.Form.Get("RoleArn"))
+		assert.Equal(t, "external", r.Form.Get("ExternalId"))
+		assert.Equal(t, "aienvoy", r.Form.Get("RoleSessionName"))
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIAASSUMED</AccessKeyId>
+      <SecretAccessKey>assumed-secret</SecretAccessKey>
+      <SessionToken>assumed-token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::123456789012:assumed-role/bedrock/aienvoy</Arn>
+      <AssumedRoleId>AROA:aienvoy</AssumedRoleId>
+    </AssumedRoleUser>
+  </AssumeRoleResult>
+  <ResponseMetadata><RequestId>1</RequestId></ResponseMetadata>
+</AssumeRoleResponse>`))
+	}))
+	defer s.Close()
+
+	base := aws.Config{Region: "us-east-1", Credentials: credentials.NewStaticCredentialsProvider("ak", "sk", "")}
+	provider := assumeRole(base, llmconfig.AWSBedrockConfig{
+		RoleArn:         "arn:aws:iam::123456789012:role/bedrock",
+		ExternalId:      "external",
+		RoleSessionName: "aienvoy",
+	}, func(o *sts.Options) {
+		o.BaseEndpoint = aws.String(s.URL)
+	})
+
+	for i := 0; i < 2; i++ {
+		creds, err := provider.Retrieve(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "ASIAASSUMED", creds.AccessKeyID)
+		assert.Equal(t, "assumed-token", creds.SessionToken)
+	}
+	// the credentials are cached until they expire
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,81 @@
+package awsbedrock
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/llm/chattemplate"
+)
+
+var llama2Template = mustParse(chattemplate.Llama2)
+
+func mustParse(src string) *chattemplate.Template {
+	t, err := chattemplate.Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// LlamaRequest is the request of meta llama 2 chat models, messages are rendered to `[INST]` prompts
+type LlamaRequest struct {
+	Prompt      string  `json:"prompt"`
+	MaxGenLen   int     `json:"max_gen_len,omitempty"`
+	Temperature float32 `json:"temperature,omitempty"`
+	TopP        float32 `json:"top_p,omitempty"`
+}
+
+// LlamaResponse is the response and the chunk of streams of meta llama 2 chat models
+type LlamaResponse struct {
+	Generation           string `json:"generation"`
+	PromptTokenCount     int    `json:"prompt_token_count"`
+	GenerationTokenCount int    `json:"generation_token_count"`
+	StopReason           string `json:"stop_reason"`
+}
+
+func (r LlamaResponse) finishReason() llm.FinishReason {
+	switch r.StopReason {
+	case "":
+		return ""
+	case "length":
+		return llm.FinishReasonLength
+	default:
+		return llm.FinishReasonStop
+	}
+}
+
+type llamaCodec struct{}
+
+func (llamaCodec) Encode(req llm.ChatCompletionRequest) ([]byte, error) {
+	prompt, err := llama2Template.Render(req.Messages, chattemplate.Options{BosToken: "<s>", EosToken: "</s>"})
+	if err != nil {
+		return nil, fmt.Errorf("render llama prompt error: %w", err)
+	}
+	return json.Marshal(LlamaRequest{
+		Prompt:      prompt,
+		MaxGenLen:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+	})
+}
+
+func (llamaCodec) DecodeResponse(body []byte) (llm.ChatCompletionResponse, error) {
+	var r LlamaResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("decode llama response error: %w", err)
+	}
+	return newResponse(r.Generation, r.finishReason(), usage(r.PromptTokenCount, r.GenerationTokenCount)), nil
+}
+
+func (llamaCodec) DecodeChunk(body []byte) (llm.ChatCompletionStreamResponse, error) {
+	var r LlamaResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return llm.ChatCompletionStreamResponse{}, fmt.Errorf("decode llama chunk error: %w", err)
+	}
+	return withMetrics(newChunk(r.Generation, r.finishReason()), body), nil
+}
+
+func (llamaCodec) Streaming() bool {
+	return true
+}
@@ -0,0 +1,92 @@
+package awsbedrock
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+type TitanTextGenerationConfig struct {
+	MaxTokenCount int      `json:"maxTokenCount,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+	Temperature   float32  `json:"temperature,omitempty"`
+	TopP          float32  `json:"topP,omitempty"`
+}
+
+// TitanRequest is the request of amazon titan text models, messages are rendered to a `User:` and `Bot:` transcript
+type TitanRequest struct {
+	InputText            string                    `json:"inputText"`
+	TextGenerationConfig TitanTextGenerationConfig `json:"textGenerationConfig"`
+}
+
+type TitanResult struct {
+	TokenCount       int    `json:"tokenCount"`
+	OutputText       string `json:"outputText"`
+	CompletionReason string `json:"completionReason"`
+}
+
+type TitanResponse struct {
+	InputTextTokenCount int           `json:"inputTextTokenCount"`
+	Results             []TitanResult `json:"results"`
+}
+
+// TitanChunk is a chunk of streams of amazon titan text models
+type TitanChunk struct {
+	OutputText                string `json:"outputText"`
+	Index                     int    `json:"index"`
+	TotalOutputTextTokenCount int    `json:"totalOutputTextTokenCount"`
+	CompletionReason          string `json:"completionReason"`
+	InputTextTokenCount       int    `json:"inputTextTokenCount"`
+}
+
+func titanFinishReason(reason string) llm.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "LENGTH":
+		return llm.FinishReasonLength
+	case "CONTENT_FILTERED":
+		return llm.FinishReasonContentFilter
+	default:
+		return llm.FinishReasonStop
+	}
+}
+
+type titanCodec struct{}
+
+func (titanCodec) Encode(req llm.ChatCompletionRequest) ([]byte, error) {
+	return json.Marshal(TitanRequest{
+		InputText: rolePrompt(req.Messages, "User", "Bot"),
+		TextGenerationConfig: TitanTextGenerationConfig{
+			MaxTokenCount: req.MaxTokens,
+			StopSequences: req.Stop,
+			Temperature:   req.Temperature,
+			TopP:          req.TopP,
+		},
+	})
+}
+
+func (titanCodec) DecodeResponse(body []byte) (llm.ChatCompletionResponse, error) {
+	var r TitanResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("decode titan response error: %w", err)
+	}
+	if len(r.Results) == 0 {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("titan response has no results")
+	}
+	result := r.Results[0]
+	return newResponse(result.OutputText, titanFinishReason(result.CompletionReason), usage(r.InputTextTokenCount, result.TokenCount)), nil
+}
+
+func (titanCodec) DecodeChunk(body []byte) (llm.ChatCompletionStreamResponse, error) {
+	var c TitanChunk
+	if err := json.Unmarshal(body, &c); err != nil {
+		return llm.ChatCompletionStreamResponse{}, fmt.Errorf("decode titan chunk error: %w", err)
+	}
+	return withMetrics(newChunk(c.OutputText, titanFinishReason(c.CompletionReason)), body), nil
+}
+
+func (titanCodec) Streaming() bool {
+	return true
+}
@@ -0,0 +1,204 @@
+package azureopenai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+type Client struct {
+	session *http.Client
+	config  llmconfig.Config
+	azure   llmconfig.AzureOpenAIConfig
+	// tokens is nil when api keys are used
+	tokens *tokenSource
+
+	mu sync.Mutex
+	// next is the deployment index of models which the next call starts with
+	next map[string]int
+}
+
+func NewClient(cfg llmconfig.Config) (*Client, error) {
+	if cfg.LLMType != llmconfig.LLMTypeAzureOpenAI {
+		return nil, fmt.Errorf("invalid config for azure openai, llmtype: %s", cfg.LLMType)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		session: http.DefaultClient,
+		config:  cfg,
+		azure:   cfg.AzureOpenAI,
+		next:    make(map[string]int),
+	}
+	if client.azure.Version == "" {
+		client.azure.Version = llmconfig.DefaultAzureOpenAIVersion
+	}
+	if client.azure.EntraId.Enabled() {
+		client.tokens = newTokenSource(client.session, client.azure.EntraId)
+	}
+	return client, nil
+}
+
+func (c *Client) WithSession(session *http.Client) *Client {
+	c.session = session
+	if c.tokens != nil {
+		c.tokens.session = session
+	}
+	return c
+}
+
+func (c *Client) ListModels() []string {
+	if len(c.config.Models) > 0 {
+		return c.config.Models
+	}
+	return c.azure.ListModels()
+}
+
+func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	req.Stream = false
+	resp, err := c.do(ctx, req.Model, "/chat/completions", req)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var chatResp ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion decode response error: %w", err)
+	}
+	return chatResp.ToChatCompletionResponse(), nil
+}
+
+// CreateChatCompletionStream relays chunks with choices, the prompt filter results of the first chunk
+// are sent with the next chunk.
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
+	req.Stream = true
+	resp, err := c.do(ctx, req.Model, "/chat/completions", req)
+	if err != nil {
+		errChan <- fmt.Errorf("create chat completion stream error: %w", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	innerDataChan := make(chan ChatStreamResponse)
+	innerErrChan := make(chan error)
+	go llm.ParseSSE(resp.Body, innerDataChan, innerErrChan)
+
+	var promptAnnotations []llm.PromptAnnotation
+	for {
+		select {
+		case data := <-innerDataChan:
+			chunk := data.ToChatCompletionStreamResponse()
+			if len(chunk.Choices) == 0 {
+				promptAnnotations = append(promptAnnotations, chunk.PromptAnnotations...)
+				continue
+			}
+			if len(chunk.PromptAnnotations) == 0 && promptAnnotations != nil {
+				chunk.PromptAnnotations = promptAnnotations
+				promptAnnotations = nil
+			}
+			dataChan <- chunk
+		case err := <-innerErrChan:
+			errChan <- err
+			return
+		}
+	}
+}
+
+func (c *Client) CreateEmbeddings(ctx context.Context, req llm.EmbeddingRequest) (llm.EmbeddingResponse, error) {
+	resp, err := c.do(ctx, req.Model, "/embeddings", req)
+	if err != nil {
+		return llm.EmbeddingResponse{}, fmt.Errorf("create embeddings error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var embeddingResp llm.EmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
+		return llm.EmbeddingResponse{}, fmt.Errorf("create embeddings decode response error: %w", err)
+	}
+	return embeddingResp, nil
+}
+
+// do posts body to the deployments of model in turns, a call failing with a retryable status code
+// or a network error is sent to the next deployment.
+func (c *Client) do(ctx context.Context, model, path string, body any) (*http.Response, error) {
+	deployments := c.azure.GetDeployments(model)
+	if len(deployments) == 0 {
+		return nil, fmt.Errorf("no azure openai deployment for model %s", model)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request error: %w", err)
+	}
+
+	start := c.nextDeployment(model, len(deployments))
+	var lastErr error
+	for i := range deployments {
+		d := deployments[(start+i)%len(deployments)]
+		resp, err := c.post(ctx, d, path, data)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || !failover(err) {
+			return nil, err
+		}
+		slog.WarnContext(ctx, "azure openai deployment failed", "err", err, "model", model, "deployment", d.Deployment, "endpoint", d.Endpoint)
+	}
+	return nil, lastErr
+}
+
+// nextDeployment balances the calls of model over its deployments
+func (c *Client) nextDeployment(model string, count int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.next[model] % count
+	c.next[model] = i + 1
+	return i
+}
+
+func failover(err error) bool {
+	code, ok := llm.StatusCode(err)
+	if !ok {
+		return true
+	}
+	return slices.Contains(llm.DefaultRetryableStatusCodes, code)
+}
+
+func (c *Client) post(ctx context.Context, d llmconfig.AzureOpenAIDeployment, path string, data []byte) (*http.Response, error) {
+	url := fmt.Sprintf("%s/openai/deployments/%s%s?api-version=%s", d.Endpoint, d.Deployment, path, c.azure.Version)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create request error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.Header.Set("api-key", d.ApiKey)
+	}
+
+	resp, err := c.session.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, llm.NewHTTPError(resp)
+	}
+	return resp, nil
+}
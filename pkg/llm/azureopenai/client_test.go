@@ -0,0 +1,229 @@
+package azureopenai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chatResponse = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"created": 1700000000,
+	"model": "gpt-35-turbo",
+	"prompt_filter_results": [{"prompt_index": 0, "content_filter_results": {"hate": {"filtered": false, "severity": "safe"}}}],
+	"choices": [{
+		"index": 0,
+		"finish_reason": "stop",
+		"message": {"role": "assistant", "content": "hello"},
+		"content_filter_results": {"violence": {"filtered": false, "severity": "low"}}
+	}],
+	"usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4}
+}`
+
+func newTestClient(t *testing.T, azure llmconfig.AzureOpenAIConfig) *Client {
+	client, err := NewClient(llmconfig.Config{LLMType: llmconfig.LLMTypeAzureOpenAI, AzureOpenAI: azure})
+	require.NoError(t, err)
+	return client
+}
+
+func TestCreateChatCompletionDeploymentMapping(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/openai/deployments/chat-35/chat/completions", r.URL.Path)
+		assert.Equal(t, llmconfig.DefaultAzureOpenAIVersion, r.URL.Query().Get("api-version"))
+		assert.Equal(t, "key", r.Header.Get("api-key"))
+		_, _ = w.Write([]byte(chatResponse))
+	}))
+	defer s.Close()
+
+	client := newTestClient(t, llmconfig.AzureOpenAIConfig{
+		ApiKey:      "key",
+		Deployments: []llmconfig.AzureOpenAIDeployment{{Model: "gpt-3.5-turbo", Deployment: "chat-35", Endpoint: s.URL}},
+	})
+	assert.Equal(t, []string{"gpt-3.5-turbo"}, client.ListModels())
+
+	resp, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "gpt-3.5-turbo"})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, 4, resp.Usage.TotalTokens)
+	require.Len(t, resp.PromptAnnotations, 1)
+	assert.Equal(t, "safe", resp.PromptAnnotations[0].ContentFilterResults.Hate.Severity)
+	require.NotNil(t, resp.Choices[0].ContentFilterResults)
+	assert.Equal(t, "low", resp.Choices[0].ContentFilterResults.Violence.Severity)
+}
+
+func TestCreateChatCompletionFailover(t *testing.T) {
+	var calls []string
+	newServer := func(name string, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, name)
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(`{"error": {"code": "429", "message": "rate limited"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(chatResponse))
+		}))
+	}
+	east := newServer("east", http.StatusTooManyRequests)
+	defer east.Close()
+	west := newServer("west", http.StatusOK)
+	defer west.Close()
+
+	client := newTestClient(t, llmconfig.AzureOpenAIConfig{
+		ApiKey: "key",
+		Deployments: []llmconfig.AzureOpenAIDeployment{
+			{Model: "gpt-4", Deployment: "gpt-4", Endpoint: east.URL},
+			{Model: "gpt-4", Deployment: "gpt-4", Endpoint: west.URL},
+		},
+	})
+
+	_, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "gpt-4"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"east", "west"}, calls)
+
+	// the next call starts with the next deployment
+	_, err = client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "gpt-4"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"east", "west", "west"}, calls)
+
+	_, err = client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "gpt-3.5-turbo"})
+	assert.ErrorContains(t, err, "no azure openai deployment")
+}
+
+func TestCreateChatCompletionStream(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"id":"","object":"","created":0,"model":"","prompt_filter_results":[{"prompt_index":0,"content_filter_results":{"sexual":{"filtered":false,"severity":"safe"}}}],"choices":[]}`,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4","choices":[{"index":0,"delta":{"role":"assistant","content":"hel"},"content_filter_results":{}}]}`,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop","content_filter_results":{"hate":{"filtered":false,"severity":"safe"}}}]}`,
+		}
+		for _, chunk := range chunks {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer s.Close()
+
+	client := newTestClient(t, llmconfig.AzureOpenAIConfig{
+		ApiKey:      "key",
+		Deployments: []llmconfig.AzureOpenAIDeployment{{Model: "gpt-4", Deployment: "gpt-4", Endpoint: s.URL}},
+	})
+	resp, err := llm.CompleteStream(context.Background(), llm.ChatCompletionRequest{Model: "gpt-4"}, client.CreateChatCompletionStream)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+	require.Len(t, resp.PromptAnnotations, 1)
+	assert.Equal(t, "safe", resp.PromptAnnotations[0].ContentFilterResults.Sexual.Severity)
+	require.NotNil(t, resp.Choices[0].ContentFilterResults)
+	assert.Equal(t, "safe", resp.Choices[0].ContentFilterResults.Hate.Severity)
+}
+
+func TestEntraIdClientCredentials(t *testing.T) {
+	var tokens int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tenant/oauth2/v2.0/token" {
+			tokens++
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+			assert.Equal(t, "client", r.Form.Get("client_id"))
+			assert.Equal(t, "secret", r.Form.Get("client_secret"))
+			assert.Equal(t, "https://cognitiveservices.azure.com/.default", r.Form.Get("scope"))
+			_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": 3600}`, tokens)
+			return
+		}
+		assert.Empty(t, r.Header.Get("api-key"))
+		assert.Equal(t, fmt.Sprintf("Bearer token-%d", tokens), r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(chatResponse))
+	}))
+	defer s.Close()
+
+	client := newTestClient(t, llmconfig.AzureOpenAIConfig{
+		Deployments: []llmconfig.AzureOpenAIDeployment{{Model: "gpt-4", Deployment: "gpt-4", Endpoint: s.URL}},
+		EntraId:     llmconfig.AzureEntraIdConfig{TenantId: "tenant", ClientId: "client", ClientSecret: "secret", AuthorityHost: s.URL},
+	})
+	req := llm.ChatCompletionRequest{Model: "gpt-4"}
+	for i := 0; i < 2; i++ {
+		_, err := client.CreateChatCompletion(context.Background(), req)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, tokens)
+
+	// tokens expiring soon are refreshed
+	client.tokens.expiresAt = time.Now().Add(time.Minute)
+	_, err := client.CreateChatCompletion(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, 2, tokens)
+}
+
+func TestEntraIdManagedIdentity(t *testing.T) {
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.Header.Get("Metadata"))
+		assert.Equal(t, "https://cognitiveservices.azure.com", r.URL.Query().Get("resource"))
+		assert.Equal(t, "identity", r.URL.Query().Get("client_id"))
+		_, _ = w.Write([]byte(`{"access_token": "msi", "expires_in": "86399"}`))
+	}))
+	defer imds.Close()
+	defer func(u string) { imdsTokenUrl = u }(imdsTokenUrl)
+	imdsTokenUrl = imds.URL
+
+	tokens := newTokenSource(http.DefaultClient, llmconfig.AzureEntraIdConfig{ManagedIdentity: true, ClientId: "identity"})
+	token, err := tokens.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "msi", token)
+	assert.WithinDuration(t, time.Now().Add(86399*time.Second), tokens.expiresAt, time.Minute)
+}
+
+func TestNewClientValidate(t *testing.T) {
+	_, err := NewClient(llmconfig.Config{LLMType: llmconfig.LLMTypeAzureOpenAI, AzureOpenAI: llmconfig.AzureOpenAIConfig{
+		Deployments: []llmconfig.AzureOpenAIDeployment{{Model: "gpt-4", Deployment: "gpt-4", ResourceName: "east"}},
+	}})
+	assert.ErrorContains(t, err, "azure_openai.deployments[0].api_key is required")
+}
+
+// rewriteTransport sends the requests of azure resources to a test server
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Original-Host", req.URL.Host)
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCreateChatCompletionModelAsDeployment(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "res.openai.azure.com", r.Header.Get("X-Original-Host"))
+		assert.Equal(t, "/openai/deployments/gpt-4/chat/completions", r.URL.Path)
+		assert.Equal(t, "key", r.Header.Get("api-key"))
+		_, _ = w.Write([]byte(chatResponse))
+	}))
+	defer s.Close()
+
+	client, err := NewClient(llmconfig.Config{
+		LLMType:     llmconfig.LLMTypeAzureOpenAI,
+		Models:      []string{"gpt-4"},
+		AzureOpenAI: llmconfig.AzureOpenAIConfig{ApiKey: "key", ResourceName: "res"},
+	})
+	require.NoError(t, err)
+	target, err := url.Parse(s.URL)
+	require.NoError(t, err)
+	client.WithSession(&http.Client{Transport: rewriteTransport{target: target}})
+	assert.Equal(t, []string{"gpt-4"}, client.ListModels())
+
+	resp, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "gpt-4"})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+}
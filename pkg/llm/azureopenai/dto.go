@@ -0,0 +1,34 @@
+package azureopenai
+
+import (
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+// ChatResponse is the chat response of azure openai, api versions since 2023-06-01 name the prompt annotations
+// prompt_filter_results.
+type ChatResponse struct {
+	llm.ChatCompletionResponse
+	PromptFilterResults []llm.PromptAnnotation `json:"prompt_filter_results,omitempty"`
+}
+
+func (r ChatResponse) ToChatCompletionResponse() llm.ChatCompletionResponse {
+	resp := r.ChatCompletionResponse
+	if len(resp.PromptAnnotations) == 0 {
+		resp.PromptAnnotations = r.PromptFilterResults
+	}
+	return resp
+}
+
+// ChatStreamResponse is a chunk of streams, the first chunk only has the prompt filter results.
+type ChatStreamResponse struct {
+	llm.ChatCompletionStreamResponse
+	PromptFilterResults []llm.PromptAnnotation `json:"prompt_filter_results,omitempty"`
+}
+
+func (r ChatStreamResponse) ToChatCompletionStreamResponse() llm.ChatCompletionStreamResponse {
+	resp := r.ChatCompletionStreamResponse
+	if len(resp.PromptAnnotations) == 0 {
+		resp.PromptAnnotations = r.PromptFilterResults
+	}
+	return resp
+}
@@ -0,0 +1,20 @@
+package azureopenai
+
+import (
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+type AzureOpenAI struct {
+	*llm.LLM
+}
+
+func New(cfg llmconfig.Config, dao llm.Dao) (*AzureOpenAI, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &AzureOpenAI{
+		llm.New(dao, client),
+	}, nil
+}
@@ -0,0 +1,120 @@
+package azureopenai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+const (
+	defaultAuthorityHost = "https://login.microsoftonline.com"
+	cognitiveServices    = "https://cognitiveservices.azure.com"
+	// tokenRefreshBefore refreshes tokens before they expire, so that no call is sent with an expired token
+	tokenRefreshBefore = 5 * time.Minute
+)
+
+// imdsTokenUrl is the token endpoint of managed identities, it is a var for tests
+var imdsTokenUrl = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// tokenResponse is the token of client credentials and managed identities,
+// managed identities return expires_in as a string.
+type tokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+}
+
+func (r tokenResponse) expiresIn() time.Duration {
+	seconds, err := strconv.Atoi(strings.Trim(string(r.ExpiresIn), `"`))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// tokenSource caches the token of Microsoft Entra ID and refreshes it before it expires
+type tokenSource struct {
+	session *http.Client
+	config  llmconfig.AzureEntraIdConfig
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func newTokenSource(session *http.Client, cfg llmconfig.AzureEntraIdConfig) *tokenSource {
+	return &tokenSource{session: session, config: cfg}
+}
+
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Until(s.expiresAt) > tokenRefreshBefore {
+		return s.token, nil
+	}
+
+	req, err := s.newRequest(ctx)
+	if err != nil {
+		return "", fmt.Errorf("create entra id token request error: %w", err)
+	}
+	resp, err := s.session.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("get entra id token error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get entra id token error: %w", llm.NewHTTPError(resp))
+	}
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decode entra id token error: %w", err)
+	}
+	s.token = token.AccessToken
+	s.expiresAt = time.Now().Add(token.expiresIn())
+	return s.token, nil
+}
+
+// newRequest requests a token by client credentials with a client secret, or else of the managed identity
+func (s *tokenSource) newRequest(ctx context.Context) (*http.Request, error) {
+	if s.config.ClientSecret != "" {
+		host := s.config.AuthorityHost
+		if host == "" {
+			host = defaultAuthorityHost
+		}
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {s.config.ClientId},
+			"client_secret": {s.config.ClientSecret},
+			"scope":         {cognitiveServices + "/.default"},
+		}
+		tokenUrl := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(host, "/"), s.config.TenantId)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenUrl, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	}
+
+	query := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {cognitiveServices},
+	}
+	if s.config.ClientId != "" {
+		query.Set("client_id", s.config.ClientId)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imdsTokenUrl+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	return req, nil
+}
@@ -0,0 +1,769 @@
+package chattemplate
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errRaised is returned by raise_exception in a template
+type errRaised struct{ msg string }
+
+func (e errRaised) Error() string {
+	return "template raised exception: " + e.msg
+}
+
+type scope struct {
+	vars   map[string]any
+	parent *scope
+}
+
+func (s *scope) get(key string) (any, bool) {
+	for sc := s; sc != nil; sc = sc.parent {
+		if v, ok := sc.vars[key]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// set assigns to the nearest scope defining key, or the current scope
+func (s *scope) set(key string, value any) {
+	for sc := s; sc != nil; sc = sc.parent {
+		if _, ok := sc.vars[key]; ok {
+			sc.vars[key] = value
+			return
+		}
+	}
+	s.vars[key] = value
+}
+
+// undefined is the value of a missing variable or attribute
+type undefined struct{}
+
+func render(sb *strings.Builder, nodes []node, sc *scope) error {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case textNode:
+			sb.WriteString(n.text)
+		case outputNode:
+			v, err := eval(n.expr, sc)
+			if err != nil {
+				return err
+			}
+			sb.WriteString(toString(v))
+		case setNode:
+			v, err := eval(n.expr, sc)
+			if err != nil {
+				return err
+			}
+			sc.set(n.name, v)
+		case ifNode:
+			done := false
+			for _, branch := range n.branches {
+				v, err := eval(branch.cond, sc)
+				if err != nil {
+					return err
+				}
+				if truthy(v) {
+					if err := render(sb, branch.body, sc); err != nil {
+						return err
+					}
+					done = true
+					break
+				}
+			}
+			if !done {
+				if err := render(sb, n.elseBody, sc); err != nil {
+					return err
+				}
+			}
+		case forNode:
+			if err := renderFor(sb, n, sc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func renderFor(sb *strings.Builder, n forNode, sc *scope) error {
+	v, err := eval(n.iter, sc)
+	if err != nil {
+		return err
+	}
+	items, err := iterate(v)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return render(sb, n.elseBody, sc)
+	}
+	for i, item := range items {
+		inner := &scope{vars: map[string]any{
+			"loop": map[string]any{
+				"index":     i + 1,
+				"index0":    i,
+				"first":     i == 0,
+				"last":      i == len(items)-1,
+				"length":    len(items),
+				"revindex":  len(items) - i,
+				"revindex0": len(items) - i - 1,
+			},
+		}, parent: sc}
+		if len(n.vars) == 1 {
+			inner.vars[n.vars[0]] = item
+		} else {
+			values, err := iterate(item)
+			if err != nil || len(values) != len(n.vars) {
+				return fmt.Errorf("can not unpack %v into %d variables", item, len(n.vars))
+			}
+			for j, name := range n.vars {
+				inner.vars[name] = values[j]
+			}
+		}
+		if err := render(sb, n.body, inner); err != nil {
+			return err
+		}
+		// like jinja, assignments in the loop body do not leak outside, except to existing outer variables
+		for key, value := range inner.vars {
+			if key == "loop" || contains(n.vars, key) {
+				continue
+			}
+			if _, ok := sc.get(key); ok {
+				sc.set(key, value)
+			}
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func eval(e expr, sc *scope) (any, error) {
+	switch e := e.(type) {
+	case literal:
+		return e.value, nil
+	case name:
+		if v, ok := sc.get(e.name); ok {
+			return v, nil
+		}
+		switch e.name {
+		case "raise_exception", "range":
+			return builtin(e.name), nil
+		}
+		return undefined{}, nil
+	case listExpr:
+		items := make([]any, 0, len(e.items))
+		for _, item := range e.items {
+			v, err := eval(item, sc)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case attrExpr:
+		target, err := eval(e.target, sc)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := target.(map[string]any); ok {
+			if v, ok := m[e.attr]; ok {
+				return v, nil
+			}
+		}
+		if _, ok := target.(string); ok {
+			return method{target: target, name: e.attr}, nil
+		}
+		if _, ok := target.(map[string]any); ok && (e.attr == "items" || e.attr == "keys" || e.attr == "values" || e.attr == "get") {
+			return method{target: target, name: e.attr}, nil
+		}
+		return undefined{}, nil
+	case indexExpr:
+		target, err := eval(e.target, sc)
+		if err != nil {
+			return nil, err
+		}
+		index, err := eval(e.index, sc)
+		if err != nil {
+			return nil, err
+		}
+		return getItem(target, index), nil
+	case sliceExpr:
+		return evalSlice(e, sc)
+	case callExpr:
+		fn, err := eval(e.fn, sc)
+		if err != nil {
+			return nil, err
+		}
+		args, err := evalArgs(e.args, sc)
+		if err != nil {
+			return nil, err
+		}
+		return call(fn, args)
+	case filterExpr:
+		target, err := eval(e.target, sc)
+		if err != nil {
+			return nil, err
+		}
+		args, err := evalArgs(e.args, sc)
+		if err != nil {
+			return nil, err
+		}
+		return applyFilter(e.name, target, args)
+	case testExpr:
+		target, err := eval(e.target, sc)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := applyTest(e.name, target)
+		if err != nil {
+			return nil, err
+		}
+		return ok != e.negate, nil
+	case unaryExpr:
+		v, err := eval(e.operand, sc)
+		if err != nil {
+			return nil, err
+		}
+		if e.op == "not" {
+			return !truthy(v), nil
+		}
+		if n, ok := toNumber(v); ok {
+			return normalizeNumber(-n), nil
+		}
+		return nil, fmt.Errorf("bad operand %v for unary -", v)
+	case binaryExpr:
+		return evalBinary(e, sc)
+	}
+	return nil, fmt.Errorf("unknown expression %T", e)
+}
+
+func evalArgs(exprs []expr, sc *scope) ([]any, error) {
+	args := make([]any, 0, len(exprs))
+	for _, a := range exprs {
+		v, err := eval(a, sc)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	return args, nil
+}
+
+func evalBinary(e binaryExpr, sc *scope) (any, error) {
+	left, err := eval(e.left, sc)
+	if err != nil {
+		return nil, err
+	}
+	// short circuit
+	switch e.op {
+	case "and":
+		if !truthy(left) {
+			return left, nil
+		}
+		return eval(e.right, sc)
+	case "or":
+		if truthy(left) {
+			return left, nil
+		}
+		return eval(e.right, sc)
+	}
+
+	right, err := eval(e.right, sc)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "~":
+		return toString(left) + toString(right), nil
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		return in(left, right), nil
+	case "+":
+		if ls, ok := left.(string); ok {
+			if rs, ok := right.(string); ok {
+				return ls + rs, nil
+			}
+		}
+		if ll, ok := left.([]any); ok {
+			if rl, ok := right.([]any); ok {
+				return append(append([]any{}, ll...), rl...), nil
+			}
+		}
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("unsupported operand types for %s: %T and %T", e.op, left, right)
+	}
+	switch e.op {
+	case "+":
+		return normalizeNumber(l + r), nil
+	case "-":
+		return normalizeNumber(l - r), nil
+	case "*":
+		return normalizeNumber(l * r), nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, fmt.Errorf("modulo by zero")
+		}
+		return normalizeNumber(math.Mod(l, r)), nil
+	case "<":
+		return l < r, nil
+	case ">":
+		return l > r, nil
+	case "<=":
+		return l <= r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", e.op)
+}
+
+func evalSlice(e sliceExpr, sc *scope) (any, error) {
+	target, err := eval(e.target, sc)
+	if err != nil {
+		return nil, err
+	}
+	var length int
+	switch t := target.(type) {
+	case string:
+		length = len(t)
+	case []any:
+		length = len(t)
+	default:
+		return nil, fmt.Errorf("can not slice %T", target)
+	}
+	bound := func(b expr, def int) (int, error) {
+		if b == nil {
+			return def, nil
+		}
+		v, err := eval(b, sc)
+		if err != nil {
+			return 0, err
+		}
+		n, ok := toNumber(v)
+		if !ok {
+			return 0, fmt.Errorf("slice index must be a number, got %T", v)
+		}
+		i := int(n)
+		if i < 0 {
+			i += length
+		}
+		return max(0, min(i, length)), nil
+	}
+	start, err := bound(e.start, 0)
+	if err != nil {
+		return nil, err
+	}
+	stop, err := bound(e.stop, length)
+	if err != nil {
+		return nil, err
+	}
+	if start > stop {
+		start = stop
+	}
+	if s, ok := target.(string); ok {
+		return s[start:stop], nil
+	}
+	return target.([]any)[start:stop], nil
+}
+
+func getItem(target, index any) any {
+	switch t := target.(type) {
+	case map[string]any:
+		if key, ok := index.(string); ok {
+			if v, ok := t[key]; ok {
+				return v
+			}
+		}
+	case []any:
+		if n, ok := toNumber(index); ok {
+			i := int(n)
+			if i < 0 {
+				i += len(t)
+			}
+			if i >= 0 && i < len(t) {
+				return t[i]
+			}
+		}
+	case string:
+		if n, ok := toNumber(index); ok {
+			i := int(n)
+			if i < 0 {
+				i += len(t)
+			}
+			if i >= 0 && i < len(t) {
+				return t[i : i+1]
+			}
+		}
+	}
+	return undefined{}
+}
+
+type builtin string
+
+// method is a bound method of a string or a dict, like message.content.strip
+type method struct {
+	target any
+	name   string
+}
+
+func call(fn any, args []any) (any, error) {
+	switch f := fn.(type) {
+	case builtin:
+		switch f {
+		case "raise_exception":
+			msg := ""
+			if len(args) > 0 {
+				msg = toString(args[0])
+			}
+			return nil, errRaised{msg: msg}
+		case "range":
+			nums := make([]int, 0, len(args))
+			for _, a := range args {
+				n, ok := toNumber(a)
+				if !ok {
+					return nil, fmt.Errorf("range arguments must be numbers")
+				}
+				nums = append(nums, int(n))
+			}
+			start, stop, step := 0, 0, 1
+			switch len(nums) {
+			case 1:
+				stop = nums[0]
+			case 2:
+				start, stop = nums[0], nums[1]
+			case 3:
+				start, stop, step = nums[0], nums[1], nums[2]
+			default:
+				return nil, fmt.Errorf("range expects 1 to 3 arguments")
+			}
+			if step == 0 {
+				return nil, fmt.Errorf("range step must not be zero")
+			}
+			items := make([]any, 0)
+			for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+				items = append(items, i)
+			}
+			return items, nil
+		}
+	case method:
+		return callMethod(f, args)
+	}
+	return nil, fmt.Errorf("%v is not callable", fn)
+}
+
+func callMethod(m method, args []any) (any, error) {
+	if s, ok := m.target.(string); ok {
+		chars := " \t\n\r"
+		if len(args) > 0 {
+			chars = toString(args[0])
+		}
+		switch m.name {
+		case "strip":
+			return strings.Trim(s, chars), nil
+		case "lstrip":
+			return strings.TrimLeft(s, chars), nil
+		case "rstrip":
+			return strings.TrimRight(s, chars), nil
+		case "upper":
+			return strings.ToUpper(s), nil
+		case "lower":
+			return strings.ToLower(s), nil
+		case "title":
+			return strings.Title(s), nil //nolint:staticcheck
+		case "startswith":
+			return len(args) > 0 && strings.HasPrefix(s, toString(args[0])), nil
+		case "endswith":
+			return len(args) > 0 && strings.HasSuffix(s, toString(args[0])), nil
+		case "split":
+			var parts []string
+			if len(args) > 0 {
+				parts = strings.Split(s, toString(args[0]))
+			} else {
+				parts = strings.Fields(s)
+			}
+			items := make([]any, 0, len(parts))
+			for _, p := range parts {
+				items = append(items, p)
+			}
+			return items, nil
+		case "replace":
+			if len(args) < 2 {
+				return nil, fmt.Errorf("replace expects 2 arguments")
+			}
+			return strings.ReplaceAll(s, toString(args[0]), toString(args[1])), nil
+		}
+	}
+	if d, ok := m.target.(map[string]any); ok {
+		keys := sortedKeys(d)
+		switch m.name {
+		case "items":
+			items := make([]any, 0, len(d))
+			for _, k := range keys {
+				items = append(items, []any{k, d[k]})
+			}
+			return items, nil
+		case "keys":
+			items := make([]any, 0, len(d))
+			for _, k := range keys {
+				items = append(items, k)
+			}
+			return items, nil
+		case "values":
+			items := make([]any, 0, len(d))
+			for _, k := range keys {
+				items = append(items, d[k])
+			}
+			return items, nil
+		case "get":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("get expects a key")
+			}
+			if v, ok := d[toString(args[0])]; ok {
+				return v, nil
+			}
+			if len(args) > 1 {
+				return args[1], nil
+			}
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown method %s of %T", m.name, m.target)
+}
+
+func applyFilter(name string, v any, args []any) (any, error) {
+	switch name {
+	case "trim":
+		return strings.TrimSpace(toString(v)), nil
+	case "upper":
+		return strings.ToUpper(toString(v)), nil
+	case "lower":
+		return strings.ToLower(toString(v)), nil
+	case "string":
+		return toString(v), nil
+	case "length", "count":
+		switch t := v.(type) {
+		case string:
+			return len(t), nil
+		case []any:
+			return len(t), nil
+		case map[string]any:
+			return len(t), nil
+		}
+		return 0, nil
+	case "first", "last":
+		items, err := iterate(v)
+		if err != nil || len(items) == 0 {
+			return undefined{}, err
+		}
+		if name == "first" {
+			return items[0], nil
+		}
+		return items[len(items)-1], nil
+	case "join":
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		sep := ""
+		if len(args) > 0 {
+			sep = toString(args[0])
+		}
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			parts = append(parts, toString(item))
+		}
+		return strings.Join(parts, sep), nil
+	case "default", "d":
+		if _, ok := v.(undefined); ok && len(args) > 0 {
+			return args[0], nil
+		}
+		return v, nil
+	case "int":
+		if n, ok := toNumber(v); ok {
+			return int(n), nil
+		}
+		n, _ := strconv.Atoi(toString(v))
+		return n, nil
+	}
+	return nil, fmt.Errorf("unsupported filter %s", name)
+}
+
+func applyTest(name string, v any) (bool, error) {
+	_, isUndefined := v.(undefined)
+	switch name {
+	case "defined":
+		return !isUndefined, nil
+	case "undefined":
+		return isUndefined, nil
+	case "none":
+		return v == nil, nil
+	case "string":
+		_, ok := v.(string)
+		return ok, nil
+	case "number":
+		_, ok := toNumber(v)
+		return ok, nil
+	case "mapping":
+		_, ok := v.(map[string]any)
+		return ok, nil
+	case "iterable", "sequence":
+		_, err := iterate(v)
+		return err == nil, nil
+	case "true":
+		return v == true, nil
+	case "false":
+		return v == false, nil
+	case "even", "odd":
+		n, ok := toNumber(v)
+		if !ok {
+			return false, nil
+		}
+		return (int(n)%2 == 0) == (name == "even"), nil
+	}
+	return false, fmt.Errorf("unsupported test %s", name)
+}
+
+func iterate(v any) ([]any, error) {
+	switch t := v.(type) {
+	case []any:
+		return t, nil
+	case string:
+		items := make([]any, 0, len(t))
+		for _, r := range t {
+			items = append(items, string(r))
+		}
+		return items, nil
+	case map[string]any:
+		items := make([]any, 0, len(t))
+		for _, k := range sortedKeys(t) {
+			items = append(items, k)
+		}
+		return items, nil
+	case undefined, nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%T is not iterable", v)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func truthy(v any) bool {
+	switch t := v.(type) {
+	case nil, undefined:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case int:
+		return t != 0
+	case float64:
+		return t != 0
+	case []any:
+		return len(t) > 0
+	case map[string]any:
+		return len(t) > 0
+	}
+	return true
+}
+
+func toNumber(v any) (float64, bool) {
+	switch t := v.(type) {
+	case int:
+		return float64(t), true
+	case float64:
+		return t, true
+	case bool:
+		if t {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// normalizeNumber keeps integer results as int
+func normalizeNumber(f float64) any {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int(f)
+	}
+	return f
+}
+
+func toString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "None"
+	case undefined:
+		return ""
+	case string:
+		return t
+	case bool:
+		if t {
+			return "True"
+		}
+		return "False"
+	case int:
+		return strconv.Itoa(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func equal(a, b any) bool {
+	if an, ok := toNumber(a); ok {
+		if bn, ok := toNumber(b); ok {
+			return an == bn
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func in(item, container any) bool {
+	switch c := container.(type) {
+	case string:
+		return strings.Contains(c, toString(item))
+	case []any:
+		for _, v := range c {
+			if equal(item, v) {
+				return true
+			}
+		}
+	case map[string]any:
+		_, ok := c[toString(item)]
+		return ok
+	}
+	return false
+}
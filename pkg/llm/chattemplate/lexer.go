@@ -0,0 +1,170 @@
+package chattemplate
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type nodeKind int
+
+const (
+	nodeText nodeKind = iota
+	nodeExpr
+	nodeStmt
+)
+
+// segment is raw text, the source of a {{ expression }} or the source of a {% statement %}
+type segment struct {
+	kind nodeKind
+	src  string
+}
+
+// split splits the template into segments, with whitespace control of `-` and the
+// trim_blocks and lstrip_blocks behaviour chat templates are written for.
+func split(src string) ([]segment, error) {
+	segments := make([]segment, 0)
+	trimNext := false
+	trimNewline := false
+	for len(src) > 0 {
+		start := strings.Index(src, "{")
+		for start >= 0 && start+1 < len(src) && !strings.ContainsRune("{%#", rune(src[start+1])) {
+			next := strings.Index(src[start+1:], "{")
+			if next < 0 {
+				start = -1
+				break
+			}
+			start += next + 1
+		}
+		if start < 0 || start+1 >= len(src) {
+			segments = appendText(segments, src, trimNext, trimNewline)
+			break
+		}
+
+		text := src[:start]
+		open := src[start+1]
+		body := src[start+2:]
+		closeTag := map[byte]string{'{': "}}", '%': "%}", '#': "#}"}[open]
+		end := strings.Index(body, closeTag)
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed tag {%c", open)
+		}
+		inner := body[:end]
+		src = body[end+2:]
+
+		stripLeft := strings.HasPrefix(inner, "-")
+		stripRight := strings.HasSuffix(inner, "-")
+		inner = strings.TrimPrefix(inner, "-")
+		inner = strings.TrimSuffix(inner, "-")
+		if stripLeft {
+			text = strings.TrimRightFunc(text, unicode.IsSpace)
+		} else if open != '{' {
+			// lstrip_blocks: whitespace before a block tag at the start of a line
+			if i := strings.LastIndex(text, "\n"); strings.TrimLeft(text[i+1:], " \t") == "" && (i >= 0 || len(segments) == 0) {
+				text = text[:i+1]
+			}
+		}
+		segments = appendText(segments, text, trimNext, trimNewline)
+		trimNext = stripRight
+		// trim_blocks: first newline after a block tag
+		trimNewline = open != '{'
+
+		switch open {
+		case '{':
+			segments = append(segments, segment{kind: nodeExpr, src: strings.TrimSpace(inner)})
+		case '%':
+			segments = append(segments, segment{kind: nodeStmt, src: strings.TrimSpace(inner)})
+		}
+	}
+	return segments, nil
+}
+
+func appendText(segments []segment, text string, trimLeft, trimNewline bool) []segment {
+	if trimLeft {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	} else if trimNewline {
+		text = strings.TrimPrefix(text, "\n")
+	}
+	if text == "" {
+		return segments
+	}
+	return append(segments, segment{kind: nodeText, src: text})
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+}
+
+// tokenize splits an expression or statement into tokens
+func tokenize(src string) ([]token, error) {
+	tokens := make([]token, 0)
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			sb := strings.Builder{}
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+					switch src[j] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					default:
+						sb.WriteByte(src[j])
+					}
+					continue
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string in %q", src)
+			}
+			tokens = append(tokens, token{kind: tokenString, val: sb.String()})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, val: src[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenName, val: src[i:j]})
+			i = j
+		default:
+			if i+1 < len(src) {
+				if two := src[i : i+2]; two == "==" || two == "!=" || two == "<=" || two == ">=" {
+					tokens = append(tokens, token{kind: tokenOp, val: two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("+-*/%~<>=()[].,:|", rune(c)) {
+				return nil, fmt.Errorf("unexpected character %q in %q", c, src)
+			}
+			tokens = append(tokens, token{kind: tokenOp, val: string(c)})
+			i++
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
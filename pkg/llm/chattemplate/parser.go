@@ -0,0 +1,522 @@
+package chattemplate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type node interface{}
+
+type textNode struct{ text string }
+
+type outputNode struct{ expr expr }
+
+type setNode struct {
+	name string
+	expr expr
+}
+
+type forNode struct {
+	vars     []string
+	iter     expr
+	body     []node
+	elseBody []node
+}
+
+type ifBranch struct {
+	cond expr
+	body []node
+}
+
+type ifNode struct {
+	branches []ifBranch
+	elseBody []node
+}
+
+// parseNodes parses segments until one of the statements in stops, it returns the stop statement
+func parseNodes(segments []segment, pos *int, stops ...string) ([]node, string, error) {
+	nodes := make([]node, 0)
+	for *pos < len(segments) {
+		seg := segments[*pos]
+		*pos++
+		switch seg.kind {
+		case nodeText:
+			nodes = append(nodes, textNode{text: seg.src})
+		case nodeExpr:
+			e, err := parseExpr(seg.src)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, outputNode{expr: e})
+		case nodeStmt:
+			keyword, rest, _ := strings.Cut(seg.src, " ")
+			rest = strings.TrimSpace(rest)
+			for _, stop := range stops {
+				if keyword == stop {
+					return nodes, seg.src, nil
+				}
+			}
+			n, err := parseStmt(segments, pos, keyword, rest)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	if len(stops) > 0 {
+		return nil, "", fmt.Errorf("missing {%% %s %%}", stops[len(stops)-1])
+	}
+	return nodes, "", nil
+}
+
+func parseStmt(segments []segment, pos *int, keyword, rest string) (node, error) {
+	switch keyword {
+	case "set":
+		name, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid set statement %q", rest)
+		}
+		e, err := parseExpr(value)
+		if err != nil {
+			return nil, err
+		}
+		return setNode{name: strings.TrimSpace(name), expr: e}, nil
+	case "for":
+		vars, iter, ok := strings.Cut(rest, " in ")
+		if !ok {
+			return nil, fmt.Errorf("invalid for statement %q", rest)
+		}
+		n := forNode{}
+		for _, v := range strings.Split(vars, ",") {
+			n.vars = append(n.vars, strings.TrimSpace(v))
+		}
+		var err error
+		if n.iter, err = parseExpr(iter); err != nil {
+			return nil, err
+		}
+		body, stop, err := parseNodes(segments, pos, "else", "endfor")
+		if err != nil {
+			return nil, err
+		}
+		n.body = body
+		if stop == "else" {
+			if n.elseBody, _, err = parseNodes(segments, pos, "endfor"); err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+	case "if":
+		n := ifNode{}
+		cond := rest
+		for {
+			e, err := parseExpr(cond)
+			if err != nil {
+				return nil, err
+			}
+			body, stop, err := parseNodes(segments, pos, "elif", "else", "endif")
+			if err != nil {
+				return nil, err
+			}
+			n.branches = append(n.branches, ifBranch{cond: e, body: body})
+			switch {
+			case strings.HasPrefix(stop, "elif"):
+				cond = strings.TrimSpace(strings.TrimPrefix(stop, "elif"))
+				continue
+			case stop == "else":
+				if n.elseBody, _, err = parseNodes(segments, pos, "endif"); err != nil {
+					return nil, err
+				}
+			}
+			return n, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported statement %q", keyword)
+	}
+}
+
+type expr interface{}
+
+type literal struct{ value any }
+
+type name struct{ name string }
+
+type listExpr struct{ items []expr }
+
+type attrExpr struct {
+	target expr
+	attr   string
+}
+
+type indexExpr struct {
+	target expr
+	index  expr
+}
+
+type sliceExpr struct {
+	target      expr
+	start, stop expr
+}
+
+type callExpr struct {
+	fn   expr
+	args []expr
+}
+
+type filterExpr struct {
+	target expr
+	name   string
+	args   []expr
+}
+
+type testExpr struct {
+	target expr
+	name   string
+	negate bool
+}
+
+type unaryExpr struct {
+	op      string
+	operand expr
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func parseExpr(src string) (expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q in %q", p.peek().val, src)
+	}
+	return e, nil
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator or keyword val
+func (p *exprParser) accept(val string) bool {
+	t := p.peek()
+	if (t.kind == tokenOp || t.kind == tokenName) && t.val == val {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(val string) error {
+	if !p.accept(val) {
+		return fmt.Errorf("expected %q, got %q", val, p.peek().val)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (expr, error) {
+	if p.accept("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{op: "not", operand: operand}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (expr, error) {
+	left, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenOp && (t.val == "==" || t.val == "!=" || t.val == "<" || t.val == ">" || t.val == "<=" || t.val == ">="):
+			p.next()
+			right, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			left = binaryExpr{op: t.val, left: left, right: right}
+		case t.kind == tokenName && t.val == "in":
+			p.next()
+			right, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			left = binaryExpr{op: "in", left: left, right: right}
+		case t.kind == tokenName && t.val == "not" && p.tokens[p.pos+1].val == "in":
+			p.pos += 2
+			right, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			left = unaryExpr{op: "not", operand: binaryExpr{op: "in", left: left, right: right}}
+		case t.kind == tokenName && t.val == "is":
+			p.next()
+			negate := p.accept("not")
+			test := p.next()
+			if test.kind != tokenName {
+				return nil, fmt.Errorf("expected test name after is, got %q", test.val)
+			}
+			left = testExpr{target: left, name: test.val, negate: negate}
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *exprParser) parseConcat() (expr, error) {
+	left, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("~") {
+		right, err := p.parseAdd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "~", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAdd() (expr, error) {
+	left, err := p.parseMul()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenOp || (t.val != "+" && t.val != "-") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseMul()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: t.val, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseMul() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenOp || (t.val != "*" && t.val != "/" && t.val != "%") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: t.val, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	if p.accept("-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{op: "-", operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (expr, error) {
+	e, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("."):
+			attr := p.next()
+			if attr.kind != tokenName {
+				return nil, fmt.Errorf("expected attribute name, got %q", attr.val)
+			}
+			e = attrExpr{target: e, attr: attr.val}
+		case p.accept("["):
+			var start, stop expr
+			if !p.accept(":") {
+				if start, err = p.parseOr(); err != nil {
+					return nil, err
+				}
+				if p.accept("]") {
+					e = indexExpr{target: e, index: start}
+					continue
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+			}
+			if !p.accept("]") {
+				if stop, err = p.parseOr(); err != nil {
+					return nil, err
+				}
+				if err := p.expect("]"); err != nil {
+					return nil, err
+				}
+			}
+			e = sliceExpr{target: e, start: start, stop: stop}
+		case p.accept("("):
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			e = callExpr{fn: e, args: args}
+		case p.accept("|"):
+			filter := p.next()
+			if filter.kind != tokenName {
+				return nil, fmt.Errorf("expected filter name, got %q", filter.val)
+			}
+			f := filterExpr{target: e, name: filter.val}
+			if p.accept("(") {
+				if f.args, err = p.parseArgs(); err != nil {
+					return nil, err
+				}
+			}
+			e = f
+		default:
+			return e, nil
+		}
+	}
+}
+
+// parseArgs parses arguments until the closing parenthesis
+func (p *exprParser) parseArgs() ([]expr, error) {
+	args := make([]expr, 0)
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		s := t.val
+		// adjacent string literals are concatenated
+		for p.peek().kind == tokenString {
+			s += p.next().val
+		}
+		return literal{value: s}, nil
+	case tokenNumber:
+		if strings.Contains(t.val, ".") {
+			f, err := strconv.ParseFloat(t.val, 64)
+			return literal{value: f}, err
+		}
+		i, err := strconv.Atoi(t.val)
+		return literal{value: i}, err
+	case tokenName:
+		switch t.val {
+		case "true", "True":
+			return literal{value: true}, nil
+		case "false", "False":
+			return literal{value: false}, nil
+		case "none", "None":
+			return literal{value: nil}, nil
+		}
+		return name{name: t.val}, nil
+	case tokenOp:
+		switch t.val {
+		case "(":
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		case "[":
+			items, err := p.parseList()
+			return listExpr{items: items}, err
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q", t.val)
+}
+
+func (p *exprParser) parseList() ([]expr, error) {
+	items := make([]expr, 0)
+	for !p.accept("]") {
+		if len(items) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,76 @@
+// Package chattemplate renders the jinja chat templates shipped with open models,
+// it supports the subset of jinja used by chat templates: for, if, set, filters, tests and string methods.
+package chattemplate
+
+import (
+	"strings"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+// Llama2 is the chat template of llama 2 chat models, system messages go into the first user message.
+const Llama2 = "{% if messages[0]['role'] == 'system' %}{% set loop_messages = messages[1:] %}{% set system_message = '<<SYS>>\\n' + messages[0]['content'] + '\\n<</SYS>>\\n\\n' %}{% else %}{% set loop_messages = messages %}{% set system_message = '' %}{% endif %}{% for message in loop_messages %}{% if message['role'] == 'user' %}{% if loop.index0 == 0 %}{% set content = system_message + message['content'] %}{% else %}{% set content = message['content'] %}{% endif %}{{ bos_token + '[INST] ' + content.strip() + ' [/INST]' }}{% elif message['role'] == 'assistant' %}{{ ' ' + message['content'].strip() + ' ' + eos_token }}{% endif %}{% endfor %}"
+
+// Template is a parsed chat template, it is safe for concurrent use.
+type Template struct {
+	nodes []node
+}
+
+func Parse(src string) (*Template, error) {
+	segments, err := split(src)
+	if err != nil {
+		return nil, err
+	}
+	pos := 0
+	nodes, _, err := parseNodes(segments, &pos)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{nodes: nodes}, nil
+}
+
+// Execute renders the template with vars.
+func (t *Template) Execute(vars map[string]any) (string, error) {
+	sb := strings.Builder{}
+	sc := &scope{vars: make(map[string]any, len(vars))}
+	for k, v := range vars {
+		sc.vars[k] = v
+	}
+	if err := render(&sb, t.nodes, sc); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Options are the special tokens and flags chat templates expect.
+type Options struct {
+	BosToken            string
+	EosToken            string
+	AddGenerationPrompt bool
+}
+
+// Render renders messages to a raw prompt, the way `apply_chat_template` of transformers does.
+func (t *Template) Render(messages []llm.ChatCompletionMessage, opts Options) (string, error) {
+	items := make([]any, 0, len(messages))
+	for _, m := range messages {
+		items = append(items, map[string]any{
+			"role":    m.Role,
+			"content": m.Content,
+		})
+	}
+	return t.Execute(map[string]any{
+		"messages":              items,
+		"bos_token":             opts.BosToken,
+		"eos_token":             opts.EosToken,
+		"add_generation_prompt": opts.AddGenerationPrompt,
+	})
+}
+
+// Render parses src and renders messages with it.
+func Render(src string, messages []llm.ChatCompletionMessage, opts Options) (string, error) {
+	t, err := Parse(src)
+	if err != nil {
+		return "", err
+	}
+	return t.Render(messages, opts)
+}
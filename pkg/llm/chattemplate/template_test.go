@@ -0,0 +1,169 @@
+package chattemplate
+
+import (
+	"testing"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	llama2Template = "{% if messages[0]['role'] == 'system' %}{% set loop_messages = messages[1:] %}{% set system_message = messages[0]['content'] %}{% else %}{% set loop_messages = messages %}{% set system_message = false %}{% endif %}{% for message in loop_messages %}{% if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}{{ raise_exception('Conversation roles must alternate user/assistant/user/assistant/...') }}{% endif %}{% if loop.index0 == 0 and system_message != false %}{% set content = '<<SYS>>\\n' + system_message + '\\n<</SYS>>\\n\\n' + message['content'] %}{% else %}{% set content = message['content'] %}{% endif %}{% if message['role'] == 'user' %}{{ bos_token + '[INST] ' + content.strip() + ' [/INST]' }}{% elif message['role'] == 'assistant' %}{{ ' '  + content.strip() + ' ' + eos_token }}{% endif %}{% endfor %}"
+
+	// llama3Template is the template of Meta-Llama-3-8B-Instruct
+	llama3Template = "{% set loop_messages = messages %}{% for message in loop_messages %}{% set content = '<|start_header_id|>' + message['role'] + '<|end_header_id|>\n\n'+ message['content'] | trim + '<|eot_id|>' %}{% if loop.index0 == 0 %}{% set content = bos_token + content %}{% endif %}{{ content }}{% endfor %}{% if add_generation_prompt %}{{ '<|start_header_id|>assistant<|end_header_id|>\n\n' }}{% endif %}"
+
+	// mistralTemplate is the template of Mistral-7B-Instruct-v0.2
+	mistralTemplate = `{%- if messages[0]['role'] == 'system' %}
+    {%- set system_message = messages[0]['content'] %}
+    {%- set loop_messages = messages[1:] %}
+{%- else %}
+    {%- set loop_messages = messages %}
+{%- endif %}
+
+{{- bos_token }}
+{%- for message in loop_messages %}
+    {%- if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}
+        {{- raise_exception('After the optional system message, conversation roles must alternate user/assistant/user/assistant/...') }}
+    {%- endif %}
+    {%- if message['role'] == 'user' %}
+        {%- if loop.first and system_message is defined %}
+            {{- ' [INST] ' + system_message + '\n\n' + message['content'] + ' [/INST]' }}
+        {%- else %}
+            {{- ' [INST] ' + message['content'] + ' [/INST]' }}
+        {%- endif %}
+    {%- elif message['role'] == 'assistant' %}
+        {{- ' ' + message['content'] + eos_token}}
+    {%- else %}
+        {{- raise_exception('Only user and assistant roles are supported, with the exception of an initial optional system message!') }}
+    {%- endif %}
+{%- endfor %}`
+
+	// qwenTemplate is the chatml template of Qwen2-7B-Instruct, it adds a default system message
+	qwenTemplate = "{% for message in messages %}{% if loop.first and messages[0]['role'] != 'system' %}{{ '<|im_start|>system\nYou are a helpful assistant.<|im_end|>\n' }}{% endif %}{{'<|im_start|>' + message['role'] + '\n' + message['content'] + '<|im_end|>' + '\n'}}{% endfor %}{% if add_generation_prompt %}{{ '<|im_start|>assistant\n' }}{% endif %}"
+
+	chatmlTemplate = `{% for message in messages %}
+    {{- '<|im_start|>' + message.role + '\n' + message.content | trim + '<|im_end|>\n' }}
+{%- endfor %}
+{% if add_generation_prompt %}
+    {{- '<|im_start|>assistant\n' }}
+{%- endif %}`
+)
+
+var messages = []llm.ChatCompletionMessage{
+	{Role: llm.ChatMessageRoleSystem, Content: "You are helpful."},
+	{Role: llm.ChatMessageRoleUser, Content: "Hi "},
+	{Role: llm.ChatMessageRoleAssistant, Content: "Hello!"},
+	{Role: llm.ChatMessageRoleUser, Content: "How are you?"},
+}
+
+func TestRenderLlama2(t *testing.T) {
+	prompt, err := Render(llama2Template, messages, Options{BosToken: "<s>", EosToken: "</s>"})
+	require.NoError(t, err)
+	assert.Equal(t, "<s>[INST] <<SYS>>\nYou are helpful.\n<</SYS>>\n\nHi [/INST] Hello! </s><s>[INST] How are you? [/INST]", prompt)
+
+	_, err = Render(llama2Template, messages[2:], Options{})
+	assert.ErrorContains(t, err, "Conversation roles must alternate")
+}
+
+func TestRenderChatML(t *testing.T) {
+	prompt, err := Render(chatmlTemplate, messages[:2], Options{AddGenerationPrompt: true})
+	require.NoError(t, err)
+	assert.Equal(t, "<|im_start|>system\nYou are helpful.<|im_end|>\n<|im_start|>user\nHi<|im_end|>\n<|im_start|>assistant\n", prompt)
+}
+
+func TestRenderModelTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		messages []llm.ChatCompletionMessage
+		opts     Options
+		want     string
+		err      string
+	}{
+		{
+			name:     "llama3",
+			src:      llama3Template,
+			messages: messages,
+			opts:     Options{BosToken: "<|begin_of_text|>", AddGenerationPrompt: true},
+			want: "<|begin_of_text|><|start_header_id|>system<|end_header_id|>\n\nYou are helpful.<|eot_id|>" +
+				"<|start_header_id|>user<|end_header_id|>\n\nHi<|eot_id|>" +
+				"<|start_header_id|>assistant<|end_header_id|>\n\nHello!<|eot_id|>" +
+				"<|start_header_id|>user<|end_header_id|>\n\nHow are you?<|eot_id|>" +
+				"<|start_header_id|>assistant<|end_header_id|>\n\n",
+		},
+		{
+			name:     "mistral with system message",
+			src:      mistralTemplate,
+			messages: messages,
+			opts:     Options{BosToken: "<s>", EosToken: "</s>"},
+			want:     "<s> [INST] You are helpful.\n\nHi  [/INST] Hello!</s> [INST] How are you? [/INST]",
+		},
+		{
+			name:     "mistral",
+			src:      mistralTemplate,
+			messages: messages[3:],
+			opts:     Options{BosToken: "<s>", EosToken: "</s>"},
+			want:     "<s> [INST] How are you? [/INST]",
+		},
+		{
+			name:     "mistral roles must alternate",
+			src:      mistralTemplate,
+			messages: []llm.ChatCompletionMessage{messages[1], messages[3]},
+			err:      "conversation roles must alternate",
+		},
+		{
+			name:     "mistral system message in the middle",
+			src:      mistralTemplate,
+			messages: []llm.ChatCompletionMessage{messages[1], messages[0]},
+			err:      "Only user and assistant roles are supported",
+		},
+		{
+			name:     "qwen",
+			src:      qwenTemplate,
+			messages: messages[3:],
+			opts:     Options{AddGenerationPrompt: true},
+			want:     "<|im_start|>system\nYou are a helpful assistant.<|im_end|>\n<|im_start|>user\nHow are you?<|im_end|>\n<|im_start|>assistant\n",
+		},
+		{
+			name:     "qwen with system message",
+			src:      qwenTemplate,
+			messages: messages[:2],
+			want:     "<|im_start|>system\nYou are helpful.<|im_end|>\n<|im_start|>user\nHi <|im_end|>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, err := Render(tt.src, tt.messages, tt.opts)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, prompt)
+		})
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		src  string
+		vars map[string]any
+		want string
+	}{
+		{src: "{{ a ~ 1 + 2 }}", vars: map[string]any{"a": "x"}, want: "x3"},
+		{src: "{% for k, v in d.items() %}{{ k }}={{ v }}{% if not loop.last %},{% endif %}{% endfor %}", vars: map[string]any{"d": map[string]any{"b": 2, "a": 1}}, want: "a=1,b=2"},
+		{src: "{% if x is defined %}yes{% else %}no{% endif %}", want: "no"},
+		{src: "{% set n = 0 %}{% for i in range(3) %}{% set n = n + i %}{% endfor %}{{ n }}", want: "3"},
+		{src: "{{ 'a' in 'cat' and 'z' not in ['x', 'y'] }}", want: "True"},
+		{src: "{{ items[-1] | upper }} {{ items | length }} {{ items | join('-') }}", vars: map[string]any{"items": []any{"a", "b"}}, want: "B 2 a-b"},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.src)
+		require.NoError(t, err, tt.src)
+		got, err := tmpl.Execute(tt.vars)
+		require.NoError(t, err, tt.src)
+		assert.Equal(t, tt.want, got, tt.src)
+	}
+}
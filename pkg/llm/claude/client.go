@@ -7,13 +7,11 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"strings"
 
 	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/llm/awsbedrock"
 	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 )
@@ -36,16 +34,9 @@ func NewClient(cfg llmconfig.Config) (*Client, error) {
 		return nil, err
 	}
 
-	ab := cfg.AWSBedrock
-	awsConfig, err := awsconfig.LoadDefaultConfig(context.Background(),
-		awsconfig.WithRegion(ab.Region),
-		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			ab.AccessKey,
-			ab.SecretKey,
-			"",
-		)))
+	awsConfig, err := awsbedrock.LoadConfig(context.Background(), cfg.AWSBedrock)
 	if err != nil {
-		return nil, fmt.Errorf("get aws config error: %w", err)
+		return nil, err
 	}
 	return &Client{
 		bedrockruntime.NewFromConfig(awsConfig),
@@ -92,8 +83,6 @@ func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCom
 		return
 	}
 
-	sb := &strings.Builder{}
-
 	for event := range output.GetStream().Events() {
 		switch v := event.(type) {
 		case *types.ResponseStreamMemberChunk:
@@ -104,7 +93,6 @@ func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCom
 				errChan <- err
 				return
 			}
-			sb.WriteString(resp.Completion)
 			dataChan <- resp.ToChatCompletionStreamResponse()
 		case *types.UnknownUnionMember:
 			err = fmt.Errorf("unknown event type: %T", v)
@@ -4,96 +4,279 @@ import (
 	"fmt"
 	"log"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/Vaayne/aienvoy/pkg/llm"
 	"github.com/Vaayne/aienvoy/pkg/llm/aigateway"
 	"github.com/Vaayne/aienvoy/pkg/llm/awsbedrock"
+	"github.com/Vaayne/aienvoy/pkg/llm/azureopenai"
+	"github.com/Vaayne/aienvoy/pkg/llm/cohere"
 	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
 	"github.com/Vaayne/aienvoy/pkg/llm/githubcopilot"
 	"github.com/Vaayne/aienvoy/pkg/llm/googleai"
+	"github.com/Vaayne/aienvoy/pkg/llm/groq"
+	"github.com/Vaayne/aienvoy/pkg/llm/mistral"
+	"github.com/Vaayne/aienvoy/pkg/llm/ollama"
 	"github.com/Vaayne/aienvoy/pkg/llm/openai"
+	"github.com/Vaayne/aienvoy/pkg/llm/replicate"
 	"github.com/Vaayne/aienvoy/pkg/llm/together"
 )
 
 var (
-	modelLlmMapping = make(map[string]llm.Interface)
-	once            sync.Once
+	modelLlmMapping      = make(map[string]llm.Interface)
+	modelEmbedderMapping = make(map[string]llm.Embedder)
+	// clientEntries are the created clients, Refresh lists their models again
+	clientEntries []entry
+	// mappingDao is the dao of the first init, it is reused by Reload
+	mappingDao llm.Dao
+	mu         sync.RWMutex
+	once       sync.Once
 )
 
-func initModelMapping(dao llm.Dao, cfgs []llmconfig.Config) {
-	addClient := func(cli llm.Interface, err error) error {
-		if err != nil {
-			return err
-		}
+// Middleware wraps the client of an llm config, it is used to add cross-cutting
+// behaviours like auditing for every upstream call.
+type Middleware func(cfg llmconfig.Config, cli llm.Client) llm.Client
+
+var middlewares []Middleware
+
+// Use registers middlewares, it must be called before the first client is created.
+func Use(mws ...Middleware) {
+	middlewares = append(middlewares, mws...)
+}
 
-		for _, model := range cli.ListModels() {
-			modelLlmMapping[model] = cli
+// UseInterceptors registers interceptors which are applied to the clients of every config.
+func UseInterceptors(interceptors ...llm.Interceptor) {
+	Use(func(_ llmconfig.Config, cli llm.Client) llm.Client {
+		return llm.Wrap(cli, interceptors...)
+	})
+}
+
+// configInterceptors returns the interceptors enabled by cfg
+func configInterceptors(cfg llmconfig.Config) []llm.Interceptor {
+	interceptors := make([]llm.Interceptor, 0)
+	if cfg.Logging {
+		interceptors = append(interceptors, llm.WithLogging(slog.Default()))
+	}
+	if retry := cfg.Retry.WithDefaults(); retry.MaxAttempts > 1 {
+		interceptors = append(interceptors, llm.WithRetry(llm.RetryPolicy{
+			MaxAttempts:          retry.MaxAttempts,
+			Backoff:              retry.Backoff,
+			MaxBackoff:           retry.MaxBackoff,
+			Jitter:               retry.Jitter,
+			RetryableStatusCodes: retry.RetryableStatusCodes,
+		}))
+	}
+	// timeout is inside retry so that it limits every attempt
+	if cfg.Timeout > 0 {
+		interceptors = append(interceptors, llm.WithTimeout(cfg.Timeout))
+	}
+	return interceptors
+}
+
+func newClient(cfg llmconfig.Config) (llm.Client, error) {
+	switch cfg.LLMType {
+	case llmconfig.LLMTypeOpenAI, llmconfig.LLMTypeOpenRouter, llmconfig.LLMTypeOpenAICompatible:
+		return openai.NewClient(cfg)
+	case llmconfig.LLMTypeAzureOpenAI:
+		return azureopenai.NewClient(cfg)
+	case llmconfig.LLMTypeOllama:
+		return ollama.NewClient(cfg)
+	case llmconfig.LLMTypeTogether:
+		return together.NewClient(cfg)
+	case llmconfig.LLMTypeReplicate:
+		return replicate.NewClient(cfg)
+	case llmconfig.LLMTypeMistral:
+		return mistral.NewClient(cfg)
+	case llmconfig.LLMTypeCohere:
+		return cohere.NewClient(cfg)
+	case llmconfig.LLMTypeGroq:
+		return groq.NewClient(cfg)
+	case llmconfig.LLMTypeGoogleAI:
+		if err := cfg.Validate(); err != nil {
+			return nil, err
 		}
-		return nil
+		return googleai.NewClient(cfg.ApiKey), nil
+	case llmconfig.LLMTypeAWSBedrock:
+		return awsbedrock.NewClient(cfg)
+	case llmconfig.LLMTypeAiGateway:
+		return aigateway.NewClient(cfg)
+	case llmconfig.LLMTypeGithubCopilot:
+		return githubcopilot.NewClient(cfg)
 	}
+	return nil, fmt.Errorf("unsupported llm type %s", cfg.LLMType)
+}
 
+// entry is a created client and its config
+type entry struct {
+	cfg llmconfig.Config
+	llm llm.Interface
+}
+
+func newEntries(dao llm.Dao, cfgs []llmconfig.Config) []entry {
+	entries := make([]entry, 0, len(cfgs))
 	for _, cfg := range cfgs {
-		switch cfg.LLMType {
-		case llmconfig.LLMTypeOpenAI, llmconfig.LLMTypeAzureOpenAI, llmconfig.LLMTypeOpenRouter:
-			cli, err := openai.New(cfg, dao)
-			if err := addClient(cli, err); err != nil {
-				slog.Error("init openai client error", "err", err, "config", cfg)
-				continue
-			}
-		case llmconfig.LLMTypeTogether:
-			if err := addClient(together.New(cfg, dao)); err != nil {
-				slog.Error("init together client error", "err", err, "config", cfg)
-				continue
-			}
-		case llmconfig.LLMTypeGoogleAI:
-			cli, err := googleai.New(cfg, dao)
-			if err := addClient(cli, err); err != nil {
-				slog.Error("init googleai client error", "err", err, "config", cfg)
-				continue
-			}
-		case llmconfig.LLMTypeAWSBedrock:
-			cli, err := awsbedrock.New(cfg, dao)
-			if err := addClient(cli, err); err != nil {
-				slog.Error("init aws bedrock client error", "err", err, "config", cfg)
-				continue
-			}
-		case llmconfig.LLMTypeAiGateway:
-			cli, err := aigateway.New(cfg, dao)
-			if err := addClient(cli, err); err != nil {
-				slog.Error("init aigateway client error", "err", err, "config", cfg)
-				continue
-			}
-		case llmconfig.LLMTypeGithubCopilot:
-			cli, err := githubcopilot.New(cfg, dao)
-			if err := addClient(cli, err); err != nil {
-				slog.Error("init github copilot client error", "err", err, "config", cfg)
-				continue
+		cli, err := newClient(cfg)
+		if err != nil {
+			slog.Error("init llm client error", "err", err, "type", cfg.LLMType)
+			continue
+		}
+		cli = llm.Wrap(cli, configInterceptors(cfg)...)
+		for _, mw := range middlewares {
+			cli = mw(cfg, cli)
+		}
+		entries = append(entries, entry{cfg: cfg, llm: llm.New(dao, cli)})
+	}
+	return entries
+}
+
+// buildModelMapping maps models to the clients, the models of a client are listed again on every build
+func buildModelMapping(entries []entry) (map[string]llm.Interface, map[string]llm.Embedder) {
+	llms := make(map[string]llm.Interface)
+	embedders := make(map[string]llm.Embedder)
+	for _, e := range entries {
+		for _, model := range e.llm.ListModels() {
+			llms[model] = e.llm
+		}
+		if embedder, ok := e.llm.(llm.Embedder); ok {
+			for _, model := range e.cfg.EmbeddingModels {
+				embedders[model] = embedder
 			}
 		}
 	}
 
-	// get all keys from modelLlmMapping
-	models := make([]string, 0, len(modelLlmMapping))
-	for model := range modelLlmMapping {
+	// get all keys from llms
+	models := make([]string, 0, len(llms))
+	for model := range llms {
 		models = append(models, model)
 	}
 	slog.Debug("llm clients support models", "models", models)
+	return llms, embedders
+}
 
-	if len(modelLlmMapping) == 0 {
+func initModelMapping(dao llm.Dao, cfgs []llmconfig.Config) {
+	entries := newEntries(dao, cfgs)
+	llms, embedders := buildModelMapping(entries)
+	if len(llms) == 0 {
 		log.Fatal("no llm clients found")
 	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	clientEntries = entries
+	modelLlmMapping = llms
+	modelEmbedderMapping = embedders
+	mappingDao = dao
 }
 
-func NewWithDao(model string, cfgs []llmconfig.Config, dao llm.Dao) (llm.Interface, error) {
+// Reload recreates the clients from cfgs, so that changed configs and models discovered from
+// servers take effect without restarts. It does nothing before the first client is created.
+func Reload(cfgs []llmconfig.Config) {
+	mu.RLock()
+	dao := mappingDao
+	mu.RUnlock()
+	if dao == nil {
+		return
+	}
+
+	entries := newEntries(dao, cfgs)
+	llms, embedders := buildModelMapping(entries)
+	if len(llms) == 0 {
+		slog.Error("reload llm clients error, no llm clients found, keep the current clients")
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	clientEntries = entries
+	modelLlmMapping = llms
+	modelEmbedderMapping = embedders
+	slog.Info("reload llm clients success", "models", len(llms))
+}
+
+// Refresh lists the models of the created clients again, so that models added to provider
+// catalogues are served without restarts. Unlike Reload it keeps the clients.
+func Refresh() {
+	mu.RLock()
+	entries := clientEntries
+	mu.RUnlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	llms, embedders := buildModelMapping(entries)
+	if len(llms) == 0 {
+		slog.Error("refresh llm models error, no models found, keep the current models")
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	added := 0
+	for model := range llms {
+		if _, ok := modelLlmMapping[model]; !ok {
+			added++
+		}
+	}
+	modelLlmMapping = llms
+	modelEmbedderMapping = embedders
+	slog.Info("refresh llm models success", "models", len(llms), "added", added)
+}
+
+// DescribeModels returns the details of all models of the created clients, sorted by id.
+func DescribeModels() []llm.Model {
+	mu.RLock()
+	entries := clientEntries
+	served := make(map[string]bool, len(modelLlmMapping))
+	for model := range modelLlmMapping {
+		served[model] = true
+	}
+	mu.RUnlock()
+
+	models := make([]llm.Model, 0, len(served))
+	for _, e := range entries {
+		for _, m := range llm.DescribeModels(e.llm) {
+			if served[m.ID] {
+				models = append(models, m)
+				delete(served, m.ID)
+			}
+		}
+	}
+	slices.SortFunc(models, func(a, b llm.Model) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return models
+}
+
+// Models returns all models of the created clients, sorted by name.
+func Models() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	models := make([]string, 0, len(modelLlmMapping))
+	for model := range modelLlmMapping {
+		models = append(models, model)
+	}
+	slices.Sort(models)
+	return models
+}
+
+// Init creates the clients of cfgs once, later calls do nothing.
+func Init(cfgs []llmconfig.Config, dao llm.Dao) {
 	once.Do(func() {
 		initModelMapping(dao, cfgs)
 	})
+}
+
+func NewWithDao(model string, cfgs []llmconfig.Config, dao llm.Dao) (llm.Interface, error) {
+	Init(cfgs, dao)
 	if model == "" {
 		return nil, fmt.Errorf("model is empty")
 	}
 
+	mu.RLock()
 	cli, ok := modelLlmMapping[model]
+	mu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("client for model %s not found", model)
 	}
@@ -103,3 +286,22 @@ func NewWithDao(model string, cfgs []llmconfig.Config, dao llm.Dao) (llm.Interfa
 func New(model string, cfgs []llmconfig.Config) (llm.Interface, error) {
 	return NewWithDao(model, cfgs, llm.NewMemoryDao())
 }
+
+func NewEmbedderWithDao(model string, cfgs []llmconfig.Config, dao llm.Dao) (llm.Embedder, error) {
+	Init(cfgs, dao)
+	if model == "" {
+		return nil, fmt.Errorf("embedding model is empty")
+	}
+
+	mu.RLock()
+	embedder, ok := modelEmbedderMapping[model]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("embedder for model %s not found", model)
+	}
+	return embedder, nil
+}
+
+func NewEmbedder(model string, cfgs []llmconfig.Config) (llm.Embedder, error) {
+	return NewEmbedderWithDao(model, cfgs, llm.NewMemoryDao())
+}
@@ -0,0 +1,196 @@
+package cohere
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+const defaultBaseUrl = "https://api.cohere.ai/v1"
+
+// DefaultModels are served when no models are configured and the models can not be listed
+var DefaultModels = []string{
+	"command-r-plus",
+	"command-r",
+	"command",
+	"command-light",
+}
+
+type Client struct {
+	session *http.Client
+	baseUrl string
+	config  llmconfig.Config
+
+	mu     sync.Mutex
+	models []string
+}
+
+func NewClient(cfg llmconfig.Config) (*Client, error) {
+	if cfg.LLMType != llmconfig.LLMTypeCohere {
+		return nil, fmt.Errorf("invalid config for cohere, llmtype: %s", cfg.LLMType)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		session: http.DefaultClient,
+		baseUrl: defaultBaseUrl,
+		config:  cfg,
+	}
+	if cfg.BaseUrl != "" {
+		client.baseUrl = strings.TrimSuffix(cfg.BaseUrl, "/")
+	}
+	return client, nil
+}
+
+func (c *Client) WithSession(session *http.Client) *Client {
+	c.session = session
+	return c
+}
+
+// ListModels returns the configured models, without them the chat models of the account are listed once.
+func (c *Client) ListModels() []string {
+	if len(c.config.Models) > 0 {
+		return c.config.Models
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.models != nil {
+		return c.models
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	models, err := c.listModels(ctx)
+	if err != nil {
+		slog.Error("list cohere models error", "err", err)
+		return DefaultModels
+	}
+	c.models = models
+	return models
+}
+
+func (c *Client) listModels(ctx context.Context) ([]string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/models?endpoint=chat", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var modelsResp ModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		return nil, fmt.Errorf("decode cohere models error: %w", err)
+	}
+	models := make([]string, 0, len(modelsResp.Models))
+	for _, model := range modelsResp.Models {
+		models = append(models, model.Name)
+	}
+	return models, nil
+}
+
+func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	req.Stream = false
+	resp, err := c.chat(ctx, req)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var chatResp ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion decode response error: %w", err)
+	}
+	return chatResp.ToChatCompletionResponse(req.Model, time.Now().Unix()), nil
+}
+
+// CreateChatCompletionStream reads the native stream of cohere, which is a json event per line
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
+	req.Stream = true
+	resp, err := c.chat(ctx, req)
+	if err != nil {
+		errChan <- fmt.Errorf("create chat completion stream error: %w", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var id string
+	created := time.Now().Unix()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var event StreamEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			errChan <- fmt.Errorf("decode cohere chat stream error: %w, data: %s", err, line)
+			return
+		}
+		switch event.EventType {
+		case EventStreamStart:
+			id = event.GenerationId
+		case EventTextGeneration:
+			dataChan <- event.ToChatCompletionStreamResponse(id, req.Model, created)
+		case EventStreamEnd:
+			if event.FinishReason == "ERROR" || event.FinishReason == "ERROR_LIMIT" {
+				errChan <- fmt.Errorf("cohere chat stream error: %s", event.FinishReason)
+				return
+			}
+			if event.Response != nil && event.Response.ResponseId != "" {
+				id = event.Response.ResponseId
+			}
+			dataChan <- event.ToChatCompletionStreamResponse(id, req.Model, created)
+			errChan <- io.EOF
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errChan <- fmt.Errorf("read cohere chat stream error: %w", err)
+		return
+	}
+	errChan <- io.EOF
+}
+
+func (c *Client) chat(ctx context.Context, req llm.ChatCompletionRequest) (*http.Response, error) {
+	chatReq := ChatRequest{Preamble: c.config.Cohere.Preamble}.FromChatCompletionRequest(req)
+	for _, connector := range c.config.Cohere.Connectors {
+		chatReq.Connectors = append(chatReq.Connectors, Connector{Id: connector})
+	}
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal cohere chat request error: %w", err)
+	}
+	return c.do(ctx, http.MethodPost, "/chat", body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseUrl+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request error: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.ApiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.session.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, llm.NewHTTPError(resp)
+	}
+	return resp, nil
+}
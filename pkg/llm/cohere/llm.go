@@ -0,0 +1,20 @@
+package cohere
+
+import (
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+type Cohere struct {
+	*llm.LLM
+}
+
+func New(cfg llmconfig.Config, dao llm.Dao) (*Cohere, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Cohere{
+		llm.New(dao, client),
+	}, nil
+}
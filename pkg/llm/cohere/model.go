@@ -0,0 +1,185 @@
+package cohere
+
+import (
+	"strings"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+const (
+	RoleUser    = "USER"
+	RoleChatbot = "CHATBOT"
+	RoleSystem  = "SYSTEM"
+)
+
+const (
+	EventStreamStart    = "stream-start"
+	EventTextGeneration = "text-generation"
+	EventStreamEnd      = "stream-end"
+)
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Message string `json:"message"`
+}
+
+type Connector struct {
+	Id string `json:"id"`
+}
+
+type ChatRequest struct {
+	Message       string        `json:"message"`
+	Model         string        `json:"model"`
+	Preamble      string        `json:"preamble,omitempty"`
+	ChatHistory   []ChatMessage `json:"chat_history,omitempty"`
+	Connectors    []Connector   `json:"connectors,omitempty"`
+	Temperature   float32       `json:"temperature,omitempty"`
+	P             float32       `json:"p,omitempty"`
+	MaxTokens     int           `json:"max_tokens,omitempty"`
+	StopSequences []string      `json:"stop_sequences,omitempty"`
+	Stream        bool          `json:"stream"`
+}
+
+// FromChatCompletionRequest maps the last message to the message of cohere and the others to the chat history,
+// system messages are joined into the preamble, which keeps the configured preamble when there are none.
+func (r ChatRequest) FromChatCompletionRequest(req llm.ChatCompletionRequest) ChatRequest {
+	r.Model = req.Model
+	r.Stream = req.Stream
+	r.Temperature = req.Temperature
+	r.P = req.TopP
+	r.MaxTokens = req.MaxTokens
+	r.StopSequences = req.Stop
+
+	var preamble []string
+	messages := make([]llm.ChatCompletionMessage, 0, len(req.Messages))
+	for _, message := range req.Messages {
+		if message.Role == llm.ChatMessageRoleSystem {
+			preamble = append(preamble, message.Content)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	if len(preamble) > 0 {
+		r.Preamble = strings.Join(preamble, "\n")
+	}
+	if len(messages) == 0 {
+		return r
+	}
+
+	last := messages[len(messages)-1]
+	r.Message = last.Content
+	for _, message := range messages[:len(messages)-1] {
+		role := RoleUser
+		if message.Role == llm.ChatMessageRoleAssistant {
+			role = RoleChatbot
+		}
+		r.ChatHistory = append(r.ChatHistory, ChatMessage{Role: role, Message: message.Content})
+	}
+	return r
+}
+
+type Tokens struct {
+	InputTokens  float64 `json:"input_tokens"`
+	OutputTokens float64 `json:"output_tokens"`
+}
+
+type Meta struct {
+	BilledUnits Tokens  `json:"billed_units"`
+	Tokens      *Tokens `json:"tokens,omitempty"`
+}
+
+// toUsage prefers the actual tokens, which include the preamble and documents, over the billed units
+func (m Meta) toUsage() llm.Usage {
+	tokens := m.BilledUnits
+	if m.Tokens != nil {
+		tokens = *m.Tokens
+	}
+	return llm.Usage{
+		PromptTokens:     int(tokens.InputTokens),
+		CompletionTokens: int(tokens.OutputTokens),
+		TotalTokens:      int(tokens.InputTokens + tokens.OutputTokens),
+	}
+}
+
+type ChatResponse struct {
+	ResponseId   string `json:"response_id"`
+	GenerationId string `json:"generation_id"`
+	Text         string `json:"text"`
+	FinishReason string `json:"finish_reason"`
+	Meta         Meta   `json:"meta"`
+}
+
+func finishReason(reason string) llm.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "COMPLETE":
+		return llm.FinishReasonStop
+	case "MAX_TOKENS":
+		return llm.FinishReasonLength
+	case "ERROR_TOXIC":
+		return llm.FinishReasonContentFilter
+	default:
+		return llm.FinishReason(strings.ToLower(reason))
+	}
+}
+
+func (r ChatResponse) ToChatCompletionResponse(model string, created int64) llm.ChatCompletionResponse {
+	return llm.ChatCompletionResponse{
+		ID:      r.ResponseId,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   model,
+		Choices: []llm.ChatCompletionChoice{
+			{
+				Message: llm.ChatCompletionMessage{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: r.Text,
+				},
+				FinishReason: finishReason(r.FinishReason),
+			},
+		},
+		Usage: r.Meta.toUsage(),
+	}
+}
+
+// StreamEvent is a line of the stream, stream-end has the full response which carries the usage
+type StreamEvent struct {
+	EventType    string        `json:"event_type"`
+	IsFinished   bool          `json:"is_finished"`
+	GenerationId string        `json:"generation_id,omitempty"`
+	Text         string        `json:"text,omitempty"`
+	FinishReason string        `json:"finish_reason,omitempty"`
+	Response     *ChatResponse `json:"response,omitempty"`
+}
+
+func (e StreamEvent) ToChatCompletionStreamResponse(id, model string, created int64) llm.ChatCompletionStreamResponse {
+	resp := llm.ChatCompletionStreamResponse{
+		ID:      id,
+		Object:  "chat.completion.chunk",
+		Created: created,
+		Model:   model,
+		Choices: []llm.ChatCompletionStreamChoice{
+			{
+				Delta: llm.ChatCompletionStreamChoiceDelta{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: e.Text,
+				},
+				FinishReason: finishReason(e.FinishReason),
+			},
+		},
+	}
+	if e.Response != nil {
+		usage := e.Response.Meta.toUsage()
+		resp.Usage = &usage
+	}
+	return resp
+}
+
+type ModelsResponse struct {
+	Models []struct {
+		Name          string   `json:"name"`
+		Endpoints     []string `json:"endpoints"`
+		ContextLength float64  `json:"context_length"`
+	} `json:"models"`
+}
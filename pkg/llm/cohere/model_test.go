@@ -0,0 +1,142 @@
+package cohere
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+func TestFromChatCompletionRequest(t *testing.T) {
+	req := llm.ChatCompletionRequest{
+		Model: "command-r",
+		Messages: []llm.ChatCompletionMessage{
+			{Role: llm.ChatMessageRoleSystem, Content: "System message"},
+			{Role: llm.ChatMessageRoleUser, Content: "How are you?"},
+			{Role: llm.ChatMessageRoleAssistant, Content: "I'm fine, thank you."},
+			{Role: llm.ChatMessageRoleUser, Content: "Hello"},
+		},
+		Stop:        []string{"stop"},
+		Temperature: 0.5,
+		TopP:        0.9,
+		MaxTokens:   100,
+	}
+
+	expected := ChatRequest{
+		Message:  "Hello",
+		Model:    "command-r",
+		Preamble: "System message",
+		ChatHistory: []ChatMessage{
+			{Role: RoleUser, Message: "How are you?"},
+			{Role: RoleChatbot, Message: "I'm fine, thank you."},
+		},
+		Temperature:   req.Temperature,
+		P:             req.TopP,
+		MaxTokens:     req.MaxTokens,
+		StopSequences: req.Stop,
+	}
+
+	result := ChatRequest{Preamble: "Default preamble"}.FromChatCompletionRequest(req)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("FromChatCompletionRequest() = %v, want %v", result, expected)
+	}
+
+	// the configured preamble is kept without system messages
+	result = ChatRequest{Preamble: "Default preamble"}.FromChatCompletionRequest(llm.ChatCompletionRequest{
+		Messages: []llm.ChatCompletionMessage{{Role: llm.ChatMessageRoleUser, Content: "Hello"}},
+	})
+	if result.Preamble != "Default preamble" || result.Message != "Hello" || len(result.ChatHistory) != 0 {
+		t.Errorf("FromChatCompletionRequest() = %v, want default preamble", result)
+	}
+}
+
+func TestToChatCompletionResponse(t *testing.T) {
+	data, err := os.ReadFile("testdata/chat_response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chatResp ChatResponse
+	if err := json.Unmarshal(data, &chatResp); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := llm.ChatCompletionResponse{
+		ID:      "2e8d2d4b-5d3c-4b3b-9d43-6c1a1f0a6b1e",
+		Object:  "chat.completion",
+		Created: 1710000000,
+		Model:   "command-r",
+		Choices: []llm.ChatCompletionChoice{
+			{
+				Message: llm.ChatCompletionMessage{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: "Hello! How can I help you today?",
+				},
+				FinishReason: llm.FinishReasonStop,
+			},
+		},
+		Usage: llm.Usage{PromptTokens: 71, CompletionTokens: 9, TotalTokens: 80},
+	}
+
+	result := chatResp.ToChatCompletionResponse("command-r", 1710000000)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ToChatCompletionResponse() = %v, want %v", result, expected)
+	}
+}
+
+func TestCreateChatCompletionStream(t *testing.T) {
+	data, err := os.ReadFile("testdata/chat_stream.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chatReq ChatRequest
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat" || r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&chatReq)
+		_, _ = w.Write(data)
+	}))
+	defer s.Close()
+
+	client, err := NewClient(llmconfig.Config{
+		LLMType: llmconfig.LLMTypeCohere,
+		ApiKey:  "key",
+		BaseUrl: s.URL + "/v1",
+		Cohere:  llmconfig.CohereConfig{Connectors: []string{"web-search"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := llm.ChatCompletionRequest{
+		Model:    "command-r",
+		Messages: []llm.ChatCompletionMessage{{Role: llm.ChatMessageRoleUser, Content: "Hello"}},
+	}
+	resp, err := llm.CompleteStream(context.Background(), req, client.CreateChatCompletionStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !chatReq.Stream || !reflect.DeepEqual(chatReq.Connectors, []Connector{{Id: "web-search"}}) {
+		t.Errorf("chat request = %v, want stream with web-search connector", chatReq)
+	}
+	if resp.ID != "2e8d2d4b-5d3c-4b3b-9d43-6c1a1f0a6b1e" {
+		t.Errorf("id = %q, want the response id", resp.ID)
+	}
+	if content := resp.Choices[0].Message.Content; content != "Hello!" {
+		t.Errorf("content = %q, want %q", content, "Hello!")
+	}
+	if reason := resp.Choices[0].FinishReason; reason != llm.FinishReasonStop {
+		t.Errorf("finish reason = %q, want %q", reason, llm.FinishReasonStop)
+	}
+	expected := llm.Usage{PromptTokens: 71, CompletionTokens: 2, TotalTokens: 73}
+	if !reflect.DeepEqual(resp.Usage, expected) {
+		t.Errorf("usage = %v, want %v", resp.Usage, expected)
+	}
+}
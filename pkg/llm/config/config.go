@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 )
 
 type LLMType string
@@ -22,6 +25,12 @@ const (
 	LLMTypeGoogleBard    LLMType = "google-bard"
 	LLMTypeGoogleAI      LLMType = "google-ai"
 	LLMTypeGithubCopilot LLMType = "github-copilot"
+	LLMTypeOllama        LLMType = "ollama"
+	LLMTypeMistral       LLMType = "mistral"
+	LLMTypeCohere        LLMType = "cohere"
+	LLMTypeGroq          LLMType = "groq"
+	// LLMTypeOpenAICompatible is any server with OpenAI compatible api, like llama.cpp, vLLM and LocalAI
+	LLMTypeOpenAICompatible LLMType = "openai-compatible"
 )
 
 type AiGatewayProviderType string
@@ -48,6 +57,8 @@ type Config struct {
 	LLMType LLMType `json:"type" yaml:"type" mapstructure:"type"`
 	// Models is a list of valid model ids for this config
 	Models []string `json:"models" yaml:"models" mapstructure:"models"`
+	// EmbeddingModels is a list of valid embedding model ids for this config
+	EmbeddingModels []string `json:"embedding_models" yaml:"embedding_models" mapstructure:"embedding_models"`
 
 	// ApiKey is the API key for the provider, works for OpenAI, HuggingFace, Replicate and Together
 	ApiKey string `json:"api_key" yaml:"api_key" mapstructure:"api_key"`
@@ -60,6 +71,25 @@ type Config struct {
 	AWSBedrock AWSBedrockConfig `json:"aws_bedrock" yaml:"aws_bedrock" mapstructure:"aws_bedrock"`
 	// AiGateway is the config for Cloudflare AI Gateway
 	AiGateway AiGatewayConfig `json:"aigateway" yaml:"aigateway" mapstructure:"aigateway"`
+	// Ollama is the config for Ollama
+	Ollama OllamaConfig `json:"ollama" yaml:"ollama" mapstructure:"ollama"`
+	// Together is the config for Together
+	Together TogetherConfig `json:"together" yaml:"together" mapstructure:"together"`
+	// Replicate is the config for Replicate
+	Replicate ReplicateConfig `json:"replicate" yaml:"replicate" mapstructure:"replicate"`
+	// Mistral is the config for Mistral La Plateforme
+	Mistral MistralConfig `json:"mistral" yaml:"mistral" mapstructure:"mistral"`
+	// Cohere is the config for Cohere
+	Cohere CohereConfig `json:"cohere" yaml:"cohere" mapstructure:"cohere"`
+	// GithubCopilot is the config for GitHub Copilot
+	GithubCopilot GithubCopilotConfig `json:"github_copilot" yaml:"github_copilot" mapstructure:"github_copilot"`
+
+	// Timeout limits every attempt of upstream calls, streams are limited as a whole, zero means no timeout
+	Timeout time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	// Retry is the retry policy of upstream calls
+	Retry RetryConfig `json:"retry" yaml:"retry" mapstructure:"retry"`
+	// Logging logs start, latency and error of every upstream call
+	Logging bool `json:"logging" yaml:"logging" mapstructure:"logging"`
 }
 
 func (c Config) Validate() error {
@@ -68,16 +98,31 @@ func (c Config) Validate() error {
 	}
 
 	switch c.LLMType {
-	case LLMTypeOpenAI, LLMTypeClaudeWeb, LLMTypeGoogleBard, LLMTypeTogether, LLMTypeReplicate, LLMTypeGoogleAI, LLMTypeOpenRouter:
+	case LLMTypeOpenAI, LLMTypeClaudeWeb, LLMTypeGoogleBard, LLMTypeTogether, LLMTypeGoogleAI, LLMTypeOpenRouter,
+		LLMTypeMistral, LLMTypeGroq:
+		if c.ApiKey == "" {
+			return fmt.Errorf("api_key is required")
+		}
+	case LLMTypeCohere:
+		if c.ApiKey == "" {
+			return fmt.Errorf("api_key is required")
+		}
+		return c.Cohere.validate()
+	case LLMTypeReplicate:
 		if c.ApiKey == "" {
 			return fmt.Errorf("api_key is required")
 		}
+		return c.Replicate.validate()
 	case LLMTypeAzureOpenAI:
 		return c.AzureOpenAI.validate()
 	case LLMTypeAWSBedrock:
 		return c.AWSBedrock.validate()
 	case LLMTypeAiGateway:
 		return c.AiGateway.validate()
+	case LLMTypeOpenAICompatible:
+		if c.BaseUrl == "" {
+			return fmt.Errorf("base_url is required")
+		}
 	}
 	return nil
 }
@@ -86,46 +131,276 @@ func (c *Config) ListModels() []string {
 	return c.Models
 }
 
+type RetryConfig struct {
+	// MaxAttempts includes the first attempt, zero means 3 and 1 disables retries
+	MaxAttempts int `json:"max_attempts" mapstructure:"max_attempts" yaml:"max_attempts"`
+	// Backoff is the wait before the first retry, it doubles for every following retry, default 1s
+	Backoff time.Duration `json:"backoff" mapstructure:"backoff" yaml:"backoff"`
+	// MaxBackoff caps the wait between attempts, a longer Retry-After fails the call, default 30s
+	MaxBackoff time.Duration `json:"max_backoff" mapstructure:"max_backoff" yaml:"max_backoff"`
+	// Jitter randomizes the backoff by up to this fraction of it, default 0.2
+	Jitter float64 `json:"jitter" mapstructure:"jitter" yaml:"jitter"`
+	// RetryableStatusCodes default to 408, 429, 500, 502, 503 and 504
+	RetryableStatusCodes []int `json:"retryable_status_codes" mapstructure:"retryable_status_codes" yaml:"retryable_status_codes"`
+}
+
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryBackoff     = time.Second
+	DefaultRetryMaxBackoff  = 30 * time.Second
+	DefaultRetryJitter      = 0.2
+)
+
+// WithDefaults returns the config with zero values replaced by defaults
+func (c RetryConfig) WithDefaults() RetryConfig {
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if c.Backoff == 0 {
+		c.Backoff = DefaultRetryBackoff
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if c.Jitter == 0 {
+		c.Jitter = DefaultRetryJitter
+	}
+	return c
+}
+
+type OllamaConfig struct {
+	// KeepAlive is how long models stay loaded after a request, like 5m or -1 for forever, empty uses the server default
+	KeepAlive string `json:"keep_alive" mapstructure:"keep_alive" yaml:"keep_alive"`
+	// PullOnDemand pulls a model which is not on the server yet on its first request
+	PullOnDemand bool `json:"pull_on_demand" mapstructure:"pull_on_demand" yaml:"pull_on_demand"`
+	// Preload loads the configured models into memory at startup, so the first requests do not wait for loading
+	Preload bool `json:"preload" mapstructure:"preload" yaml:"preload"`
+}
+
+type TogetherConfig struct {
+	// CatalogTTL is how long the model catalogue is used before it is revalidated with the server, default 1h
+	CatalogTTL time.Duration `json:"catalog_ttl" mapstructure:"catalog_ttl" yaml:"catalog_ttl"`
+}
+
+const DefaultTogetherCatalogTTL = time.Hour
+
+type MistralConfig struct {
+	// SafePrompt injects the mistral safety prompt before all conversations
+	SafePrompt bool `json:"safe_prompt" mapstructure:"safe_prompt" yaml:"safe_prompt"`
+}
+
+// GithubCopilotConfig selects the github login of device flow, ApiKey is used instead when it is set.
+type GithubCopilotConfig struct {
+	// Account is the name of the stored github login, default is DefaultGithubCopilotAccount
+	Account string `json:"account" mapstructure:"account" yaml:"account"`
+}
+
+const DefaultGithubCopilotAccount = "default"
+
+// GetAccount returns the account name of the stored github login
+func (c GithubCopilotConfig) GetAccount() string {
+	if c.Account == "" {
+		return DefaultGithubCopilotAccount
+	}
+	return c.Account
+}
+
+type CohereConfig struct {
+	// Preamble replaces the default preamble of cohere, system messages of requests take precedence over it
+	Preamble string `json:"preamble" mapstructure:"preamble" yaml:"preamble"`
+	// Connectors are the ids of the connectors used to ground the answers, like web-search
+	Connectors []string `json:"connectors" mapstructure:"connectors" yaml:"connectors"`
+}
+
+func (c CohereConfig) validate() error {
+	for _, connector := range c.Connectors {
+		if connector == "" {
+			return fmt.Errorf("cohere.connectors must not contain empty ids")
+		}
+	}
+	return nil
+}
+
+type ReplicateConfig struct {
+	// Models are the replicate models served by this config
+	Models []ReplicateModel `json:"models" mapstructure:"models" yaml:"models"`
+	// PollInterval is the interval of polling predictions which are not streamed, default 1s
+	PollInterval time.Duration `json:"poll_interval" mapstructure:"poll_interval" yaml:"poll_interval"`
+}
+
+type ReplicateModel struct {
+	// Name is the model name of requests, default Version
+	Name string `json:"name" mapstructure:"name" yaml:"name"`
+	// Version is `owner/name` of an official model, `owner/name:version` or a bare version id
+	Version     string `json:"version" mapstructure:"version" yaml:"version"`
+	Description string `json:"description" mapstructure:"description" yaml:"description"`
+	// ContextLength is the context window of the model, it is informational only
+	ContextLength int `json:"context_length" mapstructure:"context_length" yaml:"context_length"`
+	// ChatTemplate is a jinja chat template rendering messages into the prompt input,
+	// by default the prompt is in the llama 2 chat format with a separate system_prompt input
+	ChatTemplate string `json:"chat_template" mapstructure:"chat_template" yaml:"chat_template"`
+	// Input are extra inputs of every prediction, like top_k or prompt_template
+	Input map[string]any `json:"input" mapstructure:"input" yaml:"input"`
+}
+
+const DefaultReplicatePollInterval = time.Second
+
+func (c ReplicateConfig) validate() error {
+	for i, m := range c.Models {
+		if m.Version == "" {
+			return fmt.Errorf("replicate.models[%d].version is required", i)
+		}
+	}
+	return nil
+}
+
 type AzureOpenAIConfig struct {
-	ApiKey                 string            `json:"api_key" mapstructure:"api_key" yaml:"api_key"`
-	ResourceName           string            `json:"resource_name" mapstructure:"resource_name" yaml:"resource_name"`
+	// ApiKey is the key of ResourceName, it is the default key of Deployments
+	ApiKey       string `json:"api_key" mapstructure:"api_key" yaml:"api_key"`
+	ResourceName string `json:"resource_name" mapstructure:"resource_name" yaml:"resource_name"`
+	// ModelDeploymentMapping maps models to the deployment names of ResourceName
 	ModelDeploymentMapping map[string]string `json:"model_deployment_mapping" mapstructure:"model_deployment_mapping" yaml:"model_deployment_mapping"`
-	Version                string            `json:"version" mapstructure:"version" yaml:"version"`
+	// Version is the api version, default is DefaultAzureOpenAIVersion
+	Version string `json:"version" mapstructure:"version" yaml:"version"`
+	// Deployments serve models from more resources or regions, deployments of the same model are balanced
+	// and a failed call is retried by the next deployment
+	Deployments []AzureOpenAIDeployment `json:"deployments" mapstructure:"deployments" yaml:"deployments"`
+	// EntraId authenticates with tokens of Microsoft Entra ID instead of api keys
+	EntraId AzureEntraIdConfig `json:"entra_id" mapstructure:"entra_id" yaml:"entra_id"`
+}
+
+const DefaultAzureOpenAIVersion = "2023-12-01-preview"
+
+type AzureOpenAIDeployment struct {
+	// Model is the model id requested by clients
+	Model string `json:"model" mapstructure:"model" yaml:"model"`
+	// Deployment is the deployment name of the model in the resource
+	Deployment string `json:"deployment" mapstructure:"deployment" yaml:"deployment"`
+	// ResourceName is the resource of the deployment, default is AzureOpenAIConfig.ResourceName
+	ResourceName string `json:"resource_name" mapstructure:"resource_name" yaml:"resource_name"`
+	// Endpoint overrides https://{resource_name}.openai.azure.com, like custom domains
+	Endpoint string `json:"endpoint" mapstructure:"endpoint" yaml:"endpoint"`
+	// ApiKey is the key of the resource, default is AzureOpenAIConfig.ApiKey
+	ApiKey string `json:"api_key" mapstructure:"api_key" yaml:"api_key"`
+}
+
+// AzureEntraIdConfig uses client credentials with ClientSecret, without it the token of the managed identity
+// is used, ClientId selects a user assigned managed identity.
+type AzureEntraIdConfig struct {
+	TenantId     string `json:"tenant_id" mapstructure:"tenant_id" yaml:"tenant_id"`
+	ClientId     string `json:"client_id" mapstructure:"client_id" yaml:"client_id"`
+	ClientSecret string `json:"client_secret" mapstructure:"client_secret" yaml:"client_secret"`
+	// ManagedIdentity enables the managed identity of the azure vm, app service or aks node
+	ManagedIdentity bool `json:"managed_identity" mapstructure:"managed_identity" yaml:"managed_identity"`
+	// AuthorityHost is the host of client credentials tokens, default is https://login.microsoftonline.com
+	AuthorityHost string `json:"authority_host" mapstructure:"authority_host" yaml:"authority_host"`
+}
+
+// Enabled reports if tokens are used instead of api keys
+func (c AzureEntraIdConfig) Enabled() bool {
+	return c.ClientSecret != "" || c.ManagedIdentity
 }
 
 func (c *AzureOpenAIConfig) validate() error {
-	if c.ApiKey == "" {
-		return fmt.Errorf("azure_openai.api_key is required")
+	if c.EntraId.ClientSecret != "" && (c.EntraId.TenantId == "" || c.EntraId.ClientId == "") {
+		return fmt.Errorf("azure_openai.entra_id.tenant_id and client_id are required with client_secret")
 	}
-	if c.ResourceName == "" {
-		return fmt.Errorf("azure_openai.resource_name is required")
+	for i, d := range c.Deployments {
+		if d.Model == "" || d.Deployment == "" {
+			return fmt.Errorf("azure_openai.deployments[%d].model and deployment are required", i)
+		}
+		if d.ResourceName == "" && d.Endpoint == "" && c.ResourceName == "" {
+			return fmt.Errorf("azure_openai.deployments[%d].resource_name is required", i)
+		}
+		if d.ApiKey == "" && c.ApiKey == "" && !c.EntraId.Enabled() {
+			return fmt.Errorf("azure_openai.deployments[%d].api_key is required", i)
+		}
+	}
+	if len(c.ModelDeploymentMapping) > 0 || len(c.Deployments) == 0 {
+		if c.ApiKey == "" && !c.EntraId.Enabled() {
+			return fmt.Errorf("azure_openai.api_key is required")
+		}
+		if c.ResourceName == "" {
+			return fmt.Errorf("azure_openai.resource_name is required")
+		}
 	}
 	return nil
 }
 
+// GetDeployments returns the deployments of model, ModelDeploymentMapping comes first.
+// Models without deployment are served by the deployment of their name in ResourceName.
+func (c *AzureOpenAIConfig) GetDeployments(model string) []AzureOpenAIDeployment {
+	deployments := make([]AzureOpenAIDeployment, 0)
+	if name, ok := c.ModelDeploymentMapping[model]; ok {
+		deployments = append(deployments, AzureOpenAIDeployment{Model: model, Deployment: name})
+	}
+	for _, d := range c.Deployments {
+		if d.Model == model {
+			deployments = append(deployments, d)
+		}
+	}
+	if len(deployments) == 0 && c.ResourceName != "" {
+		deployments = append(deployments, AzureOpenAIDeployment{Model: model, Deployment: model})
+	}
+	for i := range deployments {
+		if deployments[i].ResourceName == "" {
+			deployments[i].ResourceName = c.ResourceName
+		}
+		if deployments[i].ApiKey == "" {
+			deployments[i].ApiKey = c.ApiKey
+		}
+		if deployments[i].Endpoint == "" {
+			deployments[i].Endpoint = fmt.Sprintf("https://%s.openai.azure.com", deployments[i].ResourceName)
+		}
+	}
+	return deployments
+}
+
+// ListModels returns the sorted models of ModelDeploymentMapping and Deployments
 func (c *AzureOpenAIConfig) ListModels() []string {
 	models := make([]string, 0)
 	for k := range c.ModelDeploymentMapping {
 		models = append(models, k)
 	}
+	for _, d := range c.Deployments {
+		if !slices.Contains(models, d.Model) {
+			models = append(models, d.Model)
+		}
+	}
+	slices.Sort(models)
 	return models
 }
 
+// AWSBedrockConfig is the config for AWS Bedrock, without keys the credentials are loaded by the default
+// credential chain of aws: environment, shared profile, web identity (IRSA) and EC2 or ECS metadata.
 type AWSBedrockConfig struct {
-	// AccessKey is the access key for AWS Bedrock
+	// AccessKey is the access key for AWS Bedrock, it is optional
 	AccessKey string `json:"access_key" mapstructure:"access_key" yaml:"access_key"`
-	// SecretKey is the secret key for AWS Bedrock
+	// SecretKey is the secret key for AWS Bedrock, it is required with AccessKey
 	SecretKey string `json:"secret_key" mapstructure:"secret_key" yaml:"secret_key"`
-	// egion is the region for AWS Bedrock
+	// SessionToken is the session token of temporary keys
+	SessionToken string `json:"session_token" mapstructure:"session_token" yaml:"session_token"`
+	// Profile is the shared config profile used by the default credential chain
+	Profile string `json:"profile" mapstructure:"profile" yaml:"profile"`
+	// RoleArn is the role assumed with the loaded credentials by sts:AssumeRole
+	RoleArn string `json:"role_arn" mapstructure:"role_arn" yaml:"role_arn"`
+	// ExternalId is passed to sts:AssumeRole when the trust policy of the role requires it
+	ExternalId string `json:"external_id" mapstructure:"external_id" yaml:"external_id"`
+	// RoleSessionName is the session name of the assumed role, default is generated by the aws sdk
+	RoleSessionName string `json:"role_session_name" mapstructure:"role_session_name" yaml:"role_session_name"`
+	// Region is the region for AWS Bedrock
 	Region string `json:"region" mapstructure:"region" yaml:"region"`
 }
 
 func (c *AWSBedrockConfig) validate() error {
-	if c.AccessKey == "" {
-		return fmt.Errorf("aws_bedrock.access_key is required")
+	if (c.AccessKey == "") != (c.SecretKey == "") {
+		return fmt.Errorf("aws_bedrock.access_key and aws_bedrock.secret_key must be set together")
 	}
-	if c.SecretKey == "" {
-		return fmt.Errorf("aws_bedrock.secret_key is required")
+	if c.SessionToken != "" && c.AccessKey == "" {
+		return fmt.Errorf("aws_bedrock.session_token requires aws_bedrock.access_key")
+	}
+	if c.ExternalId != "" && c.RoleArn == "" {
+		return fmt.Errorf("aws_bedrock.external_id requires aws_bedrock.role_arn")
 	}
 	if c.Region == "" {
 		return fmt.Errorf("aws_bedrock.region is required")
@@ -138,10 +413,15 @@ func (c *AWSBedrockConfig) ListModels() []string {
 }
 
 type AiGatewayProvider struct {
-	Type        AiGatewayProviderType `json:"type" mapstructure:"type" yaml:"type"`
-	ApiKey      string                `json:"api_key" mapstructure:"api_key" yaml:"api_key"`
-	AzureOpenAI AzureOpenAIConfig     `json:"azure_openai" mapstructure:"azure_openai" yaml:"azure_openai"`
-	AWSBedrock  AWSBedrockConfig      `json:"aws_bedrock" mapstructure:"aws_bedrock" yaml:"aws_bedrock"`
+	Type AiGatewayProviderType `json:"type" mapstructure:"type" yaml:"type"`
+	// ApiKey is the key of the provider, for workers-ai it is a cloudflare api token with Workers AI read permission
+	ApiKey      string            `json:"api_key" mapstructure:"api_key" yaml:"api_key"`
+	AzureOpenAI AzureOpenAIConfig `json:"azure_openai" mapstructure:"azure_openai" yaml:"azure_openai"`
+	AWSBedrock  AWSBedrockConfig  `json:"aws_bedrock" mapstructure:"aws_bedrock" yaml:"aws_bedrock"`
+	Replicate   ReplicateConfig   `json:"replicate" mapstructure:"replicate" yaml:"replicate"`
+	// ChatTemplate is a jinja chat template rendering messages into the inputs of huggingface models,
+	// default is the llama 2 chat format
+	ChatTemplate string `json:"chat_template" mapstructure:"chat_template" yaml:"chat_template"`
 }
 
 type AiGatewayConfig struct {
@@ -151,6 +431,12 @@ type AiGatewayConfig struct {
 	Name string `json:"name" mapstructure:"name" yaml:"name"`
 	// Provider is the provider type of AI Gateway
 	Provider AiGatewayProvider `json:"provider" mapstructure:"provider" yaml:"provider"`
+	// Host is the host of the gateway, default is AIGatewayHost
+	Host string `json:"host" mapstructure:"host" yaml:"host"`
+	// SkipCache sends cf-skip-cache, so responses are never served from the gateway cache
+	SkipCache bool `json:"skip_cache" mapstructure:"skip_cache" yaml:"skip_cache"`
+	// CacheTTL sends cf-cache-ttl, zero uses the ttl of the gateway settings
+	CacheTTL time.Duration `json:"cache_ttl" mapstructure:"cache_ttl" yaml:"cache_ttl"`
 }
 
 func (c *AiGatewayConfig) validate() error {
@@ -164,10 +450,15 @@ func (c *AiGatewayConfig) validate() error {
 		return fmt.Errorf("aigateway.provider.type is required")
 	}
 	switch c.Provider.Type {
-	case AiGatewayProviderOpenAI, AiGatewayProviderHuggingFace, AiGatewayProviderReplicate:
+	case AiGatewayProviderOpenAI, AiGatewayProviderHuggingFace, AiGatewayProviderWorkersAI:
+		if c.Provider.ApiKey == "" {
+			return fmt.Errorf("aigateway.provider.api_key is required")
+		}
+	case AiGatewayProviderReplicate:
 		if c.Provider.ApiKey == "" {
 			return fmt.Errorf("aigateway.provider.api_key is required")
 		}
+		return c.Provider.Replicate.validate()
 	case AiGatewayProviderAzureOpenAI:
 		return c.Provider.AzureOpenAI.validate()
 	case AiGatewayProviderAWSBedrock:
@@ -176,12 +467,23 @@ func (c *AiGatewayConfig) validate() error {
 	return nil
 }
 
+// GetProviderURL returns the url of the provider behind the gateway
+func (c *AiGatewayConfig) GetProviderURL() string {
+	host := AIGatewayHost
+	if c.Host != "" {
+		host = strings.TrimSuffix(c.Host, "/")
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", host, c.AccountId, c.Name, c.Provider.Type)
+}
+
 func (c *AiGatewayConfig) GetChatURL(model string) string {
-	baseUrl := fmt.Sprintf("%s/%s/%s/%s", AIGatewayHost, c.AccountId, c.Name, c.Provider.Type)
+	baseUrl := c.GetProviderURL()
 	switch c.Provider.Type {
-	case AiGatewayProviderOpenAI, AiGatewayProviderHuggingFace, AiGatewayProviderReplicate:
+	case AiGatewayProviderOpenAI:
 		return fmt.Sprintf("%s/chat/completions", baseUrl)
-	case AiGatewayProviderWorkersAI:
+	case AiGatewayProviderReplicate:
+		return fmt.Sprintf("%s/predictions", baseUrl)
+	case AiGatewayProviderWorkersAI, AiGatewayProviderHuggingFace:
 		return fmt.Sprintf("%s/%s", baseUrl, model)
 	case AiGatewayProviderAzureOpenAI:
 		az := c.Provider.AzureOpenAI
@@ -193,6 +495,15 @@ func (c *AiGatewayConfig) GetChatURL(model string) string {
 	return ""
 }
 
+// GetStreamURL returns the url of streaming chats, only bedrock has a different one
+func (c *AiGatewayConfig) GetStreamURL(model string) string {
+	if c.Provider.Type == AiGatewayProviderAWSBedrock {
+		ab := c.Provider.AWSBedrock
+		return fmt.Sprintf("%s/bedrock-runtime/%s/model/%s/invoke-with-response-stream", c.GetProviderURL(), ab.Region, model)
+	}
+	return c.GetChatURL(model)
+}
+
 func (c AiGatewayConfig) GetAuthHeader() map[string]string {
 	switch c.Provider.Type {
 	case AiGatewayProviderOpenAI, AiGatewayProviderHuggingFace, AiGatewayProviderWorkersAI:
@@ -207,12 +518,8 @@ func (c AiGatewayConfig) GetAuthHeader() map[string]string {
 		return map[string]string{
 			"api-key": c.Provider.AzureOpenAI.ApiKey,
 		}
-	case AiGatewayProviderAWSBedrock:
-		ab := c.Provider.AWSBedrock
-		return map[string]string{
-			"Authorization": fmt.Sprintf("Basic %s %s", ab.AccessKey, ab.SecretKey),
-		}
 	}
+	// aws bedrock requests are signed with sigv4 instead
 	return nil
 }
 
@@ -220,7 +527,16 @@ func (c AiGatewayConfig) ListModels() []string {
 	models := make([]string, 0)
 	switch c.Provider.Type {
 	case AiGatewayProviderWorkersAI:
-		// TODO: get models from workers.ai
+		// the client discovers the current text generation models, these are used when discovery fails
+		return DefaultWorkersAIModels
+	case AiGatewayProviderReplicate:
+		for _, m := range c.Provider.Replicate.Models {
+			if m.Name != "" {
+				models = append(models, m.Name)
+			} else {
+				models = append(models, m.Version)
+			}
+		}
 		return models
 	case AiGatewayProviderAzureOpenAI:
 		for k := range c.Provider.AzureOpenAI.ModelDeploymentMapping {
@@ -241,11 +557,17 @@ var DefaultOpenAIChatModels = []string{
 	"gpt-3.5-turbo-1106", "gpt-3.5-turbo", "gpt-3.5-turbo-16k", "gpt-3.5-turbo-instruct", "gpt-3.5-turbo-0613", "gpt-3.5-turbo-16k-0613", "gpt-3.5-turbo-0301",
 }
 
+// DefaultAwsBedrockModels are the text models of the families supported by awsbedrock,
+// they are served when no models are configured.
 var DefaultAwsBedrockModels = []string{
-	// "ai21.2-mid-v1", "ai21.2-ultra-v1",
-	// "amazon.titan-embed-text-v1", "amazon.titan-text-express-v1", "amazon.titan-embed-image-v1", "amazon.titan-image-generator-v1",
 	"anthropic.claude-v1", "anthropic.claude-v2", "anthropic.claude-v2:1", "anthropic.claude-instant-v1",
-	// "cohere.command-text-v14", "cohere.command-light-text-v14", "cohere.embed-english-v3", "cohere.embed-multilingual-v3",
-	// "meta.llama2-13b-chat-v1", "metallama2-70b-chat-v1",
-	// "stability.stable-diffusion-xl-vo", "stability.stable-diffusion-xL-v1",
+	"meta.llama2-13b-chat-v1", "meta.llama2-70b-chat-v1",
+	"amazon.titan-text-lite-v1", "amazon.titan-text-express-v1",
+	"cohere.command-text-v14", "cohere.command-light-text-v14",
+	"ai21.j2-mid-v1", "ai21.j2-ultra-v1",
+}
+
+var DefaultWorkersAIModels = []string{
+	"@cf/meta/llama-2-7b-chat-fp16", "@cf/meta/llama-2-7b-chat-int8", "@cf/mistral/mistral-7b-instruct-v0.1",
+	"@hf/thebloke/codellama-7b-instruct-awq", "@hf/thebloke/zephyr-7b-beta-awq", "@hf/thebloke/openhermes-2.5-mistral-7b-awq",
 }
@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxErrorBodySize limits how much of the upstream error body is kept
+const maxErrorBodySize = 4 << 10
+
+// HTTPError is returned by providers when upstream responds with an unexpected status code.
+type HTTPError struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+// NewHTTPError reads the error body of resp, the caller still has to close it.
+func NewHTTPError(resp *http.Response) *HTTPError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       string(body),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("upstream response status %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+func (e *HTTPError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// RetryAfter returns how long upstream asked to wait before retrying, zero if it did not.
+// Retry-After, retry-after-ms and the x-ratelimit-reset headers are supported.
+func (e *HTTPError) RetryAfter() time.Duration {
+	return parseRetryAfter(e.Header, time.Now())
+}
+
+// StatusCode returns the http status code carried by err, it works for HTTPError
+// and every error with a HTTPStatusCode method, like the errors of the aws sdk.
+func StatusCode(err error) (int, bool) {
+	var coder interface{ HTTPStatusCode() int }
+	if errors.As(err, &coder) {
+		return coder.HTTPStatusCode(), true
+	}
+	return 0, false
+}
+
+// RetryAfter returns the wait upstream asked for in err, zero if there is none.
+func RetryAfter(err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.RetryAfter()
+	}
+	return 0
+}
+
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if header == nil {
+		return 0
+	}
+	if v := header.Get("retry-after-ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	if v := header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+
+	// the longest reset wins, a request is throttled until all of them are reset
+	var wait time.Duration
+	for _, key := range []string{"x-ratelimit-reset", "x-ratelimit-reset-requests", "x-ratelimit-reset-tokens"} {
+		if d := parseReset(header.Get(key), now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// parseReset parses a rate limit reset, which is a duration like 6m0s,
+// seconds to wait, a unix timestamp or a RFC3339 time depending on the provider.
+func parseReset(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return d
+	}
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		// values larger than a year of seconds are unix timestamps
+		if n > 365*24*60*60 {
+			return time.Unix(int64(n), 0).Sub(now)
+		}
+		return time.Duration(n * float64(time.Second))
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,194 @@
+package githubcopilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+const (
+	// githubClientId is the oauth app of the copilot plugins, copilot tokens are only issued to its logins
+	githubClientId       = "Iv1.b507a08c87ecfe98"
+	githubDeviceCodeURL  = "https://github.com/login/device/code"
+	githubAccessTokenURL = "https://github.com/login/oauth/access_token"
+)
+
+var ErrNotLoggedIn = errors.New("github copilot is not logged in")
+
+// TokenStore keeps the github tokens of device flow logins by account
+type TokenStore interface {
+	// GetToken returns ErrNotLoggedIn when the account has no token
+	GetToken(ctx context.Context, account string) (string, error)
+	SaveToken(ctx context.Context, account, token string) error
+}
+
+// DefaultTokenStore is used by clients without api key, the server sets it to a PocketBase store
+var DefaultTokenStore TokenStore
+
+// DeviceCode is the code shown to users, they enter UserCode on VerificationUri to authorize the login.
+type DeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationUri string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// DeviceFlow logs in github by the oauth device authorization flow
+type DeviceFlow struct {
+	session        *http.Client
+	deviceCodeUrl  string
+	accessTokenUrl string
+}
+
+func NewDeviceFlow() *DeviceFlow {
+	return &DeviceFlow{
+		session:        http.DefaultClient,
+		deviceCodeUrl:  githubDeviceCodeURL,
+		accessTokenUrl: githubAccessTokenURL,
+	}
+}
+
+// Start requests the device code of a new login
+func (f *DeviceFlow) Start(ctx context.Context) (DeviceCode, error) {
+	var code DeviceCode
+	err := f.post(ctx, f.deviceCodeUrl, url.Values{
+		"client_id": {githubClientId},
+		"scope":     {"read:user"},
+	}, &code)
+	if err != nil {
+		return DeviceCode{}, fmt.Errorf("request github device code error: %w", err)
+	}
+	if code.Interval <= 0 {
+		code.Interval = 5
+	}
+	return code, nil
+}
+
+// Wait polls the github token until the user authorized the device code, it is denied or expired.
+func (f *DeviceFlow) Wait(ctx context.Context, code DeviceCode) (string, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(code.ExpiresIn)*time.Second)
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("wait github authorization error: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+
+		var resp struct {
+			AccessToken string `json:"access_token"`
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+			Interval    int    `json:"interval"`
+		}
+		err := f.post(ctx, f.accessTokenUrl, url.Values{
+			"client_id":   {githubClientId},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		}, &resp)
+		if err != nil {
+			return "", fmt.Errorf("get github token error: %w", err)
+		}
+
+		switch resp.Error {
+		case "":
+			return resp.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval = time.Duration(resp.Interval) * time.Second
+			if interval <= 0 {
+				interval = time.Duration(code.Interval+5) * time.Second
+			}
+		default:
+			return "", fmt.Errorf("github authorization error: %s, %s", resp.Error, resp.Description)
+		}
+	}
+}
+
+func (f *DeviceFlow) post(ctx context.Context, u string, form url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := f.session.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return llm.NewHTTPError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// FileTokenStore keeps the tokens in a json file, it is used by the cli which has no database.
+type FileTokenStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+func (s *FileTokenStore) GetToken(ctx context.Context, account string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	token, ok := tokens[account]
+	if !ok {
+		return "", ErrNotLoggedIn
+	}
+	return token, nil
+}
+
+func (s *FileTokenStore) SaveToken(ctx context.Context, account, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	tokens[account] = token
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("create token dir error: %w", err)
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+func (s *FileTokenStore) load() (map[string]string, error) {
+	tokens := make(map[string]string)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read token file error: %w", err)
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("decode token file error: %w", err)
+	}
+	return tokens, nil
+}
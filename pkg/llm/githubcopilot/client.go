@@ -6,40 +6,124 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/Vaayne/aienvoy/pkg/cache"
 	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
 	"github.com/Vaayne/aienvoy/pkg/session"
 )
 
 const (
-	copilotTokenURL = "https://api.github.com/copilot_internal/v2/token"
-	defaultChatURL  = "https://api.githubcopilot.com/chat/completions"
+	copilotTokenURL   = "https://api.github.com/copilot_internal/v2/token"
+	defaultCopilotAPI = "https://api.githubcopilot.com"
+	// tokenRefreshBefore refreshes copilot tokens before they expire, they are valid for about 30 minutes
+	tokenRefreshBefore = 5 * time.Minute
 )
 
+var DefaultModels = []string{"gpt-3.5-turbo", "gpt-4"}
+
+// copilotToken is the session token of copilot, it is exchanged from the github token
+type copilotToken struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+	Endpoints struct {
+		Api string `json:"api"`
+	} `json:"endpoints"`
+}
+
+func (t copilotToken) expiresAt() time.Time {
+	return time.Unix(t.ExpiresAt, 0)
+}
+
 type Client struct {
-	session *session.Session
-	apiKey  string
-	chatUrl string
+	session  *session.Session
+	config   llmconfig.Config
+	tokenUrl string
+	// store overrides DefaultTokenStore
+	store TokenStore
+
+	mu     sync.Mutex
+	token  copilotToken
+	models []string
+	// refreshMu lets one call refresh the token while the others wait for it
+	refreshMu sync.Mutex
 }
 
-func NewClient(apiKey string) *Client {
-	return &Client{
-		session: session.New(),
-		apiKey:  apiKey,
-		chatUrl: defaultChatURL,
+func NewClient(cfg llmconfig.Config) (*Client, error) {
+	if cfg.LLMType != llmconfig.LLMTypeGithubCopilot {
+		return nil, fmt.Errorf("invalid config for github copilot, llmtype: %s", cfg.LLMType)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
+	return &Client{
+		session:  session.New(),
+		config:   cfg,
+		tokenUrl: copilotTokenURL,
+		models:   cfg.Models,
+	}, nil
+}
+
+// WithTokenStore uses store instead of DefaultTokenStore for the github token
+func (c *Client) WithTokenStore(store TokenStore) *Client {
+	c.store = store
+	return c
 }
 
+// ListModels returns the configured models, or else the chat models of the copilot models endpoint.
+// Models are discovered again on the next call when copilot is not logged in yet.
 func (c *Client) ListModels() []string {
-	return []string{"gpt-3.5-turbo", "gpt-4"}
+	c.mu.Lock()
+	models := c.models
+	c.mu.Unlock()
+	if len(models) > 0 {
+		return models
+	}
+
+	models, err := c.discoverModels(context.Background())
+	if err != nil {
+		slog.Error("discover github copilot models error", "err", err)
+		return DefaultModels
+	}
+	c.mu.Lock()
+	c.models = models
+	c.mu.Unlock()
+	return models
+}
+
+func (c *Client) discoverModels(ctx context.Context) ([]string, error) {
+	token, err := c.copilotToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiUrl(token)+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = c.buildHeaders(token.Token)
+	resp, err := c.session.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, llm.NewHTTPError(resp)
+	}
+
+	var modelsResp ModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		return nil, fmt.Errorf("decode models response error: %w", err)
+	}
+	models := modelsResp.ChatModels()
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no chat models found")
+	}
+	return models, nil
 }
 
 func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
@@ -47,18 +131,17 @@ func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCom
 	cReq.FromChatCompletionRequest(req)
 	body, _ := json.Marshal(cReq)
 
-	hReq, err := http.NewRequest(http.MethodPost, c.chatUrl, bytes.NewReader(body))
+	token, err := c.copilotToken(ctx)
 	if err != nil {
-		errChan <- fmt.Errorf("create chat completion stream error: %w", err)
+		errChan <- err
 		return
 	}
-
-	copilotToken, err := c.getCopilotToken(c.apiKey)
+	hReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiUrl(token)+"/chat/completions", bytes.NewReader(body))
 	if err != nil {
-		errChan <- err
+		errChan <- fmt.Errorf("create chat completion stream error: %w", err)
 		return
 	}
-	hReq.Header = c.buildHeaders(copilotToken)
+	hReq.Header = c.buildHeaders(token.Token)
 	resp, err := c.session.Do(hReq)
 	if err != nil {
 		errChan <- fmt.Errorf("create chat completion stream error: %w", err)
@@ -66,81 +149,106 @@ func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCom
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		errChan <- fmt.Errorf("copilot response error: %s", resp.Status)
+		errChan <- fmt.Errorf("copilot response error: %w", llm.NewHTTPError(resp))
 		return
 	}
 
 	llm.ParseSSE(resp.Body, dataChan, errChan)
 }
 
+// CreateChatCompletion accumulates the stream, copilot only answers in streams
 func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
-	dataChan := make(chan llm.ChatCompletionStreamResponse)
-	defer close(dataChan)
-	errChan := make(chan error)
-	defer close(errChan)
-
-	go c.CreateChatCompletionStream(ctx, req, dataChan, errChan)
-	sb := strings.Builder{}
-	resp := llm.ChatCompletionResponse{}
-
-	for {
-		select {
-		case data := <-dataChan:
-			if len(data.Choices) == 0 {
-				continue
-			}
-			resp = data.ToChatCompletionResponse()
-		case err := <-errChan:
-			if errors.Is(err, io.EOF) {
-				resp.Choices[0].Message.Content = sb.String()
-				return resp, nil
-			}
-			slog.Error("\nerr", "err", err)
-			return llm.ChatCompletionResponse{}, err
+	return llm.CompleteStream(ctx, req, c.CreateChatCompletionStream)
+}
+
+// apiUrl is the api of the copilot plan of the account, base_url of the config takes precedence
+func (c *Client) apiUrl(token copilotToken) string {
+	if c.config.BaseUrl != "" {
+		return strings.TrimSuffix(c.config.BaseUrl, "/")
+	}
+	if token.Endpoints.Api != "" {
+		return strings.TrimSuffix(token.Endpoints.Api, "/")
+	}
+	return defaultCopilotAPI
+}
+
+// githubToken returns the api key of the config, or else the token of the device flow login
+func (c *Client) githubToken(ctx context.Context) (string, error) {
+	if c.config.ApiKey != "" {
+		return c.config.ApiKey, nil
+	}
+	store := c.store
+	if store == nil {
+		store = DefaultTokenStore
+	}
+	if store == nil {
+		return "", ErrNotLoggedIn
+	}
+	return store.GetToken(ctx, c.config.GithubCopilot.GetAccount())
+}
+
+// copilotToken returns the cached copilot token, it is refreshed by the first call near its expiry.
+// A failed refresh keeps using the current token until it expires.
+func (c *Client) copilotToken(ctx context.Context) (copilotToken, error) {
+	if token, ok := c.cachedToken(); ok {
+		return token, nil
+	}
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if token, ok := c.cachedToken(); ok {
+		return token, nil
+	}
+
+	token, err := c.refreshToken(ctx)
+	if err != nil {
+		c.mu.Lock()
+		current := c.token
+		c.mu.Unlock()
+		if current.Token != "" && time.Now().Before(current.expiresAt()) {
+			slog.WarnContext(ctx, "refresh github copilot token error, use the current token", "err", err)
+			return current, nil
 		}
+		return copilotToken{}, err
 	}
+	return token, nil
+}
+
+// cachedToken returns the copilot token when it is not about to expire
+func (c *Client) cachedToken() (copilotToken, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token, c.token.Token != "" && time.Until(c.token.expiresAt()) > tokenRefreshBefore
 }
 
-// getCopilotToken retrieves a token for GitHub Copilot.
-func (c *Client) getCopilotToken(githubToken string) (string, error) {
-	// Try to get the token from the cache.
-	token, ok := cache.DefaultClient.Get(githubToken)
-	if ok {
-		// If the token is in the cache, return it.
-		return token.(string), nil
+func (c *Client) refreshToken(ctx context.Context) (copilotToken, error) {
+	githubToken, err := c.githubToken(ctx)
+	if err != nil {
+		return copilotToken{}, fmt.Errorf("get github token error: %w", err)
 	}
 
-	// Create a new HTTP request to get the Copilot token.
-	req, _ := http.NewRequest("GET", copilotTokenURL, nil)
-	// Set the Authorization header with the GitHub token.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.tokenUrl, nil)
+	if err != nil {
+		return copilotToken{}, fmt.Errorf("get copilot token error: %w", err)
+	}
 	req.Header.Set("Authorization", "token "+githubToken)
-	// Send the request.
-	response, err := c.session.Do(req)
-	if err != nil || response.StatusCode != 200 {
-		// If there's an error or the status code is not 200, return an error.
-		return "", fmt.Errorf("get copilot token error: %w", err)
-	}
-	// Ensure the response body is closed after the function returns.
-	defer response.Body.Close()
-	// Read the response body.
-	body, _ := io.ReadAll(response.Body)
-	// Define a struct to hold the Copilot token and its expiration time.
-	var copilotToken struct {
-		Token     string `json:"token"`
-		ExpiresAt int64  `json:"expires_at"`
-	}
-
-	// Unmarshal the JSON response into the copilotToken struct.
-	if err = json.Unmarshal(body, &copilotToken); err != nil {
-		// If there's an error unmarshalling, return an error.
-		return "", fmt.Errorf("get copilot token error: %w", err)
-	}
-	// Convert the expiration time from Unix timestamp to time.Time, subtracting 500 seconds as a buffer.
-	expirationTime := time.Unix(copilotToken.ExpiresAt-500, 0)
-	// Set the token in the cache, with the expiration time.
-	cache.DefaultClient.Set(githubToken, copilotToken.Token, time.Until(expirationTime))
-	// Return the Copilot token.
-	return copilotToken.Token, nil
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.session.Do(req)
+	if err != nil {
+		return copilotToken{}, fmt.Errorf("get copilot token error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return copilotToken{}, fmt.Errorf("get copilot token error: %w", llm.NewHTTPError(resp))
+	}
+
+	var token copilotToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return copilotToken{}, fmt.Errorf("decode copilot token error: %w", err)
+	}
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+	return token, nil
 }
 
 func (c *Client) buildHeaders(copilotToken string) http.Header {
@@ -162,10 +270,9 @@ func (c *Client) buildHeaders(copilotToken string) http.Header {
 	headers.Set("Editor-Plugin-Version", "copilot-chat/0.8.0")
 	headers.Set("Openai-Organization", "github-copilot")
 	headers.Set("Openai-Intent", "conversation-panel")
-	headers.Set("Content-Type", "text/event-stream; charset=utf-8")
+	headers.Set("Content-Type", "application/json")
 	headers.Set("User-Agent", "GitHubCopilotChat/0.8.0")
 	headers.Set("Accept", "*/*")
-	headers.Set("Accept-Encoding", "gzip,deflate,br")
-	headers.Set("Connection", "close")
+	// Accept-Encoding is left to the transport, which only decompresses the responses of its own header
 	return headers
 }
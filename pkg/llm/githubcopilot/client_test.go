@@ -0,0 +1,136 @@
+package githubcopilot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, tokenCalls *int) *httptest.Server {
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			*tokenCalls++
+			assert.Equal(t, "token gho_xxx", r.Header.Get("Authorization"))
+			_, _ = fmt.Fprintf(w, `{"token": "tid=1", "expires_at": %d, "endpoints": {"api": %q}}`, time.Now().Add(30*time.Minute).Unix(), s.URL)
+		case "/models":
+			assert.Equal(t, "Bearer tid=1", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"data": [
+				{"id": "gpt-4", "capabilities": {"type": "chat"}},
+				{"id": "gpt-4", "capabilities": {"type": "chat"}},
+				{"id": "gpt-4o", "capabilities": {"type": "chat"}},
+				{"id": "text-embedding-ada-002", "capabilities": {"type": "embeddings"}}
+			]}`))
+		case "/chat/completions":
+			assert.Equal(t, "Bearer tid=1", r.Header.Get("Authorization"))
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"choices\":[],\"prompt_filter_results\":[]}\n\n")
+			_, _ = fmt.Fprint(w, "data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hello\"}}]}\n\n")
+			_, _ = fmt.Fprint(w, "data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" world\"},\"finish_reason\":\"stop\"}]}\n\n")
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return s
+}
+
+func newTestClient(t *testing.T, s *httptest.Server, store TokenStore) *Client {
+	client, err := NewClient(llmconfig.Config{LLMType: llmconfig.LLMTypeGithubCopilot})
+	require.NoError(t, err)
+	client.tokenUrl = s.URL + "/token"
+	return client.WithTokenStore(store)
+}
+
+func TestClientWithStoredLogin(t *testing.T) {
+	var tokenCalls int
+	s := newTestServer(t, &tokenCalls)
+	defer s.Close()
+
+	store := NewFileTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	client := newTestClient(t, s, store)
+
+	// without login the default models are listed and calls fail
+	assert.Equal(t, DefaultModels, client.ListModels())
+	_, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "gpt-4"})
+	assert.ErrorIs(t, err, ErrNotLoggedIn)
+
+	require.NoError(t, store.SaveToken(context.Background(), llmconfig.DefaultGithubCopilotAccount, "gho_xxx"))
+	assert.Equal(t, []string{"gpt-4", "gpt-4o"}, client.ListModels())
+
+	resp, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "gpt-4"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world", resp.Choices[0].Message.Content)
+	assert.Equal(t, llm.FinishReasonStop, resp.Choices[0].FinishReason)
+	// the copilot token is cached
+	assert.Equal(t, 1, tokenCalls)
+
+	// tokens are refreshed by the first call near their expiry
+	client.mu.Lock()
+	client.token.ExpiresAt = time.Now().Add(time.Minute).Unix()
+	client.mu.Unlock()
+	_, err = client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "gpt-4"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, tokenCalls)
+
+	// a failed refresh keeps using the current token until it expires
+	client.mu.Lock()
+	client.token.ExpiresAt = time.Now().Add(time.Minute).Unix()
+	client.mu.Unlock()
+	client.tokenUrl = s.URL + "/missing"
+	_, err = client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "gpt-4"})
+	require.NoError(t, err)
+}
+
+func TestDeviceFlow(t *testing.T) {
+	var polls int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, githubClientId, r.Form.Get("client_id"))
+		switch r.URL.Path {
+		case "/device/code":
+			_, _ = w.Write([]byte(`{"device_code": "dc", "user_code": "ABCD-1234", "verification_uri": "https://github.com/login/device", "expires_in": 900, "interval": 5}`))
+		case "/access_token":
+			assert.Equal(t, "dc", r.Form.Get("device_code"))
+			polls++
+			if polls == 1 {
+				_, _ = w.Write([]byte(`{"error": "authorization_pending"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token": "gho_xxx", "token_type": "bearer"}`))
+		}
+	}))
+	defer s.Close()
+
+	flow := &DeviceFlow{session: http.DefaultClient, deviceCodeUrl: s.URL + "/device/code", accessTokenUrl: s.URL + "/access_token"}
+	code, err := flow.Start(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ABCD-1234", code.UserCode)
+
+	code.Interval = 0
+	token, err := flow.Wait(context.Background(), code)
+	require.NoError(t, err)
+	assert.Equal(t, "gho_xxx", token)
+	assert.Equal(t, 2, polls)
+}
+
+func TestDeviceFlowDenied(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"error": "access_denied", "error_description": "The authorization request was denied."}`))
+	}))
+	defer s.Close()
+
+	flow := &DeviceFlow{session: http.DefaultClient, accessTokenUrl: s.URL}
+	_, err := flow.Wait(context.Background(), DeviceCode{DeviceCode: "dc", ExpiresIn: 10})
+	assert.ErrorContains(t, err, "access_denied")
+}
@@ -10,10 +10,11 @@ type GitHubCopilot struct {
 }
 
 func New(cfg llmconfig.Config, dao llm.Dao) (*GitHubCopilot, error) {
-	if err := cfg.Validate(); err != nil {
+	client, err := NewClient(cfg)
+	if err != nil {
 		return nil, err
 	}
 	return &GitHubCopilot{
-		LLM: llm.New(dao, NewClient(cfg.ApiKey)),
+		LLM: llm.New(dao, client),
 	}, nil
 }
@@ -1,6 +1,10 @@
 package githubcopilot
 
-import "github.com/Vaayne/aienvoy/pkg/llm"
+import (
+	"slices"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
 
 type Request struct {
 	Model         string                      `json:"model"`
@@ -25,3 +29,25 @@ func (r *Request) FromChatCompletionRequest(req llm.ChatCompletionRequest) {
 	r.OneTimeReturn = true
 	r.MaxTokens = 2048
 }
+
+// ModelsResponse is the response of the copilot models endpoint
+type ModelsResponse struct {
+	Data []struct {
+		Id           string `json:"id"`
+		Capabilities struct {
+			Type string `json:"type"`
+		} `json:"capabilities"`
+	} `json:"data"`
+}
+
+// ChatModels returns the unique ids of the chat models, embedding models are skipped
+func (r ModelsResponse) ChatModels() []string {
+	models := make([]string, 0, len(r.Data))
+	for _, m := range r.Data {
+		if m.Capabilities.Type != "chat" || slices.Contains(models, m.Id) {
+			continue
+		}
+		models = append(models, m.Id)
+	}
+	return models
+}
@@ -32,7 +32,7 @@ func (c *Client) ListModels() []string {
 
 func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
 	reqBody := ChatRequest{}.FromChatCompletionRequest(req)
-	chatResp, err := c.post(req.Model, reqBody, false)
+	chatResp, err := c.post(ctx, req.Model, reqBody, false)
 	if err != nil {
 		return llm.ChatCompletionResponse{}, fmt.Errorf("chat with %s error: %w", req.Model, err)
 	}
@@ -41,7 +41,7 @@ func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletio
 
 func (p *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
 	reqBody := ChatRequest{}.FromChatCompletionRequest(req)
-	chatResp, err := p.post(req.Model, reqBody, true)
+	chatResp, err := p.post(ctx, req.Model, reqBody, true)
 	if err != nil {
 		errChan <- fmt.Errorf("chat with %s error: %w", req.Model, err)
 		return
@@ -50,7 +50,7 @@ func (p *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCom
 	errChan <- io.EOF
 }
 
-func (c *Client) post(model string, body ChatRequest, stream bool) (ChatResponse, error) {
+func (c *Client) post(ctx context.Context, model string, body ChatRequest, stream bool) (ChatResponse, error) {
 	respBody := ChatResponse{}
 
 	reqBody, err := json.Marshal(body)
@@ -62,12 +62,14 @@ func (c *Client) post(model string, body ChatRequest, stream bool) (ChatResponse
 	// if stream {
 	// 	action = "streamGenerateContent"
 	// }
-	url := fmt.Sprintf("%s/v1beta/models/%s:%s?key=%s", defaultHost, model, action, c.apiKey)
-	req, err := http.NewRequest("POST", url, bytes.NewReader(reqBody))
+	url := fmt.Sprintf("%s/v1beta/models/%s:%s", defaultHost, model, action)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
 	if err != nil {
 		return respBody, fmt.Errorf("create request error: %w", err)
 	}
 
+	// send api key by header instead of query, so it never shows up in url logs
+	req.Header.Set("x-goog-api-key", c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.sess.Do(req)
 	if err != nil {
@@ -76,11 +78,7 @@ func (c *Client) post(model string, body ChatRequest, stream bool) (ChatResponse
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var data any
-		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-			return respBody, fmt.Errorf("decode response error: %w", err)
-		}
-		return respBody, fmt.Errorf("response status code %d, error: %s", resp.StatusCode, data)
+		return respBody, llm.NewHTTPError(resp)
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
 		return respBody, fmt.Errorf("decode response error: %w", err)
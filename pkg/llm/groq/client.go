@@ -0,0 +1,173 @@
+package groq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+const defaultBaseUrl = "https://api.groq.com/openai/v1"
+
+// DefaultModels are served when no models are configured and the models can not be listed
+var DefaultModels = []string{
+	"llama3-8b-8192",
+	"llama3-70b-8192",
+	"mixtral-8x7b-32768",
+	"gemma-7b-it",
+}
+
+type Client struct {
+	session *http.Client
+	baseUrl string
+	config  llmconfig.Config
+
+	mu     sync.Mutex
+	models []string
+}
+
+func NewClient(cfg llmconfig.Config) (*Client, error) {
+	if cfg.LLMType != llmconfig.LLMTypeGroq {
+		return nil, fmt.Errorf("invalid config for groq, llmtype: %s", cfg.LLMType)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		session: http.DefaultClient,
+		baseUrl: defaultBaseUrl,
+		config:  cfg,
+	}
+	if cfg.BaseUrl != "" {
+		client.baseUrl = strings.TrimSuffix(cfg.BaseUrl, "/")
+	}
+	return client, nil
+}
+
+func (c *Client) WithSession(session *http.Client) *Client {
+	c.session = session
+	return c
+}
+
+// ListModels returns the configured models, without them the models of the account are listed once.
+func (c *Client) ListModels() []string {
+	if len(c.config.Models) > 0 {
+		return c.config.Models
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.models != nil {
+		return c.models
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	models, err := c.listModels(ctx)
+	if err != nil {
+		slog.Error("list groq models error", "err", err)
+		return DefaultModels
+	}
+	c.models = models
+	return models
+}
+
+func (c *Client) listModels(ctx context.Context) ([]string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var modelsResp ModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		return nil, fmt.Errorf("decode groq models error: %w", err)
+	}
+	models := make([]string, 0, len(modelsResp.Data))
+	for _, model := range modelsResp.Data {
+		// whisper models can not chat
+		if !model.Active || strings.HasPrefix(model.Id, "whisper") {
+			continue
+		}
+		models = append(models, model.Id)
+	}
+	return models, nil
+}
+
+func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	req.Stream = false
+	resp, err := c.chat(ctx, req)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var chatResp ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion decode response error: %w", err)
+	}
+	return chatResp.ToChatCompletionResponse(), nil
+}
+
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
+	req.Stream = true
+	resp, err := c.chat(ctx, req)
+	if err != nil {
+		errChan <- fmt.Errorf("create chat completion stream error: %w", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	innerDataChan := make(chan ChatResponse)
+	innerErrChan := make(chan error)
+	go llm.ParseSSE(resp.Body, innerDataChan, innerErrChan)
+	for {
+		select {
+		case data := <-innerDataChan:
+			if data.Error != nil {
+				errChan <- fmt.Errorf("groq chat stream error: %s", data.Error.Message)
+				return
+			}
+			dataChan <- data.ToChatCompletionStreamResponse()
+		case err := <-innerErrChan:
+			errChan <- err
+			return
+		}
+	}
+}
+
+func (c *Client) chat(ctx context.Context, req llm.ChatCompletionRequest) (*http.Response, error) {
+	chatReq := ChatRequest{}.FromChatCompletionRequest(req)
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal groq chat request error: %w", err)
+	}
+	return c.do(ctx, http.MethodPost, "/chat/completions", body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseUrl+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request error: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.ApiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.session.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, llm.NewHTTPError(resp)
+	}
+	return resp, nil
+}
@@ -0,0 +1,20 @@
+package groq
+
+import (
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+type Groq struct {
+	*llm.LLM
+}
+
+func New(cfg llmconfig.Config, dao llm.Dao) (*Groq, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Groq{
+		llm.New(dao, client),
+	}, nil
+}
@@ -0,0 +1,161 @@
+package groq
+
+import (
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatRequest struct {
+	Model            string        `json:"model"`
+	Messages         []ChatMessage `json:"messages"`
+	Temperature      float32       `json:"temperature,omitempty"`
+	TopP             float32       `json:"top_p,omitempty"`
+	MaxTokens        int           `json:"max_tokens,omitempty"`
+	Stop             []string      `json:"stop,omitempty"`
+	PresencePenalty  float32       `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float32       `json:"frequency_penalty,omitempty"`
+	User             string        `json:"user,omitempty"`
+	Stream           bool          `json:"stream"`
+}
+
+func (r ChatRequest) FromChatCompletionRequest(req llm.ChatCompletionRequest) ChatRequest {
+	r.Model = req.Model
+	r.Stream = req.Stream
+	r.Temperature = req.Temperature
+	r.TopP = req.TopP
+	r.MaxTokens = req.MaxTokens
+	r.Stop = req.Stop
+	r.PresencePenalty = req.PresencePenalty
+	r.FrequencyPenalty = req.FrequencyPenalty
+	r.User = req.User
+	r.Messages = make([]ChatMessage, 0, len(req.Messages))
+	for _, message := range req.Messages {
+		r.Messages = append(r.Messages, ChatMessage{
+			Role:    message.Role,
+			Content: message.Content,
+		})
+	}
+	return r
+}
+
+// Usage has the token counts and the timings in seconds of groq
+type Usage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	PromptTime       float64 `json:"prompt_time"`
+	CompletionTime   float64 `json:"completion_time"`
+	TotalTime        float64 `json:"total_time"`
+}
+
+func (u Usage) toUsage() llm.Usage {
+	return llm.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+// XGroq is the groq extension of responses, the usage of streams is only reported in it
+type XGroq struct {
+	Id    string `json:"id"`
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+type ChatChoice struct {
+	Index        int         `json:"index"`
+	Message      ChatMessage `json:"message"`
+	Delta        ChatMessage `json:"delta"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+// ChatResponse is the response of non stream chats and every chunk of stream chats
+type ChatResponse struct {
+	Id      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   *Usage       `json:"usage,omitempty"`
+	XGroq   *XGroq       `json:"x_groq,omitempty"`
+	// Error is set when the stream fails after it has started
+	Error *Error `json:"error,omitempty"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+func (r ChatResponse) usage() *Usage {
+	if r.Usage != nil {
+		return r.Usage
+	}
+	if r.XGroq != nil {
+		return r.XGroq.Usage
+	}
+	return nil
+}
+
+func (r ChatResponse) ToChatCompletionResponse() llm.ChatCompletionResponse {
+	resp := llm.ChatCompletionResponse{
+		ID:      r.Id,
+		Object:  r.Object,
+		Created: r.Created,
+		Model:   r.Model,
+		Choices: make([]llm.ChatCompletionChoice, 0, len(r.Choices)),
+	}
+	for _, choice := range r.Choices {
+		resp.Choices = append(resp.Choices, llm.ChatCompletionChoice{
+			Index: choice.Index,
+			Message: llm.ChatCompletionMessage{
+				Role:    llm.ChatMessageRoleAssistant,
+				Content: choice.Message.Content,
+			},
+			FinishReason: llm.FinishReason(choice.FinishReason),
+		})
+	}
+	if usage := r.usage(); usage != nil {
+		resp.Usage = usage.toUsage()
+	}
+	return resp
+}
+
+func (r ChatResponse) ToChatCompletionStreamResponse() llm.ChatCompletionStreamResponse {
+	resp := llm.ChatCompletionStreamResponse{
+		ID:      r.Id,
+		Object:  r.Object,
+		Created: r.Created,
+		Model:   r.Model,
+		Choices: make([]llm.ChatCompletionStreamChoice, 0, len(r.Choices)),
+	}
+	for _, choice := range r.Choices {
+		resp.Choices = append(resp.Choices, llm.ChatCompletionStreamChoice{
+			Index: choice.Index,
+			Delta: llm.ChatCompletionStreamChoiceDelta{
+				Role:    choice.Delta.Role,
+				Content: choice.Delta.Content,
+			},
+			FinishReason: llm.FinishReason(choice.FinishReason),
+		})
+	}
+	if usage := r.usage(); usage != nil {
+		u := usage.toUsage()
+		resp.Usage = &u
+	}
+	return resp
+}
+
+type ModelsResponse struct {
+	Data []struct {
+		Id            string `json:"id"`
+		Created       int64  `json:"created"`
+		OwnedBy       string `json:"owned_by"`
+		Active        bool   `json:"active"`
+		ContextWindow int    `json:"context_window"`
+	} `json:"data"`
+}
@@ -0,0 +1,166 @@
+package groq
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+func TestFromChatCompletionRequest(t *testing.T) {
+	req := llm.ChatCompletionRequest{
+		Model: "mixtral-8x7b-32768",
+		Messages: []llm.ChatCompletionMessage{
+			{Role: llm.ChatMessageRoleSystem, Content: "System message"},
+			{Role: llm.ChatMessageRoleUser, Content: "Hello"},
+		},
+		Stop:        []string{"stop"},
+		Temperature: 0.5,
+		TopP:        0.9,
+		MaxTokens:   100,
+		Stream:      true,
+	}
+
+	expected := ChatRequest{
+		Model: "mixtral-8x7b-32768",
+		Messages: []ChatMessage{
+			{Role: "system", Content: "System message"},
+			{Role: "user", Content: "Hello"},
+		},
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
+		Stream:      true,
+	}
+
+	result := ChatRequest{}.FromChatCompletionRequest(req)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("FromChatCompletionRequest() = %v, want %v", result, expected)
+	}
+}
+
+func TestToChatCompletionResponse(t *testing.T) {
+	data, err := os.ReadFile("testdata/chat_response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chatResp ChatResponse
+	if err := json.Unmarshal(data, &chatResp); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := llm.ChatCompletionResponse{
+		ID:      "chatcmpl-9d1a0b6e-5a4c-4f7e-8d1e-3f0a2e6c1b7d",
+		Object:  "chat.completion",
+		Created: 1709880132,
+		Model:   "mixtral-8x7b-32768",
+		Choices: []llm.ChatCompletionChoice{
+			{
+				Message: llm.ChatCompletionMessage{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: "Hello! How can I help you today?",
+				},
+				FinishReason: llm.FinishReasonStop,
+			},
+		},
+		Usage: llm.Usage{PromptTokens: 18, CompletionTokens: 10, TotalTokens: 28},
+	}
+
+	result := chatResp.ToChatCompletionResponse()
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ToChatCompletionResponse() = %v, want %v", result, expected)
+	}
+}
+
+// the usage of streams is only reported in x_groq of the last chunk
+func TestCreateChatCompletionStream(t *testing.T) {
+	data, err := os.ReadFile("testdata/chat_stream.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write(data)
+	}))
+	defer s.Close()
+
+	client, err := NewClient(llmconfig.Config{LLMType: llmconfig.LLMTypeGroq, ApiKey: "key", BaseUrl: s.URL + "/openai/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := llm.ChatCompletionRequest{
+		Model:    "mixtral-8x7b-32768",
+		Messages: []llm.ChatCompletionMessage{{Role: llm.ChatMessageRoleUser, Content: "Hello"}},
+	}
+	resp, err := llm.CompleteStream(context.Background(), req, client.CreateChatCompletionStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content := resp.Choices[0].Message.Content; content != "Hello!" {
+		t.Errorf("content = %q, want %q", content, "Hello!")
+	}
+	if reason := resp.Choices[0].FinishReason; reason != llm.FinishReasonStop {
+		t.Errorf("finish reason = %q, want %q", reason, llm.FinishReasonStop)
+	}
+	expected := llm.Usage{PromptTokens: 18, CompletionTokens: 2, TotalTokens: 20}
+	if !reflect.DeepEqual(resp.Usage, expected) {
+		t.Errorf("usage = %v, want %v", resp.Usage, expected)
+	}
+}
+
+func TestCreateChatCompletion(t *testing.T) {
+	data, err := os.ReadFile("testdata/chat_response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chatReq ChatRequest
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&chatReq); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}))
+	defer s.Close()
+
+	client, err := NewClient(llmconfig.Config{LLMType: llmconfig.LLMTypeGroq, ApiKey: "key", BaseUrl: s.URL + "/openai/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{
+		Model:    "mixtral-8x7b-32768",
+		Messages: []llm.ChatCompletionMessage{{Role: llm.ChatMessageRoleUser, Content: "Hello"}},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if chatReq.Stream || chatReq.Model != "mixtral-8x7b-32768" {
+		t.Errorf("model = %q, stream = %v, want %q, false", chatReq.Model, chatReq.Stream, "mixtral-8x7b-32768")
+	}
+	if content := resp.Choices[0].Message.Content; content != "Hello! How can I help you today?" {
+		t.Errorf("content = %q, want %q", content, "Hello! How can I help you today?")
+	}
+	expected := llm.Usage{PromptTokens: 18, CompletionTokens: 10, TotalTokens: 28}
+	if !reflect.DeepEqual(resp.Usage, expected) {
+		t.Errorf("usage = %v, want %v", resp.Usage, expected)
+	}
+}
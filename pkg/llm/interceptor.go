@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+)
+
+// UnaryInvoker invokes a chat completion.
+type UnaryInvoker func(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error)
+
+// StreamInvoker invokes a streaming chat completion, the stream ends with io.EOF on errChan.
+type StreamInvoker func(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, errChan chan error)
+
+// UnaryInterceptor intercepts a chat completion, it must call next to continue the chain.
+type UnaryInterceptor func(ctx context.Context, req ChatCompletionRequest, next UnaryInvoker) (ChatCompletionResponse, error)
+
+// StreamInterceptor intercepts a streaming chat completion, it must call next to continue the chain
+// and send exactly one error to errChan.
+type StreamInterceptor func(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, errChan chan error, next StreamInvoker)
+
+// Interceptor intercepts the calls of a Client, nil fields pass calls through.
+type Interceptor struct {
+	Unary  UnaryInterceptor
+	Stream StreamInterceptor
+}
+
+type interceptedClient struct {
+	Client
+	unary  UnaryInvoker
+	stream StreamInvoker
+}
+
+// Wrap returns a Client that runs every chat completion through interceptors,
+// the first interceptor is the outermost one. Embeddings are forwarded as is.
+func Wrap(c Client, interceptors ...Interceptor) Client {
+	if len(interceptors) == 0 {
+		return c
+	}
+
+	unary := UnaryInvoker(c.CreateChatCompletion)
+	stream := StreamInvoker(c.CreateChatCompletionStream)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		if interceptor := interceptors[i].Unary; interceptor != nil {
+			next := unary
+			unary = func(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+				return interceptor(ctx, req, next)
+			}
+		}
+		if interceptor := interceptors[i].Stream; interceptor != nil {
+			next := stream
+			stream = func(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, errChan chan error) {
+				interceptor(ctx, req, dataChan, errChan, next)
+			}
+		}
+	}
+
+	return &interceptedClient{
+		Client: c,
+		unary:  unary,
+		stream: stream,
+	}
+}
+
+func (c *interceptedClient) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	return c.unary(ctx, req)
+}
+
+func (c *interceptedClient) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, errChan chan error) {
+	c.stream(ctx, req, dataChan, errChan)
+}
+
+func (c *interceptedClient) CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	embedder, ok := c.Client.(Embedder)
+	if !ok {
+		return EmbeddingResponse{}, NotImplementError
+	}
+	return embedder.CreateEmbeddings(ctx, req)
+}
+
+func (c *interceptedClient) DescribeModels() []Model {
+	return DescribeModels(c.Client)
+}
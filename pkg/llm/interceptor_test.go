@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClient fails the first failures calls, streams send chunks before failing
+type fakeClient struct {
+	failures int
+	chunks   []string
+	calls    int
+}
+
+func (c *fakeClient) ListModels() []string {
+	return []string{"fake"}
+}
+
+func (c *fakeClient) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	c.calls++
+	if c.calls <= c.failures {
+		return ChatCompletionResponse{}, &HTTPError{StatusCode: http.StatusServiceUnavailable}
+	}
+	return ChatCompletionResponse{Choices: []ChatCompletionChoice{{Message: ChatCompletionMessage{Content: "ok"}}}}, nil
+}
+
+func (c *fakeClient) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, errChan chan error) {
+	c.calls++
+	for _, chunk := range c.chunks {
+		dataChan <- ChatCompletionStreamResponse{Choices: []ChatCompletionStreamChoice{{Delta: ChatCompletionStreamChoiceDelta{Content: chunk}}}}
+	}
+	if c.calls <= c.failures {
+		errChan <- &HTTPError{StatusCode: http.StatusServiceUnavailable}
+		return
+	}
+	errChan <- io.EOF
+}
+
+func collect(cli Client) (string, error) {
+	dataChan := make(chan ChatCompletionStreamResponse)
+	errChan := make(chan error)
+	go cli.CreateChatCompletionStream(context.Background(), ChatCompletionRequest{Model: "fake", Stream: true}, dataChan, errChan)
+	var acc StreamAccumulator
+	for {
+		select {
+		case data := <-dataChan:
+			acc.Add(data)
+		case err := <-errChan:
+			if errors.Is(err, io.EOF) {
+				return acc.Content(), nil
+			}
+			return acc.Content(), err
+		}
+	}
+}
+
+func TestWrapOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Interceptor {
+		return Interceptor{
+			Unary: func(ctx context.Context, req ChatCompletionRequest, next UnaryInvoker) (ChatCompletionResponse, error) {
+				calls = append(calls, name)
+				return next(ctx, req)
+			},
+		}
+	}
+
+	cli := Wrap(&fakeClient{}, record("outer"), record("inner"))
+	_, err := cli.CreateChatCompletion(context.Background(), ChatCompletionRequest{Model: "fake"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"outer", "inner"}, calls)
+	assert.Equal(t, []string{"fake"}, cli.ListModels())
+}
+
+func TestWithRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	fake := &fakeClient{failures: 2}
+	resp, err := Wrap(fake, WithRetry(policy)).CreateChatCompletion(context.Background(), ChatCompletionRequest{Model: "fake"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp.Choices[0].Message.Content)
+	assert.Equal(t, 3, fake.calls)
+
+	fake = &fakeClient{failures: 3}
+	_, err = Wrap(fake, WithRetry(policy)).CreateChatCompletion(context.Background(), ChatCompletionRequest{Model: "fake"})
+	assert.Error(t, err)
+	assert.Equal(t, 3, fake.calls)
+}
+
+func TestWithRetryStream(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	// nothing emitted, retried
+	fake := &fakeClient{failures: 1}
+	_, err := collect(Wrap(fake, WithRetry(policy)))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.calls)
+
+	// chunks emitted, not retried
+	fake = &fakeClient{failures: 1, chunks: []string{"hello"}}
+	content, err := collect(Wrap(fake, WithRetry(policy)))
+	assert.Error(t, err)
+	assert.Equal(t, "hello", content)
+	assert.Equal(t, 1, fake.calls)
+}
+
+func TestWithHooksStream(t *testing.T) {
+	var got ChatCompletionResponse
+	chunks := 0
+	cli := Wrap(&fakeClient{chunks: []string{"hello", " ", "world"}}, WithHooks(Hooks{
+		OnChunk: func(ctx context.Context, req ChatCompletionRequest, chunk ChatCompletionStreamResponse) {
+			chunks++
+		},
+		OnResponse: func(ctx context.Context, req ChatCompletionRequest, resp ChatCompletionResponse, err error, latency time.Duration) {
+			assert.NoError(t, err)
+			got = resp
+		},
+	}))
+
+	content, err := collect(cli)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", content)
+	assert.Equal(t, 3, chunks)
+	assert.Equal(t, "hello world", got.Choices[0].Message.Content)
+}
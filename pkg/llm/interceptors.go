@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// WithTimeout limits the duration of every call, streams are limited as a whole.
+func WithTimeout(timeout time.Duration) Interceptor {
+	return Interceptor{
+		Unary: func(ctx context.Context, req ChatCompletionRequest, next UnaryInvoker) (ChatCompletionResponse, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next(ctx, req)
+		},
+		Stream: func(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, errChan chan error, next StreamInvoker) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			next(ctx, req, dataChan, errChan)
+		},
+	}
+}
+
+// WithLogging logs every call with logger, slog.Default is used if logger is nil.
+func WithLogging(logger *slog.Logger) Interceptor {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return WithHooks(Hooks{
+		OnRequest: func(ctx context.Context, req ChatCompletionRequest) {
+			logger.DebugContext(ctx, "chat start", "model", req.Model, "is_stream", req.Stream)
+		},
+		OnResponse: func(ctx context.Context, req ChatCompletionRequest, _ ChatCompletionResponse, err error, latency time.Duration) {
+			if err != nil {
+				logger.ErrorContext(ctx, "chat error", "model", req.Model, "is_stream", req.Stream, "latency_ms", latency.Milliseconds(), "err", err)
+				return
+			}
+			logger.DebugContext(ctx, "chat success", "model", req.Model, "is_stream", req.Stream, "latency_ms", latency.Milliseconds())
+		},
+	})
+}
+
+// Hooks are called around every call, nil hooks are skipped.
+type Hooks struct {
+	// OnRequest is called before the call
+	OnRequest func(ctx context.Context, req ChatCompletionRequest)
+	// OnChunk is called for every chunk of streams
+	OnChunk func(ctx context.Context, req ChatCompletionRequest, chunk ChatCompletionStreamResponse)
+	// OnResponse is called after the call, streams are accumulated into resp and err is nil when they end with io.EOF
+	OnResponse func(ctx context.Context, req ChatCompletionRequest, resp ChatCompletionResponse, err error, latency time.Duration)
+}
+
+// WithHooks calls hooks around every call.
+func WithHooks(hooks Hooks) Interceptor {
+	return Interceptor{
+		Unary: func(ctx context.Context, req ChatCompletionRequest, next UnaryInvoker) (ChatCompletionResponse, error) {
+			if hooks.OnRequest != nil {
+				hooks.OnRequest(ctx, req)
+			}
+			start := time.Now()
+			resp, err := next(ctx, req)
+			if hooks.OnResponse != nil {
+				hooks.OnResponse(ctx, req, resp, err, time.Since(start))
+			}
+			return resp, err
+		},
+		Stream: func(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, errChan chan error, next StreamInvoker) {
+			if hooks.OnRequest != nil {
+				hooks.OnRequest(ctx, req)
+			}
+			start := time.Now()
+			var acc StreamAccumulator
+			RelayStream(ctx, req, dataChan, errChan, next, func(chunk ChatCompletionStreamResponse) {
+				acc.Add(chunk)
+				if hooks.OnChunk != nil {
+					hooks.OnChunk(ctx, req, chunk)
+				}
+			}, func(err error) {
+				if hooks.OnResponse == nil {
+					return
+				}
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				hooks.OnResponse(ctx, req, acc.Response(), err, time.Since(start))
+			})
+		},
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"io"
 	"log/slog"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,6 +18,30 @@ type Client interface {
 	CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest, respChan chan ChatCompletionStreamResponse, errChan chan error)
 }
 
+// Embedder is implemented by clients which are able to create embeddings.
+type Embedder interface {
+	CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error)
+}
+
+// ModelDescriber is implemented by clients which know the details of their models,
+// like context length and pricing.
+type ModelDescriber interface {
+	DescribeModels() []Model
+}
+
+// DescribeModels returns the details of the models of c, only ID is filled
+// for clients which do not implement ModelDescriber.
+func DescribeModels(c Client) []Model {
+	if describer, ok := c.(ModelDescriber); ok {
+		return describer.DescribeModels()
+	}
+	models := make([]Model, 0)
+	for _, id := range c.ListModels() {
+		models = append(models, Model{ID: id, Name: id})
+	}
+	return models
+}
+
 type LLM struct {
 	Client
 	dao Dao
@@ -31,6 +54,20 @@ func New(dao Dao, c Client) *LLM {
 	}
 }
 
+// CreateEmbeddings creates embeddings if the underlying client supports it.
+func (l *LLM) CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	embedder, ok := l.Client.(Embedder)
+	if !ok {
+		return EmbeddingResponse{}, NotImplementError
+	}
+	return embedder.CreateEmbeddings(ctx, req)
+}
+
+// DescribeModels returns the details of the models of the underlying client.
+func (l *LLM) DescribeModels() []Model {
+	return DescribeModels(l.Client)
+}
+
 func (l *LLM) CreateConversation(ctx context.Context, name string) (Conversation, error) {
 	cov, err := l.dao.SaveConversation(ctx, Conversation{
 		Id:        uuid.NewString(),
@@ -143,22 +180,18 @@ func (l *LLM) CreateMessageStream(ctx context.Context, conversationId string, re
 
 	go l.Client.CreateChatCompletionStream(ctx, req, innerDataChan, innerErrChan)
 
-	sb := strings.Builder{}
-
-	var resp ChatCompletionStreamResponse
-
+	var acc StreamAccumulator
 	for {
 		select {
-		case resp = <-innerDataChan:
-			sb.WriteString(resp.Choices[0].Delta.Content)
+		case resp := <-innerDataChan:
+			acc.Add(resp)
 			respChan <- resp
 		case err := <-innerErrChan:
 			if errors.Is(err, io.EOF) {
 				req.Messages = originReqMessages
-				chatCompletionResponse := resp.ToChatCompletionResponse()
-				chatCompletionResponse.Choices[0].Message.Content = sb.String()
+				chatCompletionResponse := acc.Response()
 				if _, err := l.dao.SaveMessage(ctx, Message{
-					Id:             resp.ID,
+					Id:             chatCompletionResponse.ID,
 					CreatedAt:      time.Now(),
 					UpdatedAt:      time.Now(),
 					ConversationId: conversationId,
@@ -0,0 +1,20 @@
+package mistral
+
+import (
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+type Mistral struct {
+	*llm.LLM
+}
+
+func New(cfg llmconfig.Config, dao llm.Dao) (*Mistral, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Mistral{
+		llm.New(dao, client),
+	}, nil
+}
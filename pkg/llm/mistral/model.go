@@ -0,0 +1,139 @@
+package mistral
+
+import (
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []ChatMessage `json:"messages"`
+	Temperature float32       `json:"temperature,omitempty"`
+	TopP        float32       `json:"top_p,omitempty"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Stop        []string      `json:"stop,omitempty"`
+	Stream      bool          `json:"stream"`
+	SafePrompt  bool          `json:"safe_prompt"`
+}
+
+func (r ChatRequest) FromChatCompletionRequest(req llm.ChatCompletionRequest) ChatRequest {
+	r.Model = req.Model
+	r.Stream = req.Stream
+	r.Temperature = req.Temperature
+	r.TopP = req.TopP
+	r.MaxTokens = req.MaxTokens
+	r.Stop = req.Stop
+	r.Messages = make([]ChatMessage, 0, len(req.Messages))
+	for _, message := range req.Messages {
+		r.Messages = append(r.Messages, ChatMessage{
+			Role:    message.Role,
+			Content: message.Content,
+		})
+	}
+	return r
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u Usage) toUsage() llm.Usage {
+	return llm.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+type ChatChoice struct {
+	Index        int         `json:"index"`
+	Message      ChatMessage `json:"message"`
+	Delta        ChatMessage `json:"delta"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+// ChatResponse is the response of non stream chats and every chunk of stream chats,
+// the last chunk of streams carries the usage.
+type ChatResponse struct {
+	Id      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   *Usage       `json:"usage,omitempty"`
+}
+
+// finishReason maps model_length, which mistral returns when the context is full, to length
+func finishReason(reason string) llm.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "length", "model_length":
+		return llm.FinishReasonLength
+	default:
+		return llm.FinishReason(reason)
+	}
+}
+
+func (r ChatResponse) ToChatCompletionResponse() llm.ChatCompletionResponse {
+	resp := llm.ChatCompletionResponse{
+		ID:      r.Id,
+		Object:  r.Object,
+		Created: r.Created,
+		Model:   r.Model,
+		Choices: make([]llm.ChatCompletionChoice, 0, len(r.Choices)),
+	}
+	for _, choice := range r.Choices {
+		resp.Choices = append(resp.Choices, llm.ChatCompletionChoice{
+			Index: choice.Index,
+			Message: llm.ChatCompletionMessage{
+				Role:    llm.ChatMessageRoleAssistant,
+				Content: choice.Message.Content,
+			},
+			FinishReason: finishReason(choice.FinishReason),
+		})
+	}
+	if r.Usage != nil {
+		resp.Usage = r.Usage.toUsage()
+	}
+	return resp
+}
+
+func (r ChatResponse) ToChatCompletionStreamResponse() llm.ChatCompletionStreamResponse {
+	resp := llm.ChatCompletionStreamResponse{
+		ID:      r.Id,
+		Object:  r.Object,
+		Created: r.Created,
+		Model:   r.Model,
+		Choices: make([]llm.ChatCompletionStreamChoice, 0, len(r.Choices)),
+	}
+	for _, choice := range r.Choices {
+		resp.Choices = append(resp.Choices, llm.ChatCompletionStreamChoice{
+			Index: choice.Index,
+			Delta: llm.ChatCompletionStreamChoiceDelta{
+				Role:    choice.Delta.Role,
+				Content: choice.Delta.Content,
+			},
+			FinishReason: finishReason(choice.FinishReason),
+		})
+	}
+	if r.Usage != nil {
+		usage := r.Usage.toUsage()
+		resp.Usage = &usage
+	}
+	return resp
+}
+
+type ModelsResponse struct {
+	Data []struct {
+		Id      string `json:"id"`
+		Created int64  `json:"created"`
+		OwnedBy string `json:"owned_by"`
+	} `json:"data"`
+}
@@ -155,6 +155,8 @@ type ChatCompletionChoice struct {
 	// content_filter: Omitted content due to a flag from our content filters
 	// null: API response still in progress or incomplete
 	FinishReason FinishReason `json:"finish_reason"`
+	// ContentFilterResults are the results of the content filters of azure openai
+	ContentFilterResults *ContentFilterResults `json:"content_filter_results,omitempty"`
 }
 
 type Usage struct {
@@ -171,6 +173,36 @@ type ChatCompletionResponse struct {
 	Model   string                 `json:"model"`
 	Choices []ChatCompletionChoice `json:"choices"`
 	Usage   Usage                  `json:"usage"`
+	// PromptAnnotations are the results of the content filters of azure openai for the prompt
+	PromptAnnotations []PromptAnnotation `json:"prompt_annotations,omitempty"`
+}
+
+// ToChatCompletionStreamResponse returns the response as a single chunk, it is used to stream full responses.
+func (r *ChatCompletionResponse) ToChatCompletionStreamResponse() ChatCompletionStreamResponse {
+	choices := make([]ChatCompletionStreamChoice, len(r.Choices))
+	for i, choice := range r.Choices {
+		choices[i] = ChatCompletionStreamChoice{
+			Index: choice.Index,
+			Delta: ChatCompletionStreamChoiceDelta{
+				Role:    choice.Message.Role,
+				Content: choice.Message.Content,
+			},
+			FinishReason: choice.FinishReason,
+		}
+		if choice.ContentFilterResults != nil {
+			choices[i].ContentFilterResults = *choice.ContentFilterResults
+		}
+	}
+	usage := r.Usage
+	return ChatCompletionStreamResponse{
+		ID:                r.ID,
+		Object:            "chat.completion.chunk",
+		Created:           r.Created,
+		Model:             r.Model,
+		Choices:           choices,
+		PromptAnnotations: r.PromptAnnotations,
+		Usage:             &usage,
+	}
 }
 
 type ChatCompletionStreamChoiceDelta struct {
@@ -193,6 +225,17 @@ type ChatCompletionStreamResponse struct {
 	Model             string                       `json:"model"`
 	Choices           []ChatCompletionStreamChoice `json:"choices"`
 	PromptAnnotations []PromptAnnotation           `json:"prompt_annotations,omitempty"`
+	// Usage is only set on the last chunk by providers which report usage of streams
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// DeltaContent returns the content of the first choice, frames without choices like the usage only
+// frames of some providers have none.
+func (r *ChatCompletionStreamResponse) DeltaContent() string {
+	if len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Delta.Content
 }
 
 func (r *ChatCompletionStreamResponse) ToChatCompletionResponse() ChatCompletionResponse {
@@ -206,14 +249,23 @@ func (r *ChatCompletionStreamResponse) ToChatCompletionResponse() ChatCompletion
 			},
 			FinishReason: choice.FinishReason,
 		}
+		if choice.ContentFilterResults != (ContentFilterResults{}) {
+			filter := choice.ContentFilterResults
+			choices[i].ContentFilterResults = &filter
+		}
 	}
-	return ChatCompletionResponse{
-		ID:      r.ID,
-		Object:  r.Object,
-		Created: r.Created,
-		Model:   r.Model,
-		Choices: choices,
+	resp := ChatCompletionResponse{
+		ID:                r.ID,
+		Object:            r.Object,
+		Created:           r.Created,
+		Model:             r.Model,
+		Choices:           choices,
+		PromptAnnotations: r.PromptAnnotations,
 	}
+	if r.Usage != nil {
+		resp.Usage = *r.Usage
+	}
+	return resp
 }
 
 type Conversation struct {
@@ -248,7 +300,8 @@ type Model struct {
 	ID          string `json:"id"`
 	Name        string `json:"name,omitempty"`
 	Description string `json:"description,omitempty"`
-	Pricing     struct {
+	// Pricing is in USD per million tokens
+	Pricing struct {
 		Prompt     float64 `json:"prompt,omitempty"`
 		Completion float64 `json:"completion,omitempty"`
 	} `json:"pricing,omitempty"`
@@ -258,3 +311,24 @@ type Model struct {
 		Completion int `json:"completion_tokens,omitempty"`
 	}
 }
+
+// EmbeddingRequest represents a request structure for embeddings API.
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+	User  string   `json:"user,omitempty"`
+}
+
+type Embedding struct {
+	Object    string    `json:"object"`
+	Embedding []float32 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+// EmbeddingResponse represents a response structure for embeddings API.
+type EmbeddingResponse struct {
+	Object string      `json:"object"`
+	Data   []Embedding `json:"data"`
+	Model  string      `json:"model"`
+	Usage  Usage       `json:"usage"`
+}
@@ -0,0 +1,264 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/google/uuid"
+)
+
+const defaultBaseUrl = "http://localhost:11434"
+
+type Client struct {
+	session *http.Client
+	baseUrl string
+	config  llmconfig.Config
+
+	mu     sync.Mutex
+	models []string
+	// pulling dedups concurrent pulls of the same model
+	pulling map[string]*sync.Mutex
+}
+
+func NewClient(cfg llmconfig.Config) (*Client, error) {
+	if cfg.LLMType != llmconfig.LLMTypeOllama {
+		return nil, fmt.Errorf("invalid config for ollama, llmtype: %s", cfg.LLMType)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		// no timeout, loading a model into memory may take minutes
+		session: &http.Client{},
+		baseUrl: defaultBaseUrl,
+		config:  cfg,
+		pulling: make(map[string]*sync.Mutex),
+	}
+	if cfg.BaseUrl != "" {
+		client.baseUrl = strings.TrimSuffix(cfg.BaseUrl, "/")
+	}
+	if cfg.Ollama.Preload {
+		go client.preload()
+	}
+	return client, nil
+}
+
+func (c *Client) WithSession(session *http.Client) *Client {
+	c.session = session
+	return c
+}
+
+// ListModels returns the configured models and the models discovered from the server,
+// the discovery only happens once per client, clients are recreated on config reload.
+func (c *Client) ListModels() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.models != nil {
+		return c.models
+	}
+
+	models := append([]string{}, c.config.Models...)
+	tags, err := c.tags(context.Background())
+	if err != nil {
+		slog.Error("discover ollama models error", "err", err, "base_url", c.baseUrl)
+		return models
+	}
+	for _, tag := range tags {
+		if !slices.Contains(models, tag) {
+			models = append(models, tag)
+		}
+	}
+	c.models = models
+	slog.Info("discover ollama models", "models", models, "base_url", c.baseUrl)
+	return models
+}
+
+func (c *Client) tags(ctx context.Context) ([]string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tagsResp TagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tagsResp); err != nil {
+		return nil, fmt.Errorf("decode ollama tags error: %w", err)
+	}
+	models := make([]string, 0, len(tagsResp.Models))
+	for _, model := range tagsResp.Models {
+		models = append(models, model.Name)
+		// latest tag can be omitted
+		if name, ok := strings.CutSuffix(model.Name, ":latest"); ok {
+			models = append(models, name)
+		}
+	}
+	return models, nil
+}
+
+func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	req.Stream = false
+	resp, err := c.chat(ctx, req)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("decode ollama chat response error: %w", err)
+	}
+	return chatResp.ToChatCompletionResponse(uuid.NewString()), nil
+}
+
+// CreateChatCompletionStream reads the native stream of ollama, which is a json object per line
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
+	req.Stream = true
+	resp, err := c.chat(ctx, req)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	defer resp.Body.Close()
+
+	id := uuid.NewString()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chatResp ChatResponse
+		if err := json.Unmarshal(line, &chatResp); err != nil {
+			errChan <- fmt.Errorf("decode ollama chat stream error: %w, data: %s", err, line)
+			return
+		}
+		if chatResp.Error != "" {
+			errChan <- fmt.Errorf("ollama chat stream error: %s", chatResp.Error)
+			return
+		}
+		dataChan <- chatResp.ToChatCompletionStreamResponse(id)
+		if chatResp.Done {
+			errChan <- io.EOF
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errChan <- fmt.Errorf("read ollama chat stream error: %w", err)
+		return
+	}
+	errChan <- io.EOF
+}
+
+// chat posts the chat request, a missing model is pulled first when pull on demand is enabled
+func (c *Client) chat(ctx context.Context, req llm.ChatCompletionRequest) (*http.Response, error) {
+	chatReq := &ChatRequest{KeepAlive: c.config.Ollama.KeepAlive}
+	chatReq.FromChatCompletionRequest(req)
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal ollama chat request error: %w", err)
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/api/chat", body)
+	var httpErr *llm.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound && c.config.Ollama.PullOnDemand {
+		if err := c.pull(ctx, req.Model); err != nil {
+			return nil, err
+		}
+		resp, err = c.do(ctx, http.MethodPost, "/api/chat", body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ollama chat with %s error: %w", req.Model, err)
+	}
+	return resp, nil
+}
+
+func (c *Client) pull(ctx context.Context, model string) error {
+	c.mu.Lock()
+	lock, ok := c.pulling[model]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.pulling[model] = lock
+	}
+	c.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+	// pulled by a concurrent request while waiting
+	if tags, err := c.tags(ctx); err == nil && slices.Contains(tags, model) {
+		return nil
+	}
+
+	slog.InfoContext(ctx, "pull ollama model", "model", model)
+	body, _ := json.Marshal(PullRequest{Name: model, Stream: false})
+	resp, err := c.do(ctx, http.MethodPost, "/api/pull", body)
+	if err != nil {
+		return fmt.Errorf("pull ollama model %s error: %w", model, err)
+	}
+	defer resp.Body.Close()
+
+	var pullResp PullResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pullResp); err != nil {
+		return fmt.Errorf("decode ollama pull response error: %w", err)
+	}
+	if pullResp.Error != "" {
+		return fmt.Errorf("pull ollama model %s error: %s", model, pullResp.Error)
+	}
+	slog.InfoContext(ctx, "pull ollama model success", "model", model, "status", pullResp.Status)
+
+	c.mu.Lock()
+	if c.models != nil && !slices.Contains(c.models, model) {
+		c.models = append(c.models, model)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// preload loads the configured models into memory, a chat without messages only loads the model
+func (c *Client) preload() {
+	for _, model := range c.config.Models {
+		ctx := context.Background()
+		body, _ := json.Marshal(ChatRequest{Model: model, Messages: []Message{}, KeepAlive: c.config.Ollama.KeepAlive})
+		resp, err := c.do(ctx, http.MethodPost, "/api/chat", body)
+		if err != nil {
+			slog.Error("preload ollama model error", "err", err, "model", model)
+			continue
+		}
+		resp.Body.Close()
+		slog.Info("preload ollama model success", "model", model)
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseUrl+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.ApiKey != "" {
+		// ollama behind an authenticating reverse proxy
+		req.Header.Set("Authorization", "Bearer "+c.config.ApiKey)
+	}
+	resp, err := c.session.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, llm.NewHTTPError(resp)
+	}
+	return resp, nil
+}
@@ -0,0 +1,120 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// newServer fakes an ollama server which only has llama2 until another model is pulled
+func newServer(t *testing.T) (*httptest.Server, *[]string) {
+	models := []string{"llama2:latest"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		resp := TagsResponse{}
+		for _, model := range models {
+			resp.Models = append(resp.Models, struct {
+				Name       string    `json:"name"`
+				ModifiedAt time.Time `json:"modified_at"`
+				Size       int64     `json:"size"`
+			}{Name: model})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/api/pull", func(w http.ResponseWriter, r *http.Request) {
+		var req PullRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req.Name)
+		_, _ = w.Write([]byte(`{"status": "success"}`))
+	})
+	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		found := false
+		for _, model := range models {
+			if model == req.Model || model == req.Model+":latest" {
+				found = true
+			}
+		}
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(w, `{"error": "model '%s' not found, try pulling it first"}`, req.Model)
+			return
+		}
+		if !req.Stream {
+			_, _ = w.Write([]byte(`{"model": "llama2", "message": {"role": "assistant", "content": "hello world"}, "done": true, "prompt_eval_count": 3, "eval_count": 2}`))
+			return
+		}
+		for _, content := range []string{"hello", " world"} {
+			_, _ = fmt.Fprintf(w, `{"model": "llama2", "message": {"role": "assistant", "content": "%s"}, "done": false}`+"\n", content)
+		}
+		_, _ = w.Write([]byte(`{"model": "llama2", "message": {"role": "assistant", "content": ""}, "done": true, "eval_count": 2}` + "\n"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &models
+}
+
+func newTestClient(t *testing.T, url string, pullOnDemand bool) *Client {
+	client, err := NewClient(llmconfig.Config{
+		LLMType: llmconfig.LLMTypeOllama,
+		BaseUrl: url,
+		Ollama:  llmconfig.OllamaConfig{PullOnDemand: pullOnDemand},
+	})
+	assert.NoError(t, err)
+	return client
+}
+
+func TestListModels(t *testing.T) {
+	server, _ := newServer(t)
+	client := newTestClient(t, server.URL, false)
+	assert.Equal(t, []string{"llama2:latest", "llama2"}, client.ListModels())
+}
+
+func TestCreateChatCompletion(t *testing.T) {
+	server, _ := newServer(t)
+	client := newTestClient(t, server.URL, false)
+
+	req := llm.ChatCompletionRequest{}
+	req.FromPrompt("llama2", "hi")
+	resp, err := client.CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", resp.Choices[0].Message.Content)
+	assert.Equal(t, 5, resp.Usage.TotalTokens)
+}
+
+func TestCreateChatCompletionStream(t *testing.T) {
+	server, _ := newServer(t)
+	client := newTestClient(t, server.URL, false)
+
+	req := llm.ChatCompletionRequest{}
+	req.FromPrompt("llama2", "hi")
+	resp, err := llm.CompleteStream(context.Background(), req, client.CreateChatCompletionStream)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", resp.Choices[0].Message.Content)
+	assert.Equal(t, llm.FinishReasonStop, resp.Choices[0].FinishReason)
+}
+
+func TestPullOnDemand(t *testing.T) {
+	req := llm.ChatCompletionRequest{}
+	req.FromPrompt("mistral", "hi")
+
+	server, _ := newServer(t)
+	_, err := newTestClient(t, server.URL, false).CreateChatCompletion(context.Background(), req)
+	code, _ := llm.StatusCode(err)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	server, models := newServer(t)
+	resp, err := newTestClient(t, server.URL, true).CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", resp.Choices[0].Message.Content)
+	assert.Contains(t, *models, "mistral")
+}
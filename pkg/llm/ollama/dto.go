@@ -0,0 +1,128 @@
+package ollama
+
+import (
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+type Message struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+type Options struct {
+	Temperature float32  `json:"temperature,omitempty"`
+	TopP        float32  `json:"top_p,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type ChatRequest struct {
+	Model     string    `json:"model"`
+	Messages  []Message `json:"messages"`
+	Stream    bool      `json:"stream"`
+	Options   *Options  `json:"options,omitempty"`
+	KeepAlive string    `json:"keep_alive,omitempty"`
+}
+
+func (r *ChatRequest) FromChatCompletionRequest(req llm.ChatCompletionRequest) {
+	r.Model = req.Model
+	r.Stream = req.Stream
+	for _, message := range req.Messages {
+		r.Messages = append(r.Messages, Message{
+			Role:    message.Role,
+			Content: message.Content,
+		})
+	}
+	r.Options = &Options{
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		NumPredict:  req.MaxTokens,
+		Stop:        req.Stop,
+	}
+}
+
+// ChatResponse is the response of non stream chats and every line of stream chats,
+// the last line of streams has Done true and the token counts.
+type ChatResponse struct {
+	Model           string    `json:"model"`
+	CreatedAt       time.Time `json:"created_at"`
+	Message         Message   `json:"message"`
+	Done            bool      `json:"done"`
+	DoneReason      string    `json:"done_reason,omitempty"`
+	PromptEvalCount int       `json:"prompt_eval_count,omitempty"`
+	EvalCount       int       `json:"eval_count,omitempty"`
+	// Error is set when the stream fails after it has started
+	Error string `json:"error,omitempty"`
+}
+
+func (r ChatResponse) finishReason() llm.FinishReason {
+	if !r.Done {
+		return ""
+	}
+	if r.DoneReason == "length" {
+		return llm.FinishReasonLength
+	}
+	return llm.FinishReasonStop
+}
+
+func (r ChatResponse) ToChatCompletionResponse(id string) llm.ChatCompletionResponse {
+	return llm.ChatCompletionResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: r.CreatedAt.Unix(),
+		Model:   r.Model,
+		Choices: []llm.ChatCompletionChoice{
+			{
+				Message: llm.ChatCompletionMessage{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: r.Message.Content,
+				},
+				FinishReason: r.finishReason(),
+			},
+		},
+		Usage: llm.Usage{
+			PromptTokens:     r.PromptEvalCount,
+			CompletionTokens: r.EvalCount,
+			TotalTokens:      r.PromptEvalCount + r.EvalCount,
+		},
+	}
+}
+
+func (r ChatResponse) ToChatCompletionStreamResponse(id string) llm.ChatCompletionStreamResponse {
+	return llm.ChatCompletionStreamResponse{
+		ID:      id,
+		Object:  "chat.completion.chunk",
+		Created: r.CreatedAt.Unix(),
+		Model:   r.Model,
+		Choices: []llm.ChatCompletionStreamChoice{
+			{
+				Delta: llm.ChatCompletionStreamChoiceDelta{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: r.Message.Content,
+				},
+				FinishReason: r.finishReason(),
+			},
+		},
+	}
+}
+
+type TagsResponse struct {
+	Models []struct {
+		Name       string    `json:"name"`
+		ModifiedAt time.Time `json:"modified_at"`
+		Size       int64     `json:"size"`
+	} `json:"models"`
+}
+
+type PullRequest struct {
+	Name   string `json:"name"`
+	Stream bool   `json:"stream"`
+}
+
+type PullResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
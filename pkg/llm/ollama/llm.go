@@ -0,0 +1,20 @@
+package ollama
+
+import (
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+type Ollama struct {
+	*llm.LLM
+}
+
+func New(cfg llmconfig.Config, dao llm.Dao) (*Ollama, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Ollama{
+		llm.New(dao, client),
+	}, nil
+}
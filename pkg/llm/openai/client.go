@@ -6,7 +6,8 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"strings"
+	"net/http"
+	"sync"
 
 	"github.com/Vaayne/aienvoy/pkg/llm"
 	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
@@ -17,12 +18,15 @@ type Client struct {
 	*openai.Client
 	Models []string `json:"models" yaml:"models"`
 	config llmconfig.Config
+
+	// mu guards Models, refreshes and requests list the models concurrently
+	mu sync.Mutex
 }
 
 var validLLMTypes = map[llmconfig.LLMType]struct{}{
-	llmconfig.LLMTypeOpenAI:      {},
-	llmconfig.LLMTypeAzureOpenAI: {},
-	llmconfig.LLMTypeOpenRouter:  {},
+	llmconfig.LLMTypeOpenAI:           {},
+	llmconfig.LLMTypeOpenRouter:       {},
+	llmconfig.LLMTypeOpenAICompatible: {},
 }
 
 func NewClient(cfg llmconfig.Config) (*Client, error) {
@@ -36,19 +40,11 @@ func NewClient(cfg llmconfig.Config) (*Client, error) {
 		return nil, err
 	}
 
-	var oaiConfig openai.ClientConfig
-	if cfg.LLMType == llmconfig.LLMTypeOpenAI {
-		oaiConfig = openai.DefaultConfig(cfg.ApiKey)
-		if cfg.BaseUrl != "" {
-			oaiConfig.BaseURL = cfg.BaseUrl
-		}
-	} else if cfg.LLMType == llmconfig.LLMTypeAzureOpenAI {
-		baseUrl := fmt.Sprintf("https://%s.openai.azure.com", cfg.AzureOpenAI.ResourceName)
-		oaiConfig = openai.DefaultAzureConfig(cfg.ApiKey, baseUrl)
-		if cfg.AzureOpenAI.Version != "" {
-			oaiConfig.APIVersion = cfg.AzureOpenAI.Version
-		}
+	oaiConfig := openai.DefaultConfig(cfg.ApiKey)
+	if cfg.BaseUrl != "" {
+		oaiConfig.BaseURL = cfg.BaseUrl
 	}
+	oaiConfig.HTTPClient = &http.Client{Transport: headerTransport{base: http.DefaultTransport}}
 
 	return &Client{
 		Client: openai.NewClientWithConfig(oaiConfig),
@@ -58,23 +54,42 @@ func NewClient(cfg llmconfig.Config) (*Client, error) {
 }
 
 func (s *Client) ListModels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if len(s.Models) == 0 {
-		if s.config.LLMType == llmconfig.LLMTypeAzureOpenAI {
+		switch s.config.LLMType {
+		case llmconfig.LLMTypeOpenAICompatible:
+			s.Models = s.discoverModels()
+		default:
 			s.Models = llmconfig.DefaultOpenAIChatModels
-		} else {
-			s.Models = s.config.AzureOpenAI.ListModels()
 		}
 	}
 	return s.Models
 }
 
+// discoverModels lists the models served by an openai compatible server from /v1/models
+func (s *Client) discoverModels() []string {
+	resp, err := s.Client.ListModels(context.Background())
+	if err != nil {
+		slog.Error("discover models error", "err", err, "base_url", s.config.BaseUrl)
+		return nil
+	}
+	models := make([]string, 0, len(resp.Models))
+	for _, model := range resp.Models {
+		models = append(models, model.ID)
+	}
+	slog.Info("discover models", "models", models, "base_url", s.config.BaseUrl)
+	return models
+}
+
 func (s *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
 	openaiReq := toOpenAIChatCompletionRequest(req)
 	slog.DebugContext(ctx, "chat start", "llm", openaiReq.Model, "is_stream", openaiReq.Stream)
+	ctx, header := withErrorHeader(ctx)
 	resp, err := s.Client.CreateChatCompletion(ctx, openaiReq)
 	if err != nil {
 		slog.ErrorContext(ctx, "chat with OpenAI error", "err", err)
-		return llm.ChatCompletionResponse{}, err
+		return llm.ChatCompletionResponse{}, toLLMError(err, header)
 	}
 	slog.DebugContext(ctx, "chat success", "llm", openaiReq.Model, "is_stream", openaiReq.Stream)
 
@@ -84,15 +99,14 @@ func (s *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletio
 func (s *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
 	openaiReq := toOpenAIChatCompletionRequest(req)
 	slog.DebugContext(ctx, "chat start", "llm", openaiReq.Model, "is_stream", openaiReq.Stream)
+	ctx, header := withErrorHeader(ctx)
 	stream, err := s.Client.CreateChatCompletionStream(ctx, openaiReq)
 	if err != nil {
 		slog.InfoContext(ctx, "chat error", "llm", openaiReq.Model, "is_stream", openaiReq.Stream, "err", err)
-		errChan <- err
+		errChan <- toLLMError(err, header)
 		return
 	}
 
-	// log chat stream response
-	sb := &strings.Builder{}
 	for {
 		resp, err := stream.Recv()
 		if err != nil {
@@ -102,12 +116,75 @@ func (s *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCom
 				return
 			}
 			slog.InfoContext(ctx, "chat error", "llm", openaiReq.Model, "is_stream", openaiReq.Stream, "err", err)
-			errChan <- err
+			errChan <- toLLMError(err, header)
 			return
 		}
 		if len(resp.Choices) > 0 {
-			sb.WriteString(resp.Choices[0].Delta.Content)
 			dataChan <- toLLMChatCompletionStreamResponse(resp)
 		}
 	}
 }
+
+func (s *Client) CreateEmbeddings(ctx context.Context, req llm.EmbeddingRequest) (llm.EmbeddingResponse, error) {
+	openaiReq := toOpenAIEmbeddingRequest(req)
+	slog.DebugContext(ctx, "embedding start", "llm", req.Model, "inputs", len(req.Input))
+	ctx, header := withErrorHeader(ctx)
+	resp, err := s.Client.CreateEmbeddings(ctx, openaiReq)
+	if err != nil {
+		slog.ErrorContext(ctx, "embedding with OpenAI error", "err", err)
+		return llm.EmbeddingResponse{}, toLLMError(err, header)
+	}
+	slog.DebugContext(ctx, "embedding success", "llm", req.Model)
+	return toLLMEmbeddingResponse(resp), nil
+}
+
+// toLLMError keeps the status code and headers of openai errors, so that they can be retried by
+// status code and Retry-After
+func toLLMError(err error, header *errorHeader) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode > 0 {
+		return &llm.HTTPError{StatusCode: apiErr.HTTPStatusCode, Header: header.get(), Body: err.Error()}
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode > 0 {
+		return &llm.HTTPError{StatusCode: reqErr.HTTPStatusCode, Header: header.get(), Body: err.Error()}
+	}
+	return err
+}
+
+type errorHeaderKey struct{}
+
+// errorHeader keeps the headers of the failed response of a call, go-openai drops them from its errors
+type errorHeader struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+func (h *errorHeader) get() http.Header {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.header
+}
+
+func withErrorHeader(ctx context.Context) (context.Context, *errorHeader) {
+	header := &errorHeader{}
+	return context.WithValue(ctx, errorHeaderKey{}, header), header
+}
+
+// headerTransport puts the headers of failed responses into the errorHeader of the request context
+type headerTransport struct {
+	base http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	if header, ok := req.Context().Value(errorHeaderKey{}).(*errorHeader); ok {
+		header.mu.Lock()
+		header.header = resp.Header
+		header.mu.Unlock()
+	}
+	return resp, nil
+}
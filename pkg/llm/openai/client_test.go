@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// newUpstream fails the first failures calls with a rate limit of retryAfter seconds
+func newUpstream(t *testing.T, failures int32, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) <= failures {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-3.5-turbo","choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func newTestClient(t *testing.T, url string) *Client {
+	client, err := NewClient(llmconfig.Config{
+		LLMType: llmconfig.LLMTypeOpenAICompatible,
+		ApiKey:  "key",
+		BaseUrl: url + "/v1",
+		Models:  []string{"gpt-3.5-turbo"},
+	})
+	assert.NoError(t, err)
+	return client
+}
+
+func TestRetryAfter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := llm.ChatCompletionRequest{
+		Model:    "gpt-3.5-turbo",
+		Messages: []llm.ChatCompletionMessage{{Role: llm.ChatMessageRoleUser, Content: "hi"}},
+	}
+
+	// the backoff would block the test, Retry-After must win
+	server, calls := newUpstream(t, 1, "0.01")
+	policy := llm.RetryPolicy{MaxAttempts: 2, Backoff: time.Hour}
+	resp, err := llm.Wrap(newTestClient(t, server.URL), llm.WithRetry(policy)).CreateChatCompletion(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, int32(2), calls.Load())
+
+	server, calls = newUpstream(t, 1, "120")
+	_, err = newTestClient(t, server.URL).CreateChatCompletion(ctx, req)
+	assert.Error(t, err)
+	assert.Equal(t, 120*time.Second, llm.RetryAfter(err))
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -38,3 +38,17 @@ func toLLMChatCompletionStreamResponse(resp openai.ChatCompletionStreamResponse)
 	_ = json.Unmarshal(data, &req)
 	return req
 }
+
+func toOpenAIEmbeddingRequest(req llm.EmbeddingRequest) openai.EmbeddingRequestStrings {
+	data, _ := json.Marshal(req)
+	var resp openai.EmbeddingRequestStrings
+	_ = json.Unmarshal(data, &resp)
+	return resp
+}
+
+func toLLMEmbeddingResponse(resp openai.EmbeddingResponse) llm.EmbeddingResponse {
+	data, _ := json.Marshal(resp)
+	var req llm.EmbeddingResponse
+	_ = json.Unmarshal(data, &req)
+	return req
+}
@@ -0,0 +1,337 @@
+package replicate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+const baseUrl = "https://api.replicate.com/v1"
+
+type Client struct {
+	session      *http.Client
+	baseUrl      string
+	apiKey       string
+	pollInterval time.Duration
+	// models maps model names to the replicate models, names keeps the config order
+	models map[string]llmconfig.ReplicateModel
+	names  []string
+}
+
+func NewClient(cfg llmconfig.Config) (*Client, error) {
+	if cfg.LLMType != llmconfig.LLMTypeReplicate {
+		return nil, fmt.Errorf("invalid config for replicate, llmtype: %s", cfg.LLMType)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		session:      http.DefaultClient,
+		baseUrl:      baseUrl,
+		apiKey:       cfg.ApiKey,
+		pollInterval: cfg.Replicate.PollInterval,
+		models:       make(map[string]llmconfig.ReplicateModel),
+	}
+	if cfg.BaseUrl != "" {
+		client.baseUrl = strings.TrimSuffix(cfg.BaseUrl, "/")
+	}
+	if client.pollInterval <= 0 {
+		client.pollInterval = llmconfig.DefaultReplicatePollInterval
+	}
+
+	// plain models are versions without extra settings
+	models := cfg.Replicate.Models
+	for _, version := range cfg.Models {
+		models = append(models, llmconfig.ReplicateModel{Version: version})
+	}
+	for _, m := range models {
+		if m.Name == "" {
+			m.Name = m.Version
+		}
+		if _, ok := client.models[m.Name]; !ok {
+			client.names = append(client.names, m.Name)
+		}
+		client.models[m.Name] = m
+	}
+	return client, nil
+}
+
+func (c *Client) WithSession(session *http.Client) *Client {
+	c.session = session
+	return c
+}
+
+func (c *Client) ListModels() []string {
+	return c.names
+}
+
+func (c *Client) DescribeModels() []llm.Model {
+	models := make([]llm.Model, 0, len(c.names))
+	for _, name := range c.names {
+		m := c.models[name]
+		models = append(models, llm.Model{
+			ID:            name,
+			Name:          m.Version,
+			Description:   m.Description,
+			ContextLength: m.ContextLength,
+		})
+	}
+	return models
+}
+
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("Authorization", "Token "+c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+}
+
+// predictionPath returns the url path creating predictions of version and the version of the request body,
+// official models are created by their model url without version.
+func predictionPath(version string) (string, string) {
+	if _, v, ok := strings.Cut(version, ":"); ok {
+		return "/predictions", v
+	}
+	if strings.Contains(version, "/") {
+		return fmt.Sprintf("/models/%s/predictions", version), ""
+	}
+	return "/predictions", version
+}
+
+func (c *Client) createPrediction(ctx context.Context, req llm.ChatCompletionRequest, stream bool) (Prediction, error) {
+	model, ok := c.models[req.Model]
+	if !ok {
+		return Prediction{}, fmt.Errorf("replicate model %s not found", req.Model)
+	}
+	input, err := buildInput(model, req)
+	if err != nil {
+		return Prediction{}, fmt.Errorf("build replicate input error: %w", err)
+	}
+	path, version := predictionPath(model.Version)
+	reqBody, err := json.Marshal(PredictionRequest{
+		Version: version,
+		Input:   input,
+		Stream:  stream,
+	})
+	if err != nil {
+		return Prediction{}, fmt.Errorf("marshal replicate request error: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseUrl+path, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return Prediction{}, err
+	}
+	c.setHeaders(httpReq)
+	return c.doPrediction(httpReq)
+}
+
+func (c *Client) getPrediction(ctx context.Context, url string) (Prediction, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Prediction{}, err
+	}
+	c.setHeaders(httpReq)
+	return c.doPrediction(httpReq)
+}
+
+// cancelPrediction cancels a prediction whose caller is gone, so that it stops costing money
+func (c *Client) cancelPrediction(p Prediction) {
+	if p.Urls.Cancel == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Urls.Cancel, nil)
+	if err != nil {
+		return
+	}
+	c.setHeaders(httpReq)
+	if _, err := c.doPrediction(httpReq); err != nil {
+		slog.Error("cancel replicate prediction error", "err", err, "id", p.Id)
+	}
+}
+
+func (c *Client) doPrediction(httpReq *http.Request) (Prediction, error) {
+	resp, err := c.session.Do(httpReq)
+	if err != nil {
+		return Prediction{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return Prediction{}, llm.NewHTTPError(resp)
+	}
+	var p Prediction
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return Prediction{}, fmt.Errorf("decode replicate prediction error: %w", err)
+	}
+	return p, nil
+}
+
+// wait polls the prediction until it is done, it is canceled when ctx is done
+func (c *Client) wait(ctx context.Context, p Prediction) (Prediction, error) {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for !p.Done() {
+		select {
+		case <-ctx.Done():
+			c.cancelPrediction(p)
+			return p, ctx.Err()
+		case <-ticker.C:
+		}
+		next, err := c.getPrediction(ctx, p.Urls.Get)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.cancelPrediction(p)
+			}
+			return p, err
+		}
+		p = next
+	}
+	return p, p.Err()
+}
+
+func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	p, err := c.createPrediction(ctx, req, false)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion error: %w", err)
+	}
+	p, err = c.wait(ctx, p)
+	if err != nil {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion error: %w", err)
+	}
+	return p.ToChatCompletionResponse(req.Model), nil
+}
+
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
+	p, err := c.createPrediction(ctx, req, true)
+	if err != nil {
+		errChan <- fmt.Errorf("create chat completion stream error: %w", err)
+		return
+	}
+	// versions without streaming support are polled and sent as a single chunk
+	if p.Urls.Stream == "" {
+		p, err = c.wait(ctx, p)
+		if err != nil {
+			errChan <- fmt.Errorf("create chat completion stream error: %w", err)
+			return
+		}
+		dataChan <- newStreamResponse(p, req.Model, p.Text(), llm.FinishReasonStop)
+		errChan <- io.EOF
+		return
+	}
+
+	if err := c.stream(ctx, p, req.Model, dataChan); err != nil {
+		if ctx.Err() != nil {
+			c.cancelPrediction(p)
+			err = ctx.Err()
+		}
+		errChan <- fmt.Errorf("create chat completion stream error: %w", err)
+		return
+	}
+	errChan <- io.EOF
+}
+
+// stream relays the output events of the stream url until the done event
+func (c *Client) stream(ctx context.Context, p Prediction, model string, dataChan chan llm.ChatCompletionStreamResponse) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Urls.Stream, nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(httpReq)
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Cache-Control", "no-store")
+	resp, err := c.session.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return llm.NewHTTPError(resp)
+	}
+
+	return readEvents(resp.Body, func(event, data string) (bool, error) {
+		switch event {
+		case "output":
+			dataChan <- newStreamResponse(p, model, data, "")
+		case "error":
+			return true, parseStreamError(data)
+		case "done":
+			var done struct {
+				Reason string `json:"reason"`
+			}
+			_ = json.Unmarshal([]byte(data), &done)
+			if done.Reason == StatusCanceled {
+				return true, fmt.Errorf("replicate prediction %s canceled", p.Id)
+			}
+			dataChan <- newStreamResponse(p, model, "", llm.FinishReasonStop)
+			return true, nil
+		}
+		return false, nil
+	})
+}
+
+func newStreamResponse(p Prediction, model, content string, finishReason llm.FinishReason) llm.ChatCompletionStreamResponse {
+	return llm.ChatCompletionStreamResponse{
+		ID:      p.Id,
+		Object:  "chat.completion.chunk",
+		Created: p.CreatedAt.Unix(),
+		Model:   model,
+		Choices: []llm.ChatCompletionStreamChoice{
+			{
+				Delta: llm.ChatCompletionStreamChoiceDelta{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: content,
+				},
+				FinishReason: finishReason,
+			},
+		},
+	}
+}
+
+// readEvents reads server sent events and calls onEvent for each of them until it returns true,
+// unlike llm.ParseSSE the data of replicate output events is raw text and may span lines.
+func readEvents(body io.Reader, onEvent func(event, data string) (bool, error)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	event := ""
+	data := make([]string, 0)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 || event != "" {
+				if event == "" {
+					event = "message"
+				}
+				stop, err := onEvent(event, strings.Join(data, "\n"))
+				if stop || err != nil {
+					return err
+				}
+			}
+			event = ""
+			data = data[:0]
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("replicate stream closed before done")
+}
@@ -0,0 +1,192 @@
+package replicate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const slowVersion = "slow"
+
+type fakeServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []PredictionRequest
+	paths    []string
+	polls    map[string]int
+	canceled []string
+}
+
+// newServer fakes replicate, predictions succeed on the second poll except the ones of slowVersion
+func newServer(t *testing.T) *fakeServer {
+	s := &fakeServer{polls: make(map[string]int)}
+	prediction := func(id string, status string, output any) Prediction {
+		p := Prediction{Id: id, Status: status, Output: output}
+		p.Urls.Get = s.URL + "/v1/predictions/" + id
+		p.Urls.Cancel = s.URL + "/v1/predictions/" + id + "/cancel"
+		p.Metrics.InputTokenCount = 3
+		p.Metrics.OutputTokenCount = 2
+		return p
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Token key", r.Header.Get("Authorization"))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/cancel"):
+			id := strings.Split(r.URL.Path, "/")[3]
+			s.canceled = append(s.canceled, id)
+			_ = json.NewEncoder(w).Encode(prediction(id, StatusCanceled, nil))
+		case r.Method == http.MethodPost:
+			var req PredictionRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			s.requests = append(s.requests, req)
+			s.paths = append(s.paths, r.URL.Path)
+			id := fmt.Sprintf("p%d-%s", len(s.requests), req.Version)
+			p := prediction(id, StatusStarting, nil)
+			if req.Stream {
+				p.Urls.Stream = s.URL + "/stream/" + id
+			}
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(p)
+		default:
+			id := strings.TrimPrefix(r.URL.Path, "/v1/predictions/")
+			s.polls[id]++
+			if s.polls[id] < 2 || strings.HasSuffix(id, slowVersion) {
+				_ = json.NewEncoder(w).Encode(prediction(id, StatusProcessing, []string{"hel"}))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(prediction(id, StatusSucceeded, []string{"hel", "lo"}))
+		}
+	})
+	mux.HandleFunc("/stream/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "event: output\nid: 1\ndata: hello\n\n")
+		_, _ = io.WriteString(w, "event: output\nid: 2\ndata:  world\ndata: again\n\n")
+		if strings.HasSuffix(r.URL.Path, slowVersion) {
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		_, _ = io.WriteString(w, "event: done\ndata: {}\n\n")
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestClient(t *testing.T, s *fakeServer) *Client {
+	cli, err := NewClient(llmconfig.Config{
+		LLMType: llmconfig.LLMTypeReplicate,
+		ApiKey:  "key",
+		BaseUrl: s.URL + "/v1",
+		Models:  []string{"meta/llama-2-70b-chat"},
+		Replicate: llmconfig.ReplicateConfig{
+			PollInterval: time.Millisecond,
+			Models: []llmconfig.ReplicateModel{
+				{Name: "my-llama", Version: "me/llama-ft:abc", ContextLength: 4096, Input: map[string]any{"top_k": 50}},
+				{Name: "slow", Version: slowVersion},
+			},
+		},
+	})
+	require.NoError(t, err)
+	return cli
+}
+
+var messages = []llm.ChatCompletionMessage{
+	{Role: llm.ChatMessageRoleSystem, Content: "be brief"},
+	{Role: llm.ChatMessageRoleUser, Content: "hi"},
+	{Role: llm.ChatMessageRoleAssistant, Content: "hello"},
+	{Role: llm.ChatMessageRoleUser, Content: "how are you?"},
+}
+
+func TestModels(t *testing.T) {
+	cli := newTestClient(t, newServer(t))
+	assert.Equal(t, []string{"my-llama", "slow", "meta/llama-2-70b-chat"}, cli.ListModels())
+	models := cli.DescribeModels()
+	assert.Equal(t, "me/llama-ft:abc", models[0].Name)
+	assert.Equal(t, 4096, models[0].ContextLength)
+}
+
+func TestCreateChatCompletion(t *testing.T) {
+	s := newServer(t)
+	cli := newTestClient(t, s)
+
+	resp, err := cli.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "my-llama", Messages: messages, MaxTokens: 10})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, 5, resp.Usage.TotalTokens)
+
+	assert.Equal(t, "/v1/predictions", s.paths[0])
+	req := s.requests[0]
+	assert.Equal(t, "abc", req.Version)
+	assert.Equal(t, "hi [/INST] hello </s><s>[INST] how are you?", req.Input["prompt"])
+	assert.Equal(t, "be brief", req.Input["system_prompt"])
+	assert.EqualValues(t, 10, req.Input["max_new_tokens"])
+	assert.EqualValues(t, 50, req.Input["top_k"])
+
+	// official models are created by the model url
+	_, err = cli.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "meta/llama-2-70b-chat", Messages: messages})
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/models/meta/llama-2-70b-chat/predictions", s.paths[1])
+	assert.Empty(t, s.requests[1].Version)
+}
+
+func TestCreateChatCompletionCancel(t *testing.T) {
+	s := newServer(t)
+	cli := newTestClient(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := cli.CreateChatCompletion(ctx, llm.ChatCompletionRequest{Model: "slow", Messages: messages})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"p1-slow"}, s.canceled)
+}
+
+func TestCreateChatCompletionStream(t *testing.T) {
+	s := newServer(t)
+	cli := newTestClient(t, s)
+
+	stream := func(ctx context.Context, model string) (string, error) {
+		dataChan := make(chan llm.ChatCompletionStreamResponse)
+		errChan := make(chan error)
+		go cli.CreateChatCompletionStream(ctx, llm.ChatCompletionRequest{Model: model, Messages: messages}, dataChan, errChan)
+		sb := strings.Builder{}
+		for {
+			select {
+			case data := <-dataChan:
+				sb.WriteString(data.Choices[0].Delta.Content)
+			case err := <-errChan:
+				return sb.String(), err
+			}
+		}
+	}
+
+	content, err := stream(context.Background(), "my-llama")
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, "hello world\nagain", content)
+	assert.True(t, s.requests[0].Stream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	content, err = stream(ctx, "slow")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "hello world\nagain", content)
+	assert.Equal(t, []string{"p2-slow"}, s.canceled)
+}
@@ -0,0 +1,114 @@
+package replicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+const (
+	StatusStarting   = "starting"
+	StatusProcessing = "processing"
+	StatusSucceeded  = "succeeded"
+	StatusFailed     = "failed"
+	StatusCanceled   = "canceled"
+)
+
+type PredictionRequest struct {
+	// Version is empty for official models, which are created by the model url
+	Version string         `json:"version,omitempty"`
+	Input   map[string]any `json:"input"`
+	Stream  bool           `json:"stream,omitempty"`
+}
+
+type Prediction struct {
+	Id        string    `json:"id"`
+	Model     string    `json:"model"`
+	Version   string    `json:"version"`
+	Status    string    `json:"status"`
+	Output    any       `json:"output"`
+	Error     any       `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+	Urls      struct {
+		Get    string `json:"get"`
+		Cancel string `json:"cancel"`
+		Stream string `json:"stream"`
+	} `json:"urls"`
+	Metrics struct {
+		PredictTime      float64 `json:"predict_time"`
+		InputTokenCount  int     `json:"input_token_count"`
+		OutputTokenCount int     `json:"output_token_count"`
+	} `json:"metrics"`
+}
+
+// Done reports whether the prediction reached a terminal status
+func (p Prediction) Done() bool {
+	return p.Status == StatusSucceeded || p.Status == StatusFailed || p.Status == StatusCanceled
+}
+
+// Text joins the output, language models output a list of tokens
+func (p Prediction) Text() string {
+	switch output := p.Output.(type) {
+	case string:
+		return output
+	case []any:
+		sb := strings.Builder{}
+		for _, token := range output {
+			if s, ok := token.(string); ok {
+				sb.WriteString(s)
+			}
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// Err returns the error of a failed or canceled prediction
+func (p Prediction) Err() error {
+	switch p.Status {
+	case StatusFailed:
+		return fmt.Errorf("replicate prediction %s failed: %v", p.Id, p.Error)
+	case StatusCanceled:
+		return fmt.Errorf("replicate prediction %s canceled", p.Id)
+	}
+	return nil
+}
+
+func (p Prediction) ToChatCompletionResponse(model string) llm.ChatCompletionResponse {
+	return llm.ChatCompletionResponse{
+		ID:      p.Id,
+		Object:  "chat.completion",
+		Created: p.CreatedAt.Unix(),
+		Model:   model,
+		Choices: []llm.ChatCompletionChoice{
+			{
+				Message: llm.ChatCompletionMessage{
+					Role:    llm.ChatMessageRoleAssistant,
+					Content: p.Text(),
+				},
+				FinishReason: llm.FinishReasonStop,
+			},
+		},
+		Usage: llm.Usage{
+			PromptTokens:     p.Metrics.InputTokenCount,
+			CompletionTokens: p.Metrics.OutputTokenCount,
+			TotalTokens:      p.Metrics.InputTokenCount + p.Metrics.OutputTokenCount,
+		},
+	}
+}
+
+// StreamError is the data of an error event of the stream
+type StreamError struct {
+	Detail string `json:"detail"`
+}
+
+func parseStreamError(data string) error {
+	var e StreamError
+	if err := json.Unmarshal([]byte(data), &e); err != nil || e.Detail == "" {
+		return fmt.Errorf("replicate stream error: %s", data)
+	}
+	return fmt.Errorf("replicate stream error: %s", e.Detail)
+}
@@ -0,0 +1,70 @@
+package replicate
+
+import (
+	"strings"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/llm/chattemplate"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+)
+
+// buildInput converts req to the input of a language model prediction.
+func buildInput(model llmconfig.ReplicateModel, req llm.ChatCompletionRequest) (map[string]any, error) {
+	input := make(map[string]any)
+	if model.ChatTemplate != "" {
+		prompt, err := chattemplate.Render(model.ChatTemplate, req.Messages, chattemplate.Options{AddGenerationPrompt: true})
+		if err != nil {
+			return nil, err
+		}
+		input["prompt"] = prompt
+	} else {
+		system, prompt := llama2Prompt(req.Messages)
+		input["prompt"] = prompt
+		if system != "" {
+			input["system_prompt"] = system
+		}
+	}
+
+	if req.MaxTokens > 0 {
+		input["max_new_tokens"] = req.MaxTokens
+	}
+	if req.Temperature > 0 {
+		input["temperature"] = req.Temperature
+	}
+	if req.TopP > 0 {
+		input["top_p"] = req.TopP
+	}
+	if len(req.Stop) > 0 {
+		input["stop_sequences"] = strings.Join(req.Stop, ",")
+	}
+	for k, v := range model.Input {
+		input[k] = v
+	}
+	return input, nil
+}
+
+// llama2Prompt returns the system prompt and the conversation for llama 2 chat models on replicate,
+// which wrap the prompt as `[INST] <<SYS>>{system_prompt}<</SYS>> {prompt} [/INST]`, so former turns
+// are closed and reopened inside the prompt.
+func llama2Prompt(messages []llm.ChatCompletionMessage) (string, string) {
+	systems := make([]string, 0)
+	sb := strings.Builder{}
+	lastRole := ""
+	for _, message := range messages {
+		switch message.Role {
+		case llm.ChatMessageRoleSystem:
+			systems = append(systems, message.Content)
+		case llm.ChatMessageRoleAssistant:
+			sb.WriteString(" [/INST] ")
+			sb.WriteString(strings.TrimSpace(message.Content))
+			sb.WriteString(" </s><s>[INST] ")
+		default:
+			if lastRole == llm.ChatMessageRoleUser {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(strings.TrimSpace(message.Content))
+		}
+		lastRole = message.Role
+	}
+	return strings.Join(systems, "\n"), sb.String()
+}
@@ -0,0 +1,163 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// DefaultRetryableStatusCodes are retried when RetryPolicy.RetryableStatusCodes is empty.
+var DefaultRetryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy controls how failed calls are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the count of attempts including the first one, less than 2 disables retries
+	MaxAttempts int
+	// Backoff is the wait before the second attempt, it doubles for every following attempt
+	Backoff time.Duration
+	// MaxBackoff caps the wait between attempts, a longer Retry-After from upstream fails the call
+	// instead of blocking it, zero means no cap
+	MaxBackoff time.Duration
+	// Jitter randomizes the backoff by up to this fraction of it, between 0 and 1
+	Jitter float64
+	// RetryableStatusCodes are the upstream status codes to retry, DefaultRetryableStatusCodes if empty
+	RetryableStatusCodes []int
+	// Retryable overrides which errors are retried
+	Retryable func(err error) bool
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff << (attempt - 1)
+	if d < 0 || (p.MaxBackoff > 0 && d > p.MaxBackoff) {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 && d > 0 {
+		jitter := time.Duration(p.Jitter * float64(d))
+		d = d - jitter + time.Duration(rand.Int63n(int64(jitter)*2+1))
+	}
+	return d
+}
+
+// wait returns how long to wait before the next attempt, Retry-After of upstream takes precedence.
+func (p RetryPolicy) wait(attempt int, err error) (time.Duration, bool) {
+	if retryAfter := RetryAfter(err); retryAfter > 0 {
+		if p.MaxBackoff > 0 && retryAfter > p.MaxBackoff {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+	return p.backoff(attempt), true
+}
+
+// retryable reports whether err is worth another attempt, upstream errors are retried by
+// status code and network errors are always retried.
+func (p RetryPolicy) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	if code, ok := StatusCode(err); ok {
+		codes := p.RetryableStatusCodes
+		if len(codes) == 0 {
+			codes = DefaultRetryableStatusCodes
+		}
+		return slices.Contains(codes, code)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// next returns the wait before the next attempt and whether there should be one.
+func (p RetryPolicy) next(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !p.retryable(ctx, err) {
+		return 0, false
+	}
+	return p.wait(attempt, err)
+}
+
+// WithRetry retries failed calls by policy, streams are only retried when no chunk has been sent yet.
+func WithRetry(policy RetryPolicy) Interceptor {
+	return Interceptor{
+		Unary: func(ctx context.Context, req ChatCompletionRequest, next UnaryInvoker) (ChatCompletionResponse, error) {
+			for attempt := 1; ; attempt++ {
+				resp, err := next(ctx, req)
+				if err == nil {
+					return resp, nil
+				}
+				wait, ok := policy.next(ctx, attempt, err)
+				if !ok {
+					return resp, err
+				}
+				slog.WarnContext(ctx, "chat error, retrying", "model", req.Model, "attempt", attempt, "wait", wait, "err", err)
+				if err := sleepContext(ctx, wait); err != nil {
+					return resp, err
+				}
+			}
+		},
+		Stream: func(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, errChan chan error, next StreamInvoker) {
+			for attempt := 1; ; attempt++ {
+				emitted := false
+				err := relayUntilDone(ctx, req, dataChan, next, func() { emitted = true })
+				if errors.Is(err, io.EOF) || emitted {
+					errChan <- err
+					return
+				}
+				wait, ok := policy.next(ctx, attempt, err)
+				if !ok {
+					errChan <- err
+					return
+				}
+				slog.WarnContext(ctx, "chat stream error, retrying", "model", req.Model, "attempt", attempt, "wait", wait, "err", err)
+				if err := sleepContext(ctx, wait); err != nil {
+					errChan <- err
+					return
+				}
+			}
+		},
+	}
+}
+
+// relayUntilDone relays the chunks of next to dataChan and returns the final error of the stream.
+func relayUntilDone(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, next StreamInvoker, onData func()) error {
+	innerDataChan := make(chan ChatCompletionStreamResponse)
+	innerErrChan := make(chan error)
+	go next(ctx, req, innerDataChan, innerErrChan)
+	for {
+		select {
+		case data := <-innerDataChan:
+			onData()
+			dataChan <- data
+		case err := <-innerErrChan:
+			return err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,151 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// httpClient is a minimal provider talking to the fake upstream
+type httpClient struct {
+	url string
+}
+
+func (c *httpClient) ListModels() []string {
+	return []string{"fake"}
+}
+
+func (c *httpClient) do(ctx context.Context, req ChatCompletionRequest) (*http.Response, error) {
+	body, _ := json.Marshal(req)
+	httpReq, _ := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, NewHTTPError(resp)
+	}
+	return resp, nil
+}
+
+func (c *httpClient) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+	var chatResp ChatCompletionResponse
+	err = json.NewDecoder(resp.Body).Decode(&chatResp)
+	return chatResp, err
+}
+
+func (c *httpClient) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, errChan chan error) {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	defer resp.Body.Close()
+	ParseSSE(resp.Body, dataChan, errChan)
+}
+
+// newUpstream fails the first failures requests with status and headers
+func newUpstream(t *testing.T, failures int32, status int, header map[string]string) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			for k, v := range header {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"error": "fake"}`))
+			return
+		}
+		var req ChatCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Stream {
+			_, _ = fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"hello\"}}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"content": "hello"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestRetryStatusCodes(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	server, calls := newUpstream(t, 2, http.StatusServiceUnavailable, nil)
+	resp, err := Wrap(&httpClient{url: server.URL}, WithRetry(policy)).CreateChatCompletion(context.Background(), ChatCompletionRequest{Model: "fake"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, int32(3), calls.Load())
+
+	server, calls = newUpstream(t, 1, http.StatusBadRequest, nil)
+	_, err = Wrap(&httpClient{url: server.URL}, WithRetry(policy)).CreateChatCompletion(context.Background(), ChatCompletionRequest{Model: "fake"})
+	code, ok := StatusCode(err)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryAfter(t *testing.T) {
+	// the backoff would block the test, Retry-After must win
+	policy := RetryPolicy{MaxAttempts: 2, Backoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	server, calls := newUpstream(t, 1, http.StatusTooManyRequests, map[string]string{"retry-after-ms": "10"})
+	_, err := Wrap(&httpClient{url: server.URL}, WithRetry(policy)).CreateChatCompletion(ctx, ChatCompletionRequest{Model: "fake"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// waiting longer than MaxBackoff fails fast
+	policy.MaxBackoff = time.Second
+	server, calls = newUpstream(t, 1, http.StatusTooManyRequests, map[string]string{"Retry-After": "120"})
+	_, err = Wrap(&httpClient{url: server.URL}, WithRetry(policy)).CreateChatCompletion(ctx, ChatCompletionRequest{Model: "fake"})
+	assert.Error(t, err)
+	assert.Equal(t, 120*time.Second, RetryAfter(err))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryStreamUpstream(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	server, calls := newUpstream(t, 1, http.StatusBadGateway, nil)
+	content, err := collect(Wrap(&httpClient{url: server.URL}, WithRetry(policy)))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", content)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"none", http.Header{}, 0},
+		{"seconds", http.Header{"Retry-After": {"3"}}, 3 * time.Second},
+		{"http date", http.Header{"Retry-After": {now.Add(time.Minute).Format(http.TimeFormat)}}, time.Minute},
+		{"milliseconds", http.Header{"Retry-After-Ms": {"250"}}, 250 * time.Millisecond},
+		{"openai reset", http.Header{"X-Ratelimit-Reset-Requests": {"1s"}, "X-Ratelimit-Reset-Tokens": {"6m0s"}}, 6 * time.Minute},
+		{"unix reset", http.Header{"X-Ratelimit-Reset": {fmt.Sprint(now.Add(30 * time.Second).Unix())}}, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseRetryAfter(tt.header, now))
+		})
+	}
+}
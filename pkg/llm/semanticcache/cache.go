@@ -0,0 +1,261 @@
+package semanticcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+const DefaultThreshold = 0.95
+
+type Config struct {
+	// EmbeddingModel is the model used to embed prompts
+	EmbeddingModel string
+	// Threshold is the minimal cosine similarity to treat two prompts as the same question
+	Threshold float32
+	// TTL is how long a cached answer stays valid, zero means forever
+	TTL time.Duration
+	// MaxEntries bounds the cached answers kept in memory, DefaultMaxEntries is used when it is zero
+	MaxEntries int
+}
+
+// Result is the result of a cache lookup.
+type Result struct {
+	// Hit reports whether a cached answer was found
+	Hit bool
+	// Exact reports whether the cached prompt is exactly the same
+	Exact bool
+	// Similarity is the cosine similarity of the prompt and the cached prompt
+	Similarity float32
+	// Response is the cached answer, only valid when Hit is true
+	Response llm.ChatCompletionResponse
+
+	scope       string
+	model       string
+	contextHash string
+	prompt      string
+	promptHash  string
+	vector      []float32
+}
+
+// Cache answers single turn chat completion requests from previous responses of the same scope, model,
+// system prompt and sampling parameters whose user message is semantically similar.
+type Cache struct {
+	embedder llm.Embedder
+	store    Store
+	config   Config
+	index    *Index
+
+	mu     sync.Mutex
+	loaded map[string]bool
+}
+
+func New(embedder llm.Embedder, store Store, cfg Config) *Cache {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultThreshold
+	}
+	return &Cache{
+		embedder: embedder,
+		store:    store,
+		config:   cfg,
+		index:    NewIndex(cfg.TTL, cfg.MaxEntries),
+		loaded:   make(map[string]bool),
+	}
+}
+
+// Lookup searches a cached answer for req among the answers saved for scope, like the api key of the request.
+// Multi turn requests are never cached because the answer depends on the history.
+// The returned result should be passed to Save when it is a miss.
+func (c *Cache) Lookup(ctx context.Context, scope string, req llm.ChatCompletionRequest) (Result, error) {
+	prompt, ok := singleTurnPrompt(req)
+	if !ok || prompt == "" {
+		return Result{}, nil
+	}
+	contextHash, err := hashContext(req)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{
+		scope:       scope,
+		model:       req.Model,
+		contextHash: contextHash,
+		prompt:      prompt,
+		promptHash:  hashPrompt(prompt),
+	}
+
+	if err := c.load(ctx, req.Model); err != nil {
+		return result, err
+	}
+
+	if entry, ok := c.index.Get(scope, req.Model, contextHash, result.promptHash); ok {
+		result.Hit = true
+		result.Exact = true
+		result.Similarity = 1
+		result.Response = entry.Response
+		slog.DebugContext(ctx, "semantic cache exact hit", "model", req.Model, "entry_id", entry.Id)
+		return result, nil
+	}
+
+	vector, err := c.embed(ctx, prompt, req.User)
+	if err != nil {
+		return result, err
+	}
+	result.vector = vector
+
+	entry, similarity, ok := c.index.Search(scope, req.Model, contextHash, vector)
+	if ok && similarity >= c.config.Threshold {
+		result.Hit = true
+		result.Similarity = similarity
+		result.Response = entry.Response
+		slog.DebugContext(ctx, "semantic cache hit", "model", req.Model, "entry_id", entry.Id, "similarity", similarity)
+		return result, nil
+	}
+	slog.DebugContext(ctx, "semantic cache miss", "model", req.Model, "similarity", similarity)
+	return result, nil
+}
+
+// Save stores resp as the answer of the prompt from a missed lookup.
+func (c *Cache) Save(ctx context.Context, result Result, resp llm.ChatCompletionResponse, messageId string) error {
+	if result.Hit || result.prompt == "" {
+		return nil
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+		return nil
+	}
+
+	vector := result.vector
+	if len(vector) == 0 {
+		var err error
+		if vector, err = c.embed(ctx, result.prompt, ""); err != nil {
+			return err
+		}
+	}
+
+	entry, err := c.store.SaveEntry(ctx, Entry{
+		Scope:          result.scope,
+		Model:          result.model,
+		EmbeddingModel: c.config.EmbeddingModel,
+		MessageId:      messageId,
+		ContextHash:    result.contextHash,
+		Prompt:         result.prompt,
+		PromptHash:     result.promptHash,
+		Vector:         vector,
+		Response:       resp,
+	})
+	if err != nil {
+		return fmt.Errorf("save semantic cache entry error: %w", err)
+	}
+	c.index.Add(entry)
+	return nil
+}
+
+func (c *Cache) load(ctx context.Context, model string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loaded[model] {
+		return nil
+	}
+	entries, err := c.store.ListEntries(ctx, model)
+	if err != nil {
+		return fmt.Errorf("load semantic cache entries error: %w", err)
+	}
+	count := 0
+	for _, entry := range entries {
+		// vectors from another embedding model are not comparable, entries without context or scope
+		// are from before they were part of the key
+		if entry.EmbeddingModel != c.config.EmbeddingModel || entry.ContextHash == "" || entry.Scope == "" {
+			continue
+		}
+		c.index.Add(entry)
+		count++
+	}
+	c.loaded[model] = true
+	slog.DebugContext(ctx, "semantic cache loaded", "model", model, "entries", count)
+	return nil
+}
+
+func (c *Cache) embed(ctx context.Context, prompt, user string) ([]float32, error) {
+	resp, err := c.embedder.CreateEmbeddings(ctx, llm.EmbeddingRequest{
+		Model: c.config.EmbeddingModel,
+		Input: []string{prompt},
+		User:  user,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create embeddings error: %w", err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("create embeddings error: empty response")
+	}
+	return resp.Data[0].Embedding, nil
+}
+
+// singleTurnPrompt returns the user message of a request with system messages and exactly one user message
+func singleTurnPrompt(req llm.ChatCompletionRequest) (string, bool) {
+	if req.N > 1 {
+		return "", false
+	}
+	prompt := ""
+	users := 0
+	for _, message := range req.Messages {
+		switch message.Role {
+		case llm.ChatMessageRoleSystem:
+		case llm.ChatMessageRoleUser:
+			prompt = strings.TrimSpace(message.Content)
+			users++
+		default:
+			return "", false
+		}
+	}
+	return prompt, users == 1
+}
+
+// requestContext is everything besides the prompt which changes the answer of a request
+type requestContext struct {
+	System           []string                 `json:"system,omitempty"`
+	MaxTokens        int                      `json:"max_tokens,omitempty"`
+	Temperature      float32                  `json:"temperature,omitempty"`
+	TopP             float32                  `json:"top_p,omitempty"`
+	Stop             []string                 `json:"stop,omitempty"`
+	PresencePenalty  float32                  `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float32                  `json:"frequency_penalty,omitempty"`
+	LogitBias        map[string]int           `json:"logit_bias,omitempty"`
+	Functions        []llm.FunctionDefinition `json:"functions,omitempty"`
+	FunctionCall     any                      `json:"function_call,omitempty"`
+}
+
+func hashContext(req llm.ChatCompletionRequest) (string, error) {
+	rc := requestContext{
+		MaxTokens:        req.MaxTokens,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		Stop:             req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		LogitBias:        req.LogitBias,
+		Functions:        req.Functions,
+		FunctionCall:     req.FunctionCall,
+	}
+	for _, message := range req.Messages {
+		if message.Role == llm.ChatMessageRoleSystem {
+			rc.System = append(rc.System, message.Content)
+		}
+	}
+	data, err := json.Marshal(rc)
+	if err != nil {
+		return "", fmt.Errorf("hash request context error: %w", err)
+	}
+	return hashPrompt(string(data)), nil
+}
+
+func hashPrompt(prompt string) string {
+	h := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(h[:])
+}
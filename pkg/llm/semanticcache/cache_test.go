@@ -0,0 +1,196 @@
+package semanticcache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeEmbedder struct {
+	vectors map[string][]float32
+	calls   int
+}
+
+func (e *fakeEmbedder) CreateEmbeddings(ctx context.Context, req llm.EmbeddingRequest) (llm.EmbeddingResponse, error) {
+	e.calls++
+	return llm.EmbeddingResponse{
+		Data: []llm.Embedding{{Embedding: e.vectors[req.Input[0]]}},
+	}, nil
+}
+
+func newRequest(model, prompt string) llm.ChatCompletionRequest {
+	req := llm.ChatCompletionRequest{}
+	req.FromPrompt(model, prompt)
+	return req
+}
+
+func newResponse(content string) llm.ChatCompletionResponse {
+	return llm.ChatCompletionResponse{
+		Choices: []llm.ChatCompletionChoice{
+			{Message: llm.ChatCompletionMessage{Role: llm.ChatMessageRoleAssistant, Content: content}},
+		},
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	assert.InDelta(t, 1, CosineSimilarity([]float32{1, 2, 3}, []float32{2, 4, 6}), 1e-6)
+	assert.InDelta(t, 0, CosineSimilarity([]float32{1, 0}, []float32{0, 1}), 1e-6)
+	assert.InDelta(t, -1, CosineSimilarity([]float32{1, 0}, []float32{-1, 0}), 1e-6)
+	assert.Equal(t, float32(0), CosineSimilarity([]float32{1, 0}, []float32{1, 0, 0}))
+}
+
+func TestCacheLookup(t *testing.T) {
+	ctx := context.Background()
+	embedder := &fakeEmbedder{vectors: map[string][]float32{
+		"how do I reset my password?":    {1, 0.1, 0},
+		"how can I reset my password":    {1, 0.12, 0},
+		"what is the weather like today": {0, 0.2, 1},
+	}}
+	cache := New(embedder, NewMemoryStore(), Config{EmbeddingModel: "embedding", Threshold: 0.9})
+
+	result, err := cache.Lookup(ctx, "key", newRequest("gpt-4", "how do I reset my password?"))
+	assert.Nil(t, err)
+	assert.False(t, result.Hit)
+	assert.Nil(t, cache.Save(ctx, result, newResponse("click forgot password"), ""))
+
+	// exact match does not need embeddings
+	calls := embedder.calls
+	result, err = cache.Lookup(ctx, "key", newRequest("gpt-4", "how do I reset my password?"))
+	assert.Nil(t, err)
+	assert.True(t, result.Hit)
+	assert.True(t, result.Exact)
+	assert.Equal(t, calls, embedder.calls)
+	assert.Equal(t, "click forgot password", result.Response.Choices[0].Message.Content)
+
+	result, err = cache.Lookup(ctx, "key", newRequest("gpt-4", "how can I reset my password"))
+	assert.Nil(t, err)
+	assert.True(t, result.Hit)
+	assert.False(t, result.Exact)
+	assert.Greater(t, result.Similarity, float32(0.9))
+
+	result, err = cache.Lookup(ctx, "key", newRequest("gpt-4", "what is the weather like today"))
+	assert.Nil(t, err)
+	assert.False(t, result.Hit)
+
+	// answers are never shared between models
+	result, err = cache.Lookup(ctx, "key", newRequest("gemini-pro", "how do I reset my password?"))
+	assert.Nil(t, err)
+	assert.False(t, result.Hit)
+
+	// nor between api keys
+	result, err = cache.Lookup(ctx, "other key", newRequest("gpt-4", "how do I reset my password?"))
+	assert.Nil(t, err)
+	assert.False(t, result.Hit)
+}
+
+func TestCacheLoadFromStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	contextHash, _ := hashContext(newRequest("gpt-4", "hi"))
+	_, _ = store.SaveEntry(ctx, Entry{Scope: "key", Model: "gpt-4", EmbeddingModel: "embedding", ContextHash: contextHash, Prompt: "hi", PromptHash: hashPrompt("hi"), Vector: []float32{1}, Response: newResponse("hello")})
+	_, _ = store.SaveEntry(ctx, Entry{Scope: "key", Model: "gpt-4", EmbeddingModel: "other", ContextHash: contextHash, Prompt: "hey", PromptHash: hashPrompt("hey"), Vector: []float32{1}, Response: newResponse("hello")})
+
+	cache := New(&fakeEmbedder{vectors: map[string][]float32{"hey": {1}}}, store, Config{EmbeddingModel: "embedding"})
+	result, err := cache.Lookup(ctx, "key", newRequest("gpt-4", "hi"))
+	assert.Nil(t, err)
+	assert.True(t, result.Hit)
+
+	// entries from other embedding models are skipped, but similar vector still hits
+	result, err = cache.Lookup(ctx, "key", newRequest("gpt-4", "hey"))
+	assert.Nil(t, err)
+	assert.True(t, result.Hit)
+	assert.False(t, result.Exact)
+}
+
+func TestCacheLookupContext(t *testing.T) {
+	ctx := context.Background()
+	embedder := &fakeEmbedder{vectors: map[string][]float32{"translate: good morning": {1, 0}}}
+	cache := New(embedder, NewMemoryStore(), Config{EmbeddingModel: "embedding"})
+
+	req := newRequest("gpt-4", "translate: good morning")
+	req.Messages = append([]llm.ChatCompletionMessage{{Role: llm.ChatMessageRoleSystem, Content: "answer in french"}}, req.Messages...)
+	result, err := cache.Lookup(ctx, "key", req)
+	assert.Nil(t, err)
+	assert.Nil(t, cache.Save(ctx, result, newResponse("bonjour"), ""))
+
+	result, err = cache.Lookup(ctx, "key", req)
+	assert.Nil(t, err)
+	assert.True(t, result.Hit)
+
+	// the same prompt with another system prompt or sampling parameters is another question
+	other := req
+	other.Messages = []llm.ChatCompletionMessage{{Role: llm.ChatMessageRoleSystem, Content: "answer in german"}, req.Messages[1]}
+	result, err = cache.Lookup(ctx, "key", other)
+	assert.Nil(t, err)
+	assert.False(t, result.Hit)
+
+	other = req
+	other.Temperature = 1.5
+	result, err = cache.Lookup(ctx, "key", other)
+	assert.Nil(t, err)
+	assert.False(t, result.Hit)
+
+	// multi turn requests are neither answered nor saved
+	other = req
+	other.Messages = append(append([]llm.ChatCompletionMessage{}, req.Messages...),
+		llm.ChatCompletionMessage{Role: llm.ChatMessageRoleAssistant, Content: "bonjour"},
+		llm.ChatCompletionMessage{Role: llm.ChatMessageRoleUser, Content: "translate: good morning"},
+	)
+	calls := embedder.calls
+	result, err = cache.Lookup(ctx, "key", other)
+	assert.Nil(t, err)
+	assert.False(t, result.Hit)
+	assert.Equal(t, calls, embedder.calls)
+	assert.Nil(t, cache.Save(ctx, result, newResponse("bonjour encore"), ""))
+	assert.Equal(t, calls, embedder.calls)
+}
+
+func TestIndexSearchSkipsExpired(t *testing.T) {
+	index := NewIndex(time.Hour, 0)
+	now := time.Now()
+	index.Add(Entry{Id: "expired", Scope: "key", Model: "gpt-4", CreatedAt: now.Add(-time.Hour), PromptHash: "a", Vector: []float32{1, 0}})
+	index.Add(Entry{Id: "valid", Scope: "key", Model: "gpt-4", CreatedAt: now, PromptHash: "b", Vector: []float32{1, 0.1}})
+	// entries which expire between prunes are still in the index, they must be skipped
+	index.mu.Lock()
+	index.entries[groupKey("key", "gpt-4", "")] = append(index.entries[groupKey("key", "gpt-4", "")],
+		indexEntry{entry: Entry{Id: "stale", Scope: "key", Model: "gpt-4", CreatedAt: now.Add(-2 * time.Hour), Vector: []float32{1, 0}}, norm: 1})
+	index.mu.Unlock()
+
+	entry, similarity, ok := index.Search("key", "gpt-4", "", []float32{1, 0})
+	assert.True(t, ok)
+	assert.Equal(t, "valid", entry.Id)
+	assert.Less(t, similarity, float32(1))
+
+	_, ok = index.Get("key", "gpt-4", "", "a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, index.Len("key", "gpt-4", ""))
+
+	_, _, ok = index.Search("other key", "gpt-4", "", []float32{1, 0})
+	assert.False(t, ok)
+}
+
+func TestIndexEvictsOldest(t *testing.T) {
+	index := NewIndex(0, 10)
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 11; i++ {
+		index.Add(Entry{
+			Id:         fmt.Sprint(i),
+			Scope:      "key",
+			Model:      "gpt-4",
+			CreatedAt:  start.Add(time.Duration(i) * time.Minute),
+			PromptHash: fmt.Sprint(i),
+			Vector:     []float32{1, float32(i)},
+		})
+	}
+	// the oldest entries are evicted down to 90% of the max
+	assert.Equal(t, 9, index.Len("key", "gpt-4", ""))
+	_, ok := index.Get("key", "gpt-4", "", "1")
+	assert.False(t, ok)
+	entry, ok := index.Get("key", "gpt-4", "", "2")
+	assert.True(t, ok)
+	assert.Equal(t, "2", entry.Id)
+}
@@ -0,0 +1,229 @@
+package semanticcache
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMaxEntries bounds the entries of an index, the oldest entries are evicted beyond it.
+const DefaultMaxEntries = 10000
+
+// pruneInterval is how often expired entries are removed, lookups skip them in between
+const pruneInterval = time.Minute
+
+// Index is a brute-force in-memory vector index grouped by scope, chat model and request context.
+// It is good enough for tens of thousands of entries, which is what we
+// expect to see for repeated FAQ style prompts.
+type Index struct {
+	// ttl is how long entries are valid, zero means forever
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.RWMutex
+	entries map[string][]indexEntry
+	hashes  map[string]Entry
+	count   int
+	pruned  time.Time
+}
+
+type indexEntry struct {
+	entry Entry
+	norm  float64
+}
+
+// NewIndex returns an index whose entries expire after ttl, zero ttl keeps them forever.
+// At most maxEntries entries are kept, DefaultMaxEntries is used when it is not positive.
+func NewIndex(ttl time.Duration, maxEntries int) *Index {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Index{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string][]indexEntry),
+		hashes:     make(map[string]Entry),
+	}
+}
+
+// Add adds entry to the index, entries without vector are only used for exact match.
+// Expired entries are pruned and the oldest entries are evicted when there are more than maxEntries.
+func (i *Index) Add(entry Entry) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.expired(entry, time.Now()) {
+		return
+	}
+	if entry.PromptHash != "" {
+		i.hashes[hashKey(entry.group(), entry.PromptHash)] = entry
+	}
+	if len(entry.Vector) > 0 {
+		i.entries[entry.group()] = append(i.entries[entry.group()], indexEntry{
+			entry: entry,
+			norm:  norm(entry.Vector),
+		})
+		i.count++
+	}
+	i.prune()
+}
+
+// Len returns the number of valid entries for scope, model and context hash.
+func (i *Index) Len(scope, model, contextHash string) int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	now := time.Now()
+	count := 0
+	for _, e := range i.entries[groupKey(scope, model, contextHash)] {
+		if !i.expired(e.entry, now) {
+			count++
+		}
+	}
+	return count
+}
+
+// Get returns the valid entry for scope, model and context hash with exactly the same prompt hash.
+func (i *Index) Get(scope, model, contextHash, promptHash string) (Entry, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	entry, ok := i.hashes[hashKey(groupKey(scope, model, contextHash), promptHash)]
+	if !ok || i.expired(entry, time.Now()) {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Search returns the most similar valid entry for scope, model and context hash and its cosine similarity.
+// ok is false when there is no valid entry for them.
+func (i *Index) Search(scope, model, contextHash string, vector []float32) (entry Entry, similarity float32, ok bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	now := time.Now()
+	vectorNorm := norm(vector)
+	best := -1.0
+	for _, e := range i.entries[groupKey(scope, model, contextHash)] {
+		// an expired best match must not hide a valid one
+		if i.expired(e.entry, now) {
+			continue
+		}
+		s := cosine(vector, vectorNorm, e.entry.Vector, e.norm)
+		if s > best {
+			best = s
+			entry = e.entry
+			ok = true
+		}
+	}
+	return entry, float32(best), ok
+}
+
+func (i *Index) expired(entry Entry, now time.Time) bool {
+	return i.ttl > 0 && now.Sub(entry.CreatedAt) >= i.ttl
+}
+
+// prune removes the expired entries, then the oldest entries beyond maxEntries, i.mu must be held
+func (i *Index) prune() {
+	now := time.Now()
+	if i.ttl > 0 && now.Sub(i.pruned) >= pruneInterval {
+		i.pruned = now
+		for group, entries := range i.entries {
+			kept := entries[:0]
+			for _, e := range entries {
+				if i.expired(e.entry, now) {
+					i.count--
+					continue
+				}
+				kept = append(kept, e)
+			}
+			i.setGroup(group, kept)
+		}
+		for key, entry := range i.hashes {
+			if i.expired(entry, now) {
+				delete(i.hashes, key)
+			}
+		}
+	}
+	if i.count <= i.maxEntries && len(i.hashes) <= i.maxEntries {
+		return
+	}
+
+	// the entries are evicted down to 90% of maxEntries, so that not every add sorts them again
+	keep := i.maxEntries * 9 / 10
+	var all []Entry
+	for _, entries := range i.entries {
+		for _, e := range entries {
+			all = append(all, e.entry)
+		}
+	}
+	for _, entry := range i.hashes {
+		if len(entry.Vector) == 0 {
+			all = append(all, entry)
+		}
+	}
+	if len(all) <= keep {
+		return
+	}
+	sort.Slice(all, func(a, b int) bool { return all[a].CreatedAt.Before(all[b].CreatedAt) })
+	evicted := make(map[string]bool, len(all)-keep)
+	for _, entry := range all[:len(all)-keep] {
+		evicted[entry.Id] = true
+	}
+	for group, entries := range i.entries {
+		kept := entries[:0]
+		for _, e := range entries {
+			if evicted[e.entry.Id] {
+				i.count--
+				continue
+			}
+			kept = append(kept, e)
+		}
+		i.setGroup(group, kept)
+	}
+	for key, entry := range i.hashes {
+		if evicted[entry.Id] {
+			delete(i.hashes, key)
+		}
+	}
+}
+
+func (i *Index) setGroup(group string, entries []indexEntry) {
+	if len(entries) == 0 {
+		delete(i.entries, group)
+		return
+	}
+	i.entries[group] = entries
+}
+
+// CosineSimilarity returns the cosine similarity of a and b.
+func CosineSimilarity(a, b []float32) float32 {
+	return float32(cosine(a, norm(a), b, norm(b)))
+}
+
+func cosine(a []float32, normA float64, b []float32, normB float64) float64 {
+	if len(a) != len(b) || normA == 0 || normB == 0 {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot / (normA * normB)
+}
+
+func norm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
+
+func hashKey(group, promptHash string) string {
+	return group + ":" + promptHash
+}
+
+// groupKey groups the entries which answer requests of the same scope, model and context,
+// answers are never shared between scopes like api keys
+func groupKey(scope, model, contextHash string) string {
+	return scope + "#" + model + "#" + contextHash
+}
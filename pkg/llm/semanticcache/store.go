@@ -0,0 +1,67 @@
+package semanticcache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/google/uuid"
+)
+
+// Entry is a cached answer for a prompt of a chat model.
+type Entry struct {
+	Id             string                     `json:"id"`
+	CreatedAt      time.Time                  `json:"created_at"`
+	Scope          string                     `json:"scope"`
+	Model          string                     `json:"model"`
+	EmbeddingModel string                     `json:"embedding_model"`
+	MessageId      string                     `json:"message_id"`
+	ContextHash    string                     `json:"context_hash"`
+	Prompt         string                     `json:"prompt"`
+	PromptHash     string                     `json:"prompt_hash"`
+	Vector         []float32                  `json:"vector"`
+	Response       llm.ChatCompletionResponse `json:"response"`
+}
+
+func (e Entry) group() string {
+	return groupKey(e.Scope, e.Model, e.ContextHash)
+}
+
+type Store interface {
+	SaveEntry(ctx context.Context, entry Entry) (Entry, error)
+	ListEntries(ctx context.Context, model string) ([]Entry, error)
+}
+
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) SaveEntry(ctx context.Context, entry Entry) (Entry, error) {
+	if entry.Id == "" {
+		entry.Id = uuid.NewString()
+	}
+	entry.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return entry, nil
+}
+
+func (s *MemoryStore) ListEntries(ctx context.Context, model string) ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entries []Entry
+	for _, entry := range s.entries {
+		if entry.Model == model {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// StreamAccumulator accumulates stream chunks into a single ChatCompletionResponse.
+type StreamAccumulator struct {
+	sb     strings.Builder
+	last   ChatCompletionStreamResponse
+	chunks int
+	usage  *Usage
+	// promptAnnotations and filter are kept from the chunks which have them
+	promptAnnotations []PromptAnnotation
+	filter            *ContentFilterResults
+}
+
+// Add appends the content of the first choice of chunk, usage is kept even from chunks without choices.
+func (a *StreamAccumulator) Add(chunk ChatCompletionStreamResponse) {
+	if chunk.Usage != nil {
+		a.usage = chunk.Usage
+	}
+	if len(chunk.PromptAnnotations) > 0 {
+		a.promptAnnotations = chunk.PromptAnnotations
+	}
+	if len(chunk.Choices) == 0 {
+		return
+	}
+	if filter := chunk.Choices[0].ContentFilterResults; filter != (ContentFilterResults{}) {
+		a.filter = &filter
+	}
+	a.sb.WriteString(chunk.Choices[0].Delta.Content)
+	a.last = chunk
+	a.chunks++
+}
+
+// Chunks returns the count of chunks with choices.
+func (a *StreamAccumulator) Chunks() int {
+	return a.chunks
+}
+
+// Content returns the accumulated content.
+func (a *StreamAccumulator) Content() string {
+	return a.sb.String()
+}
+
+// Response returns the accumulated response, metadata is taken from the last chunk.
+func (a *StreamAccumulator) Response() ChatCompletionResponse {
+	resp := a.last.ToChatCompletionResponse()
+	if len(resp.Choices) == 0 {
+		resp.Choices = []ChatCompletionChoice{{
+			Message: ChatCompletionMessage{Role: ChatMessageRoleAssistant},
+		}}
+	}
+	resp.Choices[0].Message.Content = a.sb.String()
+	if a.usage != nil {
+		resp.Usage = *a.usage
+	}
+	if a.promptAnnotations != nil {
+		resp.PromptAnnotations = a.promptAnnotations
+	}
+	if a.filter != nil {
+		resp.Choices[0].ContentFilterResults = a.filter
+	}
+	return resp
+}
+
+// CompleteStream runs a streaming call and accumulates it into a single response,
+// it is used by providers which only support streaming.
+func CompleteStream(ctx context.Context, req ChatCompletionRequest, stream StreamInvoker) (ChatCompletionResponse, error) {
+	dataChan := make(chan ChatCompletionStreamResponse)
+	errChan := make(chan error)
+	go stream(ctx, req, dataChan, errChan)
+
+	var acc StreamAccumulator
+	for {
+		select {
+		case data := <-dataChan:
+			acc.Add(data)
+		case err := <-errChan:
+			if errors.Is(err, io.EOF) {
+				return acc.Response(), nil
+			}
+			return ChatCompletionResponse{}, err
+		}
+	}
+}
+
+// RelayStream relays the stream of next to dataChan and errChan. onData is called for
+// every chunk and onDone with the final error (io.EOF on success) before they are relayed,
+// both of them can be nil.
+func RelayStream(ctx context.Context, req ChatCompletionRequest, dataChan chan ChatCompletionStreamResponse, errChan chan error,
+	next StreamInvoker, onData func(ChatCompletionStreamResponse), onDone func(error),
+) {
+	innerDataChan := make(chan ChatCompletionStreamResponse)
+	innerErrChan := make(chan error)
+	go next(ctx, req, innerDataChan, innerErrChan)
+
+	for {
+		select {
+		case data := <-innerDataChan:
+			if onData != nil {
+				onData(data)
+			}
+			dataChan <- data
+		case err := <-innerErrChan:
+			if onDone != nil {
+				onDone(err)
+			}
+			errChan <- err
+			return
+		}
+	}
+}
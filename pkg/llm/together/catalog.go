@@ -0,0 +1,233 @@
+package together
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+)
+
+var cacheModelsFile = filepath.Join(os.TempDir(), "together_models.json")
+
+const (
+	ModelTypeChat     = "chat"
+	ModelTypeLanguage = "language"
+	ModelTypeCode     = "code"
+)
+
+type ModelConfig struct {
+	// ChatTemplate is the jinja chat template of the model
+	ChatTemplate string `json:"chat_template"`
+	// PromptFormat is the legacy prompt format of the model, like `[INST] {prompt} [/INST]`
+	PromptFormat string   `json:"prompt_format"`
+	Stop         []string `json:"stop"`
+	BosToken     string   `json:"bos_token"`
+	EosToken     string   `json:"eos_token"`
+}
+
+type Model struct {
+	Id            string      `json:"id"`
+	Type          string      `json:"type"`
+	DisplayName   string      `json:"display_name"`
+	Organization  string      `json:"organization"`
+	ContextLength int         `json:"context_length"`
+	Config        ModelConfig `json:"config"`
+	// Pricing is in USD per million tokens
+	Pricing struct {
+		Input  float64 `json:"input"`
+		Output float64 `json:"output"`
+	} `json:"pricing"`
+}
+
+func (m Model) ToLLMModel() llm.Model {
+	model := llm.Model{
+		ID:            m.Id,
+		Name:          m.DisplayName,
+		Description:   m.Organization,
+		ContextLength: m.ContextLength,
+	}
+	model.Pricing.Prompt = m.Pricing.Input
+	model.Pricing.Completion = m.Pricing.Output
+	return model
+}
+
+// supportsChat reports whether the model is served by the chat completions endpoint,
+// other models get a raw prompt rendered from their chat template.
+func (m Model) supportsChat() bool {
+	return m.Type == ModelTypeChat
+}
+
+// catalog is the model catalogue of together, it is revalidated with the server by etag
+// once it is older than ttl, so new models show up without restarts.
+type catalog struct {
+	mu          sync.RWMutex
+	ETag        string           `json:"etag"`
+	RefreshedAt time.Time        `json:"refreshed_at"`
+	Models      map[string]Model `json:"models"`
+	ttl         time.Duration
+	file        string
+}
+
+func newCatalog(ttl time.Duration, file string) *catalog {
+	c := &catalog{
+		Models: make(map[string]Model),
+		ttl:    ttl,
+		file:   file,
+	}
+	c.load()
+	return c
+}
+
+func (c *catalog) get(id string) (Model, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	m, ok := c.Models[id]
+	return m, ok
+}
+
+// list returns the models which are able to chat, sorted by id
+func (c *catalog) list() []Model {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	models := make([]Model, 0, len(c.Models))
+	for _, m := range c.Models {
+		switch m.Type {
+		case ModelTypeChat, ModelTypeLanguage, ModelTypeCode:
+			models = append(models, m)
+		}
+	}
+	slices.SortFunc(models, func(a, b Model) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	return models
+}
+
+func (c *catalog) stale() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Since(c.RefreshedAt) > c.ttl
+}
+
+// refresh fetches the catalogue with If-None-Match, an unchanged catalogue only extends its ttl.
+// Servers without etag support are handled by comparing the hash of the body.
+func (c *catalog) refresh(ctx context.Context, cli *Client) error {
+	c.mu.RLock()
+	etag := c.ETag
+	c.mu.RUnlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cli.baseUrl+"/v1/models", nil)
+	if err != nil {
+		return err
+	}
+	cli.setHeaders(req)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := cli.session.Do(req)
+	if err != nil {
+		return fmt.Errorf("list together models error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		c.touch(etag)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("list together models error: %w", llm.NewHTTPError(resp))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read together models error: %w", err)
+	}
+	newEtag := resp.Header.Get("ETag")
+	if newEtag == "" {
+		sum := sha256.Sum256(body)
+		newEtag = `"` + hex.EncodeToString(sum[:]) + `"`
+	}
+	if newEtag == etag {
+		c.touch(etag)
+		return nil
+	}
+
+	var models []Model
+	if err := json.Unmarshal(body, &models); err != nil {
+		return fmt.Errorf("decode together models error: %w", err)
+	}
+	mapping := make(map[string]Model, len(models))
+	for _, m := range models {
+		mapping[m.Id] = m
+	}
+
+	c.mu.Lock()
+	added := 0
+	for id := range mapping {
+		if _, ok := c.Models[id]; !ok {
+			added++
+		}
+	}
+	c.Models = mapping
+	c.ETag = newEtag
+	c.RefreshedAt = time.Now()
+	c.mu.Unlock()
+	slog.Info("refresh together models success", "models", len(mapping), "added", added)
+	c.save()
+	return nil
+}
+
+func (c *catalog) touch(etag string) {
+	c.mu.Lock()
+	c.ETag = etag
+	c.RefreshedAt = time.Now()
+	c.mu.Unlock()
+	c.save()
+}
+
+// load reads the catalogue cached by a former process
+func (c *catalog) load() {
+	if c.file == "" {
+		return
+	}
+	data, err := os.ReadFile(c.file)
+	if err != nil {
+		slog.Debug("read together models cache error", "err", err, "file", c.file)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := json.Unmarshal(data, c); err != nil {
+		slog.Error("decode together models cache error", "err", err, "file", c.file)
+		return
+	}
+	if c.Models == nil {
+		c.Models = make(map[string]Model)
+	}
+}
+
+func (c *catalog) save() {
+	if c.file == "" {
+		return
+	}
+	c.mu.RLock()
+	data, err := json.Marshal(c)
+	c.mu.RUnlock()
+	if err != nil {
+		slog.Error("encode together models cache error", "err", err)
+		return
+	}
+	if err := os.WriteFile(c.file, data, 0o600); err != nil {
+		slog.Error("save together models cache error", "err", err, "file", c.file)
+	}
+}
@@ -7,21 +7,22 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/llm/chattemplate"
 	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
 )
 
 const baseUrl = "https://api.together.xyz"
 
-var cacheModelsFile = filepath.Join(os.TempDir(), "together_models.json")
-
 type Client struct {
 	session *http.Client
 	baseUrl string
 	Apikey  string `json:"apikey"`
+	models  []string
+	catalog *catalog
 }
 
 func NewClient(cfg llmconfig.Config) (*Client, error) {
@@ -32,10 +33,16 @@ func NewClient(cfg llmconfig.Config) (*Client, error) {
 		return nil, err
 	}
 
+	ttl := cfg.Together.CatalogTTL
+	if ttl <= 0 {
+		ttl = llmconfig.DefaultTogetherCatalogTTL
+	}
 	client := &Client{
 		baseUrl: baseUrl,
 		session: http.DefaultClient,
 		Apikey:  cfg.ApiKey,
+		models:  cfg.Models,
+		catalog: newCatalog(ttl, cacheModelsFile),
 	}
 	if cfg.BaseUrl != "" {
 		client.baseUrl = cfg.BaseUrl
@@ -59,103 +66,127 @@ func (c *Client) WithBaseUrl(url string) *Client {
 	return c
 }
 
-type Model struct {
-	Id            string `json:"_id"`
-	Name          string `json:"name"`
-	DisplayName   string `json:"display_name"`
-	DisplayType   string `json:"display_type"`
-	Description   string `json:"description"`
-	ContextLength int    `json:"context_length"`
-	Config        struct {
-		ChatPrompt   string   `json:"chat_prompt"`
-		PromptFormat string   `json:"prompt_format"`
-		Stop         []string `json:"stop"`
-	} `json:"config"`
-}
-
 func (c *Client) setHeaders(req *http.Request) {
 	req.Header.Set("Authorization", "Bearer "+c.Apikey)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "TogetherPythonOfficial/0.2.10")
 }
 
-func readModelsCache() []string {
-	slog.Debug("read models cache", "file", cacheModelsFile)
-	modelsFile, err := os.Open(cacheModelsFile)
-	if err != nil {
-		slog.Error("list models", "err", err)
-		return []string{}
-	}
-	defer modelsFile.Close()
-	var models []Model
-	if err := json.NewDecoder(modelsFile).Decode(&models); err != nil {
-		slog.Error("list models", "err", err)
-		return []string{}
-	}
-	var modelNames []string
-	for _, model := range models {
-		if model.DisplayType == "chat" {
-			modelNames = append(modelNames, model.Name)
-		}
+// refreshCatalog revalidates the model catalogue once it is stale, errors keep the current catalogue
+func (c *Client) refreshCatalog() {
+	if !c.catalog.stale() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.catalog.refresh(ctx, c); err != nil {
+		slog.Error("refresh together models error", "err", err)
 	}
-	return modelNames
 }
 
-func saveModelsCache(models []any) {
-	modelsFile, err := os.Create(cacheModelsFile)
-	if err != nil {
-		slog.Error("list models", "err", err)
-		return
+// Models returns the catalogue of chat models, it is revalidated with the server once it is stale.
+func (c *Client) Models() []Model {
+	c.refreshCatalog()
+	return c.catalog.list()
+}
+
+// ListModels returns the configured models, or all chat models of the catalogue when none is configured.
+func (c *Client) ListModels() []string {
+	if len(c.models) > 0 {
+		return c.models
 	}
-	defer modelsFile.Close()
-	if err := json.NewEncoder(modelsFile).Encode(models); err != nil {
-		slog.Error("list models", "err", err)
-		return
+	models := make([]string, 0)
+	for _, m := range c.Models() {
+		models = append(models, m.Id)
+	}
+	return models
+}
+
+func (c *Client) DescribeModels() []llm.Model {
+	models := make([]llm.Model, 0)
+	for _, id := range c.ListModels() {
+		if m, ok := c.catalog.get(id); ok {
+			models = append(models, m.ToLLMModel())
+		} else {
+			models = append(models, llm.Model{ID: id, Name: id})
+		}
 	}
+	return models
 }
 
-func (c *Client) getModelsFromServer() []any {
-	models := make([]any, 0)
-	req, _ := http.NewRequest("GET", c.baseUrl+"/models/info", nil)
-	c.setHeaders(req)
-	resp, err := c.session.Do(req)
+// newRequest builds the request for the chat completions endpoint, or the completions endpoint
+// with a raw prompt for models which are not able to chat.
+func (c *Client) newRequest(ctx context.Context, req llm.ChatCompletionRequest) (*http.Request, error) {
+	model, ok := c.catalog.get(req.Model)
+	var (
+		path string
+		body any
+	)
+	if !ok || model.supportsChat() {
+		path = "/v1/chat/completions"
+		body = req
+	} else {
+		prompt, err := renderPrompt(model, req.Messages)
+		if err != nil {
+			return nil, fmt.Errorf("render prompt of %s error: %w", req.Model, err)
+		}
+		togReq := &TogetherCompletionRequest{}
+		togReq.FromChatCompletionRequest(req, prompt, model.Config.Stop)
+		path = "/v1/completions"
+		body = togReq
+	}
+
+	reqBody, err := json.Marshal(body)
 	if err != nil {
-		slog.Error("list models", "err", err)
-		return models
+		return nil, fmt.Errorf("marshal request error: %w", err)
 	}
-	defer resp.Body.Close()
-	if err := json.NewDecoder(resp.Body).Decode(&models); err != nil {
-		slog.Error("list models", "err", err)
-		return models
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseUrl+path, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
 	}
-	return models
+	c.setHeaders(httpReq)
+	return httpReq, nil
 }
 
-func (c *Client) ListModels() []string {
-	if _, err := os.Stat(cacheModelsFile); err != nil {
-		models := c.getModelsFromServer()
-		saveModelsCache(models)
+// renderPrompt renders messages by the chat template of the model, then the legacy prompt format
+func renderPrompt(model Model, messages []llm.ChatCompletionMessage) (string, error) {
+	if model.Config.ChatTemplate != "" {
+		return chattemplate.Render(model.Config.ChatTemplate, messages, chattemplate.Options{
+			BosToken:            model.Config.BosToken,
+			EosToken:            model.Config.EosToken,
+			AddGenerationPrompt: true,
+		})
+	}
+
+	format := model.Config.PromptFormat
+	if format == "" {
+		format = "{prompt}"
+	}
+	sb := strings.Builder{}
+	for _, message := range messages {
+		if message.Role == llm.ChatMessageRoleAssistant {
+			sb.WriteString(message.Content)
+			continue
+		}
+		sb.WriteString(strings.ReplaceAll(format, "{prompt}", message.Content))
 	}
-	return readModelsCache()
+	return sb.String(), nil
 }
 
 func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
 	req.Stream = false
-	togReq := &TogetherChatRequest{}
-	togReq.FromChatCompletionRequest(req)
-	reqBody, err := json.Marshal(togReq)
+	httpReq, err := c.newRequest(ctx, req)
 	if err != nil {
-		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion marshal request error: %w", err)
+		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion error: %w", err)
 	}
-
-	httpReq, _ := http.NewRequest("POST", c.baseUrl+"/v1/completions", bytes.NewBuffer(reqBody))
-	c.setHeaders(httpReq)
 	resp, err := c.session.Do(httpReq)
 	if err != nil {
 		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion error: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion error: %w", llm.NewHTTPError(resp))
+	}
 	var togResp TogetherChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&togResp); err != nil {
 		return llm.ChatCompletionResponse{}, fmt.Errorf("create chat completion decode response error: %w", err)
@@ -166,22 +197,21 @@ func (c *Client) CreateChatCompletion(ctx context.Context, req llm.ChatCompletio
 
 func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest, dataChan chan llm.ChatCompletionStreamResponse, errChan chan error) {
 	req.Stream = true
-	togReq := &TogetherChatRequest{}
-	togReq.FromChatCompletionRequest(req)
-	reqBody, err := json.Marshal(togReq)
+	httpReq, err := c.newRequest(ctx, req)
 	if err != nil {
-		errChan <- fmt.Errorf("create chat completion stream marshal request error: %w", err)
+		errChan <- fmt.Errorf("create chat completion stream error: %w", err)
 		return
 	}
-
-	httpReq, _ := http.NewRequest("POST", c.baseUrl+"/v1/completions", bytes.NewBuffer(reqBody))
-	c.setHeaders(httpReq)
 	resp, err := c.session.Do(httpReq)
 	if err != nil {
 		errChan <- fmt.Errorf("create chat completion stream error: %w", err)
 		return
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		errChan <- fmt.Errorf("create chat completion stream error: %w", llm.NewHTTPError(resp))
+		return
+	}
 
 	innerDataChan := make(chan TogetherChatResponse)
 	defer close(innerDataChan)
@@ -0,0 +1,133 @@
+package together
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	llmconfig "github.com/Vaayne/aienvoy/pkg/llm/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServer struct {
+	*httptest.Server
+	models   []Model
+	etag     string
+	fetched  int
+	notMod   int
+	prompts  []string
+	chatReqs []llm.ChatCompletionRequest
+}
+
+// newServer fakes together with a chat model and a language model which needs a raw prompt
+func newServer(t *testing.T) *fakeServer {
+	s := &fakeServer{etag: `"v1"`}
+	s.models = []Model{
+		{Id: "meta-llama/Llama-2-70b-chat-hf", Type: ModelTypeChat, ContextLength: 4096},
+		{Id: "mistralai/Mixtral-8x7B-v0.1", Type: ModelTypeLanguage, Config: ModelConfig{
+			ChatTemplate: "{{ bos_token }}{% for message in messages %}{{ '[INST] ' + message['content'] + ' [/INST]' }}{% endfor %}",
+			BosToken:     "<s>",
+			Stop:         []string{"</s>"},
+		}},
+		{Id: "stabilityai/stable-diffusion-xl", Type: "image"},
+	}
+	s.models[0].Pricing.Input = 0.9
+	s.models[0].Pricing.Output = 0.9
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == s.etag {
+			s.notMod++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.fetched++
+		w.Header().Set("ETag", s.etag)
+		_ = json.NewEncoder(w).Encode(s.models)
+	})
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		s.chatReqs = append(s.chatReqs, req)
+		_, _ = w.Write([]byte(`{"id": "1", "object": "chat.completion", "created": 1700000000, "model": "meta-llama/Llama-2-70b-chat-hf", "choices": [{"index": 0, "message": {"role": "assistant", "content": "hello"}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4}}`))
+	})
+	mux.HandleFunc("/v1/completions", func(w http.ResponseWriter, r *http.Request) {
+		var req TogetherCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		s.prompts = append(s.prompts, req.Prompt)
+		assert.Equal(t, []string{"</s>"}, req.Stop)
+		_, _ = w.Write([]byte(`{"id": "2", "object": "text_completion", "created": 1700000000, "model": "mistralai/Mixtral-8x7B-v0.1", "choices": [{"index": 0, "text": "world", "finish_reason": "stop"}]}`))
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestClient(t *testing.T, s *fakeServer, ttl time.Duration) *Client {
+	cacheModelsFile = filepath.Join(t.TempDir(), "together_models.json")
+	cli, err := NewClient(llmconfig.Config{
+		LLMType:  llmconfig.LLMTypeTogether,
+		ApiKey:   "key",
+		BaseUrl:  s.URL,
+		Together: llmconfig.TogetherConfig{CatalogTTL: ttl},
+	})
+	require.NoError(t, err)
+	return cli
+}
+
+func TestCatalogRefresh(t *testing.T) {
+	s := newServer(t)
+	cli := newTestClient(t, s, time.Hour)
+
+	assert.Equal(t, []string{"meta-llama/Llama-2-70b-chat-hf", "mistralai/Mixtral-8x7B-v0.1"}, cli.ListModels())
+	// fresh catalogue is not fetched again
+	cli.ListModels()
+	assert.Equal(t, 1, s.fetched)
+
+	// stale catalogue is revalidated by etag
+	cli.catalog.RefreshedAt = time.Time{}
+	cli.ListModels()
+	assert.Equal(t, 1, s.fetched)
+	assert.Equal(t, 1, s.notMod)
+
+	// a new model shows up once the etag changes
+	s.models = append(s.models, Model{Id: "Qwen/Qwen1.5-72B-Chat", Type: ModelTypeChat})
+	s.etag = `"v2"`
+	cli.catalog.RefreshedAt = time.Time{}
+	assert.Contains(t, cli.ListModels(), "Qwen/Qwen1.5-72B-Chat")
+	assert.Equal(t, 2, s.fetched)
+
+	models := cli.DescribeModels()
+	assert.Equal(t, "meta-llama/Llama-2-70b-chat-hf", models[1].ID)
+	assert.Equal(t, 4096, models[1].ContextLength)
+	assert.Equal(t, 0.9, models[1].Pricing.Prompt)
+
+	// a new client starts from the cached catalogue
+	cached := newCatalog(time.Hour, cacheModelsFile)
+	assert.Equal(t, `"v2"`, cached.ETag)
+	assert.Len(t, cached.list(), 3)
+}
+
+func TestChatCompletion(t *testing.T) {
+	s := newServer(t)
+	cli := newTestClient(t, s, time.Hour)
+	cli.ListModels()
+
+	messages := []llm.ChatCompletionMessage{{Role: llm.ChatMessageRoleUser, Content: "hi"}}
+	resp, err := cli.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "meta-llama/Llama-2-70b-chat-hf", Messages: messages})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, 4, resp.Usage.TotalTokens)
+	assert.Len(t, s.chatReqs, 1)
+
+	resp, err = cli.CreateChatCompletion(context.Background(), llm.ChatCompletionRequest{Model: "mistralai/Mixtral-8x7B-v0.1", Messages: messages})
+	require.NoError(t, err)
+	assert.Equal(t, "world", resp.Choices[0].Message.Content)
+	assert.Equal(t, []string{"<s>[INST] hi [/INST]"}, s.prompts)
+}
@@ -1,67 +1,58 @@
 package together
 
 import (
-	"log/slog"
-	"time"
-
 	"github.com/Vaayne/aienvoy/pkg/llm"
 )
 
-// var defaultStops = []string{"</s>", "user:", "<|im_start|>", "<|im_end|>"}
-
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-	Name    string `json:"name,omitempty"`
+// TogetherCompletionRequest is the request of the completions endpoint, for models without chat support
+type TogetherCompletionRequest struct {
+	Model             string   `json:"model"`  // required
+	Prompt            string   `json:"prompt"` // required
+	MaxTokens         int      `json:"max_tokens,omitempty"`
+	Stop              []string `json:"stop,omitempty"`
+	Temperature       float64  `json:"temperature,omitempty"`
+	TopP              float64  `json:"top_p,omitempty"`
+	TopK              int      `json:"top_k,omitempty"`
+	RepetitionPenalty float64  `json:"repetition_penalty,omitempty"`
+	Logprobs          int      `json:"logprobs,omitempty"`
+	Stream            bool     `json:"stream"`
 }
 
-type TogetherChatRequest struct {
-	// Prompt            string    `json:"prompt"` // required
-	Model             string    `json:"model"`      // required
-	Messages          []Message `json:"messages"`   // required
-	MaxTokens         int       `json:"max_tokens"` // required
-	Stop              []string  `json:"stop,omitempty"`
-	Temperature       float64   `json:"temperature,omitempty"`
-	TopP              float64   `json:"top_p,omitempty"`
-	TopK              int       `json:"top_k,omitempty"`
-	RepetitionPenalty int       `json:"repetition_penalty,omitempty"`
-	Logprobs          int       `json:"logprobs,omitempty"`
-	Stream            bool      `json:"stream"`
-}
-
-func (r *TogetherChatRequest) FromChatCompletionRequest(req llm.ChatCompletionRequest) {
+func (r *TogetherCompletionRequest) FromChatCompletionRequest(req llm.ChatCompletionRequest, prompt string, stops []string) {
 	r.Model = req.Model
-	// r.Prompt = req.ToPrompt()
-	for _, message := range req.Messages {
-		r.Messages = append(r.Messages, Message{
-			Role:    message.Role,
-			Content: message.Content,
-			Name:    message.Name,
-		})
-	}
+	r.Prompt = prompt
 	r.MaxTokens = req.MaxTokens
 	r.Temperature = float64(req.Temperature)
 	r.TopP = float64(req.TopP)
 	r.Stream = req.Stream
-	// req.Stop = defaultStops
-	// if len(req.Stop) > 0 {
-	// 	r.Stop = append(r.Stop, req.Stop...)
-	// }
-
-	slog.Info("from chat completion request", "tog req", r)
+	r.Stop = append(append([]string{}, stops...), req.Stop...)
 }
 
+// TogetherChatResponseChoice is a choice of both endpoints, the completions endpoint fills Text
+// and the chat completions endpoint fills Message or Delta.
 type TogetherChatResponseChoice struct {
-	FinishReason string `json:"finish_reason"`
-	Index        int    `json:"index"`
-	Text         string `json:"text"`
+	FinishReason string                              `json:"finish_reason"`
+	Index        int                                 `json:"index"`
+	Text         string                              `json:"text"`
+	Message      llm.ChatCompletionMessage           `json:"message"`
+	Delta        llm.ChatCompletionStreamChoiceDelta `json:"delta"`
+}
+
+func (c TogetherChatResponseChoice) content() string {
+	switch {
+	case c.Message.Content != "":
+		return c.Message.Content
+	case c.Delta.Content != "":
+		return c.Delta.Content
+	}
+	return c.Text
 }
 
 // TogetherChatResponseChoice to llm.ChatCompletionChoice
 func (c TogetherChatResponseChoice) ToChatCompletionChoice() llm.ChatCompletionChoice {
 	return llm.ChatCompletionChoice{
 		Message: llm.ChatCompletionMessage{
-			Content: c.Text,
+			Content: c.content(),
 			Role:    llm.ChatMessageRoleAssistant,
 		},
 		Index:        c.Index,
@@ -72,7 +63,7 @@ func (c TogetherChatResponseChoice) ToChatCompletionChoice() llm.ChatCompletionC
 func (c TogetherChatResponseChoice) ToChatCompletionStreamChoice() llm.ChatCompletionStreamChoice {
 	return llm.ChatCompletionStreamChoice{
 		Delta: llm.ChatCompletionStreamChoiceDelta{
-			Content: c.Text,
+			Content: c.content(),
 			Role:    llm.ChatMessageRoleAssistant,
 		},
 		Index:        c.Index,
@@ -83,9 +74,10 @@ func (c TogetherChatResponseChoice) ToChatCompletionStreamChoice() llm.ChatCompl
 type TogetherChatResponse struct {
 	Id      string                       `json:"id"`
 	Choices []TogetherChatResponseChoice `json:"choices"`
-	Created time.Time                    `json:"created"`
+	Created int64                        `json:"created"`
 	Model   string                       `json:"model"`
 	Object  string                       `json:"object"`
+	Usage   llm.Usage                    `json:"usage"`
 }
 
 func (r TogetherChatResponse) ToChatCompletionResponse() llm.ChatCompletionResponse {
@@ -97,7 +89,9 @@ func (r TogetherChatResponse) ToChatCompletionResponse() llm.ChatCompletionRespo
 	}
 	resp.Model = r.Model
 	resp.ID = r.Id
-	resp.Created = r.Created.Unix()
+	resp.Object = r.Object
+	resp.Created = r.Created
+	resp.Usage = r.Usage
 	return resp
 }
 
@@ -106,11 +100,11 @@ func (r TogetherChatResponse) ToChatCompletionStreamResponse() llm.ChatCompletio
 		Choices: make([]llm.ChatCompletionStreamChoice, len(r.Choices)),
 	}
 	for i, choice := range r.Choices {
-		// slog.Info("choice", "choice", choice, "stream choice", choice.ToChatCompletionStreamChoice())
 		resp.Choices[i] = choice.ToChatCompletionStreamChoice()
 	}
 	resp.Model = r.Model
 	resp.ID = r.Id
-	resp.Created = r.Created.Unix()
+	resp.Object = r.Object
+	resp.Created = r.Created
 	return resp
 }
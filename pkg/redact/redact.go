@@ -0,0 +1,133 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+const DefaultReplacement = "[REDACTED]"
+
+// Rule replaces every match of Pattern with Replacement.
+type Rule struct {
+	Name        string `json:"name" yaml:"name" mapstructure:"name"`
+	Pattern     string `json:"pattern" yaml:"pattern" mapstructure:"pattern"`
+	Replacement string `json:"replacement" yaml:"replacement" mapstructure:"replacement"`
+}
+
+// SecretRules match credentials which should never be persisted or logged.
+var SecretRules = []Rule{
+	{Name: "openai_key", Pattern: `sk-[A-Za-z0-9_\-]{16,}`},
+	{Name: "bearer_token", Pattern: `(?i)(bearer|token)\s+[A-Za-z0-9_\-\.=]{16,}`, Replacement: "$1 " + DefaultReplacement},
+	{Name: "aws_access_key", Pattern: `\b(AKIA|ASIA)[A-Z0-9]{16}\b`},
+	{Name: "google_api_key", Pattern: `AIza[0-9A-Za-z_\-]{35}`},
+	{Name: "github_token", Pattern: `\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}\b`},
+	{Name: "url_key", Pattern: `(?i)([?&](key|api_key|apikey|token|access_token)=)[^&\s"]+`, Replacement: "${1}" + DefaultReplacement},
+}
+
+// PIIRules match personal identifiable information.
+var PIIRules = []Rule{
+	{Name: "email", Pattern: `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`, Replacement: "[EMAIL]"},
+	{Name: "credit_card", Pattern: `\b(?:\d[ \-]?){13,16}\b`, Replacement: "[CREDIT_CARD]"},
+	{Name: "phone", Pattern: `\+?\d{1,3}[ \-]?\(?\d{2,4}\)?[ \-]?\d{3,4}[ \-]?\d{3,4}\b`, Replacement: "[PHONE]"},
+}
+
+// sensitiveHeaders are always redacted by Headers
+var sensitiveHeaders = []string{"Authorization", "Api-Key", "X-Api-Key", "X-Goog-Api-Key", "Cookie", "Set-Cookie", "X-Amz-Security-Token"}
+
+type rule struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// Redactor redacts text by a list of regexp rules.
+type Redactor struct {
+	rules []rule
+}
+
+func New(rules ...Rule) (*Redactor, error) {
+	r := &Redactor{}
+	for _, ru := range rules {
+		re, err := regexp.Compile(ru.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile redact rule %s error: %w", ru.Name, err)
+		}
+		replacement := ru.Replacement
+		if replacement == "" {
+			replacement = DefaultReplacement
+		}
+		r.rules = append(r.rules, rule{name: ru.Name, re: re, replacement: replacement})
+	}
+	return r, nil
+}
+
+// Redact returns text with all rule matches replaced.
+func (r *Redactor) Redact(text string) string {
+	for _, ru := range r.rules {
+		text = ru.re.ReplaceAllString(text, ru.replacement)
+	}
+	return text
+}
+
+// RedactBytes is like Redact but for bytes.
+func (r *Redactor) RedactBytes(data []byte) []byte {
+	for _, ru := range r.rules {
+		data = ru.re.ReplaceAll(data, []byte(ru.replacement))
+	}
+	return data
+}
+
+// RedactJSON is like RedactBytes for json documents, only string values are redacted so that
+// numbers and the structure of the document are kept valid.
+func (r *Redactor) RedactJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// numbers are kept as they are instead of float64
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, fmt.Errorf("decode json error: %w", err)
+	}
+	return json.Marshal(r.redactValue(v))
+}
+
+func (r *Redactor) redactValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.Redact(v)
+	case map[string]any:
+		for key, value := range v {
+			v[key] = r.redactValue(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = r.redactValue(value)
+		}
+	}
+	return v
+}
+
+var secretRedactor, _ = New(SecretRules...)
+
+// Secrets redacts SecretRules from text.
+func Secrets(text string) string {
+	return secretRedactor.Redact(text)
+}
+
+// Headers returns a copy of headers with credentials redacted, useful for logging.
+func Headers(headers http.Header) http.Header {
+	h := headers.Clone()
+	for _, key := range sensitiveHeaders {
+		if h.Get(key) != "" {
+			h.Set(key, DefaultReplacement)
+		}
+	}
+	for _, values := range h {
+		for i, v := range values {
+			values[i] = Secrets(v)
+		}
+	}
+	return h
+}
@@ -0,0 +1,103 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/session"
+)
+
+const (
+	defaultOpenAIBaseUrl     = "https://api.openai.com/v1"
+	defaultTranscribeModel   = "whisper-1"
+	defaultSynthesizeModel   = "tts-1"
+	defaultVoice             = "alloy"
+	maxSynthesizeInputLength = 4096
+)
+
+// OpenAI is the audio api of openai, it transcribes by whisper and synthesizes by tts
+type OpenAI struct {
+	session *session.Session
+	config  Config
+}
+
+func NewOpenAI(cfg Config) *OpenAI {
+	return &OpenAI{session: session.New(), config: cfg}
+}
+
+func (o *OpenAI) baseUrl() string {
+	if o.config.BaseUrl != "" {
+		return strings.TrimSuffix(o.config.BaseUrl, "/")
+	}
+	return defaultOpenAIBaseUrl
+}
+
+func (o *OpenAI) Transcribe(ctx context.Context, audio io.Reader, filename string) (string, error) {
+	model := o.config.Model
+	if model == "" {
+		model = defaultTranscribeModel
+	}
+	fields := map[string]string{"model": model, "response_format": "json"}
+	if o.config.Language != "" {
+		fields["language"] = o.config.Language
+	}
+	body, contentType, err := multipartBody(audio, filename, fields)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseUrl()+"/audio/transcriptions", body)
+	if err != nil {
+		return "", fmt.Errorf("create transcription request error: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	o.setAuth(req)
+	return doTranscribe(o.session, req)
+}
+
+func (o *OpenAI) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	if runes := []rune(text); len(runes) > maxSynthesizeInputLength {
+		text = string(runes[:maxSynthesizeInputLength])
+	}
+	model, voice := o.config.Model, o.config.Voice
+	if model == "" {
+		model = defaultSynthesizeModel
+	}
+	if voice == "" {
+		voice = defaultVoice
+	}
+	body, _ := json.Marshal(map[string]string{
+		"model":           model,
+		"input":           text,
+		"voice":           voice,
+		"response_format": "opus",
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseUrl()+"/audio/speech", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create speech request error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	o.setAuth(req)
+	resp, err := o.session.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("synthesize speech error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("synthesize speech error: %w", llm.NewHTTPError(resp))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (o *OpenAI) setAuth(req *http.Request) {
+	if o.config.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.config.ApiKey)
+	}
+}
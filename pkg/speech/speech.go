@@ -0,0 +1,84 @@
+// Package speech transcribes voice messages and synthesizes spoken answers.
+package speech
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+type Type string
+
+const (
+	// TypeOpenAI is the audio api of openai, or of a compatible server
+	TypeOpenAI Type = "openai"
+	// TypeWhisperCpp is the http server of whisper.cpp, it only transcribes
+	TypeWhisperCpp Type = "whispercpp"
+)
+
+// Transcriber converts speech to text
+type Transcriber interface {
+	// Transcribe reads the audio file, filename tells the format of it like voice.ogg
+	Transcribe(ctx context.Context, audio io.Reader, filename string) (string, error)
+}
+
+// Synthesizer converts text to speech
+type Synthesizer interface {
+	// Synthesize returns the speech of text as OGG/Opus, the format of telegram voice messages
+	Synthesize(ctx context.Context, text string) ([]byte, error)
+}
+
+// Config of a speech backend
+type Config struct {
+	Type Type `json:"type" yaml:"type" mapstructure:"type"`
+	// BaseUrl defaults to https://api.openai.com/v1 for openai, it is required by whispercpp
+	BaseUrl string `json:"base_url" yaml:"base_url" mapstructure:"base_url"`
+	ApiKey  string `json:"api_key" yaml:"api_key" mapstructure:"api_key"`
+	// Model defaults to whisper-1 for transcriptions and tts-1 for speech
+	Model string `json:"model" yaml:"model" mapstructure:"model"`
+	// Voice of the synthesized speech, it defaults to alloy
+	Voice string `json:"voice" yaml:"voice" mapstructure:"voice"`
+	// Language is the ISO-639-1 code of the speech, empty detects it
+	Language string `json:"language" yaml:"language" mapstructure:"language"`
+}
+
+// Enabled reports if the backend is configured
+func (c Config) Enabled() bool {
+	return c.Type != ""
+}
+
+func (c Config) Validate() error {
+	switch c.Type {
+	case TypeOpenAI:
+		if c.ApiKey == "" && c.BaseUrl == "" {
+			return fmt.Errorf("api_key is required for openai speech")
+		}
+	case TypeWhisperCpp:
+		if c.BaseUrl == "" {
+			return fmt.Errorf("base_url is required for whispercpp speech")
+		}
+	default:
+		return fmt.Errorf("unsupported speech type %s", c.Type)
+	}
+	return nil
+}
+
+func NewTranscriber(cfg Config) (Transcriber, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Type == TypeWhisperCpp {
+		return NewWhisperCpp(cfg), nil
+	}
+	return NewOpenAI(cfg), nil
+}
+
+func NewSynthesizer(cfg Config) (Synthesizer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Type != TypeOpenAI {
+		return nil, fmt.Errorf("speech type %s can not synthesize speech", cfg.Type)
+	}
+	return NewOpenAI(cfg), nil
+}
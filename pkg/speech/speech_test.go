@@ -0,0 +1,95 @@
+package speech
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAITranscribe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/audio/transcriptions", r.URL.Path)
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "whisper-1", r.FormValue("model"))
+		assert.Equal(t, "de", r.FormValue("language"))
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		data, _ := io.ReadAll(file)
+		assert.Equal(t, "voice.ogg", header.Filename)
+		assert.Equal(t, "OggS", string(data))
+		_, _ = w.Write([]byte(`{"text":" hallo welt "}`))
+	}))
+	defer srv.Close()
+
+	tr, err := NewTranscriber(Config{Type: TypeOpenAI, BaseUrl: srv.URL + "/v1", ApiKey: "sk-test", Language: "de"})
+	require.NoError(t, err)
+	text, err := tr.Transcribe(context.Background(), strings.NewReader("OggS"), "voice.ogg")
+	require.NoError(t, err)
+	assert.Equal(t, "hallo welt", text)
+}
+
+func TestWhisperCppTranscribe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/inference", r.URL.Path)
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "json", r.FormValue("response_format"))
+		_, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		_, _ = w.Write([]byte(`{"text":"hello world\n"}`))
+	}))
+	defer srv.Close()
+
+	tr, err := NewTranscriber(Config{Type: TypeWhisperCpp, BaseUrl: srv.URL + "/"})
+	require.NoError(t, err)
+	text, err := tr.Transcribe(context.Background(), strings.NewReader("OggS"), "voice.ogg")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", text)
+}
+
+func TestTranscribeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid key"}`))
+	}))
+	defer srv.Close()
+
+	tr, err := NewTranscriber(Config{Type: TypeOpenAI, BaseUrl: srv.URL, ApiKey: "sk"})
+	require.NoError(t, err)
+	_, err = tr.Transcribe(context.Background(), strings.NewReader("OggS"), "voice.ogg")
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestOpenAISynthesize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/audio/speech", r.URL.Path)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]string{"model": "tts-1", "input": "hello", "voice": "nova", "response_format": "opus"}, body)
+		_, _ = w.Write([]byte("OggS-opus"))
+	}))
+	defer srv.Close()
+
+	syn, err := NewSynthesizer(Config{Type: TypeOpenAI, BaseUrl: srv.URL, ApiKey: "sk", Voice: "nova"})
+	require.NoError(t, err)
+	audio, err := syn.Synthesize(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "OggS-opus", string(audio))
+}
+
+func TestConfigValidate(t *testing.T) {
+	_, err := NewTranscriber(Config{Type: TypeWhisperCpp})
+	assert.Error(t, err)
+	_, err = NewTranscriber(Config{Type: "unknown", BaseUrl: "http://localhost"})
+	assert.Error(t, err)
+	_, err = NewSynthesizer(Config{Type: TypeWhisperCpp, BaseUrl: "http://localhost"})
+	assert.Error(t, err)
+	assert.False(t, Config{}.Enabled())
+}
@@ -0,0 +1,89 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/Vaayne/aienvoy/pkg/llm"
+	"github.com/Vaayne/aienvoy/pkg/session"
+)
+
+// WhisperCpp is the http server example of whisper.cpp, start it with --convert so that it takes
+// the OGG/Opus of voice messages.
+type WhisperCpp struct {
+	session *session.Session
+	config  Config
+}
+
+func NewWhisperCpp(cfg Config) *WhisperCpp {
+	return &WhisperCpp{session: session.New(), config: cfg}
+}
+
+func (w *WhisperCpp) Transcribe(ctx context.Context, audio io.Reader, filename string) (string, error) {
+	fields := map[string]string{"response_format": "json", "temperature": "0.0"}
+	if w.config.Language != "" {
+		fields["language"] = w.config.Language
+	}
+	body, contentType, err := multipartBody(audio, filename, fields)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(w.config.BaseUrl, "/")+"/inference", body)
+	if err != nil {
+		return "", fmt.Errorf("create transcription request error: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if w.config.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.config.ApiKey)
+	}
+	return doTranscribe(w.session, req)
+}
+
+// multipartBody is the form of transcription requests, the audio is the file field
+func multipartBody(audio io.Reader, filename string, fields map[string]string) (io.Reader, string, error) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, "", fmt.Errorf("create form file error: %w", err)
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return nil, "", fmt.Errorf("read audio error: %w", err)
+	}
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return nil, "", fmt.Errorf("write form field error: %w", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", fmt.Errorf("close form error: %w", err)
+	}
+	return body, mw.FormDataContentType(), nil
+}
+
+// doTranscribe sends req and reads the text of the json response, openai and whisper.cpp share it
+func doTranscribe(s *session.Session, req *http.Request) (string, error) {
+	resp, err := s.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcribe speech error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcribe speech error: %w", llm.NewHTTPError(resp))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode transcription response error: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
@@ -0,0 +1,279 @@
+// Package tgmarkdown renders the markdown of llm answers as the HTML of telegram messages.
+package tgmarkdown
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// block is a fenced code block or a paragraph of text
+type block struct {
+	code bool
+	lang string
+	text string
+}
+
+// parse splits md into paragraphs and code blocks, a code fence which is not closed yet,
+// like the one of a streaming answer, is closed at the end of md.
+func parse(md string) []block {
+	blocks := make([]block, 0)
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	var para []string
+	flush := func() {
+		if text := strings.Trim(strings.Join(para, "\n"), "\n"); strings.TrimSpace(text) != "" {
+			blocks = append(blocks, block{text: text})
+		}
+		para = para[:0]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		fence, lang, ok := openFence(lines[i])
+		if !ok {
+			if strings.TrimSpace(lines[i]) == "" {
+				flush()
+			} else {
+				para = append(para, lines[i])
+			}
+			continue
+		}
+
+		flush()
+		code := make([]string, 0)
+		for i++; i < len(lines); i++ {
+			if closeFence(lines[i], fence) {
+				break
+			}
+			code = append(code, lines[i])
+		}
+		blocks = append(blocks, block{code: true, lang: lang, text: strings.Join(code, "\n")})
+	}
+	flush()
+	return blocks
+}
+
+// openFence returns the fence and the language of a line which opens a code block
+func openFence(line string) (string, string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return "", "", false
+	}
+	for _, marker := range []byte{'`', '~'} {
+		n := 0
+		for n < len(trimmed) && trimmed[n] == marker {
+			n++
+		}
+		if n < 3 {
+			continue
+		}
+		info := strings.TrimSpace(trimmed[n:])
+		if marker == '`' && strings.Contains(info, "`") {
+			return "", "", false
+		}
+		lang, _, _ := strings.Cut(info, " ")
+		return trimmed[:n], lang, true
+	}
+	return "", "", false
+}
+
+func closeFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// Render converts md to telegram HTML, unclosed code fences are closed so partial answers render too.
+func Render(md string) string {
+	blocks := parse(md)
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		parts = append(parts, b.render())
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (b block) render() string {
+	if b.code {
+		return renderCode(b.lang, b.text)
+	}
+	return renderText(b.text)
+}
+
+func renderCode(lang, code string) string {
+	if lang = cleanLang(lang); lang != "" {
+		return `<pre><code class="language-` + lang + `">` + html.EscapeString(code) + "</code></pre>"
+	}
+	return "<pre>" + html.EscapeString(code) + "</pre>"
+}
+
+// cleanLang keeps the characters of language names like c++, c# and objective-c
+func cleanLang(lang string) string {
+	return strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("+#_-", r)) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, lang)
+}
+
+// renderText renders the lines of a paragraph, telegram has no lists and headings so they are emulated.
+func renderText(text string) string {
+	var sb strings.Builder
+	quote := make([]string, 0)
+	flushQuote := func() {
+		if len(quote) > 0 {
+			sb.WriteString("<blockquote>" + strings.Join(quote, "\n") + "</blockquote>\n")
+			quote = quote[:0]
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(trimmed, ">"); ok {
+			quote = append(quote, renderInline(strings.TrimSpace(rest)))
+			continue
+		}
+		flushQuote()
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		switch {
+		case isHeading(trimmed):
+			sb.WriteString("<b>" + renderInline(strings.TrimSpace(strings.TrimLeft(trimmed, "#"))) + "</b>")
+		case isRule(trimmed):
+			sb.WriteString("——————")
+		case len(trimmed) > 1 && strings.ContainsRune("-*+", rune(trimmed[0])) && trimmed[1] == ' ':
+			sb.WriteString(indent + "• " + renderInline(strings.TrimSpace(trimmed[2:])))
+		default:
+			sb.WriteString(indent + renderInline(trimmed))
+		}
+		sb.WriteString("\n")
+	}
+	flushQuote()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func isHeading(line string) bool {
+	n := len(line) - len(strings.TrimLeft(line, "#"))
+	return n >= 1 && n <= 6 && len(line) > n && line[n] == ' '
+}
+
+func isRule(line string) bool {
+	if len(line) < 3 {
+		return false
+	}
+	for _, marker := range []string{"-", "*", "_"} {
+		if strings.Trim(strings.ReplaceAll(line, " ", ""), marker) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// emphases are the inline marks and their tags, longer marks go first
+var emphases = []struct {
+	mark string
+	tag  string
+}{
+	{"**", "b"},
+	{"__", "b"},
+	{"~~", "s"},
+	{"*", "i"},
+	{"_", "i"},
+}
+
+// renderInline renders code spans, links and emphases, marks without a closing mark are kept as text
+// so that the HTML of partial answers is always valid.
+func renderInline(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '`':
+			n := runLen(s, i, '`')
+			if end := strings.Index(s[i+n:], s[i:i+n]); end >= 0 {
+				sb.WriteString("<code>" + html.EscapeString(s[i+n:i+n+end]) + "</code>")
+				i += n + end + n
+				continue
+			}
+			sb.WriteString(s[i : i+n])
+			i += n
+			continue
+		case '[':
+			if text, url, n, ok := parseLink(s[i:]); ok {
+				sb.WriteString(`<a href="` + html.EscapeString(url) + `">` + renderInline(text) + "</a>")
+				i += n
+				continue
+			}
+		case '*', '_', '~':
+			if inner, tag, n, ok := parseEmphasis(s, i); ok {
+				sb.WriteString("<" + tag + ">" + renderInline(inner) + "</" + tag + ">")
+				i += n
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		sb.WriteString(html.EscapeString(string(r)))
+		i += size
+	}
+	return sb.String()
+}
+
+func runLen(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// parseLink parses [text](url) at the start of s
+func parseLink(s string) (string, string, int, bool) {
+	textEnd := strings.Index(s, "](")
+	if textEnd < 0 || strings.Contains(s[:textEnd], "\n") {
+		return "", "", 0, false
+	}
+	urlEnd := strings.IndexByte(s[textEnd+2:], ')')
+	if urlEnd < 0 {
+		return "", "", 0, false
+	}
+	url := s[textEnd+2 : textEnd+2+urlEnd]
+	if strings.ContainsAny(url, " \n") || url == "" {
+		return "", "", 0, false
+	}
+	return s[1:textEnd], url, textEnd + 2 + urlEnd + 1, true
+}
+
+// parseEmphasis parses the emphasis of s at i, underscores inside words like snake_case are text
+func parseEmphasis(s string, i int) (string, string, int, bool) {
+	for _, e := range emphases {
+		if !strings.HasPrefix(s[i:], e.mark) {
+			continue
+		}
+		if e.mark[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+			return "", "", 0, false
+		}
+		start := i + len(e.mark)
+		if start >= len(s) || s[start] == ' ' {
+			continue
+		}
+		for j := start + 1; j <= len(s)-len(e.mark); j++ {
+			if !strings.HasPrefix(s[j:], e.mark) || s[j-1] == ' ' {
+				continue
+			}
+			// ** is not the end of *
+			if len(e.mark) == 1 && j+1 < len(s) && s[j+1] == e.mark[0] {
+				j++
+				continue
+			}
+			end := j + len(e.mark)
+			if e.mark[0] == '_' && end < len(s) && isWordByte(s[end]) {
+				continue
+			}
+			return s[start:j], e.tag, end - i, true
+		}
+	}
+	return "", "", 0, false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf
+}
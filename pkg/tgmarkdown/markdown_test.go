@@ -0,0 +1,73 @@
+package tgmarkdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		md   string
+		want string
+	}{
+		{"escape", "a < b && c > d", "a &lt; b &amp;&amp; c &gt; d"},
+		{"emphasis", "**bold** *italic* ~~gone~~ __also bold__", "<b>bold</b> <i>italic</i> <s>gone</s> <b>also bold</b>"},
+		{"snake case", "call my_func_name with 2 * 3", "call my_func_name with 2 * 3"},
+		{"unclosed emphasis", "this is **still stream", "this is **still stream"},
+		{"inline code", "run `a<b && *c*`", "run <code>a&lt;b &amp;&amp; *c*</code>"},
+		{"link", "see [the **docs**](https://go.dev/?a=1&b=2)", `see <a href="https://go.dev/?a=1&amp;b=2">the <b>docs</b></a>`},
+		{"heading and list", "## Title\n- one\n* two", "<b>Title</b>\n• one\n• two"},
+		{"quote", "> quoted\n> text", "<blockquote>quoted\ntext</blockquote>"},
+		{"code", "```go\nif a < b {}\n```", `<pre><code class="language-go">if a &lt; b {}</code></pre>`},
+		{"code without lang", "```\n**not bold**\n```", "<pre>**not bold**</pre>"},
+		{"dangling fence", "text\n```python\nprint(1)", "text\n\n" + `<pre><code class="language-python">print(1)</code></pre>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Render(tt.md))
+		})
+	}
+}
+
+func TestFormatSplitsLongAnswers(t *testing.T) {
+	paragraph := strings.Repeat("word ", 60)
+	md := strings.Repeat(paragraph+"\n\n", 10)
+	messages, files := Format(md, Options{Limit: 1000})
+	assert.Empty(t, files)
+	assert.Greater(t, len(messages), 1)
+	for _, msg := range messages {
+		assert.LessOrEqual(t, length(msg), 1000)
+		assert.NotContains(t, msg, "wor\n")
+	}
+	assert.Equal(t, strings.Count(md, "word"), strings.Count(strings.Join(messages, ""), "word"))
+}
+
+func TestFormatSplitsLongCode(t *testing.T) {
+	md := "```go\n" + strings.Repeat("fmt.Println(a < b)\n", 100) + "```"
+	messages, _ := Format(md, Options{Limit: 500})
+	assert.Greater(t, len(messages), 1)
+	for _, msg := range messages {
+		assert.LessOrEqual(t, length(msg), 500)
+		assert.True(t, strings.HasPrefix(msg, `<pre><code class="language-go">`))
+		assert.True(t, strings.HasSuffix(msg, "</code></pre>"))
+	}
+}
+
+func TestFormatSplitsLongLines(t *testing.T) {
+	messages, _ := Format(strings.Repeat("x", 2500), Options{Limit: 1000})
+	assert.Len(t, messages, 3)
+}
+
+func TestFormatMovesLongCodeToFiles(t *testing.T) {
+	code := strings.Repeat("print('hello')\n", 50)
+	md := "Here you go:\n\n```python\n" + code + "```\n\nand a short one `x`"
+	messages, files := Format(md, Options{FileThreshold: 200})
+	assert.Len(t, files, 1)
+	assert.Equal(t, "snippet-1.py", files[0].Name)
+	assert.Equal(t, strings.TrimSuffix(code, "\n"), files[0].Content)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "Here you go:\n\n<i>📎 snippet-1.py</i>\n\nand a short one <code>x</code>", messages[0])
+}
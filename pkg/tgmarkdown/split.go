@@ -0,0 +1,151 @@
+package tgmarkdown
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf16"
+)
+
+// MaxMessageLength is the limit of the text of a telegram message
+const MaxMessageLength = 4096
+
+// Options of Format
+type Options struct {
+	// Limit is the max length of a message, it defaults to MaxMessageLength
+	Limit int
+	// FileThreshold moves code blocks longer than it to files, zero keeps all code in the messages
+	FileThreshold int
+}
+
+// File is a code block which is too long for messages
+type File struct {
+	Name    string
+	Lang    string
+	Content string
+}
+
+// Format renders md to the HTML of one or more messages, long answers are split between paragraphs,
+// and long code blocks between lines. Every message is valid HTML on its own.
+func Format(md string, opts Options) ([]string, []File) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = MaxMessageLength
+	}
+
+	files := make([]File, 0)
+	pieces := make([]string, 0)
+	for _, b := range parse(md) {
+		if b.code && opts.FileThreshold > 0 && length(b.text) > opts.FileThreshold {
+			file := File{Name: fmt.Sprintf("snippet-%d.%s", len(files)+1, extension(b.lang)), Lang: b.lang, Content: b.text}
+			files = append(files, file)
+			pieces = append(pieces, "<i>📎 "+html.EscapeString(file.Name)+"</i>")
+			continue
+		}
+		pieces = append(pieces, splitBlock(b, limit)...)
+	}
+
+	messages := make([]string, 0)
+	current := ""
+	for _, piece := range pieces {
+		if current != "" && length(current)+2+length(piece) > limit {
+			messages = append(messages, current)
+			current = ""
+		}
+		if current != "" {
+			current += "\n\n"
+		}
+		current += piece
+	}
+	if current != "" {
+		messages = append(messages, current)
+	}
+	return messages, files
+}
+
+// splitBlock renders b to pieces of at most limit, code is split by lines and text by lines and words.
+func splitBlock(b block, limit int) []string {
+	if rendered := b.render(); length(rendered) <= limit {
+		return []string{rendered}
+	}
+
+	if b.code {
+		overhead := length(renderCode(b.lang, ""))
+		pieces := make([]string, 0)
+		for _, chunk := range splitLines(b.text, limit-overhead, func(s string) int { return length(html.EscapeString(s)) }) {
+			pieces = append(pieces, renderCode(b.lang, chunk))
+		}
+		return pieces
+	}
+
+	pieces := make([]string, 0)
+	for _, chunk := range splitLines(b.text, limit, func(s string) int { return length(renderText(s)) }) {
+		pieces = append(pieces, renderText(chunk))
+	}
+	return pieces
+}
+
+// splitLines joins the lines of text to chunks whose size is at most limit, long lines are split by
+// spaces, or else by runes.
+func splitLines(text string, limit int, size func(string) int) []string {
+	chunks := make([]string, 0)
+	current := ""
+	add := func(s, sep string) {
+		if current != "" && size(current+sep+s) > limit {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		if current != "" {
+			current += sep
+		}
+		current += s
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if size(line) <= limit {
+			add(line, "\n")
+			continue
+		}
+		// the line starts a new chunk, its words are joined by spaces
+		if current != "" {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		for _, word := range strings.Split(line, " ") {
+			if size(word) <= limit {
+				add(word, " ")
+				continue
+			}
+			for _, r := range word {
+				add(string(r), "")
+			}
+		}
+		chunks = append(chunks, current)
+		current = ""
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// length counts like telegram, by UTF-16 code units
+func length(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+var extensions = map[string]string{
+	"bash": "sh", "c": "c", "c#": "cs", "c++": "cpp", "cpp": "cpp", "csharp": "cs", "css": "css",
+	"dockerfile": "dockerfile", "go": "go", "golang": "go", "html": "html", "java": "java",
+	"javascript": "js", "js": "js", "json": "json", "kotlin": "kt", "lua": "lua", "markdown": "md",
+	"php": "php", "python": "py", "py": "py", "ruby": "rb", "rust": "rs", "scala": "scala", "sh": "sh",
+	"shell": "sh", "sql": "sql", "swift": "swift", "toml": "toml", "ts": "ts", "tsx": "tsx",
+	"typescript": "ts", "xml": "xml", "yaml": "yaml", "yml": "yaml", "zsh": "sh",
+}
+
+func extension(lang string) string {
+	if ext, ok := extensions[cleanLang(lang)]; ok {
+		return ext
+	}
+	return "txt"
+}
@@ -0,0 +1,86 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MasterKeyEnv is the env of the base64 master key, it takes precedence over key files
+const MasterKeyEnv = "APP_VAULT_MASTER_KEY"
+
+var ErrNoMasterKey = errors.New("vault master key is not configured")
+
+// Cipher encrypts secrets by AES-256-GCM, the associated data binds ciphertexts to their secret names.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher of a 32 bytes key
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("vault master key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create aes cipher error: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm cipher error: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// LoadMasterKey reads the base64 master key from MasterKeyEnv, or else from keyFile, like a file mounted
+// by a kms agent or a kubernetes secret. It returns ErrNoMasterKey when neither of them is set.
+func LoadMasterKey(keyFile string) ([]byte, error) {
+	encoded := os.Getenv(MasterKeyEnv)
+	if encoded == "" && keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read vault master key file error: %w", err)
+		}
+		encoded = string(data)
+	}
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, ErrNoMasterKey
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode vault master key error: %w", err)
+	}
+	return key, nil
+}
+
+// Encrypt returns the base64 nonce and ciphertext of plaintext
+func (c *Cipher) Encrypt(name, plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce error: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(name))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Decrypt(name, ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("decode ciphertext error: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("ciphertext is too short")
+	}
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret %s error: %w", name, err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,88 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RefPrefix marks config values which are the names of vault secrets, like secret://openai
+const RefPrefix = "secret://"
+
+// ParseRef returns the secret name of a reference
+func ParseRef(value string) (string, bool) {
+	if !strings.HasPrefix(value, RefPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, RefPrefix), true
+}
+
+// Resolve replaces the references in the strings of v by the secrets of lookup, v must be a pointer.
+// Strings of nested structs, pointers, interfaces, slices and maps are resolved, unexported fields are skipped.
+// References failing to resolve are kept and their errors are joined, so one missing secret doesn't break the rest.
+func Resolve(v any, lookup func(name string) (string, error)) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("resolve secrets of non pointer %T", v)
+	}
+	return resolve(rv.Elem(), lookup)
+}
+
+func resolve(v reflect.Value, lookup func(name string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		name, ok := ParseRef(v.String())
+		if !ok {
+			return nil
+		}
+		secret, err := lookup(name)
+		if err != nil {
+			return fmt.Errorf("resolve secret %s error: %w", name, err)
+		}
+		v.SetString(secret)
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return resolve(v.Elem(), lookup)
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		// values of interfaces are not addressable, they are resolved on copies
+		value := addressable(v.Elem())
+		err := resolve(value, lookup)
+		v.Set(value)
+		return err
+	case reflect.Struct:
+		var errs []error
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				errs = append(errs, resolve(v.Field(i), lookup))
+			}
+		}
+		return errors.Join(errs...)
+	case reflect.Slice, reflect.Array:
+		var errs []error
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, resolve(v.Index(i), lookup))
+		}
+		return errors.Join(errs...)
+	case reflect.Map:
+		var errs []error
+		iter := v.MapRange()
+		for iter.Next() {
+			value := addressable(iter.Value())
+			errs = append(errs, resolve(value, lookup))
+			v.SetMapIndex(iter.Key(), value)
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func addressable(v reflect.Value) reflect.Value {
+	value := reflect.New(v.Type()).Elem()
+	value.Set(v)
+	return value
+}
@@ -0,0 +1,108 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher(newKey(t))
+	require.NoError(t, err)
+
+	ciphertext, err := c.Encrypt("openai", "sk-xxx")
+	require.NoError(t, err)
+	assert.NotContains(t, ciphertext, "sk-xxx")
+
+	plaintext, err := c.Decrypt("openai", ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-xxx", plaintext)
+
+	// ciphertexts are bound to their names
+	_, err = c.Decrypt("claude", ciphertext)
+	assert.Error(t, err)
+
+	other, err := NewCipher(newKey(t))
+	require.NoError(t, err)
+	_, err = other.Decrypt("openai", ciphertext)
+	assert.Error(t, err)
+
+	_, err = NewCipher([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestLoadMasterKey(t *testing.T) {
+	key := newKey(t)
+	keyFile := filepath.Join(t.TempDir(), "master.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600))
+
+	t.Setenv(MasterKeyEnv, "")
+	_, err := LoadMasterKey("")
+	assert.ErrorIs(t, err, ErrNoMasterKey)
+
+	loaded, err := LoadMasterKey(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, key, loaded)
+
+	envKey := newKey(t)
+	t.Setenv(MasterKeyEnv, base64.StdEncoding.EncodeToString(envKey))
+	loaded, err = LoadMasterKey(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, envKey, loaded)
+}
+
+func TestResolve(t *testing.T) {
+	type provider struct {
+		ApiKey string
+		secret string
+	}
+	type config struct {
+		Token     string
+		Providers []provider
+		Headers   map[string]string
+		Extra     map[string]any
+		Optional  *provider
+		Plain     string
+	}
+	cfg := &config{
+		Token:     "secret://telegram",
+		Providers: []provider{{ApiKey: "secret://openai", secret: "secret://openai"}},
+		Headers:   map[string]string{"Authorization": "secret://gateway"},
+		Extra:     map[string]any{"password": "secret://cookiecloud", "port": 8080},
+		Optional:  &provider{ApiKey: "secret://missing"},
+		Plain:     "sk-plain",
+	}
+	secrets := map[string]string{"telegram": "tg", "openai": "sk-xxx", "gateway": "gw", "cookiecloud": "pass"}
+	err := Resolve(cfg, func(name string) (string, error) {
+		secret, ok := secrets[name]
+		if !ok {
+			return "", fmt.Errorf("not found")
+		}
+		return secret, nil
+	})
+	assert.ErrorContains(t, err, "resolve secret missing error")
+
+	assert.Equal(t, "tg", cfg.Token)
+	assert.Equal(t, "sk-xxx", cfg.Providers[0].ApiKey)
+	assert.Equal(t, "secret://openai", cfg.Providers[0].secret)
+	assert.Equal(t, "gw", cfg.Headers["Authorization"])
+	assert.Equal(t, "pass", cfg.Extra["password"])
+	assert.Equal(t, 8080, cfg.Extra["port"])
+	assert.Equal(t, "secret://missing", cfg.Optional.ApiKey)
+	assert.Equal(t, "sk-plain", cfg.Plain)
+
+	assert.Error(t, Resolve(*cfg, nil))
+}